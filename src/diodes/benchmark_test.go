@@ -0,0 +1,112 @@
+package diodes_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"diodes"
+	"sync"
+	"testing"
+	"time"
+)
+
+const idleReaders = 1000
+
+var discard = diodes.AlertFunc(func(int) {})
+
+// BenchmarkPollingLatency measures a round trip between two goroutines over
+// polling diodes.
+func BenchmarkPollingLatency(b *testing.B) {
+	benchmarkLatency(b, func(ctx context.Context) *diodes.OneToOne {
+		return diodes.NewOneToOne(1024, discard)
+	})
+}
+
+// BenchmarkWaitingLatency measures a round trip between two goroutines over
+// waiting diodes.
+func BenchmarkWaitingLatency(b *testing.B) {
+	benchmarkLatency(b, func(ctx context.Context) *diodes.OneToOne {
+		return diodes.NewWaitingOneToOne(ctx, 1024, discard)
+	})
+}
+
+// BenchmarkPollingIdleReaders measures the CPU left for other work while many
+// readers poll empty diodes, as GRPCManager did for idle subscriptions.
+func BenchmarkPollingIdleReaders(b *testing.B) {
+	benchmarkIdleReaders(b, func(ctx context.Context, wg *sync.WaitGroup) {
+		d := diodes.NewOneToOne(1024, discard)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, ok := d.TryNext(); !ok {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		}()
+	})
+}
+
+// BenchmarkWaitingIdleReaders measures the CPU left for other work while many
+// readers wait on empty diodes.
+func BenchmarkWaitingIdleReaders(b *testing.B) {
+	benchmarkIdleReaders(b, func(ctx context.Context, wg *sync.WaitGroup) {
+		d := diodes.NewWaitingOneToOne(ctx, 1024, discard)
+		go func() {
+			defer wg.Done()
+			for d.Next() != nil {
+			}
+		}()
+	})
+}
+
+func benchmarkLatency(b *testing.B, newDiode func(context.Context) *diodes.OneToOne) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ping := newDiode(ctx)
+	pong := newDiode(ctx)
+	data := []byte("some-data")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			d := ping.Next()
+			if d == nil || ctx.Err() != nil {
+				return
+			}
+			pong.Set(d)
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ping.Set(data)
+		pong.Next()
+	}
+	b.StopTimer()
+
+	// Polling diodes ignore the context, so wake the reader to let it exit.
+	cancel()
+	ping.Set(data)
+	<-done
+}
+
+func benchmarkIdleReaders(b *testing.B, startReader func(context.Context, *sync.WaitGroup)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(idleReaders)
+	for i := 0; i < idleReaders; i++ {
+		startReader(ctx, &wg)
+	}
+
+	data := make([]byte, 1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sha256.Sum256(data)
+	}
+	b.StopTimer()
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+package diodes
+
+import (
+	"context"
+	"sync/atomic"
+	"unsafe"
+)
+
+type Alerter interface {
+	Alert(missed int)
+}
+
+type AlertFunc func(missed int)
+
+func (f AlertFunc) Alert(missed int) {
+	f(missed)
+}
+
+type bucket struct {
+	data unsafe.Pointer
+	seq  uint64
+}
+
+// diode is a ring buffer of opaque pointers with a single reader. Writers
+// never block; once the buffer is full they overwrite the oldest data and the
+// reader alerts how much it missed. The typed diodes wrap it to convert their
+// payloads.
+type diode struct {
+	buffer      []unsafe.Pointer
+	writeIndex  uint64
+	readIndex   uint64
+	manyWriters bool
+	alerter     Alerter
+	signal      signal
+	ctx         context.Context
+}
+
+func newDiode(size int, manyWriters bool, alerter Alerter) *diode {
+	d := &diode{
+		buffer:      make([]unsafe.Pointer, size),
+		manyWriters: manyWriters,
+		alerter:     alerter,
+	}
+	d.writeIndex = ^d.writeIndex
+	return d
+}
+
+// wait makes the reader wait to be signalled by a writer rather than poll.
+func (d *diode) wait(ctx context.Context) *diode {
+	d.signal = newSignal()
+	d.ctx = ctx
+	return d
+}
+
+func (d *diode) set(data unsafe.Pointer) {
+	if !d.manyWriters {
+		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
+		idx := writeIndex % uint64(len(d.buffer))
+		newBucket := &bucket{
+			data: data,
+			seq:  writeIndex,
+		}
+
+		atomic.StorePointer(&d.buffer[idx], unsafe.Pointer(newBucket))
+		d.signal.notify()
+		return
+	}
+
+	for {
+		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
+		idx := writeIndex % uint64(len(d.buffer))
+		old := atomic.LoadPointer(&d.buffer[idx])
+
+		if old != nil &&
+			(*bucket)(old) != nil &&
+			(*bucket)(old).seq != writeIndex-uint64(len(d.buffer)) {
+			continue
+		}
+
+		newBucket := &bucket{
+			data: data,
+			seq:  writeIndex,
+		}
+
+		if !atomic.CompareAndSwapPointer(&d.buffer[idx], old, unsafe.Pointer(newBucket)) {
+			continue
+		}
+
+		d.signal.notify()
+		return
+	}
+}
+
+func (d *diode) tryNext() (unsafe.Pointer, bool) {
+	readIndex := atomic.LoadUint64(&d.readIndex)
+	idx := readIndex % uint64(len(d.buffer))
+
+	value, ok := d.read(idx)
+	if ok {
+		atomic.AddUint64(&d.readIndex, 1)
+	}
+	return value, ok
+}
+
+// next blocks until data is available. It reports false once a waiting
+// diode's context is done.
+func (d *diode) next() (unsafe.Pointer, bool) {
+	readIndex := atomic.LoadUint64(&d.readIndex)
+	idx := readIndex % uint64(len(d.buffer))
+
+	for {
+		result, ok := d.read(idx)
+		if ok {
+			atomic.AddUint64(&d.readIndex, 1)
+			return result, true
+		}
+
+		if !d.signal.wait(d.ctx) {
+			return nil, false
+		}
+	}
+}
+
+func (d *diode) read(idx uint64) (unsafe.Pointer, bool) {
+	result := (*bucket)(atomic.SwapPointer(&d.buffer[idx], nil))
+
+	if result == nil {
+		return nil, false
+	}
+
+	if result.seq > d.readIndex {
+		if d.alerter != nil {
+			d.alerter.Alert(int(result.seq - d.readIndex))
+		}
+		atomic.StoreUint64(&d.readIndex, result.seq)
+	}
+
+	return result.data, true
+}
+
+// len returns the number of entries written but not yet read, capped at the
+// size of the buffer.
+func (d *diode) len() int {
+	written := atomic.LoadUint64(&d.writeIndex) + 1
+	read := atomic.LoadUint64(&d.readIndex)
+	if written <= read {
+		return 0
+	}
+
+	if n := written - read; n < uint64(len(d.buffer)) {
+		return int(n)
+	}
+	return len(d.buffer)
+}
@@ -1,99 +1,50 @@
 package diodes
 
 import (
-	"sync/atomic"
-	"time"
+	"context"
 	"unsafe"
 )
 
 // ManyToOne diode is optimal for many writers and a single
 // reader.
 type ManyToOne struct {
-	buffer     []unsafe.Pointer
-	writeIndex uint64
-	readIndex  uint64
-	alerter    Alerter
+	d *diode
 }
 
 var NewManyToOne = func(size int, alerter Alerter) *ManyToOne {
-	d := &ManyToOne{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
-	}
-	d.writeIndex = ^d.writeIndex
-	return d
+	return &ManyToOne{d: newDiode(size, true, alerter)}
 }
 
-func (d *ManyToOne) Set(data []byte) {
-	for {
-		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
-		idx := writeIndex % uint64(len(d.buffer))
-		old := atomic.LoadPointer(&d.buffer[idx])
-
-		if old != nil &&
-			(*bucket)(old) != nil &&
-			(*bucket)(old).seq != writeIndex-uint64(len(d.buffer)) {
-			continue
-		}
-
-		newBucket := &bucket{
-			data: data,
-			seq:  writeIndex,
-		}
-
-		if !atomic.CompareAndSwapPointer(&d.buffer[idx], old, unsafe.Pointer(newBucket)) {
-			continue
-		}
+// NewWaitingManyToOne returns a ManyToOne diode whose reader waits to be
+// signalled by a writer rather than polling. Next returns nil once the
+// context is done.
+var NewWaitingManyToOne = func(ctx context.Context, size int, alerter Alerter) *ManyToOne {
+	return &ManyToOne{d: newDiode(size, true, alerter).wait(ctx)}
+}
 
-		return
-	}
+func (d *ManyToOne) Set(data []byte) {
+	d.d.set(unsafe.Pointer(&data))
 }
 
 func (d *ManyToOne) TryNext() ([]byte, bool) {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	value, ok := d.tryNext(idx)
-	if ok {
-		atomic.AddUint64(&d.readIndex, 1)
+	data, ok := d.d.tryNext()
+	if !ok {
+		return nil, false
 	}
-	return value, ok
+	return *(*[]byte)(data), true
 }
 
+// Next blocks until data is available. Waiting diodes return nil once their
+// context is done.
 func (d *ManyToOne) Next() []byte {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	result := d.pollBuffer(idx)
-	atomic.AddUint64(&d.readIndex, 1)
-
-	return result
-}
-
-func (d *ManyToOne) tryNext(idx uint64) ([]byte, bool) {
-	result := (*bucket)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	if result == nil {
-		return nil, false
+	data, ok := d.d.next()
+	if !ok {
+		return nil
 	}
-
-	if result.seq > d.readIndex {
-		d.alerter.Alert(int(result.seq - d.readIndex))
-		atomic.StoreUint64(&d.readIndex, result.seq)
-	}
-
-	return result.data, true
+	return *(*[]byte)(data)
 }
 
-func (d *ManyToOne) pollBuffer(idx uint64) []byte {
-	for {
-		result, ok := d.tryNext(idx)
-
-		if !ok {
-			time.Sleep(time.Millisecond * 10)
-			continue
-		}
-
-		return result
-	}
+// Len returns the number of entries waiting to be read.
+func (d *ManyToOne) Len() int {
+	return d.d.len()
 }
@@ -1,8 +1,7 @@
 package diodes
 
 import (
-	"sync/atomic"
-	"time"
+	"context"
 	"unsafe"
 
 	"github.com/cloudfoundry/sonde-go/events"
@@ -11,96 +10,37 @@ import (
 // ManyToOneEnvelope diode is optimal for many writers and a single
 // reader.
 type ManyToOneEnvelope struct {
-	buffer     []unsafe.Pointer
-	writeIndex uint64
-	readIndex  uint64
-	alerter    Alerter
+	d *diode
 }
 
-type bucketEnvelope struct {
-	data *events.Envelope
-	seq  uint64
+var NewManyToOneEnvelope = func(size int, alerter Alerter) *ManyToOneEnvelope {
+	return &ManyToOneEnvelope{d: newDiode(size, true, alerter)}
 }
 
-var NewManyToOneEnvelope = func(size int, alerter Alerter) *ManyToOneEnvelope {
-	d := &ManyToOneEnvelope{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
-	}
-	d.writeIndex = ^d.writeIndex
-	return d
+// NewWaitingManyToOneEnvelope returns a ManyToOneEnvelope diode whose reader
+// waits to be signalled by a writer rather than polling. Next returns nil
+// once the context is done.
+var NewWaitingManyToOneEnvelope = func(ctx context.Context, size int, alerter Alerter) *ManyToOneEnvelope {
+	return &ManyToOneEnvelope{d: newDiode(size, true, alerter).wait(ctx)}
 }
 
 func (d *ManyToOneEnvelope) Set(data *events.Envelope) {
-	for {
-		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
-		idx := writeIndex % uint64(len(d.buffer))
-		old := atomic.LoadPointer(&d.buffer[idx])
-
-		if old != nil &&
-			(*bucketEnvelope)(old) != nil &&
-			(*bucketEnvelope)(old).seq != writeIndex-uint64(len(d.buffer)) {
-			continue
-		}
-
-		newBucket := &bucketEnvelope{
-			data: data,
-			seq:  writeIndex,
-		}
-
-		if !atomic.CompareAndSwapPointer(&d.buffer[idx], old, unsafe.Pointer(newBucket)) {
-			continue
-		}
-
-		return
-	}
+	d.d.set(unsafe.Pointer(data))
 }
 
 func (d *ManyToOneEnvelope) TryNext() (*events.Envelope, bool) {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	value, ok := d.tryNext(idx)
-	if ok {
-		atomic.AddUint64(&d.readIndex, 1)
-	}
-	return value, ok
+	data, ok := d.d.tryNext()
+	return (*events.Envelope)(data), ok
 }
 
+// Next blocks until data is available. Waiting diodes return nil once their
+// context is done.
 func (d *ManyToOneEnvelope) Next() *events.Envelope {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	result := d.pollBuffer(idx)
-	atomic.AddUint64(&d.readIndex, 1)
-
-	return result
-}
-
-func (d *ManyToOneEnvelope) tryNext(idx uint64) (*events.Envelope, bool) {
-	result := (*bucketEnvelope)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	if result == nil {
-		return nil, false
-	}
-
-	if result.seq > d.readIndex {
-		d.alerter.Alert(int(result.seq - d.readIndex))
-		atomic.StoreUint64(&d.readIndex, result.seq)
-	}
-
-	return result.data, true
+	data, _ := d.d.next()
+	return (*events.Envelope)(data)
 }
 
-func (d *ManyToOneEnvelope) pollBuffer(idx uint64) *events.Envelope {
-	for {
-		result, ok := d.tryNext(idx)
-
-		if !ok {
-			time.Sleep(time.Millisecond * 10)
-			continue
-		}
-
-		return result
-	}
+// Len returns the number of entries waiting to be read.
+func (d *ManyToOneEnvelope) Len() int {
+	return d.d.len()
 }
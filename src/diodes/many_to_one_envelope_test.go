@@ -156,4 +156,31 @@ var _ = Describe("ManyToOneEnvelope", func() {
 			})
 		})
 	})
+
+	Describe("Len()", func() {
+		BeforeEach(func() {
+			d = diodes.NewManyToOneEnvelope(5, newMockAlerter())
+		})
+
+		It("returns the number of unread envelopes", func() {
+			Expect(d.Len()).To(Equal(0))
+
+			d.Set(&events.Envelope{})
+			d.Set(&events.Envelope{})
+			Expect(d.Len()).To(Equal(2))
+
+			d.Next()
+			Expect(d.Len()).To(Equal(1))
+		})
+
+		It("does not exceed the buffer size", func() {
+			for i := 0; i < 12; i++ {
+				d.Set(&events.Envelope{})
+			}
+			Expect(d.Len()).To(Equal(5))
+
+			d.Next()
+			Expect(d.Len()).To(Equal(1))
+		})
+	})
 })
@@ -1,105 +1,45 @@
 package diodes
 
 import (
+	"context"
 	v2 "plumbing/v2"
-	"sync/atomic"
-	"time"
 	"unsafe"
 )
 
 // ManyToOneEnvelopeV2 diode is optimal for many writers and a single
 // reader.
 type ManyToOneEnvelopeV2 struct {
-	buffer     []unsafe.Pointer
-	writeIndex uint64
-	readIndex  uint64
-	alerter    Alerter
+	d *diode
 }
 
-type bucketEnvelopeV2 struct {
-	data *v2.Envelope
-	seq  uint64
+var NewManyToOneEnvelopeV2 = func(size int, alerter Alerter) *ManyToOneEnvelopeV2 {
+	return &ManyToOneEnvelopeV2{d: newDiode(size, true, alerter)}
 }
 
-var NewManyToOneEnvelopeV2 = func(size int, alerter Alerter) *ManyToOneEnvelopeV2 {
-	d := &ManyToOneEnvelopeV2{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
-	}
-	d.writeIndex = ^d.writeIndex
-	return d
+// NewWaitingManyToOneEnvelopeV2 returns a ManyToOneEnvelopeV2 diode whose
+// reader waits to be signalled by a writer rather than polling. Next returns
+// nil once the context is done.
+var NewWaitingManyToOneEnvelopeV2 = func(ctx context.Context, size int, alerter Alerter) *ManyToOneEnvelopeV2 {
+	return &ManyToOneEnvelopeV2{d: newDiode(size, true, alerter).wait(ctx)}
 }
 
 func (d *ManyToOneEnvelopeV2) Set(data *v2.Envelope) {
-	for {
-		writeIndex := atomic.AddUint64(&d.writeIndex, 1)
-		idx := writeIndex % uint64(len(d.buffer))
-		old := atomic.LoadPointer(&d.buffer[idx])
-
-		if old != nil &&
-			(*bucketEnvelopeV2)(old) != nil &&
-			(*bucketEnvelopeV2)(old).seq != writeIndex-uint64(len(d.buffer)) {
-			continue
-		}
-
-		newBucket := &bucketEnvelopeV2{
-			data: data,
-			seq:  writeIndex,
-		}
-
-		if !atomic.CompareAndSwapPointer(&d.buffer[idx], old, unsafe.Pointer(newBucket)) {
-			continue
-		}
-
-		return
-	}
+	d.d.set(unsafe.Pointer(data))
 }
 
 func (d *ManyToOneEnvelopeV2) TryNext() (*v2.Envelope, bool) {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	value, ok := d.tryNext(idx)
-	if ok {
-		atomic.AddUint64(&d.readIndex, 1)
-	}
-	return value, ok
+	data, ok := d.d.tryNext()
+	return (*v2.Envelope)(data), ok
 }
 
+// Next blocks until data is available. Waiting diodes return nil once their
+// context is done.
 func (d *ManyToOneEnvelopeV2) Next() *v2.Envelope {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	result := d.pollBuffer(idx)
-	atomic.AddUint64(&d.readIndex, 1)
-
-	return result
-}
-
-func (d *ManyToOneEnvelopeV2) tryNext(idx uint64) (*v2.Envelope, bool) {
-	result := (*bucketEnvelopeV2)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	if result == nil {
-		return nil, false
-	}
-
-	if result.seq > d.readIndex {
-		d.alerter.Alert(int(result.seq - d.readIndex))
-		atomic.StoreUint64(&d.readIndex, result.seq)
-	}
-
-	return result.data, true
+	data, _ := d.d.next()
+	return (*v2.Envelope)(data)
 }
 
-func (d *ManyToOneEnvelopeV2) pollBuffer(idx uint64) *v2.Envelope {
-	for {
-		result, ok := d.tryNext(idx)
-
-		if !ok {
-			time.Sleep(time.Millisecond * 10)
-			continue
-		}
-
-		return result
-	}
+// Len returns the number of entries waiting to be read.
+func (d *ManyToOneEnvelopeV2) Len() int {
+	return d.d.len()
 }
@@ -0,0 +1,26 @@
+package diodes
+
+import (
+	"log"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+// MetricAlerter is an Alerter that accounts for dropped data by logging and
+// adding the missed count to a counter metric.
+type MetricAlerter struct {
+	name string
+}
+
+// NewMetricAlerter returns a MetricAlerter that adds to the counter with
+// the given name.
+func NewMetricAlerter(name string) *MetricAlerter {
+	return &MetricAlerter{
+		name: name,
+	}
+}
+
+func (a *MetricAlerter) Alert(missed int) {
+	log.Printf("Dropped %d envelopes (%s)", missed, a.name)
+	metrics.BatchAddCounter(a.name, uint64(missed))
+}
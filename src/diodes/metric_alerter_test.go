@@ -0,0 +1,48 @@
+package diodes_test
+
+import (
+	"diodes"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/dropsonde/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MetricAlerter", func() {
+	var sender *fake.FakeMetricSender
+
+	BeforeEach(func() {
+		sender = fake.NewFakeMetricSender()
+		batcher := metricbatcher.New(sender, time.Millisecond)
+		metrics.Initialize(sender, batcher)
+	})
+
+	It("adds the missed count to the counter", func() {
+		alerter := diodes.NewMetricAlerter("test.dropped")
+		alerter.Alert(3)
+		alerter.Alert(4)
+
+		Eventually(func() uint64 {
+			return sender.GetCounter("test.dropped")
+		}).Should(BeEquivalentTo(7))
+	})
+
+	It("counts the data a lapped diode drops", func() {
+		d := diodes.NewOneToOne(5, diodes.NewMetricAlerter("test.diodeDropped"))
+		for i := 0; i < 12; i++ {
+			d.Set([]byte{byte(i)})
+		}
+
+		data, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(data).To(Equal([]byte{10}))
+
+		Eventually(func() uint64 {
+			return sender.GetCounter("test.diodeDropped")
+		}).Should(BeEquivalentTo(10))
+	})
+})
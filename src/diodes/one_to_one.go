@@ -1,99 +1,55 @@
 package diodes
 
 import (
-	"sync/atomic"
-	"time"
+	"context"
 	"unsafe"
 )
 
-type Alerter interface {
-	Alert(missed int)
-}
-
-type bucket struct {
-	data []byte
-	seq  uint64
-}
-
-// OneToOne diode is optimized for a single writer and a single reader
+// OneToOne diode is optimized for a single writer and a single reader.
+//
+// The diode stores pointers, so Set boxes each slice header on the heap: a
+// second allocation per write on top of the diode's bucket. Paths that write
+// many payloads should prefer a diode of pointers, such as
+// OneToOneMarshalled.
 type OneToOne struct {
-	buffer     []unsafe.Pointer
-	writeIndex uint64
-	readIndex  uint64
-	alerter    Alerter
+	d *diode
 }
 
 var NewOneToOne = func(size int, alerter Alerter) *OneToOne {
-	d := &OneToOne{
-		buffer:  make([]unsafe.Pointer, size),
-		alerter: alerter,
-	}
-	d.writeIndex = ^d.writeIndex
-	return d
+	return &OneToOne{d: newDiode(size, false, alerter)}
 }
 
-func (d *OneToOne) Set(data []byte) {
-	writeIndex := atomic.AddUint64(&d.writeIndex, 1)
-	idx := writeIndex % uint64(len(d.buffer))
-	newBucket := &bucket{
-		data: data,
-		seq:  writeIndex,
-	}
-
-	atomic.StorePointer(&d.buffer[idx], unsafe.Pointer(newBucket))
-}
-
-func (d *OneToOne) TryNext() ([]byte, bool) {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	value, ok := d.tryNext(idx)
-	if ok {
-		atomic.AddUint64(&d.readIndex, 1)
-	}
-	return value, ok
+// NewWaitingOneToOne returns a OneToOne diode whose reader waits to be
+// signalled by a writer rather than polling. Next returns nil once the
+// context is done.
+var NewWaitingOneToOne = func(ctx context.Context, size int, alerter Alerter) *OneToOne {
+	return &OneToOne{d: newDiode(size, false, alerter).wait(ctx)}
 }
 
-func (d *OneToOne) Next() []byte {
-	readIndex := atomic.LoadUint64(&d.readIndex)
-	idx := readIndex % uint64(len(d.buffer))
-
-	result := d.pollBuffer(idx)
-	atomic.AddUint64(&d.readIndex, 1)
-
-	return result
+// Set writes the data, allocating to box its slice header.
+func (d *OneToOne) Set(data []byte) {
+	d.d.set(unsafe.Pointer(&data))
 }
 
-func (d *OneToOne) tryNext(idx uint64) ([]byte, bool) {
-	result := (*bucket)(atomic.SwapPointer(&d.buffer[idx], nil))
-
-	if result == nil {
+func (d *OneToOne) TryNext() ([]byte, bool) {
+	data, ok := d.d.tryNext()
+	if !ok {
 		return nil, false
 	}
-
-	if result.seq > d.readIndex {
-		d.alerter.Alert(int(result.seq - d.readIndex))
-		atomic.StoreUint64(&d.readIndex, result.seq)
-	}
-
-	return result.data, true
+	return *(*[]byte)(data), true
 }
 
-func (d *OneToOne) pollBuffer(idx uint64) []byte {
-	for {
-		result, ok := d.tryNext(idx)
-
-		if !ok {
-			time.Sleep(time.Millisecond * 10)
-			continue
-		}
-
-		return result
+// Next blocks until data is available. Waiting diodes return nil once their
+// context is done.
+func (d *OneToOne) Next() []byte {
+	data, ok := d.d.next()
+	if !ok {
+		return nil
 	}
+	return *(*[]byte)(data)
 }
 
-type AlertFunc func(missed int)
-
-func (f AlertFunc) Alert(missed int) {
-	f(missed)
+// Len returns the number of entries waiting to be read.
+func (d *OneToOne) Len() int {
+	return d.d.len()
 }
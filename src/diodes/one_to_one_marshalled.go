@@ -0,0 +1,45 @@
+package diodes
+
+import (
+	"context"
+	"marshalled"
+	"unsafe"
+)
+
+// OneToOneMarshalled diode is optimized for a single writer and a single
+// reader of marshalled envelopes.
+type OneToOneMarshalled struct {
+	d *diode
+}
+
+var NewOneToOneMarshalled = func(size int, alerter Alerter) *OneToOneMarshalled {
+	return &OneToOneMarshalled{d: newDiode(size, false, alerter)}
+}
+
+// NewWaitingOneToOneMarshalled returns a OneToOneMarshalled diode whose
+// reader waits to be signalled by a writer rather than polling. Next returns
+// nil once the context is done.
+var NewWaitingOneToOneMarshalled = func(ctx context.Context, size int, alerter Alerter) *OneToOneMarshalled {
+	return &OneToOneMarshalled{d: newDiode(size, false, alerter).wait(ctx)}
+}
+
+func (d *OneToOneMarshalled) Set(data *marshalled.Envelope) {
+	d.d.set(unsafe.Pointer(data))
+}
+
+func (d *OneToOneMarshalled) TryNext() (*marshalled.Envelope, bool) {
+	data, ok := d.d.tryNext()
+	return (*marshalled.Envelope)(data), ok
+}
+
+// Next blocks until data is available. Waiting diodes return nil once their
+// context is done.
+func (d *OneToOneMarshalled) Next() *marshalled.Envelope {
+	data, _ := d.d.next()
+	return (*marshalled.Envelope)(data)
+}
+
+// Len returns the number of entries waiting to be read.
+func (d *OneToOneMarshalled) Len() int {
+	return d.d.len()
+}
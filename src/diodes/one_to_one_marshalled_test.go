@@ -0,0 +1,60 @@
+package diodes_test
+
+import (
+	"context"
+	"diodes"
+	"marshalled"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OneToOneMarshalled", func() {
+	var (
+		d           *diodes.OneToOneMarshalled
+		mockAlerter *mockAlerter
+	)
+
+	BeforeEach(func() {
+		mockAlerter = newMockAlerter()
+		d = diodes.NewOneToOneMarshalled(5, mockAlerter)
+	})
+
+	It("returns the envelopes in order", func() {
+		first := marshalled.New(&events.Envelope{Origin: proto.String("some-origin")})
+		second := marshalled.New(&events.Envelope{Origin: proto.String("some-other-origin")})
+		d.Set(first)
+		d.Set(second)
+
+		Expect(d.Len()).To(Equal(2))
+		Expect(d.Next()).To(BeIdenticalTo(first))
+
+		next, ok := d.TryNext()
+		Expect(ok).To(BeTrue())
+		Expect(next).To(BeIdenticalTo(second))
+
+		_, ok = d.TryNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("alerts for each dropped envelope", func() {
+		for i := 0; i < 6; i++ {
+			d.Set(marshalled.New(&events.Envelope{Origin: proto.String("some-origin")}))
+		}
+		d.TryNext()
+
+		Eventually(mockAlerter.AlertInput.Missed).Should(Receive(Equal(5)))
+	})
+
+	Context("when waiting", func() {
+		It("returns nil once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			d = diodes.NewWaitingOneToOneMarshalled(ctx, 5, mockAlerter)
+			cancel()
+
+			Expect(d.Next()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package diodes
+
+import (
+	"context"
+	"time"
+)
+
+const pollInterval = 10 * time.Millisecond
+
+// signal wakes a diode's reader once a writer has set data. It holds at most
+// one pending wake up so that writers never block. A nil signal never wakes
+// the reader, which instead polls.
+type signal chan struct{}
+
+func newSignal() signal {
+	return make(signal, 1)
+}
+
+func (s signal) notify() {
+	select {
+	case s <- struct{}{}:
+	default:
+	}
+}
+
+// wait parks the reader until there may be data to read. It reports false
+// once the context is done.
+func (s signal) wait(ctx context.Context) bool {
+	if s == nil {
+		time.Sleep(pollInterval)
+		return true
+	}
+
+	select {
+	case <-s:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
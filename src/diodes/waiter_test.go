@@ -0,0 +1,117 @@
+package diodes_test
+
+import (
+	"context"
+	"diodes"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Waiting diodes", func() {
+	var (
+		ctx    context.Context
+		cancel func()
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("OneToOne", func() {
+		var d *diodes.OneToOne
+
+		BeforeEach(func() {
+			d = diodes.NewWaitingOneToOne(ctx, 5, newMockAlerter())
+		})
+
+		It("waits for data to be set", func() {
+			result := make(chan []byte, 1)
+			go func() {
+				result <- d.Next()
+			}()
+			Consistently(result).ShouldNot(Receive())
+
+			d.Set([]byte("some-data"))
+
+			Eventually(result).Should(Receive(Equal([]byte("some-data"))))
+		})
+
+		It("returns data that was set before reading", func() {
+			d.Set([]byte("some-data"))
+			d.Set([]byte("some-other-data"))
+
+			Expect(d.Next()).To(Equal([]byte("some-data")))
+			Expect(d.Next()).To(Equal([]byte("some-other-data")))
+		})
+
+		It("returns nil once the context is done", func() {
+			result := make(chan []byte, 1)
+			go func() {
+				result <- d.Next()
+			}()
+
+			cancel()
+
+			Eventually(result).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("ManyToOneEnvelope", func() {
+		var d *diodes.ManyToOneEnvelope
+
+		BeforeEach(func() {
+			d = diodes.NewWaitingManyToOneEnvelope(ctx, 5, newMockAlerter())
+		})
+
+		It("waits for data to be set", func() {
+			result := make(chan *events.Envelope, 1)
+			go func() {
+				result <- d.Next()
+			}()
+			Consistently(result).ShouldNot(Receive())
+
+			data := &events.Envelope{Origin: proto.String("some-origin")}
+			d.Set(data)
+
+			Eventually(result).Should(Receive(Equal(data)))
+		})
+
+		It("returns nil once the context is done", func() {
+			result := make(chan *events.Envelope, 1)
+			go func() {
+				result <- d.Next()
+			}()
+
+			cancel()
+
+			Eventually(result).Should(Receive(BeNil()))
+		})
+
+		It("wakes the reader for every write", func(done Done) {
+			defer close(done)
+			received := make(chan *events.Envelope)
+			go func() {
+				for {
+					e := d.Next()
+					if e == nil {
+						return
+					}
+					received <- e
+				}
+			}()
+
+			for i := 0; i < 1000; i++ {
+				data := &events.Envelope{Origin: proto.String("some-origin")}
+				d.Set(data)
+				Expect(<-received).To(Equal(data))
+			}
+		}, 5)
+	})
+})
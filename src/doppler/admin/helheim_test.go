@@ -0,0 +1,47 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package admin_test
+
+import (
+	"doppler/grpcmanager/v1"
+	"doppler/sinks"
+)
+
+type mockSinkLister struct {
+	AppSinksCalled chan bool
+	AppSinksOutput struct {
+		Ret0 chan map[string][]sinks.Sink
+	}
+}
+
+func newMockSinkLister() *mockSinkLister {
+	m := &mockSinkLister{}
+	m.AppSinksCalled = make(chan bool, 100)
+	m.AppSinksOutput.Ret0 = make(chan map[string][]sinks.Sink, 100)
+	return m
+}
+func (m *mockSinkLister) AppSinks() map[string][]sinks.Sink {
+	m.AppSinksCalled <- true
+	return <-m.AppSinksOutput.Ret0
+}
+
+type mockSubscriptionLister struct {
+	SubscriptionsCalled chan bool
+	SubscriptionsOutput struct {
+		Ret0 chan []v1.Subscription
+	}
+}
+
+func newMockSubscriptionLister() *mockSubscriptionLister {
+	m := &mockSubscriptionLister{}
+	m.SubscriptionsCalled = make(chan bool, 100)
+	m.SubscriptionsOutput.Ret0 = make(chan []v1.Subscription, 100)
+	return m
+}
+func (m *mockSubscriptionLister) Subscriptions() []v1.Subscription {
+	m.SubscriptionsCalled <- true
+	return <-m.SubscriptionsOutput.Ret0
+}
@@ -0,0 +1,264 @@
+// Package admin serves an authenticated HTTP API for inspecting the sinks
+// and subscriptions registered with Doppler.
+package admin
+
+import (
+	"crypto/subtle"
+	"doppler/grpcmanager/v1"
+	"doppler/sinks"
+	"doppler/sinks/containermetric"
+	"doppler/sinks/dump"
+	"doppler/sinks/syslog"
+	"doppler/sinks/websocket"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"plumbing"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SinkLister returns the sinks registered for each app, keyed by app ID.
+type SinkLister interface {
+	AppSinks() map[string][]sinks.Sink
+}
+
+// SubscriptionLister returns the registered gRPC subscriptions.
+type SubscriptionLister interface {
+	Subscriptions() []v1.Subscription
+}
+
+type Server struct {
+	username      string
+	password      string
+	sinks         SinkLister
+	subscriptions SubscriptionLister
+	listener      net.Listener
+
+	done chan struct{}
+}
+
+func New(addr, username, password string, sinks SinkLister, subscriptions SubscriptionLister) (*Server, error) {
+	log.Printf("Admin: Listening at %s", addr)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		username:      username,
+		password:      password,
+		sinks:         sinks,
+		subscriptions: subscriptions,
+		listener:      listener,
+		done:          make(chan struct{}),
+	}, nil
+}
+
+func (s *Server) Start() {
+	server := &http.Server{Handler: s}
+	err := server.Serve(s.listener)
+	log.Printf("Admin: Serve ended with %v", err)
+	close(s.done)
+}
+
+func (s *Server) Stop() {
+	s.listener.Close()
+	<-s.done
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="doppler admin"`)
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/sinks":
+		s.writeJSON(w, s.appSinks(r.URL.Query().Get("app_id")))
+	case "/subscriptions":
+		s.writeJSON(w, s.subscriptionList())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok || s.password == "" {
+		return false
+	}
+
+	usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+	return usernameMatches && passwordMatches
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Admin: failed to write response: %s", err)
+	}
+}
+
+type sinksResponse struct {
+	Apps map[string]*appSinks `json:"apps"`
+}
+
+type appSinks struct {
+	Drains           []drainStatus `json:"drains"`
+	Websockets       []string      `json:"websockets"`
+	RecentLogs       bool          `json:"recent_logs"`
+	ContainerMetrics bool          `json:"container_metrics"`
+}
+
+type drainStatus struct {
+	URL             string     `json:"url"`
+	DrainID         string     `json:"drain_id"`
+	DrainType       string     `json:"drain_type"`
+	State           string     `json:"state"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	SentMessages    uint64     `json:"sent_messages"`
+	DroppedMessages uint64     `json:"dropped_messages"`
+	Errors          uint64     `json:"errors"`
+	Reconnects      uint64     `json:"reconnects"`
+}
+
+// appSinks describes the sinks for every app, or only for the given app
+// when appID is not empty.
+func (s *Server) appSinks(appID string) sinksResponse {
+	resp := sinksResponse{Apps: make(map[string]*appSinks)}
+
+	for id, registered := range s.sinks.AppSinks() {
+		if appID != "" && id != appID {
+			continue
+		}
+
+		app := &appSinks{
+			Drains:     []drainStatus{},
+			Websockets: []string{},
+		}
+		for _, sink := range registered {
+			switch sink := sink.(type) {
+			case *syslog.SyslogSink:
+				status := sink.Status()
+				drain := drainStatus{
+					URL:             sink.Identifier(),
+					DrainID:         sink.DrainID(),
+					DrainType:       sink.DrainType().String(),
+					State:           status.State.String(),
+					LastError:       status.LastError,
+					SentMessages:    status.SentMessages,
+					DroppedMessages: status.DroppedMessages,
+					Errors:          status.Errors,
+					Reconnects:      status.Reconnects,
+				}
+				if !status.LastSuccess.IsZero() {
+					drain.LastSuccess = &status.LastSuccess
+				}
+				app.Drains = append(app.Drains, drain)
+			case *websocket.WebsocketSink:
+				app.Websockets = append(app.Websockets, sink.Identifier())
+			case *dump.DumpSink:
+				app.RecentLogs = true
+			case *containermetric.ContainerMetricSink:
+				app.ContainerMetrics = true
+			}
+		}
+
+		sort.Sort(byURL(app.Drains))
+		sort.Strings(app.Websockets)
+		resp.Apps[id] = app
+	}
+
+	return resp
+}
+
+type byURL []drainStatus
+
+func (d byURL) Len() int           { return len(d) }
+func (d byURL) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byURL) Less(i, j int) bool { return d[i].URL < d[j].URL }
+
+type subscriptionsResponse struct {
+	Subscriptions []subscription `json:"subscriptions"`
+}
+
+type subscription struct {
+	ShardID     string  `json:"shard_id"`
+	ShardType   string  `json:"shard_type"`
+	Filter      *filter `json:"filter"`
+	Subscribers int     `json:"subscribers"`
+}
+
+type filter struct {
+	AppID       string   `json:"app_id,omitempty"`
+	EventTypes  []string `json:"event_types,omitempty"`
+	SourceTypes []string `json:"source_types,omitempty"`
+	Origins     []string `json:"origins,omitempty"`
+}
+
+func (f *filter) appID() string {
+	if f == nil {
+		return ""
+	}
+	return f.AppID
+}
+
+type byShard []subscription
+
+func (s byShard) Len() int      { return len(s) }
+func (s byShard) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byShard) Less(i, j int) bool {
+	if s[i].ShardID != s[j].ShardID {
+		return s[i].ShardID < s[j].ShardID
+	}
+	return s[i].Filter.appID() < s[j].Filter.appID()
+}
+
+func (s *Server) subscriptionList() subscriptionsResponse {
+	resp := subscriptionsResponse{Subscriptions: []subscription{}}
+
+	for _, sub := range s.subscriptions.Subscriptions() {
+		var f *filter
+		if sub.Filter != nil {
+			f = &filter{
+				AppID:       sub.Filter.AppID,
+				EventTypes:  sub.Filter.EventTypes,
+				SourceTypes: sub.Filter.SourceTypes,
+				Origins:     sub.Filter.Origins,
+			}
+		}
+
+		resp.Subscriptions = append(resp.Subscriptions, subscription{
+			ShardID:     sub.ShardID,
+			ShardType:   shardTypeName(sub.ShardType),
+			Filter:      f,
+			Subscribers: sub.Subscribers,
+		})
+	}
+
+	sort.Sort(byShard(resp.Subscriptions))
+
+	return resp
+}
+
+// shardTypeName returns the shard type as it is given in the traffic
+// controller's shard_type query parameter.
+func shardTypeName(t plumbing.SubscriptionRequest_ShardType) string {
+	if t == plumbing.SubscriptionRequest_APP_AFFINITY {
+		return "app_affinity"
+	}
+	return "random"
+}
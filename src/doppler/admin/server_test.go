@@ -0,0 +1,237 @@
+package admin_test
+
+import (
+	"doppler/admin"
+	"doppler/grpcmanager/v1"
+	"doppler/sinks"
+	"doppler/sinks/containermetric"
+	"doppler/sinks/dump"
+	"doppler/sinks/syslog"
+	"doppler/sinks/websocket"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"plumbing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		sinkLister         *mockSinkLister
+		subscriptionLister *mockSubscriptionLister
+		server             *admin.Server
+		recorder           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		sinkLister = newMockSinkLister()
+		subscriptionLister = newMockSubscriptionLister()
+		recorder = httptest.NewRecorder()
+
+		var err error
+		server, err = admin.New("127.0.0.1:0", "admin", "secret", sinkLister, subscriptionLister)
+		Expect(err).ToNot(HaveOccurred())
+		go server.Start()
+	})
+
+	AfterEach(func() {
+		server.Stop()
+	})
+
+	get := func(path string) *http.Request {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.SetBasicAuth("admin", "secret")
+		return req
+	}
+
+	Describe("authentication", func() {
+		It("rejects requests without credentials", func() {
+			req, err := http.NewRequest("GET", "/sinks", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring("Basic"))
+			Expect(sinkLister.AppSinksCalled).ToNot(Receive())
+		})
+
+		It("rejects requests with the wrong password", func() {
+			req := get("/sinks")
+			req.SetBasicAuth("admin", "wrong")
+
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("rejects every request when no password is configured", func() {
+			noPassword, err := admin.New("127.0.0.1:0", "admin", "", sinkLister, subscriptionLister)
+			Expect(err).ToNot(HaveOccurred())
+			go noPassword.Start()
+			defer noPassword.Stop()
+
+			req := get("/sinks")
+			req.SetBasicAuth("admin", "")
+
+			noPassword.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	It("only allows GET requests", func() {
+		req := get("/sinks")
+		req.Method = "DELETE"
+
+		server.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("returns 404 for unknown paths", func() {
+		server.ServeHTTP(recorder, get("/unknown"))
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("/sinks", func() {
+		var drainID string
+
+		BeforeEach(func() {
+			drain := syslog.NewSyslogSink(
+				"app-1",
+				&url.URL{Scheme: "syslog", Host: "drain.example.com:514"},
+				100,
+				nil,
+				func(string, string) {},
+				"origin",
+			)
+			drainID = drain.DrainID()
+			ws := websocket.NewWebsocketSink("app-1", &fakeWebsocket{}, 100, time.Second, "origin")
+
+			sinkLister.AppSinksOutput.Ret0 <- map[string][]sinks.Sink{
+				"app-1": {
+					drain,
+					ws,
+					dump.NewDumpSink("app-1", 10, time.Second),
+					containermetric.NewContainerMetricSink("app-1", time.Second, time.Second),
+				},
+				"app-2": {
+					dump.NewDumpSink("app-2", 10, time.Second),
+				},
+			}
+		})
+
+		It("lists the sinks for each app", func() {
+			server.ServeHTTP(recorder, get("/sinks"))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"apps": {
+					"app-1": {
+						"drains": [{
+							"url": "syslog://drain.example.com:514",
+							"drain_id": "` + drainID + `",
+							"drain_type": "logs",
+							"state": "connecting",
+							"sent_messages": 0,
+							"dropped_messages": 0,
+							"errors": 0,
+							"reconnects": 0
+						}],
+						"websockets": ["10.0.0.1:4443"],
+						"recent_logs": true,
+						"container_metrics": true
+					},
+					"app-2": {
+						"drains": [],
+						"websockets": [],
+						"recent_logs": true,
+						"container_metrics": false
+					}
+				}
+			}`))
+		})
+
+		It("only lists the sinks for the requested app", func() {
+			server.ServeHTTP(recorder, get("/sinks?app_id=app-2"))
+
+			var resp struct {
+				Apps map[string]interface{} `json:"apps"`
+			}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+			Expect(resp.Apps).To(HaveLen(1))
+			Expect(resp.Apps).To(HaveKey("app-2"))
+		})
+	})
+
+	Describe("/subscriptions", func() {
+		It("lists the subscriptions by shard ID", func() {
+			subscriptionLister.SubscriptionsOutput.Ret0 <- []v1.Subscription{
+				{
+					ShardID:     "shard-b",
+					ShardType:   plumbing.SubscriptionRequest_APP_AFFINITY,
+					Subscribers: 3,
+				},
+				{
+					ShardID: "shard-a",
+					Filter: &plumbing.Filter{
+						AppID:      "app-1",
+						EventTypes: []string{"LogMessage"},
+					},
+					Subscribers: 1,
+				},
+			}
+
+			server.ServeHTTP(recorder, get("/subscriptions"))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"subscriptions": [
+					{
+						"shard_id": "shard-a",
+						"shard_type": "random",
+						"filter": {"app_id": "app-1", "event_types": ["LogMessage"]},
+						"subscribers": 1
+					},
+					{
+						"shard_id": "shard-b",
+						"shard_type": "app_affinity",
+						"filter": null,
+						"subscribers": 3
+					}
+				]
+			}`))
+		})
+
+		It("returns an empty list without subscriptions", func() {
+			subscriptionLister.SubscriptionsOutput.Ret0 <- nil
+
+			server.ServeHTTP(recorder, get("/subscriptions"))
+
+			Expect(recorder.Body.String()).To(MatchJSON(`{"subscriptions": []}`))
+		})
+	})
+})
+
+type fakeWebsocket struct{}
+
+func (*fakeWebsocket) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4443}
+}
+
+func (*fakeWebsocket) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (*fakeWebsocket) WriteMessage(int, []byte) error {
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package affinity assigns envelopes to the members of a sharded
+// subscription so that every envelope for an app goes to the same member.
+package affinity
+
+import (
+	"hash/fnv"
+
+	"github.com/cloudfoundry/dropsonde/envelope_extensions"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// Key returns the key an envelope is sharded by: its app ID, or its origin
+// for envelopes that do not belong to an app such as system metrics.
+func Key(envelope *events.Envelope) string {
+	appID := envelope_extensions.GetAppId(envelope)
+	if appID == envelope_extensions.SystemAppId {
+		return envelope.GetOrigin()
+	}
+	return appID
+}
+
+// Pick returns the index of the member the key is assigned to. It uses
+// rendezvous hashing, so adding or removing a member only moves the keys
+// assigned to that member. It returns -1 when there are no members.
+func Pick(key string, members []string) int {
+	k := hash(key)
+	best := -1
+	var bestScore uint64
+	for i, member := range members {
+		score := mix(k ^ hash(member))
+		if best == -1 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix scrambles the bits of the combined hashes so that scores for similar
+// members are not correlated.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
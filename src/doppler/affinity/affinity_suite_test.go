@@ -0,0 +1,13 @@
+package affinity_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAffinity(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Affinity Suite")
+}
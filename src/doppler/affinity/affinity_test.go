@@ -0,0 +1,82 @@
+package affinity_test
+
+import (
+	"doppler/affinity"
+	"fmt"
+
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/dropsonde/factories"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Affinity", func() {
+	Describe("Key", func() {
+		It("returns the app ID of app envelopes", func() {
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "some-app", "App"), "some-origin")
+
+			Expect(affinity.Key(envelope)).To(Equal("some-app"))
+		})
+
+		It("returns the origin of envelopes without an app", func() {
+			envelope := &events.Envelope{
+				Origin:    proto.String("some-origin"),
+				EventType: events.Envelope_ValueMetric.Enum(),
+				ValueMetric: &events.ValueMetric{
+					Name:  proto.String("some-metric"),
+					Value: proto.Float64(1),
+					Unit:  proto.String("unit"),
+				},
+			}
+
+			Expect(affinity.Key(envelope)).To(Equal("some-origin"))
+		})
+	})
+
+	Describe("Pick", func() {
+		var members []string
+
+		BeforeEach(func() {
+			members = []string{"member-a", "member-b", "member-c", "member-d"}
+		})
+
+		It("returns -1 without members", func() {
+			Expect(affinity.Pick("some-app", nil)).To(Equal(-1))
+		})
+
+		It("always picks the same member for a key", func() {
+			first := affinity.Pick("some-app", members)
+			for i := 0; i < 10; i++ {
+				Expect(affinity.Pick("some-app", members)).To(Equal(first))
+			}
+		})
+
+		It("spreads keys across the members", func() {
+			counts := make(map[int]int)
+			for i := 0; i < 1000; i++ {
+				counts[affinity.Pick(fmt.Sprintf("app-%d", i), members)]++
+			}
+
+			Expect(counts).To(HaveLen(len(members)))
+			for _, count := range counts {
+				Expect(count).To(BeNumerically("~", 250, 75))
+			}
+		})
+
+		It("only moves the keys of a member that leaves", func() {
+			remaining := []string{"member-a", "member-c", "member-d"}
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("app-%d", i)
+				before := members[affinity.Pick(key, members)]
+				if before == "member-b" {
+					continue
+				}
+				Expect(remaining[affinity.Pick(key, remaining)]).To(Equal(before))
+			}
+		})
+	})
+})
@@ -32,12 +32,77 @@ type GRPC struct {
 	KeyFile  string
 }
 
+// RecentLogsStore configures persisting recent logs to disk so that they
+// survive restarts. It is disabled when Dir is empty. Persisted logs are kept
+// regardless of age when MaxAgeSeconds is zero. At most MaxApps apps have
+// their logs persisted.
+type RecentLogsStore struct {
+	Dir           string
+	MaxAgeSeconds uint
+	MaxApps       uint
+}
+
+// ContainerMetricHistory configures the downsampled history of container
+// metrics retained per app instance. It is disabled when WindowSeconds is
+// zero.
+type ContainerMetricHistory struct {
+	ResolutionSeconds uint
+	WindowSeconds     uint
+}
+
+// AppRateLimit configures the rate at which each app's envelopes are routed.
+// Apps are not limited when MessagesPerSecond is zero. Overrides are keyed by
+// app ID and replace the limit for that app; a zero MessagesPerSecond
+// override lifts the limit for the app.
+type AppRateLimit struct {
+	MessagesPerSecond     float64
+	Burst                 uint
+	NoticeIntervalSeconds uint
+	Overrides             map[string]AppRateLimitOverride
+}
+
+type AppRateLimitOverride struct {
+	MessagesPerSecond float64
+	Burst             uint
+}
+
+// EnvelopeBuffer configures the weights with which the buffered app logs,
+// container metrics and other metrics are read for routing. The classes with
+// lower weights are shed first when Doppler falls behind.
+type EnvelopeBuffer struct {
+	LogsWeight             uint
+	ContainerMetricsWeight uint
+	MetricsWeight          uint
+}
+
+// Admin configures the HTTP API for inspecting the registered sinks and
+// subscriptions. It is disabled when Port is zero and requires basic auth
+// with the given username and password.
+type Admin struct {
+	Port     uint32
+	Username string
+	Password string
+}
+
+// SyslogDrainFormat configures the RFC5424 messages written to syslog
+// drains. StructuredData adds an SD-ELEMENT with the envelope tags, and
+// HostnameTemplate is a Go template for the HOSTNAME, which is
+// "loggregator" when it is empty.
+type SyslogDrainFormat struct {
+	StructuredData   bool
+	HostnameTemplate string
+}
+
 type Config struct {
+	Admin                           Admin
+	AppRateLimit                    AppRateLimit
 	BlackListIps                    []iprange.IPRange
 	ContainerMetricTTLSeconds       int
+	ContainerMetricHistory          ContainerMetricHistory
 	IncomingUDPPort                 uint32
 	IncomingTCPPort                 uint32
 	EnableTLSTransport              bool
+	EnvelopeBuffer                  EnvelopeBuffer
 	TLSListenerConfig               TLSListenerConfig
 	EtcdMaxConcurrentRequests       int
 	EtcdUrls                        []string
@@ -48,18 +113,22 @@ type Config struct {
 	LogFilePath                     string
 	MaxRetainedLogMessages          uint32
 	MessageDrainBufferSize          uint
+	MessageRouterWorkers            int
 	MetricBatchIntervalMilliseconds uint
 	MetronAddress                   string
 	MonitorIntervalSeconds          uint
 	WebsocketHost                   string
 	OutgoingPort                    uint32
 	GRPC                            GRPC
+	RecentLogsStore                 RecentLogsStore
 	SharedSecret                    string
 	SinkDialTimeoutSeconds          int
 	SinkIOTimeoutSeconds            int
 	SinkInactivityTimeoutSeconds    int
 	SinkSkipCertVerify              bool
 	Syslog                          string
+	SyslogDrainFormat               SyslogDrainFormat
+	SyslogUDPMTU                    int
 	UnmarshallerCount               int
 	WebsocketWriteTimeoutSeconds    int
 	Zone                            string
@@ -90,6 +159,10 @@ func (c *Config) validate() (err error) {
 		}
 	}
 
+	if c.Admin.Port != 0 && c.Admin.Password == "" {
+		return errors.New("invalid doppler config, no Admin.Password provided")
+	}
+
 	if len(c.GRPC.CAFile) == 0 {
 		return errors.New("invalid doppler config, no GRPC.CAFile provided")
 	}
@@ -159,6 +232,26 @@ func Parse(confData []byte) (*Config, error) {
 		config.UnmarshallerCount = 1
 	}
 
+	if config.MessageRouterWorkers == 0 {
+		config.MessageRouterWorkers = 4
+	}
+
+	if config.EnvelopeBuffer.LogsWeight == 0 {
+		config.EnvelopeBuffer.LogsWeight = 4
+	}
+
+	if config.EnvelopeBuffer.ContainerMetricsWeight == 0 {
+		config.EnvelopeBuffer.ContainerMetricsWeight = 2
+	}
+
+	if config.EnvelopeBuffer.MetricsWeight == 0 {
+		config.EnvelopeBuffer.MetricsWeight = 1
+	}
+
+	if config.AppRateLimit.NoticeIntervalSeconds == 0 {
+		config.AppRateLimit.NoticeIntervalSeconds = 10
+	}
+
 	if config.EtcdMaxConcurrentRequests < 1 {
 		config.EtcdMaxConcurrentRequests = 1
 	}
@@ -167,5 +260,13 @@ func Parse(confData []byte) (*Config, error) {
 		config.GRPC.Port = 8082
 	}
 
+	if config.ContainerMetricHistory.ResolutionSeconds == 0 {
+		config.ContainerMetricHistory.ResolutionSeconds = 60
+	}
+
+	if config.RecentLogsStore.MaxApps == 0 {
+		config.RecentLogsStore.MaxApps = 10000
+	}
+
 	return config, nil
 }
@@ -1,14 +1,21 @@
 package main
 
 import (
-	"diodes"
+	"context"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"doppler/admin"
 	"doppler/config"
 	"doppler/grpcmanager/v1"
+	"doppler/lanes"
+	"doppler/logstore"
+	"doppler/ratelimit"
+	"doppler/sinks/containermetric"
+	"doppler/sinks/dump"
+	"doppler/sinks/syslogwriter"
 	"doppler/sinkserver"
 	"doppler/sinkserver/blacklist"
 	"doppler/sinkserver/sinkmanager"
@@ -36,14 +43,17 @@ type Doppler struct {
 	appStoreWatcher *store.AppServiceStoreWatcher
 
 	errChan         chan error
-	envelopeBuffer  *diodes.ManyToOneEnvelope
+	envelopeBuffer  *lanes.Buffer
+	stopBuffer      context.CancelFunc
 	udpListener     *listeners.UDPListener
 	tcpListener     *listeners.TCPListener
 	tlsListener     *listeners.TCPListener
 	grpcListener    *listeners.GRPCListener
 	sinkManager     *sinkmanager.SinkManager
+	recentLogsStore *logstore.Store
 	messageRouter   *sinkserver.MessageRouter
 	websocketServer *websocketserver.WebsocketServer
+	adminServer     *admin.Server
 
 	dropsondeUnmarshallerCollection *dropsonde_unmarshaller.DropsondeUnmarshallerCollection
 	dropsondeBytesChan              <-chan []byte
@@ -55,6 +65,9 @@ type Doppler struct {
 	uptimeMonitor   *monitor.Uptime
 	openFileMonitor *monitor.LinuxFileDescriptor
 
+	metronLatency     *monitor.Latency
+	subscriberLatency *monitor.Latency
+
 	newAppServiceChan, deletedAppServiceChan <-chan appservice.AppService
 	wg                                       sync.WaitGroup
 }
@@ -80,13 +93,31 @@ func New(
 
 	doppler.batcher = initializeMetrics(conf.MetricBatchIntervalMilliseconds)
 
+	monitorInterval := time.Duration(conf.MonitorIntervalSeconds) * time.Second
+	doppler.openFileMonitor = monitor.NewLinuxFD(monitorInterval)
+	doppler.uptimeMonitor = monitor.NewUptime(monitorInterval)
+	doppler.metronLatency = monitor.NewLatency("transit.metronToDoppler", monitorInterval)
+	doppler.subscriberLatency = monitor.NewLatency("transit.dopplerToSubscriber", monitorInterval)
+
 	doppler.udpListener, doppler.dropsondeBytesChan = listeners.NewUDPListener(
 		fmt.Sprintf("%s:%d", host, conf.IncomingUDPPort),
 		doppler.batcher,
 		"udpListener",
 	)
 
-	doppler.envelopeBuffer = diodes.NewManyToOneEnvelope(10000, doppler)
+	var bufferCtx context.Context
+	bufferCtx, doppler.stopBuffer = context.WithCancel(context.Background())
+	doppler.envelopeBuffer = lanes.New(
+		bufferCtx,
+		10000,
+		lanes.Weights{
+			Logs:             int(conf.EnvelopeBuffer.LogsWeight),
+			ContainerMetrics: int(conf.EnvelopeBuffer.ContainerMetricsWeight),
+			Metrics:          int(conf.EnvelopeBuffer.MetricsWeight),
+		},
+		doppler.batcher,
+		doppler.metronLatency,
+	)
 
 	var err error
 	if conf.EnableTLSTransport {
@@ -107,10 +138,32 @@ func New(
 
 	doppler.dropsondeUnmarshallerCollection = dropsonde_unmarshaller.NewDropsondeUnmarshallerCollection(conf.UnmarshallerCount)
 
+	var recentLogsStore dump.Store
+	if conf.RecentLogsStore.Dir != "" {
+		doppler.recentLogsStore, err = logstore.New(
+			conf.RecentLogsStore.Dir,
+			int(conf.MaxRetainedLogMessages),
+			int(conf.RecentLogsStore.MaxApps),
+			time.Duration(conf.RecentLogsStore.MaxAgeSeconds)*time.Second,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open the recent logs store: %s", err)
+		}
+		recentLogsStore = doppler.recentLogsStore
+	}
+
 	blacklist := blacklist.New(conf.BlackListIps)
 	metricTTL := time.Duration(conf.ContainerMetricTTLSeconds) * time.Second
+	metricHistory := containermetric.History{
+		Resolution: time.Duration(conf.ContainerMetricHistory.ResolutionSeconds) * time.Second,
+		Window:     time.Duration(conf.ContainerMetricHistory.WindowSeconds) * time.Second,
+	}
 	sinkTimeout := time.Duration(conf.SinkInactivityTimeoutSeconds) * time.Second
 	sinkIOTimeout := time.Duration(conf.SinkIOTimeoutSeconds) * time.Second
+	syslogFormat, err := syslogwriter.NewFormat(conf.SyslogDrainFormat.StructuredData, conf.SyslogDrainFormat.HostnameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the syslog drain format: %s", err)
+	}
 	doppler.sinkManager = sinkmanager.New(
 		conf.MaxRetainedLogMessages,
 		conf.SinkSkipCertVerify,
@@ -121,15 +174,41 @@ func New(
 		sinkIOTimeout,
 		metricTTL,
 		dialTimeout,
+		recentLogsStore,
+		metricHistory,
+		syslogFormat,
+		conf.SyslogUDPMTU,
 	)
 
 	grpcRouter := v1.NewRouter()
-	doppler.grpcListener, err = listeners.NewGRPCListener(grpcRouter, doppler.sinkManager, conf.GRPC, doppler.envelopeBuffer, doppler.batcher)
+	doppler.grpcListener, err = listeners.NewGRPCListener(grpcRouter, doppler.sinkManager, conf.GRPC, doppler.envelopeBuffer, doppler.batcher, doppler.subscriberLatency)
 	if err != nil {
 		return nil, err
 	}
 
-	doppler.messageRouter = sinkserver.NewMessageRouter(doppler.sinkManager, grpcRouter)
+	var limiter *ratelimit.Limiter
+	if conf.AppRateLimit.MessagesPerSecond > 0 || len(conf.AppRateLimit.Overrides) > 0 {
+		overrides := make(map[string]ratelimit.Limit, len(conf.AppRateLimit.Overrides))
+		for appID, o := range conf.AppRateLimit.Overrides {
+			overrides[appID] = ratelimit.Limit{
+				MessagesPerSecond: o.MessagesPerSecond,
+				Burst:             int(o.Burst),
+			}
+		}
+
+		limiter = ratelimit.New(
+			ratelimit.Limit{
+				MessagesPerSecond: conf.AppRateLimit.MessagesPerSecond,
+				Burst:             int(conf.AppRateLimit.Burst),
+			},
+			overrides,
+			time.Duration(conf.AppRateLimit.NoticeIntervalSeconds)*time.Second,
+			dropsondeOrigin,
+			doppler.batcher,
+		)
+	}
+
+	doppler.messageRouter = sinkserver.NewMessageRouter(conf.MessageRouterWorkers, limiter, doppler.sinkManager, grpcRouter)
 
 	doppler.websocketServer, err = websocketserver.New(
 		fmt.Sprintf("%s:%d", conf.WebsocketHost, conf.OutgoingPort),
@@ -144,9 +223,18 @@ func New(
 		return nil, fmt.Errorf("Failed to create the websocket server: %s", err.Error())
 	}
 
-	monitorInterval := time.Duration(conf.MonitorIntervalSeconds) * time.Second
-	doppler.openFileMonitor = monitor.NewLinuxFD(monitorInterval)
-	doppler.uptimeMonitor = monitor.NewUptime(monitorInterval)
+	if conf.Admin.Port != 0 {
+		doppler.adminServer, err = admin.New(
+			fmt.Sprintf("%s:%d", host, conf.Admin.Port),
+			conf.Admin.Username,
+			conf.Admin.Password,
+			doppler.sinkManager,
+			grpcRouter,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create the admin server: %s", err)
+		}
+	}
 
 	return doppler, nil
 }
@@ -220,8 +308,22 @@ func (doppler *Doppler) Start() {
 		doppler.websocketServer.Start()
 	}()
 
+	if doppler.adminServer != nil {
+		doppler.wg.Add(1)
+		go func() {
+			defer doppler.wg.Done()
+			doppler.adminServer.Start()
+		}()
+	}
+
+	if doppler.recentLogsStore != nil {
+		go doppler.recentLogsStore.Start()
+	}
+
 	go doppler.uptimeMonitor.Start()
 	go doppler.openFileMonitor.Start()
+	go doppler.metronLatency.Start()
+	go doppler.subscriberLatency.Start()
 
 	// The following runs forever. Put all startup functions above here.
 	for err := range doppler.errChan {
@@ -235,18 +337,23 @@ func (doppler *Doppler) Stop() {
 	go doppler.tlsListener.Stop()
 	go doppler.sinkManager.Stop()
 	go doppler.websocketServer.Stop()
+	if doppler.adminServer != nil {
+		go doppler.adminServer.Stop()
+	}
 	doppler.appStoreWatcher.Stop()
+	doppler.stopBuffer()
 	doppler.wg.Wait()
 
+	if doppler.recentLogsStore != nil {
+		doppler.recentLogsStore.Stop()
+	}
+
 	doppler.storeAdapter.Disconnect()
 	close(doppler.errChan)
 	doppler.uptimeMonitor.Stop()
 	doppler.openFileMonitor.Stop()
-}
-
-func (doppler *Doppler) Alert(missed int) {
-	log.Printf("Shed %d envelopes", missed)
-	doppler.batcher.BatchCounter("doppler.shedEnvelopes").Add(uint64(missed))
+	doppler.metronLatency.Stop()
+	doppler.subscriberLatency.Stop()
 }
 
 func initializeMetrics(batchIntervalMilliseconds uint) *metricbatcher.MetricBatcher {
@@ -1,34 +1,63 @@
 package firehose_group
 
 import (
+	"doppler/affinity"
 	"doppler/groupedsinks/sink_wrapper"
 	"doppler/sinks"
+	"fmt"
+	"log"
+	"marshalled"
 	"sync"
+	"time"
 
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
 )
 
 type FirehoseGroup interface {
-	AddSink(sink sinks.Sink, in chan<- *events.Envelope) bool
+	AddSink(sink sinks.Sink, in chan<- *marshalled.Envelope) bool
 	Exists(sink sinks.Sink) bool
 	RemoveSink(fsink sinks.Sink) bool
 	RemoveAllSinks()
 	IsEmpty() bool
-	BroadcastMessage(msg *events.Envelope)
+	BroadcastMessage(msg *marshalled.Envelope)
 }
 
 type firehoseGroup struct {
-	sinkWrappers      []*sink_wrapper.SinkWrapper
+	sinkWrappers      []*firehoseSink
+	members           []string
 	lastUsedSinkIndex int
+	appAffinity       bool
+	dropsondeOrigin   string
 	sync.RWMutex
 }
 
-func NewFirehoseGroup() *firehoseGroup {
+// firehoseSink accounts for the messages dropped because the sink was too
+// slow to accept them.
+type firehoseSink struct {
+	*sink_wrapper.SinkWrapper
+	dropped    uint64
+	unreported uint64
+}
+
+func NewFirehoseGroup(dropsondeOrigin string) *firehoseGroup {
 	return &firehoseGroup{
-		sinkWrappers: make([]*sink_wrapper.SinkWrapper, 0),
+		sinkWrappers:    make([]*firehoseSink, 0),
+		dropsondeOrigin: dropsondeOrigin,
 	}
 }
 
+// NewAppAffinityFirehoseGroup returns a FirehoseGroup that sends every
+// message for an app, or for an origin without an app, to the same sink.
+// Adding or removing a sink only moves the apps assigned to that sink.
+func NewAppAffinityFirehoseGroup(dropsondeOrigin string) *firehoseGroup {
+	group := NewFirehoseGroup(dropsondeOrigin)
+	group.appAffinity = true
+	return group
+}
+
 func (group *firehoseGroup) Exists(sink sinks.Sink) bool {
 	group.Lock()
 	defer group.Unlock()
@@ -40,7 +69,7 @@ func (group *firehoseGroup) Exists(sink sinks.Sink) bool {
 	return false
 }
 
-func (group *firehoseGroup) AddSink(sink sinks.Sink, in chan<- *events.Envelope) bool {
+func (group *firehoseGroup) AddSink(sink sinks.Sink, in chan<- *marshalled.Envelope) bool {
 	if group.Exists(sink) {
 		return false
 	}
@@ -48,11 +77,28 @@ func (group *firehoseGroup) AddSink(sink sinks.Sink, in chan<- *events.Envelope)
 	group.Lock()
 	defer group.Unlock()
 
-	sinkWrapper := sink_wrapper.SinkWrapper{InputChan: in, Sink: sink}
-	group.sinkWrappers = append(group.sinkWrappers, &sinkWrapper)
+	sinkWrapper := &firehoseSink{
+		SinkWrapper: &sink_wrapper.SinkWrapper{InputChan: in, Sink: sink},
+	}
+	group.sinkWrappers = append(group.sinkWrappers, sinkWrapper)
+	group.members = append(group.members, memberID(sink))
 	return true
 }
 
+// memberSink is implemented by sinks whose subscriber gives an ID that is
+// the same on every Doppler, so that app affinity groups on different
+// Dopplers assign an app to the same subscriber.
+type memberSink interface {
+	MemberID() string
+}
+
+func memberID(sink sinks.Sink) string {
+	if s, ok := sink.(memberSink); ok && s.MemberID() != "" {
+		return s.MemberID()
+	}
+	return sink.Identifier()
+}
+
 func (group *firehoseGroup) RemoveSink(fsink sinks.Sink) bool {
 	for i, sinkWrapper := range group.sinkWrappers {
 		if sinkWrapper.Sink == fsink {
@@ -62,6 +108,8 @@ func (group *firehoseGroup) RemoveSink(fsink sinks.Sink) bool {
 			close(sinkWrapper.InputChan)
 			s := group.sinkWrappers
 			group.sinkWrappers = s[:i+copy(s[i:], s[i+1:])]
+			m := group.members
+			group.members = m[:i+copy(m[i:], m[i+1:])]
 
 			return true
 		}
@@ -80,21 +128,106 @@ func (group *firehoseGroup) IsEmpty() bool {
 	return group.length() == 0
 }
 
-func (group *firehoseGroup) BroadcastMessage(msg *events.Envelope) {
+// BroadcastMessage sends the message to the next sink in the group that can
+// accept it without blocking. Sinks with full input channels are skipped. If
+// every sink is full the message is dropped and accounted against the sink
+// whose turn it was, which is told how many messages it missed once it
+// catches up. App affinity groups never skip the sink assigned to the
+// message's app, dropping the message instead.
+func (group *firehoseGroup) BroadcastMessage(msg *marshalled.Envelope) {
 	group.Lock()
 	defer group.Unlock()
 
 	l := len(group.sinkWrappers)
-	lastUsed := group.lastUsedSinkIndex
-	if lastUsed >= l {
+	if l == 0 {
+		return
+	}
+
+	if group.appAffinity {
+		sink := group.sinkWrappers[affinity.Pick(affinity.Key(msg.Envelope), group.members)]
+		if !group.send(sink, msg) {
+			group.drop(sink)
+		}
+		return
+	}
+
+	if group.lastUsedSinkIndex >= l {
 		group.lastUsedSinkIndex = 0
 	}
 
-	group.sinkWrappers[group.lastUsedSinkIndex].InputChan <- msg
+	for i := 0; i < l; i++ {
+		idx := (group.lastUsedSinkIndex + i) % l
+		if group.send(group.sinkWrappers[idx], msg) {
+			group.lastUsedSinkIndex = idx + 1
+			return
+		}
+	}
 
+	group.drop(group.sinkWrappers[group.lastUsedSinkIndex])
 	group.lastUsedSinkIndex += 1
 }
 
+func (group *firehoseGroup) drop(sink *firehoseSink) {
+	sink.dropped++
+	sink.unreported++
+	metrics.BatchIncrementCounter("firehoseGroup.droppedMessages")
+}
+
+// send writes the message to the sink without blocking, preceded by a notice
+// of any messages the sink has missed.
+func (group *firehoseGroup) send(sink *firehoseSink, msg *marshalled.Envelope) bool {
+	if sink.unreported > 0 {
+		notice, err := group.dropNotice(sink)
+		if err != nil {
+			log.Printf("Error marshalling message: %v", err)
+			return false
+		}
+
+		select {
+		case sink.InputChan <- notice:
+			log.Printf("FirehoseGroup: deltaDropped=%d totalDropped=%d subscriptionId=%s destination=%s",
+				sink.unreported,
+				sink.dropped,
+				sink.Sink.AppID(),
+				sink.Sink.Identifier(),
+			)
+			sink.unreported = 0
+		default:
+			return false
+		}
+	}
+
+	select {
+	case sink.InputChan <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (group *firehoseGroup) dropNotice(sink *firehoseSink) (*marshalled.Envelope, error) {
+	messageType := events.LogMessage_ERR
+	logMessage := &events.LogMessage{
+		Message: []byte(fmt.Sprintf(
+			"Firehose subscriber is too slow. %d messages dropped (Total %d messages dropped) from %s to %s.",
+			sink.unreported,
+			sink.dropped,
+			group.dropsondeOrigin,
+			sink.Sink.Identifier(),
+		)),
+		MessageType: &messageType,
+		SourceType:  proto.String("LGR"),
+		Timestamp:   proto.Int64(time.Now().UnixNano()),
+	}
+
+	envelope, err := emitter.Wrap(logMessage, group.dropsondeOrigin)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalled.New(envelope), nil
+}
+
 func (group *firehoseGroup) length() int {
 	group.RLock()
 	defer group.RUnlock()
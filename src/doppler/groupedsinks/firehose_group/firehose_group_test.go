@@ -2,6 +2,8 @@ package firehose_group_test
 
 import (
 	"doppler/sinks"
+	"fmt"
+	"marshalled"
 
 	"github.com/cloudfoundry/dropsonde/emitter"
 	"github.com/cloudfoundry/dropsonde/factories"
@@ -14,21 +16,26 @@ import (
 )
 
 type fakeSink struct {
-	sinkId string
-	appId  string
+	sinkId   string
+	appId    string
+	memberId string
 }
 
 func (f *fakeSink) AppID() string {
 	return f.appId
 }
 
-func (f *fakeSink) Run(<-chan *events.Envelope) {
+func (f *fakeSink) Run(<-chan *marshalled.Envelope) {
 }
 
 func (f *fakeSink) Identifier() string {
 	return f.sinkId
 }
 
+func (f *fakeSink) MemberID() string {
+	return f.memberId
+}
+
 func (f *fakeSink) ShouldReceiveErrors() bool {
 	return false
 }
@@ -41,22 +48,23 @@ func (f *fakeSink) UpdateDroppedMessageCount(messageCount int64) {}
 
 var _ = Describe("FirehoseGroup", func() {
 	It("sends message to all registered sinks", func() {
-		receiveChan1 := make(chan *events.Envelope, 10)
-		receiveChan2 := make(chan *events.Envelope, 10)
+		receiveChan1 := make(chan *marshalled.Envelope, 10)
+		receiveChan2 := make(chan *marshalled.Envelope, 10)
 
 		sink1 := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
 		sink2 := fakeSink{appId: "firehose-a", sinkId: "sink-b"}
 
-		group := firehose_group.NewFirehoseGroup()
+		group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 
 		group.AddSink(&sink1, receiveChan1)
 		group.AddSink(&sink2, receiveChan2)
 
-		msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+		envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+		msg := marshalled.New(envelope)
 		group.BroadcastMessage(msg)
 
-		var nextChannelToReceive chan *events.Envelope
-		var rmsg *events.Envelope
+		var nextChannelToReceive chan *marshalled.Envelope
+		var rmsg *marshalled.Envelope
 		select {
 		case rmsg = <-receiveChan1:
 			nextChannelToReceive = receiveChan2
@@ -71,19 +79,20 @@ var _ = Describe("FirehoseGroup", func() {
 	})
 
 	It("does not send messages to unregistered sinks", func() {
-		receiveChan1 := make(chan *events.Envelope, 10)
-		receiveChan2 := make(chan *events.Envelope, 10)
+		receiveChan1 := make(chan *marshalled.Envelope, 10)
+		receiveChan2 := make(chan *marshalled.Envelope, 10)
 
 		sink1 := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
 		sink2 := fakeSink{appId: "firehose-a", sinkId: "sink-b"}
 
-		group := firehose_group.NewFirehoseGroup()
+		group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 
 		group.AddSink(&sink1, receiveChan1)
 		group.AddSink(&sink2, receiveChan2)
 		group.RemoveSink(&sink2)
 
-		msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+		envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+		msg := marshalled.New(envelope)
 
 		group.BroadcastMessage(msg)
 		Expect(receiveChan1).To(Receive(&msg))
@@ -92,17 +101,171 @@ var _ = Describe("FirehoseGroup", func() {
 		Expect(receiveChan1).To(Receive(&msg))
 	})
 
+	It("skips sinks that cannot accept the message", func() {
+		fullChan := make(chan *marshalled.Envelope, 1)
+		receiveChan := make(chan *marshalled.Envelope, 10)
+
+		sink1 := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
+		sink2 := fakeSink{appId: "firehose-a", sinkId: "sink-b"}
+
+		group := firehose_group.NewFirehoseGroup("dropsonde-origin")
+		group.AddSink(&sink1, fullChan)
+		group.AddSink(&sink2, receiveChan)
+
+		envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+		msg := marshalled.New(envelope)
+		fullChan <- msg
+
+		for i := 0; i < 3; i++ {
+			group.BroadcastMessage(msg)
+		}
+
+		Expect(fullChan).To(HaveLen(1))
+		Expect(receiveChan).To(HaveLen(3))
+	})
+
+	Context("when every sink is full", func() {
+		var (
+			group       firehose_group.FirehoseGroup
+			receiveChan chan *marshalled.Envelope
+			msg         *marshalled.Envelope
+		)
+
+		BeforeEach(func() {
+			receiveChan = make(chan *marshalled.Envelope, 1)
+			sink := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
+
+			group = firehose_group.NewFirehoseGroup("dropsonde-origin")
+			group.AddSink(&sink, receiveChan)
+
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+			msg = marshalled.New(envelope)
+			receiveChan <- msg
+		})
+
+		It("drops the message without blocking", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				group.BroadcastMessage(msg)
+			}()
+
+			Eventually(done).Should(BeClosed())
+			Expect(receiveChan).To(HaveLen(1))
+		})
+
+		It("notifies the sink of the dropped messages once it catches up", func() {
+			group.BroadcastMessage(msg)
+			group.BroadcastMessage(msg)
+			<-receiveChan
+
+			group.BroadcastMessage(msg)
+
+			var notice *marshalled.Envelope
+			Expect(receiveChan).To(Receive(&notice))
+			Expect(notice.GetOrigin()).To(Equal("dropsonde-origin"))
+			Expect(notice.GetLogMessage().GetSourceType()).To(Equal("LGR"))
+			Expect(notice.GetLogMessage().GetMessageType()).To(Equal(events.LogMessage_ERR))
+			Expect(string(notice.GetLogMessage().GetMessage())).To(ContainSubstring("2 messages dropped (Total 2 messages dropped)"))
+		})
+	})
+
+	Context("with app affinity", func() {
+		var (
+			group    firehose_group.FirehoseGroup
+			sinks    []*fakeSink
+			channels []chan *marshalled.Envelope
+		)
+
+		var receivers = func() []int {
+			var indexes []int
+			for i, c := range channels {
+				if len(c) > 0 {
+					indexes = append(indexes, i)
+				}
+			}
+			return indexes
+		}
+
+		BeforeEach(func() {
+			group = firehose_group.NewAppAffinityFirehoseGroup("dropsonde-origin")
+			sinks = nil
+			channels = nil
+			for _, id := range []string{"sink-a", "sink-b", "sink-c"} {
+				sink := &fakeSink{appId: "firehose-a", sinkId: id}
+				c := make(chan *marshalled.Envelope, 20)
+				group.AddSink(sink, c)
+				sinks = append(sinks, sink)
+				channels = append(channels, c)
+			}
+		})
+
+		It("sends every message for an app to the same sink", func() {
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+			msg := marshalled.New(envelope)
+			for i := 0; i < 10; i++ {
+				group.BroadcastMessage(msg)
+			}
+
+			Expect(receivers()).To(HaveLen(1))
+			Expect(channels[receivers()[0]]).To(HaveLen(10))
+		})
+
+		It("keeps sending an app's messages to its sink when another leaves", func() {
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "234", "App"), "origin")
+			msg := marshalled.New(envelope)
+			group.BroadcastMessage(msg)
+			Expect(receivers()).To(HaveLen(1))
+			receiver := receivers()[0]
+			<-channels[receiver]
+
+			group.RemoveSink(sinks[(receiver+1)%len(sinks)])
+			group.BroadcastMessage(msg)
+
+			Expect(channels[receiver]).To(HaveLen(1))
+		})
+
+		It("assigns an app to the same member regardless of the order sinks join", func() {
+			first := firehose_group.NewAppAffinityFirehoseGroup("dropsonde-origin")
+			second := firehose_group.NewAppAffinityFirehoseGroup("dropsonde-origin")
+			firstChannels := make(map[string]chan *marshalled.Envelope)
+			secondChannels := make(map[string]chan *marshalled.Envelope)
+			for _, member := range []string{"member-a", "member-b", "member-c"} {
+				firstChannels[member] = make(chan *marshalled.Envelope, 20)
+				first.AddSink(&fakeSink{appId: "firehose-a", sinkId: "first-" + member, memberId: member}, firstChannels[member])
+			}
+			for _, member := range []string{"member-c", "member-a", "member-b"} {
+				secondChannels[member] = make(chan *marshalled.Envelope, 20)
+				second.AddSink(&fakeSink{appId: "firehose-a", sinkId: "second-" + member, memberId: member}, secondChannels[member])
+			}
+
+			for i := 0; i < 10; i++ {
+				env, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", fmt.Sprintf("app-%d", i), "App"), "origin")
+				first.BroadcastMessage(marshalled.New(env))
+				second.BroadcastMessage(marshalled.New(env))
+
+				for member, c := range firstChannels {
+					Expect(secondChannels[member]).To(HaveLen(len(c)))
+					if len(c) > 0 {
+						<-c
+						<-secondChannels[member]
+					}
+				}
+			}
+		})
+	})
+
 	Describe("IsEmpty", func() {
 		It("is true when the group is empty", func() {
-			group := firehose_group.NewFirehoseGroup()
+			group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 			Expect(group.IsEmpty()).To(BeTrue())
 		})
 
 		It("is false when the group is not empty", func() {
-			group := firehose_group.NewFirehoseGroup()
+			group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 			sink := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
 
-			group.AddSink(&sink, make(chan *events.Envelope, 10))
+			group.AddSink(&sink, make(chan *marshalled.Envelope, 10))
 
 			Expect(group.IsEmpty()).To(BeFalse())
 		})
@@ -110,20 +273,20 @@ var _ = Describe("FirehoseGroup", func() {
 
 	Describe("RemoveSink", func() {
 		It("makes the group empty and returns true when there is one sink to remove", func() {
-			group := firehose_group.NewFirehoseGroup()
+			group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 			sink := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
 
-			group.AddSink(&sink, make(chan *events.Envelope, 10))
+			group.AddSink(&sink, make(chan *marshalled.Envelope, 10))
 
 			Expect(group.RemoveSink(&sink)).To(BeTrue())
 			Expect(group.IsEmpty()).To(BeTrue())
 		})
 
 		It("returns false when the group does not contain the requested sink and does not remove any sinks from the group", func() {
-			group := firehose_group.NewFirehoseGroup()
+			group := firehose_group.NewFirehoseGroup("dropsonde-origin")
 			sink := fakeSink{appId: "firehose-a", sinkId: "sink-a"}
 
-			group.AddSink(&sink, make(chan *events.Envelope, 10))
+			group.AddSink(&sink, make(chan *marshalled.Envelope, 10))
 
 			otherSink := fakeSink{appId: "firehose-a", sinkId: "sink-b"}
 
@@ -9,25 +9,26 @@ import (
 	"doppler/sinks/syslog"
 	"doppler/sinks/websocket"
 	"log"
+	"marshalled"
 	"sync"
-
-	"github.com/cloudfoundry/sonde-go/events"
 )
 
-func NewGroupedSinks() *GroupedSinks {
+func NewGroupedSinks(dropsondeOrigin string) *GroupedSinks {
 	return &GroupedSinks{
-		apps:      make(map[string]map[string]*sink_wrapper.SinkWrapper),
-		firehoses: make(map[string]firehose_group.FirehoseGroup),
+		apps:            make(map[string]map[string]*sink_wrapper.SinkWrapper),
+		firehoses:       make(map[string]firehose_group.FirehoseGroup),
+		dropsondeOrigin: dropsondeOrigin,
 	}
 }
 
 type GroupedSinks struct {
-	apps      map[string]map[string]*sink_wrapper.SinkWrapper
-	firehoses map[string]firehose_group.FirehoseGroup
+	apps            map[string]map[string]*sink_wrapper.SinkWrapper
+	firehoses       map[string]firehose_group.FirehoseGroup
+	dropsondeOrigin string
 	sync.RWMutex
 }
 
-func (group *GroupedSinks) RegisterAppSink(in chan<- *events.Envelope, sink sinks.Sink) bool {
+func (group *GroupedSinks) RegisterAppSink(in chan<- *marshalled.Envelope, sink sinks.Sink) bool {
 	group.Lock()
 	defer group.Unlock()
 
@@ -48,7 +49,16 @@ func (group *GroupedSinks) RegisterAppSink(in chan<- *events.Envelope, sink sink
 	return true
 }
 
-func (group *GroupedSinks) RegisterFirehoseSink(in chan<- *events.Envelope, sink sinks.Sink) bool {
+// appAffinitySink is implemented by firehose sinks that can ask for every
+// message for an app to be sent to the same sink in their subscription.
+type appAffinitySink interface {
+	AppAffinity() bool
+}
+
+// RegisterFirehoseSink adds the sink to the firehose group for its
+// subscription. The first sink in a subscription decides whether the group
+// shards by app affinity.
+func (group *GroupedSinks) RegisterFirehoseSink(in chan<- *marshalled.Envelope, sink sinks.Sink) bool {
 	group.Lock()
 	defer group.Unlock()
 
@@ -59,8 +69,12 @@ func (group *GroupedSinks) RegisterFirehoseSink(in chan<- *events.Envelope, sink
 
 	fgroup := group.firehoses[subscriptionId]
 	if fgroup == nil {
-		group.firehoses[subscriptionId] = firehose_group.NewFirehoseGroup()
-		fgroup = group.firehoses[subscriptionId]
+		if s, ok := sink.(appAffinitySink); ok && s.AppAffinity() {
+			fgroup = firehose_group.NewAppAffinityFirehoseGroup(group.dropsondeOrigin)
+		} else {
+			fgroup = firehose_group.NewFirehoseGroup(group.dropsondeOrigin)
+		}
+		group.firehoses[subscriptionId] = fgroup
 	}
 
 	return fgroup.AddSink(sink, in)
@@ -82,7 +96,7 @@ func (group *GroupedSinks) IsFirehoseRegistered(sink sinks.Sink) bool {
 	return fgroup.Exists(sink)
 }
 
-func (group *GroupedSinks) Broadcast(appId string, msg *events.Envelope) {
+func (group *GroupedSinks) Broadcast(appId string, msg *marshalled.Envelope) {
 	group.RLock()
 	defer group.RUnlock()
 
@@ -97,7 +111,7 @@ func (group *GroupedSinks) Broadcast(appId string, msg *events.Envelope) {
 	group.BroadcastMessageToFirehoses(msg)
 }
 
-func (group *GroupedSinks) BroadcastError(appId string, errorMsg *events.Envelope) {
+func (group *GroupedSinks) BroadcastError(appId string, errorMsg *marshalled.Envelope) {
 	group.RLock()
 	defer group.RUnlock()
 
@@ -114,7 +128,7 @@ func (group *GroupedSinks) BroadcastError(appId string, errorMsg *events.Envelop
 	group.BroadcastMessageToFirehoses(errorMsg)
 }
 
-func (group *GroupedSinks) BroadcastMessageToFirehoses(msg *events.Envelope) {
+func (group *GroupedSinks) BroadcastMessageToFirehoses(msg *marshalled.Envelope) {
 	for _, fgroup := range group.firehoses {
 		fgroup.BroadcastMessage(msg)
 	}
@@ -206,13 +220,30 @@ func (group *GroupedSinks) WebsocketSinksFor(appId string) []websocket.Websocket
 	return results
 }
 
+// AppSinks returns the sinks registered for each app, keyed by app ID.
+func (group *GroupedSinks) AppSinks() map[string][]sinks.Sink {
+	group.RLock()
+	defer group.RUnlock()
+
+	results := make(map[string][]sinks.Sink, len(group.apps))
+	for appId, appSinks := range group.apps {
+		for _, wrapper := range appSinks {
+			results[appId] = append(results[appId], wrapper.Sink)
+		}
+	}
+
+	return results
+}
+
+// CloseAndDelete removes the sink. It leaves a different sink that has
+// since been registered with the same identifier in place.
 func (group *GroupedSinks) CloseAndDelete(sink sinks.Sink) bool {
 	group.Lock()
 	defer group.Unlock()
 
 	appId := sink.AppID()
 	wrapper, ok := group.apps[appId][sink.Identifier()]
-	if ok {
+	if ok && wrapper.Sink == sink {
 		close(wrapper.InputChan)
 		delete(group.apps[appId], sink.Identifier())
 		return true
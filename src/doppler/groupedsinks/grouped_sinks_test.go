@@ -6,7 +6,9 @@ import (
 	"doppler/sinks/containermetric"
 	"doppler/sinks/dump"
 	"doppler/sinks/syslog"
+	"doppler/sinks/syslogwriter"
 	"doppler/sinks/websocket"
+	"marshalled"
 	"net/url"
 	"time"
 
@@ -20,26 +22,27 @@ import (
 
 var _ = Describe("GroupedSink", func() {
 	var groupedSinks *groupedsinks.GroupedSinks
-	var inputChan chan *events.Envelope
+	var inputChan chan *marshalled.Envelope
 
 	BeforeEach(func() {
-		groupedSinks = groupedsinks.NewGroupedSinks()
-		inputChan = make(chan *events.Envelope, 10)
+		groupedSinks = groupedsinks.NewGroupedSinks("dropsonde-origin")
+		inputChan = make(chan *marshalled.Envelope, 10)
 	})
 
 	Describe("Broadcast", func() {
 		Context("when all pre-existing firehose connections have been deleted", func() {
 			It("sends message to all registered app sinks", func() {
 				firehoseSink := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
-				firehoseSinkChan := make(chan *events.Envelope, 2)
+				firehoseSinkChan := make(chan *marshalled.Envelope, 2)
 				groupedSinks.RegisterFirehoseSink(firehoseSinkChan, firehoseSink)
 
 				groupedSinks.CloseAndDeleteFirehose(firehoseSink)
 				appSink := syslog.NewSyslogSink("123", &url.URL{Host: "url"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
-				appSinkInputChan := make(chan *events.Envelope)
+				appSinkInputChan := make(chan *marshalled.Envelope)
 				groupedSinks.RegisterAppSink(appSinkInputChan, appSink)
 
-				msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "123", "App"), "origin")
+				envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "123", "App"), "origin")
+				msg := marshalled.New(envelope)
 				go groupedSinks.Broadcast("123", msg)
 
 				Expect(<-appSinkInputChan).To(Equal(msg))
@@ -50,7 +53,7 @@ var _ = Describe("GroupedSink", func() {
 			appId := "123"
 			appSink := syslog.NewSyslogSink("123", &url.URL{Host: "url"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
 
-			otherInputChan := make(chan *events.Envelope)
+			otherInputChan := make(chan *marshalled.Envelope)
 			groupedSinks.RegisterAppSink(otherInputChan, appSink)
 
 			appId = "789"
@@ -58,7 +61,8 @@ var _ = Describe("GroupedSink", func() {
 
 			groupedSinks.RegisterAppSink(inputChan, appSink)
 
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+			msg := marshalled.New(envelope)
 			go groupedSinks.Broadcast(appId, msg)
 
 			Expect(<-inputChan).To(Equal(msg))
@@ -68,14 +72,15 @@ var _ = Describe("GroupedSink", func() {
 
 		It("sends message to all registered firehose subscribers", func() {
 			fakeSink1 := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
-			inputChan1 := make(chan *events.Envelope, 2)
+			inputChan1 := make(chan *marshalled.Envelope, 2)
 			groupedSinks.RegisterFirehoseSink(inputChan1, fakeSink1)
 
 			fakeSink2 := &fakeSink{sinkId: "sink2", appId: "firehose-b"}
-			inputChan2 := make(chan *events.Envelope, 2)
+			inputChan2 := make(chan *marshalled.Envelope, 2)
 			groupedSinks.RegisterFirehoseSink(inputChan2, fakeSink2)
 
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			msg := marshalled.New(envelope)
 			go groupedSinks.Broadcast("app-id", msg)
 
 			Eventually(inputChan2).Should(Receive(Equal(msg)))
@@ -84,18 +89,19 @@ var _ = Describe("GroupedSink", func() {
 
 		It("distributes messages to all firehose sinks with the same subscription id", func() {
 			fakeSink1A := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
-			inputChan1A := make(chan *events.Envelope, 100)
+			inputChan1A := make(chan *marshalled.Envelope, 100)
 			groupedSinks.RegisterFirehoseSink(inputChan1A, fakeSink1A)
 
 			fakeSink2A := &fakeSink{sinkId: "sink2", appId: "firehose-a"}
-			inputChan2A := make(chan *events.Envelope, 100)
+			inputChan2A := make(chan *marshalled.Envelope, 100)
 			groupedSinks.RegisterFirehoseSink(inputChan2A, fakeSink2A)
 
 			fakeSinkB := &fakeSink{sinkId: "sink3", appId: "firehose-b"}
-			inputChanB := make(chan *events.Envelope, 100)
+			inputChanB := make(chan *marshalled.Envelope, 100)
 			groupedSinks.RegisterFirehoseSink(inputChanB, fakeSinkB)
 
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			msg := marshalled.New(envelope)
 			for i := 0; i < 100; i++ {
 				go groupedSinks.Broadcast("app-id", msg)
 			}
@@ -119,7 +125,8 @@ var _ = Describe("GroupedSink", func() {
 
 		It("does not block when sending to an appId that has no sinks", func(done Done) {
 			appId := "NonExistantApp"
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+			msg := marshalled.New(envelope)
 			groupedSinks.Broadcast(appId, msg)
 			close(done)
 		})
@@ -127,13 +134,14 @@ var _ = Describe("GroupedSink", func() {
 		It("does not block when sending to slow sink", func() {
 			appId := "syslog-a"
 			fakeSink1A := &fakeSink{sinkId: "sink1", appId: appId}
-			inputChan1A := make(chan *events.Envelope)
+			inputChan1A := make(chan *marshalled.Envelope)
 			groupedSinks.RegisterAppSink(inputChan1A, fakeSink1A)
 
 			c := make(chan struct{})
 			go func() {
 				defer close(c)
-				msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+				envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+				msg := marshalled.New(envelope)
 				groupedSinks.Broadcast(appId, msg)
 			}()
 
@@ -145,14 +153,15 @@ var _ = Describe("GroupedSink", func() {
 		It("sends message to all registered sinks that match the appId", func(done Done) {
 			appId := "123"
 			appSink := dump.NewDumpSink(appId, 10, time.Second)
-			otherInputChan := make(chan *events.Envelope)
+			otherInputChan := make(chan *marshalled.Envelope)
 			groupedSinks.RegisterAppSink(otherInputChan, appSink)
 
 			appId = "789"
 			appSink = dump.NewDumpSink(appId, 10, time.Second)
 
 			groupedSinks.RegisterAppSink(inputChan, appSink)
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "error message", appId, "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "error message", appId, "App"), "origin")
+			msg := marshalled.New(envelope)
 			go groupedSinks.BroadcastError(appId, msg)
 
 			Expect(<-inputChan).To(Equal(msg))
@@ -162,14 +171,15 @@ var _ = Describe("GroupedSink", func() {
 
 		It("sends message to all registered firehose subscribers", func() {
 			fakeSink1 := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
-			inputChan1 := make(chan *events.Envelope, 2)
+			inputChan1 := make(chan *marshalled.Envelope, 2)
 			groupedSinks.RegisterFirehoseSink(inputChan1, fakeSink1)
 
 			fakeSink2 := &fakeSink{sinkId: "sink2", appId: "firehose-b"}
-			inputChan2 := make(chan *events.Envelope, 2)
+			inputChan2 := make(chan *marshalled.Envelope, 2)
 			groupedSinks.RegisterFirehoseSink(inputChan2, fakeSink2)
 
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "app-id", "App"), "origin")
+			msg := marshalled.New(envelope)
 			go groupedSinks.BroadcastError("app-id", msg)
 
 			Eventually(inputChan2).Should(Receive(Equal(msg)))
@@ -184,7 +194,8 @@ var _ = Describe("GroupedSink", func() {
 
 			groupedSinks.RegisterAppSink(inputChan, sink1)
 			groupedSinks.RegisterAppSink(inputChan, sink2)
-			msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "error message", appId, "App"), "origin")
+			envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "error message", appId, "App"), "origin")
+			msg := marshalled.New(envelope)
 			go groupedSinks.BroadcastError(appId, msg)
 			Eventually(inputChan).Should(Receive(Equal(msg)))
 			Expect(inputChan).To(HaveLen(0))
@@ -193,13 +204,14 @@ var _ = Describe("GroupedSink", func() {
 		It("does not block when sending to slow sink", func() {
 			appId := "syslog-a"
 			fakeSink1A := &fakeSink{sinkId: "sink1", appId: appId, shouldRxErrors: true}
-			inputChan1A := make(chan *events.Envelope)
+			inputChan1A := make(chan *marshalled.Envelope)
 			groupedSinks.RegisterAppSink(inputChan1A, fakeSink1A)
 
 			c := make(chan struct{})
 			go func() {
 				defer close(c)
-				msg, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+				envelope, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", appId, "App"), "origin")
+				msg := marshalled.New(envelope)
 				groupedSinks.BroadcastError(appId, msg)
 			}()
 
@@ -286,6 +298,20 @@ var _ = Describe("GroupedSink", func() {
 			Expect(groupedSinks.CountFor(target)).To(Equal(1))
 		})
 
+		It("does not delete a different sink registered with the same identifier", func() {
+			target := "789"
+
+			sink1 := syslog.NewSyslogSink(target, &url.URL{Host: "url1"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
+			sink2 := syslog.NewSyslogSink(target, &url.URL{Host: "url1"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
+
+			groupedSinks.RegisterAppSink(inputChan, sink1)
+
+			ok := groupedSinks.CloseAndDelete(sink2)
+			Expect(ok).To(BeFalse())
+
+			Expect(groupedSinks.DrainFor(target, sink1.Identifier())).To(Equal(sink1))
+		})
+
 		It("closes the inputChan", func() {
 			target := "789"
 			sink := syslog.NewSyslogSink(target, &url.URL{Host: "url1"}, 100, DummySyslogWriter{}, dummyErrorHandler, "dropsonde-origin")
@@ -302,18 +328,18 @@ var _ = Describe("GroupedSink", func() {
 			fakeSink1 := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
 			fakeSink2 := &fakeSink{sinkId: "sink2", appId: "firehose-a"}
 
-			groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), fakeSink1)
-			groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), fakeSink2)
+			groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), fakeSink1)
+			groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), fakeSink2)
 
 			ok := groupedSinks.CloseAndDeleteFirehose(fakeSink1)
 			Expect(ok).To(BeTrue())
-			Expect(groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), fakeSink1)).To(BeTrue())
-			Expect(groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), fakeSink2)).To(BeFalse())
+			Expect(groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), fakeSink1)).To(BeTrue())
+			Expect(groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), fakeSink2)).To(BeFalse())
 		})
 
 		It("closes the sink's input channel", func() {
 			fakeSink1 := &fakeSink{sinkId: "sink1", appId: "firehose-a"}
-			inputChan1 := make(chan *events.Envelope)
+			inputChan1 := make(chan *marshalled.Envelope)
 
 			groupedSinks.RegisterFirehoseSink(inputChan1, fakeSink1)
 
@@ -334,15 +360,15 @@ var _ = Describe("GroupedSink", func() {
 			sink2 := &fakeSink{sinkId: "sink2", appId: "app2"}
 			sink3 := &fakeSink{sinkId: "sink3", appId: "firehose-a"}
 
-			groupedSinks.RegisterAppSink(make(chan *events.Envelope), sink1)
-			groupedSinks.RegisterAppSink(make(chan *events.Envelope), sink2)
-			groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), sink3)
+			groupedSinks.RegisterAppSink(make(chan *marshalled.Envelope), sink1)
+			groupedSinks.RegisterAppSink(make(chan *marshalled.Envelope), sink2)
+			groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), sink3)
 
 			groupedSinks.DeleteAll()
 
 			Expect(groupedSinks.CountFor("123")).To(BeZero())
 			Expect(groupedSinks.CountFor("465")).To(BeZero())
-			Expect(groupedSinks.RegisterFirehoseSink(make(chan *events.Envelope), sink3)).To(BeTrue())
+			Expect(groupedSinks.RegisterFirehoseSink(make(chan *marshalled.Envelope), sink3)).To(BeTrue())
 		})
 
 		It("closes all the sinks input chans", func() {
@@ -350,7 +376,7 @@ var _ = Describe("GroupedSink", func() {
 			sink2 := &fakeSink{sinkId: "sink2", appId: "firehose-a"}
 
 			groupedSinks.RegisterAppSink(inputChan, sink1)
-			firehoseInputChan := make(chan *events.Envelope)
+			firehoseInputChan := make(chan *marshalled.Envelope)
 			groupedSinks.RegisterFirehoseSink(firehoseInputChan, sink2)
 
 			groupedSinks.DeleteAll()
@@ -360,6 +386,25 @@ var _ = Describe("GroupedSink", func() {
 		})
 	})
 
+	Describe("AppSinks", func() {
+		It("returns the app sinks keyed by app id", func() {
+			sink1 := &fakeSink{sinkId: "sink1", appId: "app1"}
+			sink2 := &fakeSink{sinkId: "sink2", appId: "app1"}
+			sink3 := &fakeSink{sinkId: "sink3", appId: "app2"}
+			sink4 := &fakeSink{sinkId: "sink4", appId: "firehose-a"}
+
+			groupedSinks.RegisterAppSink(inputChan, sink1)
+			groupedSinks.RegisterAppSink(inputChan, sink2)
+			groupedSinks.RegisterAppSink(inputChan, sink3)
+			groupedSinks.RegisterFirehoseSink(inputChan, sink4)
+
+			appSinks := groupedSinks.AppSinks()
+			Expect(appSinks).To(HaveLen(2))
+			Expect(appSinks["app1"]).To(ConsistOf(sink1, sink2))
+			Expect(appSinks["app2"]).To(ConsistOf(sink3))
+		})
+	})
+
 	Describe("DrainsFor", func() {
 		It("does not return dump sinks", func() {
 			target := "789"
@@ -530,7 +575,10 @@ func dummyErrorHandler(_, _ string) {}
 type DummySyslogWriter struct{}
 
 func (d DummySyslogWriter) Connect() error { return nil }
-func (d DummySyslogWriter) Write(p int, b []byte, source, sourceId string, timestamp int64) (int, error) {
+func (d DummySyslogWriter) Write(p int, b []byte, source, sourceId string, timestamp int64, tags map[string]string) (int, error) {
+	return 0, nil
+}
+func (d DummySyslogWriter) WriteMetric(m syslogwriter.Metric, source, sourceId string, timestamp int64, tags map[string]string) (int, error) {
 	return 0, nil
 }
 func (d DummySyslogWriter) Close() error { return nil }
@@ -545,7 +593,7 @@ func (f *fakeSink) AppID() string {
 	return f.appId
 }
 
-func (f *fakeSink) Run(<-chan *events.Envelope) {
+func (f *fakeSink) Run(<-chan *marshalled.Envelope) {
 
 }
 
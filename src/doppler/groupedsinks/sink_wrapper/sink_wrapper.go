@@ -2,11 +2,10 @@ package sink_wrapper
 
 import (
 	"doppler/sinks"
-
-	"github.com/cloudfoundry/sonde-go/events"
+	"marshalled"
 )
 
 type SinkWrapper struct {
-	InputChan chan<- *events.Envelope
+	InputChan chan<- *marshalled.Envelope
 	Sink      sinks.Sink
 }
@@ -0,0 +1,66 @@
+package v1_test
+
+import (
+	"doppler/grpcmanager/v1"
+	"marshalled"
+	"plumbing"
+	"testing"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+const subscribers = 20
+
+// marshallingSetter marshals every envelope it is given, as the gRPC
+// subscription streams do before sending it.
+type marshallingSetter struct {
+	b       *testing.B
+	marshal func(*marshalled.Envelope) ([]byte, error)
+}
+
+func (s marshallingSetter) Set(envelope *marshalled.Envelope) {
+	if _, err := s.marshal(envelope); err != nil {
+		s.b.Fatal(err)
+	}
+}
+
+// BenchmarkFanOutMarshalEach measures routing an envelope to many firehose
+// subscriptions when every subscription marshals it.
+func BenchmarkFanOutMarshalEach(b *testing.B) {
+	benchmarkFanOut(b, func(e *marshalled.Envelope) ([]byte, error) {
+		return proto.Marshal(e.Envelope)
+	})
+}
+
+// BenchmarkFanOutShared measures routing an envelope to many firehose
+// subscriptions when they share the bytes of the wrapped envelope.
+func BenchmarkFanOutShared(b *testing.B) {
+	benchmarkFanOut(b, func(e *marshalled.Envelope) ([]byte, error) {
+		return e.Marshal()
+	})
+}
+
+func benchmarkFanOut(b *testing.B, marshal func(*marshalled.Envelope) ([]byte, error)) {
+	router := v1.NewRouter()
+	for i := 0; i < subscribers; i++ {
+		router.Register(&plumbing.SubscriptionRequest{}, marshallingSetter{b: b, marshal: marshal})
+	}
+
+	envelope := &events.Envelope{
+		Origin:    proto.String("some-origin"),
+		EventType: events.Envelope_LogMessage.Enum(),
+		LogMessage: &events.LogMessage{
+			Message:     []byte("some-message"),
+			MessageType: events.LogMessage_OUT.Enum(),
+			Timestamp:   proto.Int64(1),
+			AppId:       proto.String("some-app-id"),
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.SendTo("some-app-id", marshalled.New(envelope))
+	}
+}
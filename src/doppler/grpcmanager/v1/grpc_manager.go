@@ -2,14 +2,14 @@ package v1
 
 import (
 	"diodes"
-	"log"
+	"doppler/sinks/syslog"
+	"marshalled"
+	"net/url"
 	"plumbing"
 	"sync/atomic"
 	"time"
 
 	"github.com/cloudfoundry/dropsonde/metrics"
-	"github.com/cloudfoundry/sonde-go/events"
-	"github.com/gogo/protobuf/proto"
 
 	"golang.org/x/net/context"
 )
@@ -23,22 +23,34 @@ type Registrar interface {
 	Register(req *plumbing.SubscriptionRequest, setter DataSetter) func()
 }
 
-// DataSetter accepts writes of marshalled data.
+// DataSetter accepts writes of envelopes.
 type DataSetter interface {
-	Set(data []byte)
+	Set(envelope *marshalled.Envelope)
 }
 
-// DataDumper dumps Envelopes for container metrics and recent logs requests.
+// DataDumper dumps Envelopes for container metrics, container metrics
+// history and recent logs requests, and lists an app's drains for drain
+// status requests.
 type DataDumper interface {
-	LatestContainerMetrics(appID string) []*events.Envelope
-	RecentLogsFor(appID string) []*events.Envelope
+	LatestContainerMetrics(appID string) []*marshalled.Envelope
+	RecentLogsFor(appID string) []*marshalled.Envelope
+	ContainerMetricsHistory(appID string, startTime int64) []*marshalled.Envelope
+	DrainsFor(appID string) []*syslog.SyslogSink
+}
+
+// LatencyRecorder records the time envelopes spend in Doppler, from being
+// received by its envelope buffer to being sent to a subscriber.
+type LatencyRecorder interface {
+	Record(time.Duration)
 }
 
 // GRPCManager is the GRPC server component that accepts requests for firehose
-// streams, application streams, container metrics, and recent logs.
+// streams, application streams, container metrics, container metrics history,
+// recent logs and drain status.
 type GRPCManager struct {
 	registrar        Registrar
 	dumper           DataDumper
+	latency          LatencyRecorder
 	numSubscriptions int64
 }
 
@@ -48,10 +60,11 @@ type sender interface {
 }
 
 // New creates a new GRPCManager.
-func New(registrar Registrar, dumper DataDumper) *GRPCManager {
+func New(registrar Registrar, dumper DataDumper, latency LatencyRecorder) *GRPCManager {
 	m := &GRPCManager{
 		registrar: registrar,
 		dumper:    dumper,
+		latency:   latency,
 	}
 
 	go m.emitMetrics()
@@ -74,69 +87,122 @@ func (m *GRPCManager) ContainerMetrics(ctx context.Context, req *plumbing.Contai
 	}, nil
 }
 
-// RecentLogs is called by GRPC on recent logs requests.
+// RecentLogs is called by GRPC on recent logs requests. Only the logs that
+// match the request's filters are returned.
 func (m *GRPCManager) RecentLogs(ctx context.Context, req *plumbing.RecentLogsRequest) (*plumbing.RecentLogsResponse, error) {
-	envelopes := m.dumper.RecentLogsFor(req.AppID)
+	envelopes := filterRecentLogs(req, m.dumper.RecentLogsFor(req.AppID))
 	return &plumbing.RecentLogsResponse{
 		Payload: marshalEnvelopes(envelopes),
 	}, nil
 }
 
+// ContainerMetricsHistory is called by GRPC on container metrics history
+// requests.
+func (m *GRPCManager) ContainerMetricsHistory(ctx context.Context, req *plumbing.ContainerMetricsHistoryRequest) (*plumbing.ContainerMetricsHistoryResponse, error) {
+	envelopes := m.dumper.ContainerMetricsHistory(req.AppID, req.StartTime)
+	return &plumbing.ContainerMetricsHistoryResponse{
+		Payload: marshalEnvelopes(envelopes),
+	}, nil
+}
+
+// DrainStatus is called by GRPC on drain status requests.
+func (m *GRPCManager) DrainStatus(ctx context.Context, req *plumbing.DrainStatusRequest) (*plumbing.DrainStatusResponse, error) {
+	resp := &plumbing.DrainStatusResponse{}
+	for _, drain := range m.dumper.DrainsFor(req.AppID) {
+		status := drain.Status()
+
+		var lastSuccess int64
+		if !status.LastSuccess.IsZero() {
+			lastSuccess = status.LastSuccess.UnixNano()
+		}
+
+		resp.Drains = append(resp.Drains, &plumbing.DrainStatus{
+			Url:             strippedURL(drain.Identifier()),
+			DrainID:         drain.DrainID(),
+			State:           drainState(status.State),
+			LastError:       status.LastError,
+			LastSuccess:     lastSuccess,
+			SentMessages:    status.SentMessages,
+			DroppedMessages: status.DroppedMessages,
+			Errors:          status.Errors,
+			Reconnects:      status.Reconnects,
+		})
+	}
+	return resp, nil
+}
+
 func (m *GRPCManager) emitMetrics() {
 	for range time.Tick(metricsInterval) {
 		metrics.SendValue("grpcManager.subscriptions", float64(atomic.LoadInt64(&m.numSubscriptions)), "subscriptions")
 	}
 }
 
-func marshalEnvelopes(envelopes []*events.Envelope) [][]byte {
-	var marshalled [][]byte
+func marshalEnvelopes(envelopes []*marshalled.Envelope) [][]byte {
+	var payload [][]byte
 	for _, env := range envelopes {
-		bts, err := proto.Marshal(env)
+		bts, err := env.Marshal()
 		if err != nil {
 			continue
 		}
-		marshalled = append(marshalled, bts)
+		payload = append(payload, bts)
 	}
-	return marshalled
+	return payload
 }
 
 func (m *GRPCManager) sendData(req *plumbing.SubscriptionRequest, sender sender) error {
-	d := diodes.NewOneToOne(1000, m)
+	d := diodes.NewWaitingOneToOneMarshalled(
+		sender.Context(),
+		1000,
+		diodes.NewMetricAlerter("grpcManager.droppedEnvelopes"),
+	)
 	cleanup := m.registrar.Register(req, d)
 	defer cleanup()
 
-	var done int64
-	go m.monitorContext(sender.Context(), &done)
-
 	for {
-		if atomic.LoadInt64(&done) > 0 {
-			break
+		envelope := d.Next()
+		if envelope == nil {
+			return sender.Context().Err()
 		}
 
-		data, ok := d.TryNext()
-		if !ok {
-			time.Sleep(10 * time.Millisecond)
+		data, err := envelope.Marshal()
+		if err != nil {
 			continue
 		}
 
-		err := sender.Send(&plumbing.Response{
-			Payload: data,
+		if received, ok := envelope.Received(); ok {
+			m.latency.Record(time.Since(received))
+		}
+
+		err = sender.Send(&plumbing.Response{
+			Payload:         data,
+			EgressTimestamp: time.Now().UnixNano(),
 		})
 
 		if err != nil {
 			return err
 		}
 	}
-
-	return sender.Context().Err()
 }
 
-// Alert logs dropped message counts to stderr.
-func (m *GRPCManager) Alert(missed int) {
-	log.Printf("Dropped %d envelopes", missed)
+// strippedURL returns the drain URL without its credentials or query, which
+// may hold secrets, so that it can be handed out through the API.
+func strippedURL(drainURL string) string {
+	u, err := url.Parse(drainURL)
+	if err != nil {
+		return ""
+	}
+
+	stripped := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	return stripped.String()
 }
 
-func (m *GRPCManager) monitorContext(ctx context.Context, done *int64) {
-	<-ctx.Done()
-	atomic.StoreInt64(done, 1)
+func drainState(state syslog.State) plumbing.DrainStatus_State {
+	switch state {
+	case syslog.Connected:
+		return plumbing.DrainStatus_CONNECTED
+	case syslog.BackingOff:
+		return plumbing.DrainStatus_BACKING_OFF
+	default:
+		return plumbing.DrainStatus_CONNECTING
+	}
 }
@@ -2,8 +2,11 @@ package v1_test
 
 import (
 	"doppler/grpcmanager/v1"
+	"doppler/sinks/syslog"
 	"io"
+	"marshalled"
 	"net"
+	"net/url"
 	"plumbing"
 	"time"
 
@@ -28,6 +31,7 @@ var _ = Describe("GRPCManager", func() {
 		mockCleanup    func()
 		cleanupCalled  chan struct{}
 		mockDataDumper *mockDataDumper
+		mockLatency    *mockLatencyRecorder
 
 		manager       *v1.GRPCManager
 		listener      net.Listener
@@ -86,7 +90,9 @@ var _ = Describe("GRPCManager", func() {
 		mockRegistrar.RegisterOutput.Ret0 <- mockCleanup
 		mockDataDumper = newMockDataDumper()
 
-		manager = v1.New(mockRegistrar, mockDataDumper)
+		mockLatency = newMockLatencyRecorder()
+
+		manager = v1.New(mockRegistrar, mockDataDumper, mockLatency)
 
 		listener = startGRPCServer(manager)
 		dopplerClient, connCloser = establishClient(listener.Addr().String())
@@ -174,23 +180,54 @@ var _ = Describe("GRPCManager", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			setter = fetchSetter()
-			setter.Set([]byte("some-data-0"))
-			setter.Set([]byte("some-data-1"))
-			setter.Set([]byte("some-data-2"))
+			var expected [][]byte
+			for i := 0; i < 3; i++ {
+				envelope, data := buildLogMessage()
+				setter.Set(envelope)
+				expected = append(expected, data)
+			}
 
 			c := readFromReceiver(rx)
-			Eventually(c).Should(BeCalled(With(
-				[]byte("some-data-0"),
-				[]byte("some-data-1"),
-				[]byte("some-data-2"),
-			)))
+			for _, data := range expected {
+				Eventually(c).Should(Receive(Equal(data)))
+			}
+		})
+
+		It("skips envelopes that cannot be marshalled", func() {
+			rx, err := dopplerClient.Subscribe(context.TODO(), subscribeRequest)
+			Expect(err).ToNot(HaveOccurred())
+
+			envelope, data := buildLogMessage()
+			setter = fetchSetter()
+			setter.Set(marshalled.New(&events.Envelope{}))
+			setter.Set(envelope)
+
+			Eventually(readFromReceiver(rx)).Should(Receive(Equal(data)))
+		})
+
+		It("sends the egress timestamp and records the time since Doppler received the envelope", func() {
+			rx, err := dopplerClient.Subscribe(context.TODO(), subscribeRequest)
+			Expect(err).ToNot(HaveOccurred())
+
+			envelope, data := buildLogMessage()
+			setter = fetchSetter()
+			setter.Set(envelope)
+
+			resp, err := rx.Recv()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Payload).To(Equal(data))
+			Expect(time.Unix(0, resp.EgressTimestamp)).To(BeTemporally("~", time.Now(), time.Second))
+
+			var elapsed time.Duration
+			Eventually(mockLatency.RecordInput.Arg0).Should(Receive(&elapsed))
+			Expect(elapsed).To(BeNumerically("<", time.Second))
 		})
 	})
 
 	Describe("container metrics", func() {
 		It("returns container metrics from its data dumper", func() {
 			envelope, data := buildContainerMetric()
-			mockDataDumper.LatestContainerMetricsOutput.Ret0 <- []*events.Envelope{
+			mockDataDumper.LatestContainerMetricsOutput.Ret0 <- []*marshalled.Envelope{
 				envelope,
 			}
 
@@ -208,8 +245,8 @@ var _ = Describe("GRPCManager", func() {
 
 		It("throw away invalid envelopes from its data dumper", func() {
 			envelope, _ := buildContainerMetric()
-			mockDataDumper.LatestContainerMetricsOutput.Ret0 <- []*events.Envelope{
-				{},
+			mockDataDumper.LatestContainerMetricsOutput.Ret0 <- []*marshalled.Envelope{
+				marshalled.New(&events.Envelope{}),
 				envelope,
 			}
 
@@ -221,10 +258,61 @@ var _ = Describe("GRPCManager", func() {
 		})
 	})
 
+	Describe("container metrics history", func() {
+		It("returns the container metrics history from its data dumper", func() {
+			envelope, data := buildContainerMetric()
+			mockDataDumper.ContainerMetricsHistoryOutput.Ret0 <- []*marshalled.Envelope{
+				envelope,
+			}
+
+			resp, err := dopplerClient.ContainerMetricsHistory(context.TODO(),
+				&plumbing.ContainerMetricsHistoryRequest{AppID: "some-app", StartTime: 99})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Payload).To(ContainElement(data))
+			Expect(mockDataDumper.ContainerMetricsHistoryInput).To(BeCalled(
+				With("some-app", int64(99)),
+			))
+		})
+	})
+
+	Describe("drain status", func() {
+		It("returns the status of the app's drains from its data dumper", func() {
+			drain := syslog.NewSyslogSink(
+				"some-app",
+				&url.URL{
+					Scheme:   "syslog",
+					User:     url.UserPassword("user", "secret"),
+					Host:     "drain.example.com:514",
+					Path:     "/some-path",
+					RawQuery: "token=secret",
+				},
+				100,
+				nil,
+				func(string, string) {},
+				"origin",
+			)
+			mockDataDumper.DrainsForOutput.Ret0 <- []*syslog.SyslogSink{drain}
+
+			resp, err := dopplerClient.DrainStatus(context.TODO(),
+				&plumbing.DrainStatusRequest{AppID: "some-app"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.Drains).To(HaveLen(1))
+			Expect(resp.Drains[0].Url).To(Equal("syslog://drain.example.com:514/some-path"))
+			Expect(resp.Drains[0].DrainID).To(Equal(drain.DrainID()))
+			Expect(resp.Drains[0].State).To(Equal(plumbing.DrainStatus_CONNECTING))
+			Expect(resp.Drains[0].LastSuccess).To(BeZero())
+			Expect(mockDataDumper.DrainsForInput).To(BeCalled(
+				With("some-app"),
+			))
+		})
+	})
+
 	Describe("recent logs", func() {
 		It("returns recent logs from its data dumper", func() {
 			envelope, data := buildLogMessage()
-			mockDataDumper.RecentLogsForOutput.Ret0 <- []*events.Envelope{
+			mockDataDumper.RecentLogsForOutput.Ret0 <- []*marshalled.Envelope{
 				envelope,
 			}
 			resp, err := dopplerClient.RecentLogs(context.TODO(),
@@ -240,8 +328,8 @@ var _ = Describe("GRPCManager", func() {
 
 		It("throw away invalid envelopes from its data dumper", func() {
 			envelope, _ := buildLogMessage()
-			mockDataDumper.RecentLogsForOutput.Ret0 <- []*events.Envelope{
-				{},
+			mockDataDumper.RecentLogsForOutput.Ret0 <- []*marshalled.Envelope{
+				marshalled.New(&events.Envelope{}),
 				envelope,
 			}
 
@@ -251,10 +339,83 @@ var _ = Describe("GRPCManager", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(resp.Payload).To(HaveLen(1))
 		})
+
+		Context("with filters", func() {
+			var (
+				first, second, third []byte
+			)
+
+			BeforeEach(func() {
+				var e1, e2, e3 *marshalled.Envelope
+				e1, first = buildLogMessageWith(100, "APP", events.LogMessage_OUT)
+				e2, second = buildLogMessageWith(200, "RTR", events.LogMessage_OUT)
+				e3, third = buildLogMessageWith(300, "APP", events.LogMessage_ERR)
+				mockDataDumper.RecentLogsForOutput.Ret0 <- []*marshalled.Envelope{
+					e1, e2, e3,
+				}
+			})
+
+			It("returns logs within the time range", func() {
+				resp, err := dopplerClient.RecentLogs(context.TODO(),
+					&plumbing.RecentLogsRequest{
+						AppID:     "some-app",
+						StartTime: 200,
+						EndTime:   300,
+					})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Payload).To(Equal([][]byte{second}))
+			})
+
+			It("returns the most recent logs up to the limit", func() {
+				resp, err := dopplerClient.RecentLogs(context.TODO(),
+					&plumbing.RecentLogsRequest{
+						AppID: "some-app",
+						Limit: 2,
+					})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Payload).To(Equal([][]byte{second, third}))
+			})
+
+			It("returns logs with the given source types", func() {
+				resp, err := dopplerClient.RecentLogs(context.TODO(),
+					&plumbing.RecentLogsRequest{
+						AppID:       "some-app",
+						SourceTypes: []string{"APP"},
+					})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Payload).To(Equal([][]byte{first, third}))
+			})
+
+			It("returns logs with the given log types", func() {
+				resp, err := dopplerClient.RecentLogs(context.TODO(),
+					&plumbing.RecentLogsRequest{
+						AppID:    "some-app",
+						LogTypes: []plumbing.RecentLogsRequest_LogType{plumbing.RecentLogsRequest_ERR},
+					})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Payload).To(Equal([][]byte{third}))
+			})
+
+			It("applies the limit after the other filters", func() {
+				resp, err := dopplerClient.RecentLogs(context.TODO(),
+					&plumbing.RecentLogsRequest{
+						AppID:       "some-app",
+						SourceTypes: []string{"APP"},
+						Limit:       1,
+					})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.Payload).To(Equal([][]byte{third}))
+			})
+		})
 	})
 })
 
-func buildContainerMetric() (*events.Envelope, []byte) {
+func buildContainerMetric() (*marshalled.Envelope, []byte) {
 	envelope := &events.Envelope{
 		Origin:    proto.String("doppler"),
 		EventType: events.Envelope_ContainerMetric.Enum(),
@@ -269,10 +430,10 @@ func buildContainerMetric() (*events.Envelope, []byte) {
 	}
 	data, err := proto.Marshal(envelope)
 	Expect(err).ToNot(HaveOccurred())
-	return envelope, data
+	return marshalled.New(envelope), data
 }
 
-func buildLogMessage() (*events.Envelope, []byte) {
+func buildLogMessage() (*marshalled.Envelope, []byte) {
 	envelope := &events.Envelope{
 		Origin:    proto.String("doppler"),
 		EventType: events.Envelope_LogMessage.Enum(),
@@ -285,5 +446,22 @@ func buildLogMessage() (*events.Envelope, []byte) {
 	}
 	data, err := proto.Marshal(envelope)
 	Expect(err).ToNot(HaveOccurred())
-	return envelope, data
+	return marshalled.New(envelope), data
+}
+
+func buildLogMessageWith(timestamp int64, sourceType string, messageType events.LogMessage_MessageType) (*marshalled.Envelope, []byte) {
+	envelope := &events.Envelope{
+		Origin:    proto.String("doppler"),
+		EventType: events.Envelope_LogMessage.Enum(),
+		Timestamp: proto.Int64(timestamp),
+		LogMessage: &events.LogMessage{
+			Message:     []byte("some-log-message"),
+			MessageType: messageType.Enum(),
+			Timestamp:   proto.Int64(timestamp),
+			SourceType:  proto.String(sourceType),
+		},
+	}
+	data, err := proto.Marshal(envelope)
+	Expect(err).ToNot(HaveOccurred())
+	return marshalled.New(envelope), data
 }
@@ -7,6 +7,8 @@ package v1_test
 
 import (
 	"doppler/grpcmanager/v1"
+	"doppler/sinks/syslog"
+	"marshalled"
 	"plumbing"
 	"time"
 
@@ -45,19 +47,37 @@ func (m *mockRegistrar) Register(req *plumbing.SubscriptionRequest, setter v1.Da
 type mockDataSetter struct {
 	SetCalled chan bool
 	SetInput  struct {
-		Data chan []byte
+		Envelope chan *marshalled.Envelope
 	}
 }
 
 func newMockDataSetter() *mockDataSetter {
 	m := &mockDataSetter{}
 	m.SetCalled = make(chan bool, 100)
-	m.SetInput.Data = make(chan []byte, 100)
+	m.SetInput.Envelope = make(chan *marshalled.Envelope, 100)
 	return m
 }
-func (m *mockDataSetter) Set(data []byte) {
+func (m *mockDataSetter) Set(envelope *marshalled.Envelope) {
 	m.SetCalled <- true
-	m.SetInput.Data <- data
+	m.SetInput.Envelope <- envelope
+}
+
+type mockLatencyRecorder struct {
+	RecordCalled chan bool
+	RecordInput  struct {
+		Arg0 chan time.Duration
+	}
+}
+
+func newMockLatencyRecorder() *mockLatencyRecorder {
+	m := &mockLatencyRecorder{}
+	m.RecordCalled = make(chan bool, 100)
+	m.RecordInput.Arg0 = make(chan time.Duration, 100)
+	return m
+}
+func (m *mockLatencyRecorder) Record(arg0 time.Duration) {
+	m.RecordCalled <- true
+	m.RecordInput.Arg0 <- arg0
 }
 
 type mockDataDumper struct {
@@ -66,14 +86,29 @@ type mockDataDumper struct {
 		AppID chan string
 	}
 	LatestContainerMetricsOutput struct {
-		Ret0 chan []*events.Envelope
+		Ret0 chan []*marshalled.Envelope
 	}
 	RecentLogsForCalled chan bool
 	RecentLogsForInput  struct {
 		AppID chan string
 	}
 	RecentLogsForOutput struct {
-		Ret0 chan []*events.Envelope
+		Ret0 chan []*marshalled.Envelope
+	}
+	ContainerMetricsHistoryCalled chan bool
+	ContainerMetricsHistoryInput  struct {
+		AppID     chan string
+		StartTime chan int64
+	}
+	ContainerMetricsHistoryOutput struct {
+		Ret0 chan []*marshalled.Envelope
+	}
+	DrainsForCalled chan bool
+	DrainsForInput  struct {
+		AppID chan string
+	}
+	DrainsForOutput struct {
+		Ret0 chan []*syslog.SyslogSink
 	}
 }
 
@@ -81,22 +116,40 @@ func newMockDataDumper() *mockDataDumper {
 	m := &mockDataDumper{}
 	m.LatestContainerMetricsCalled = make(chan bool, 100)
 	m.LatestContainerMetricsInput.AppID = make(chan string, 100)
-	m.LatestContainerMetricsOutput.Ret0 = make(chan []*events.Envelope, 100)
+	m.LatestContainerMetricsOutput.Ret0 = make(chan []*marshalled.Envelope, 100)
 	m.RecentLogsForCalled = make(chan bool, 100)
 	m.RecentLogsForInput.AppID = make(chan string, 100)
-	m.RecentLogsForOutput.Ret0 = make(chan []*events.Envelope, 100)
+	m.RecentLogsForOutput.Ret0 = make(chan []*marshalled.Envelope, 100)
+	m.ContainerMetricsHistoryCalled = make(chan bool, 100)
+	m.ContainerMetricsHistoryInput.AppID = make(chan string, 100)
+	m.ContainerMetricsHistoryInput.StartTime = make(chan int64, 100)
+	m.ContainerMetricsHistoryOutput.Ret0 = make(chan []*marshalled.Envelope, 100)
+	m.DrainsForCalled = make(chan bool, 100)
+	m.DrainsForInput.AppID = make(chan string, 100)
+	m.DrainsForOutput.Ret0 = make(chan []*syslog.SyslogSink, 100)
 	return m
 }
-func (m *mockDataDumper) LatestContainerMetrics(appID string) []*events.Envelope {
+func (m *mockDataDumper) LatestContainerMetrics(appID string) []*marshalled.Envelope {
 	m.LatestContainerMetricsCalled <- true
 	m.LatestContainerMetricsInput.AppID <- appID
 	return <-m.LatestContainerMetricsOutput.Ret0
 }
-func (m *mockDataDumper) RecentLogsFor(appID string) []*events.Envelope {
+func (m *mockDataDumper) RecentLogsFor(appID string) []*marshalled.Envelope {
 	m.RecentLogsForCalled <- true
 	m.RecentLogsForInput.AppID <- appID
 	return <-m.RecentLogsForOutput.Ret0
 }
+func (m *mockDataDumper) ContainerMetricsHistory(appID string, startTime int64) []*marshalled.Envelope {
+	m.ContainerMetricsHistoryCalled <- true
+	m.ContainerMetricsHistoryInput.AppID <- appID
+	m.ContainerMetricsHistoryInput.StartTime <- startTime
+	return <-m.ContainerMetricsHistoryOutput.Ret0
+}
+func (m *mockDataDumper) DrainsFor(appID string) []*syslog.SyslogSink {
+	m.DrainsForCalled <- true
+	m.DrainsForInput.AppID <- appID
+	return <-m.DrainsForOutput.Ret0
+}
 
 type mockSender struct {
 	SendCalled chan bool
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"context"
+	"doppler/losstracker"
 	"io"
 	"log"
 	"plumbing"
@@ -16,6 +17,7 @@ import (
 type IngestorManager struct {
 	sender  MessageSender
 	batcher Batcher
+	tracker *losstracker.LossTracker
 }
 
 type Batcher interface {
@@ -34,6 +36,7 @@ func NewIngestor(sender MessageSender, batcher Batcher) *IngestorManager {
 	return &IngestorManager{
 		sender:  sender,
 		batcher: batcher,
+		tracker: losstracker.New(batcher),
 	}
 }
 
@@ -61,6 +64,7 @@ func (i *IngestorManager) Pusher(pusher plumbing.DopplerIngestor_PusherServer) e
 			log.Printf("Received bad envelope: %s", err)
 			continue
 		}
+		i.tracker.TrackV1(env)
 		i.batcher.BatchCounter("listeners.receivedEnvelopes").
 			SetTag("protocol", "grpc").
 			SetTag("event_type", env.GetEventType().String()).
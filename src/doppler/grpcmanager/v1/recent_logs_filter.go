@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"marshalled"
+	"plumbing"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// filterRecentLogs returns the envelopes matching the request's time range,
+// source types and log types, keeping only the most recent when a limit is
+// given. Envelopes are expected to be oldest first.
+func filterRecentLogs(req *plumbing.RecentLogsRequest, envelopes []*marshalled.Envelope) []*marshalled.Envelope {
+	var filtered []*marshalled.Envelope
+	for _, e := range envelopes {
+		if matchesRecentLogsRequest(req, e.GetLogMessage()) {
+			filtered = append(filtered, e)
+		}
+	}
+
+	if req.Limit > 0 && len(filtered) > int(req.Limit) {
+		filtered = filtered[len(filtered)-int(req.Limit):]
+	}
+
+	return filtered
+}
+
+func matchesRecentLogsRequest(req *plumbing.RecentLogsRequest, msg *events.LogMessage) bool {
+	if req.StartTime != 0 && msg.GetTimestamp() < req.StartTime {
+		return false
+	}
+
+	if req.EndTime != 0 && msg.GetTimestamp() >= req.EndTime {
+		return false
+	}
+
+	if len(req.SourceTypes) > 0 && !containsString(req.SourceTypes, msg.GetSourceType()) {
+		return false
+	}
+
+	if len(req.LogTypes) > 0 && !containsLogType(req.LogTypes, msg.GetMessageType()) {
+		return false
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLogType(logTypes []plumbing.RecentLogsRequest_LogType, messageType events.LogMessage_MessageType) bool {
+	for _, t := range logTypes {
+		switch {
+		case t == plumbing.RecentLogsRequest_OUT && messageType == events.LogMessage_OUT:
+			return true
+		case t == plumbing.RecentLogsRequest_ERR && messageType == events.LogMessage_ERR:
+			return true
+		}
+	}
+	return false
+}
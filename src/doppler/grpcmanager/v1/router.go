@@ -1,8 +1,13 @@
 package v1
 
 import (
+	"doppler/affinity"
+	"fmt"
+	"marshalled"
 	"math/rand"
 	"plumbing"
+	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/cloudfoundry/sonde-go/events"
@@ -10,12 +15,39 @@ import (
 
 type Router struct {
 	lock          sync.RWMutex
-	subscriptions map[plumbing.Filter]map[string][]DataSetter
+	subscriptions map[string]map[subscriptionKey]*subscription
+	nextMemberID  uint64
+}
+
+// subscriptionKey identifies the setters that share a shard. Setters only
+// share a shard when they also share the same filter and shard type.
+type subscriptionKey struct {
+	shardID   string
+	shardType plumbing.SubscriptionRequest_ShardType
+	filter    string
+}
+
+// subscription holds the setters sharing a shard. Each setter has the member
+// ID given by its subscription request for app affinity sharding, so that
+// every Doppler assigns an app to the same subscriber. Setters registered
+// without one are given an ID local to this router.
+type subscription struct {
+	filter  *plumbing.Filter
+	setters []DataSetter
+	members []string
+}
+
+// Subscription describes the subscribers registered for a shard and filter.
+type Subscription struct {
+	ShardID     string
+	ShardType   plumbing.SubscriptionRequest_ShardType
+	Filter      *plumbing.Filter
+	Subscribers int
 }
 
 func NewRouter() *Router {
 	return &Router{
-		subscriptions: make(map[plumbing.Filter]map[string][]DataSetter),
+		subscriptions: make(map[string]map[subscriptionKey]*subscription),
 	}
 }
 
@@ -28,54 +60,85 @@ func (r *Router) Register(req *plumbing.SubscriptionRequest, dataSetter DataSett
 	return r.buildCleanup(req, dataSetter)
 }
 
-func (r *Router) SendTo(appID string, envelope *events.Envelope) {
+// SendTo writes the envelope to the subscriptions for the app and to the
+// firehose subscriptions whose filters it matches.
+func (r *Router) SendTo(appID string, envelope *marshalled.Envelope) {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 
-	data := r.marshal(envelope)
-
-	if data == nil {
-		return
+	send := func(subscriptions map[subscriptionKey]*subscription) {
+		for key, s := range subscriptions {
+			if matchesFilter(s.filter, envelope.Envelope) {
+				r.writeToShard(key, s, envelope)
+			}
+		}
 	}
 
-	filter := plumbing.Filter{
-		AppID: appID,
+	if appID != "" {
+		send(r.subscriptions[appID])
 	}
+	send(r.subscriptions[""])
+}
 
-	for shardID, setters := range r.subscriptions[filter] {
-		r.writeToShard(shardID, setters, data)
-	}
+// Subscriptions returns the registered subscriptions.
+func (r *Router) Subscriptions() []Subscription {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	var noFilter plumbing.Filter
-	for shardID, setters := range r.subscriptions[noFilter] {
-		r.writeToShard(shardID, setters, data)
+	var results []Subscription
+	for _, subscriptions := range r.subscriptions {
+		for key, s := range subscriptions {
+			results = append(results, Subscription{
+				ShardID:     key.shardID,
+				ShardType:   key.shardType,
+				Filter:      s.filter,
+				Subscribers: len(s.setters),
+			})
+		}
 	}
+
+	return results
 }
 
-func (r *Router) writeToShard(shardID string, setters []DataSetter, data []byte) {
-	if shardID == "" {
-		for _, setter := range setters {
-			setter.Set(data)
+func (r *Router) writeToShard(key subscriptionKey, s *subscription, envelope *marshalled.Envelope) {
+	if key.shardID == "" {
+		for _, setter := range s.setters {
+			setter.Set(envelope)
 		}
 		return
 	}
 
-	setters[rand.Intn(len(setters))].Set(data)
+	if key.shardType == plumbing.SubscriptionRequest_APP_AFFINITY {
+		s.setters[affinity.Pick(affinity.Key(envelope.Envelope), s.members)].Set(envelope)
+		return
+	}
+
+	s.setters[rand.Intn(len(s.setters))].Set(envelope)
 }
 
 func (r *Router) registerSetter(req *plumbing.SubscriptionRequest, dataSetter DataSetter) {
-	var filter plumbing.Filter
-	if req.Filter != nil {
-		filter = *req.Filter
+	appID, key := subscriptionKeyFor(req)
+
+	m, ok := r.subscriptions[appID]
+	if !ok {
+		m = make(map[subscriptionKey]*subscription)
+		r.subscriptions[appID] = m
 	}
 
-	m, ok := r.subscriptions[filter]
+	s, ok := m[key]
 	if !ok {
-		m = make(map[string][]DataSetter)
-		r.subscriptions[filter] = m
+		s = &subscription{filter: req.Filter}
+		m[key] = s
 	}
 
-	m[req.ShardID] = append(m[req.ShardID], dataSetter)
+	memberID := req.MemberID
+	if memberID == "" {
+		r.nextMemberID++
+		memberID = strconv.FormatUint(r.nextMemberID, 10)
+	}
+
+	s.setters = append(s.setters, dataSetter)
+	s.members = append(s.members, memberID)
 }
 
 func (r *Router) buildCleanup(req *plumbing.SubscriptionRequest, dataSetter DataSetter) func() {
@@ -83,36 +146,90 @@ func (r *Router) buildCleanup(req *plumbing.SubscriptionRequest, dataSetter Data
 		r.lock.Lock()
 		defer r.lock.Unlock()
 
-		var filter plumbing.Filter
-		if req.Filter != nil {
-			filter = *req.Filter
+		appID, key := subscriptionKeyFor(req)
+		s, ok := r.subscriptions[appID][key]
+		if !ok {
+			return
 		}
 
-		var setters []DataSetter
-		for _, s := range r.subscriptions[filter][req.ShardID] {
-			if s != dataSetter {
-				setters = append(setters, s)
+		var (
+			setters []DataSetter
+			members []string
+		)
+		for i, setter := range s.setters {
+			if setter != dataSetter {
+				setters = append(setters, setter)
+				members = append(members, s.members[i])
 			}
 		}
 
 		if len(setters) > 0 {
-			r.subscriptions[filter][req.ShardID] = setters
+			s.setters = setters
+			s.members = members
 			return
 		}
 
-		delete(r.subscriptions[filter], req.ShardID)
+		delete(r.subscriptions[appID], key)
 
-		if len(r.subscriptions[filter]) == 0 {
-			delete(r.subscriptions, filter)
+		if len(r.subscriptions[appID]) == 0 {
+			delete(r.subscriptions, appID)
 		}
 	}
 }
 
-func (r *Router) marshal(envelope *events.Envelope) []byte {
-	data, err := envelope.Marshal()
-	if err != nil {
-		return nil
+func subscriptionKeyFor(req *plumbing.SubscriptionRequest) (string, subscriptionKey) {
+	key := subscriptionKey{
+		shardID:   req.ShardID,
+		shardType: req.ShardType,
+	}
+
+	f := req.Filter
+	if f == nil {
+		return "", key
+	}
+
+	// A filter without event types, source types or origins matches every
+	// envelope, just like a missing filter, and so shares its shard. The
+	// lists are sets, so their order and duplicates do not matter either.
+	if len(f.EventTypes) > 0 || len(f.SourceTypes) > 0 || len(f.Origins) > 0 {
+		key.filter = fmt.Sprintf("%q %q %q", uniqueSorted(f.EventTypes), uniqueSorted(f.SourceTypes), uniqueSorted(f.Origins))
+	}
+	return f.AppID, key
+}
+
+// uniqueSorted returns a sorted copy of values without duplicates.
+func uniqueSorted(values []string) []string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+
+	unique := sorted[:0]
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// matchesFilter reports whether the envelope matches the event types, source
+// types and origins of the filter. The app ID is matched by the router.
+func matchesFilter(f *plumbing.Filter, envelope *events.Envelope) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.EventTypes) > 0 && !containsString(f.EventTypes, envelope.GetEventType().String()) {
+		return false
+	}
+
+	if len(f.SourceTypes) > 0 && !containsString(f.SourceTypes, envelope.GetLogMessage().GetSourceType()) {
+		return false
+	}
+
+	if len(f.Origins) > 0 && !containsString(f.Origins, envelope.GetOrigin()) {
+		return false
 	}
 
-	return data
+	return true
 }
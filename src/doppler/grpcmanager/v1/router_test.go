@@ -2,6 +2,8 @@ package v1_test
 
 import (
 	"doppler/grpcmanager/v1"
+	"fmt"
+	"marshalled"
 	"plumbing"
 
 	. "github.com/apoydence/eachers"
@@ -23,8 +25,7 @@ var _ = Describe("Router", func() {
 		mockDataSetterE *mockDataSetter
 		mockDataSetterF *mockDataSetter
 
-		envelope      *events.Envelope
-		envelopeBytes []byte
+		envelope *marshalled.Envelope
 
 		router *v1.Router
 	)
@@ -37,13 +38,10 @@ var _ = Describe("Router", func() {
 		mockDataSetterE = newMockDataSetter()
 		mockDataSetterF = newMockDataSetter()
 
-		envelope = &events.Envelope{
+		envelope = marshalled.New(&events.Envelope{
 			Origin:    proto.String("some-origin"),
 			EventType: events.Envelope_CounterEvent.Enum(),
-		}
-		var err error
-		envelopeBytes, err = envelope.Marshal()
-		Expect(err).ToNot(HaveOccurred())
+		})
 
 		router = v1.NewRouter()
 	})
@@ -101,11 +99,11 @@ var _ = Describe("Router", func() {
 				router.SendTo("some-app-id", envelope)
 
 				Eventually(mockDataSetterA.SetInput).Should(
-					BeCalled(With(envelopeBytes)),
+					BeCalled(With(envelope)),
 				)
 
 				Eventually(mockDataSetterB.SetInput).Should(
-					BeCalled(With(envelopeBytes)),
+					BeCalled(With(envelope)),
 				)
 			})
 
@@ -127,23 +125,7 @@ var _ = Describe("Router", func() {
 				Eventually(f).Should(Equal(1))
 
 				Eventually(mockDataSetterF.SetInput).Should(
-					BeCalled(With(envelopeBytes)),
-				)
-			})
-
-			It("does not send data for bad envelope", func() {
-				router.SendTo("some-app-id", new(events.Envelope))
-
-				Consistently(mockDataSetterA.SetCalled).Should(
-					Not(BeCalled()),
-				)
-
-				Consistently(mockDataSetterD.SetCalled).Should(
-					Not(BeCalled()),
-				)
-
-				Consistently(mockDataSetterE.SetCalled).Should(
-					Not(BeCalled()),
+					BeCalled(With(envelope)),
 				)
 			})
 
@@ -164,7 +146,7 @@ var _ = Describe("Router", func() {
 					router.SendTo("some-app-id", envelope)
 
 					Eventually(mockDataSetterB.SetInput).Should(
-						BeCalled(With(envelopeBytes)),
+						BeCalled(With(envelope)),
 					)
 				})
 			})
@@ -209,5 +191,292 @@ var _ = Describe("Router", func() {
 				})
 			})
 		})
+
+		Context("when setters are routed with filters", func() {
+			var (
+				logEnvelope *marshalled.Envelope
+			)
+
+			BeforeEach(func() {
+				logEnvelope = marshalled.New(&events.Envelope{
+					Origin:    proto.String("some-origin"),
+					EventType: events.Envelope_LogMessage.Enum(),
+					LogMessage: &events.LogMessage{
+						Message:     []byte("some-message"),
+						MessageType: events.LogMessage_OUT.Enum(),
+						Timestamp:   proto.Int64(1234),
+						SourceType:  proto.String("RTR"),
+					},
+				})
+			})
+
+			It("only sends envelopes of the requested event types", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"LogMessage"},
+					},
+				}, mockDataSetterA)
+
+				router.SendTo("some-app-id", envelope)
+				router.SendTo("some-app-id", logEnvelope)
+
+				Eventually(mockDataSetterA.SetInput).Should(
+					BeCalled(With(logEnvelope)),
+				)
+				Consistently(mockDataSetterA.SetInput).Should(
+					Not(BeCalled()),
+				)
+			})
+
+			It("only sends log messages from the requested source types", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					Filter: &plumbing.Filter{
+						AppID:       "some-app-id",
+						SourceTypes: []string{"APP"},
+					},
+				}, mockDataSetterA)
+				router.Register(&plumbing.SubscriptionRequest{
+					Filter: &plumbing.Filter{
+						AppID:       "some-app-id",
+						SourceTypes: []string{"RTR"},
+					},
+				}, mockDataSetterB)
+
+				router.SendTo("some-app-id", logEnvelope)
+
+				Eventually(mockDataSetterB.SetInput).Should(
+					BeCalled(With(logEnvelope)),
+				)
+				Consistently(mockDataSetterA.SetCalled).Should(
+					Not(BeCalled()),
+				)
+			})
+
+			It("only sends envelopes from the requested origins", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					Filter: &plumbing.Filter{
+						Origins: []string{"other-origin"},
+					},
+				}, mockDataSetterA)
+
+				router.SendTo("some-app-id", envelope)
+
+				Consistently(mockDataSetterA.SetCalled).Should(
+					Not(BeCalled()),
+				)
+			})
+
+			It("does not share a shard between different filters", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"LogMessage"},
+					},
+				}, mockDataSetterD)
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"CounterEvent"},
+					},
+				}, mockDataSetterE)
+
+				router.SendTo("some-app-id", logEnvelope)
+				router.SendTo("some-app-id", envelope)
+
+				Eventually(mockDataSetterD.SetInput).Should(
+					BeCalled(With(logEnvelope)),
+				)
+				Eventually(mockDataSetterE.SetInput).Should(
+					BeCalled(With(envelope)),
+				)
+			})
+
+			It("shares a shard between the same filter given in a different order", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"LogMessage", "CounterEvent"},
+						Origins:    []string{"some-origin", "other-origin"},
+					},
+				}, mockDataSetterD)
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"CounterEvent", "LogMessage", "CounterEvent"},
+						Origins:    []string{"other-origin", "some-origin"},
+					},
+				}, mockDataSetterE)
+
+				router.SendTo("some-app-id", logEnvelope)
+				router.SendTo("some-app-id", envelope)
+
+				f := func() int {
+					return len(mockDataSetterD.SetCalled) + len(mockDataSetterE.SetCalled)
+				}
+				Eventually(f).Should(Equal(2))
+				Consistently(f).Should(Equal(2))
+				Expect(router.Subscriptions()).To(HaveLen(1))
+			})
+
+			It("shares a shard between a missing and an empty filter", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+				}, mockDataSetterD)
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+					Filter:  &plumbing.Filter{},
+				}, mockDataSetterE)
+
+				router.SendTo("some-app-id", envelope)
+
+				f := func() int {
+					return len(mockDataSetterD.SetCalled) + len(mockDataSetterE.SetCalled)
+				}
+				Eventually(f).Should(Equal(1))
+				Consistently(f).Should(Equal(1))
+				Expect(router.Subscriptions()).To(HaveLen(1))
+			})
+
+			It("stops sending once the filtered setter is unregistered", func() {
+				cleanup := router.Register(&plumbing.SubscriptionRequest{
+					Filter: &plumbing.Filter{
+						EventTypes: []string{"LogMessage"},
+					},
+				}, mockDataSetterA)
+				cleanup()
+
+				router.SendTo("some-app-id", logEnvelope)
+
+				Consistently(mockDataSetterA.SetCalled).Should(
+					Not(BeCalled()),
+				)
+			})
+		})
+
+		Context("with app affinity sharding", func() {
+			var (
+				setters  []*mockDataSetter
+				cleanups []func()
+			)
+
+			var appEnvelope = func(appID string) *events.Envelope {
+				return &events.Envelope{
+					Origin:    proto.String("some-origin"),
+					EventType: events.Envelope_LogMessage.Enum(),
+					LogMessage: &events.LogMessage{
+						Message:     []byte("some-message"),
+						MessageType: events.LogMessage_OUT.Enum(),
+						Timestamp:   proto.Int64(1),
+						AppId:       proto.String(appID),
+					},
+				}
+			}
+
+			var receivers = func() []int {
+				var indexes []int
+				for i, setter := range setters {
+					if len(setter.SetCalled) > 0 {
+						indexes = append(indexes, i)
+					}
+				}
+				return indexes
+			}
+
+			BeforeEach(func() {
+				setters = []*mockDataSetter{mockDataSetterD, mockDataSetterE, mockDataSetterF}
+				cleanups = nil
+				for i, setter := range setters {
+					cleanups = append(cleanups, router.Register(&plumbing.SubscriptionRequest{
+						ShardID:   "some-sub-id",
+						ShardType: plumbing.SubscriptionRequest_APP_AFFINITY,
+						MemberID:  fmt.Sprintf("member-%d", i),
+					}, setter))
+				}
+			})
+
+			It("sends every envelope for an app to the same setter", func() {
+				for i := 0; i < 10; i++ {
+					router.SendTo("some-app-id", marshalled.New(appEnvelope("some-app-id")))
+				}
+
+				Expect(receivers()).To(HaveLen(1))
+				Expect(setters[receivers()[0]].SetCalled).To(HaveLen(10))
+			})
+
+			It("keeps sending an app's envelopes to its setter when another leaves", func() {
+				router.SendTo("some-app-id", marshalled.New(appEnvelope("some-app-id")))
+				Expect(receivers()).To(HaveLen(1))
+				receiver := receivers()[0]
+				<-setters[receiver].SetCalled
+
+				cleanups[(receiver+1)%len(setters)]()
+				router.SendTo("some-app-id", marshalled.New(appEnvelope("some-app-id")))
+
+				Expect(receivers()).To(Equal([]int{receiver}))
+			})
+
+			It("assigns an app to the same member regardless of registration order", func() {
+				otherSetters := []*mockDataSetter{newMockDataSetter(), newMockDataSetter(), newMockDataSetter()}
+				otherRouter := v1.NewRouter()
+				for _, i := range []int{2, 0, 1} {
+					otherRouter.Register(&plumbing.SubscriptionRequest{
+						ShardID:   "some-sub-id",
+						ShardType: plumbing.SubscriptionRequest_APP_AFFINITY,
+						MemberID:  fmt.Sprintf("member-%d", i),
+					}, otherSetters[i])
+				}
+
+				for i := 0; i < 20; i++ {
+					appID := fmt.Sprintf("app-%d", i)
+					router.SendTo(appID, marshalled.New(appEnvelope(appID)))
+					otherRouter.SendTo(appID, marshalled.New(appEnvelope(appID)))
+
+					Expect(receivers()).To(HaveLen(1))
+					receiver := receivers()[0]
+					<-setters[receiver].SetCalled
+					Expect(otherSetters[receiver].SetCalled).To(HaveLen(1))
+					<-otherSetters[receiver].SetCalled
+				}
+			})
+
+			It("does not share a shard with random sharding", func() {
+				router.Register(&plumbing.SubscriptionRequest{
+					ShardID: "some-sub-id",
+				}, mockDataSetterA)
+
+				router.SendTo("some-app-id", marshalled.New(appEnvelope("some-app-id")))
+
+				Eventually(mockDataSetterA.SetCalled).Should(BeCalled())
+				Expect(receivers()).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("Subscriptions", func() {
+		It("returns each shard with its filter and number of subscribers", func() {
+			filter := &plumbing.Filter{AppID: "some-app-id"}
+			router.Register(&plumbing.SubscriptionRequest{Filter: filter}, mockDataSetterA)
+			router.Register(&plumbing.SubscriptionRequest{Filter: filter}, mockDataSetterB)
+			router.Register(&plumbing.SubscriptionRequest{
+				ShardID:   "some-shard",
+				ShardType: plumbing.SubscriptionRequest_APP_AFFINITY,
+			}, mockDataSetterD)
+
+			Expect(router.Subscriptions()).To(ConsistOf(
+				v1.Subscription{Filter: filter, Subscribers: 2},
+				v1.Subscription{
+					ShardID:     "some-shard",
+					ShardType:   plumbing.SubscriptionRequest_APP_AFFINITY,
+					Subscribers: 1,
+				},
+			))
+		})
+
+		It("does not return unregistered subscriptions", func() {
+			cleanup := router.Register(&plumbing.SubscriptionRequest{ShardID: "some-shard"}, mockDataSetterD)
+			cleanup()
+
+			Expect(router.Subscriptions()).To(BeEmpty())
+		})
 	})
 })
@@ -8,6 +8,7 @@ package v2_test
 import (
 	"golang.org/x/net/context"
 
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
 	"github.com/cloudfoundry/sonde-go/events"
 
 	plumbing "plumbing/v2"
@@ -132,3 +133,66 @@ func (m *mockDataSetter) Set(data *events.Envelope) {
 	m.SetCalled <- true
 	m.SetInput.Data <- data
 }
+
+type mockBatcher struct {
+	BatchCounterCalled chan bool
+	BatchCounterInput  struct {
+		Name chan string
+	}
+	BatchCounterOutput struct {
+		Ret0 chan metricbatcher.BatchCounterChainer
+	}
+}
+
+func newMockBatcher() *mockBatcher {
+	m := &mockBatcher{}
+	m.BatchCounterCalled = make(chan bool, 100)
+	m.BatchCounterInput.Name = make(chan string, 100)
+	m.BatchCounterOutput.Ret0 = make(chan metricbatcher.BatchCounterChainer, 100)
+	return m
+}
+func (m *mockBatcher) BatchCounter(name string) metricbatcher.BatchCounterChainer {
+	m.BatchCounterCalled <- true
+	m.BatchCounterInput.Name <- name
+	return <-m.BatchCounterOutput.Ret0
+}
+
+type mockBatchCounterChainer struct {
+	SetTagCalled chan bool
+	SetTagInput  struct {
+		Key, Value chan string
+	}
+	SetTagOutput struct {
+		Ret0 chan metricbatcher.BatchCounterChainer
+	}
+	IncrementCalled chan bool
+	AddCalled       chan bool
+	AddInput        struct {
+		Value chan uint64
+	}
+}
+
+func newMockBatchCounterChainer() *mockBatchCounterChainer {
+	m := &mockBatchCounterChainer{}
+	m.SetTagCalled = make(chan bool, 100)
+	m.SetTagInput.Key = make(chan string, 100)
+	m.SetTagInput.Value = make(chan string, 100)
+	m.SetTagOutput.Ret0 = make(chan metricbatcher.BatchCounterChainer, 100)
+	m.IncrementCalled = make(chan bool, 100)
+	m.AddCalled = make(chan bool, 100)
+	m.AddInput.Value = make(chan uint64, 100)
+	return m
+}
+func (m *mockBatchCounterChainer) SetTag(key, value string) metricbatcher.BatchCounterChainer {
+	m.SetTagCalled <- true
+	m.SetTagInput.Key <- key
+	m.SetTagInput.Value <- value
+	return <-m.SetTagOutput.Ret0
+}
+func (m *mockBatchCounterChainer) Increment() {
+	m.IncrementCalled <- true
+}
+func (m *mockBatchCounterChainer) Add(value uint64) {
+	m.AddCalled <- true
+	m.AddInput.Value <- value
+}
@@ -1,9 +1,11 @@
 package v2
 
 import (
+	"doppler/losstracker"
 	"plumbing/conversion"
 	plumbing "plumbing/v2"
 
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
@@ -15,13 +17,21 @@ type DataSetter interface {
 	Set(data *events.Envelope)
 }
 
+type Batcher interface {
+	BatchCounter(name string) metricbatcher.BatchCounterChainer
+}
+
 type Ingestor struct {
 	envelopeBuffer DataSetter
+	batcher        Batcher
+	tracker        *losstracker.LossTracker
 }
 
-func NewIngestor(envelopeBuffer DataSetter) *Ingestor {
+func NewIngestor(envelopeBuffer DataSetter, batcher Batcher) *Ingestor {
 	return &Ingestor{
 		envelopeBuffer: envelopeBuffer,
+		batcher:        batcher,
+		tracker:        losstracker.New(batcher),
 	}
 }
 
@@ -31,6 +41,7 @@ func (i Ingestor) Sender(s plumbing.DopplerIngress_SenderServer) error {
 		if err != nil {
 			return err
 		}
+		i.tracker.TrackV2(v2e)
 
 		v1e := conversion.ToV1(v2e)
 		if v1e == nil || v1e.EventType == nil {
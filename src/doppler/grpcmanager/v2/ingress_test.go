@@ -3,8 +3,11 @@ package v2_test
 import (
 	"doppler/grpcmanager/v2"
 	"io"
+	plumbingv1 "plumbing"
 	plumbing "plumbing/v2"
 
+	"github.com/apoydence/eachers/testhelpers"
+	"github.com/cloudfoundry/sonde-go/events"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -13,6 +16,8 @@ var _ = Describe("Ingress", func() {
 	var (
 		mockDataSetter *mockDataSetter
 		mockSender     *mockDopplerIngress_SenderServer
+		mockBatcher    *mockBatcher
+		mockChainer    *mockBatchCounterChainer
 
 		ingestor *v2.Ingestor
 	)
@@ -21,7 +26,12 @@ var _ = Describe("Ingress", func() {
 		mockDataSetter = newMockDataSetter()
 		mockSender = newMockDopplerIngress_SenderServer()
 
-		ingestor = v2.NewIngestor(mockDataSetter)
+		mockBatcher = newMockBatcher()
+		mockChainer = newMockBatchCounterChainer()
+		testhelpers.AlwaysReturn(mockBatcher.BatchCounterOutput, mockChainer)
+		testhelpers.AlwaysReturn(mockChainer.SetTagOutput, mockChainer)
+
+		ingestor = v2.NewIngestor(mockDataSetter, mockBatcher)
 	})
 
 	It("writes the v2 envelope as a v1 envelope to data setter", func() {
@@ -49,4 +59,32 @@ var _ = Describe("Ingress", func() {
 		ingestor.Sender(mockSender)
 		Expect(mockDataSetter.SetCalled).To(HaveLen(0))
 	})
+
+	It("removes sequence tags and counts gaps", func() {
+		for _, seq := range []int64{1, 4} {
+			mockSender.RecvOutput.Ret0 <- &plumbing.Envelope{
+				Tags: map[string]*plumbing.Value{
+					plumbingv1.SequenceTag: {Data: &plumbing.Value_Integer{Integer: seq}},
+					plumbingv1.StreamTag:   {Data: &plumbing.Value_Integer{Integer: 1}},
+					plumbingv1.SourceTag:   {Data: &plumbing.Value_Text{Text: "some-deployment/some-job/0/0"}},
+				},
+				Message: &plumbing.Envelope_Log{
+					Log: &plumbing.Log{
+						Payload: []byte("hello"),
+					},
+				},
+			}
+			mockSender.RecvOutput.Ret1 <- nil
+		}
+		mockSender.RecvOutput.Ret0 <- nil
+		mockSender.RecvOutput.Ret1 <- io.EOF
+
+		ingestor.Sender(mockSender)
+
+		var e *events.Envelope
+		Expect(mockDataSetter.SetInput.Data).To(Receive(&e))
+		Expect(e.Tags).To(BeEmpty())
+		Expect(mockBatcher.BatchCounterInput.Name).To(Receive(Equal("listeners.lostEnvelopes")))
+		Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(2))))
+	})
 })
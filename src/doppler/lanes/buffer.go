@@ -0,0 +1,254 @@
+// Package lanes buffers envelopes in separate lanes by class so that a burst
+// of a lower priority class cannot evict the envelopes of a higher one. The
+// lanes share a fixed capacity. When it is full, room is made by shedding
+// the oldest envelope of the lowest priority lane holding any, and an
+// envelope is only shed itself when every buffered envelope outranks it. App
+// logs have the highest priority, then container metrics, then all other
+// metrics.
+//
+// The buffer is Doppler's ingress: it stamps each envelope with the time it
+// was received, which later stages measure their latency from.
+package lanes
+
+import (
+	"context"
+	"marshalled"
+	"plumbing"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type Batcher interface {
+	BatchCounter(name string) metricbatcher.BatchCounterChainer
+}
+
+// LatencyRecorder records the time envelopes spend travelling from Metron to
+// Doppler.
+type LatencyRecorder interface {
+	Record(time.Duration)
+}
+
+// Weights are the number of envelopes read from each lane in turn. They only
+// divide the reader's time, so that a lane with a lower weight is still read
+// while the others are busy. Which envelopes are shed when the buffer is full
+// depends on the lanes' priority alone. Every lane is read at least once per
+// turn.
+type Weights struct {
+	Logs             int
+	ContainerMetrics int
+	Metrics          int
+}
+
+type lane struct {
+	class     string
+	weight    int
+	envelopes *queue
+}
+
+// Buffer is a lane per class of envelope: app logs, container metrics and
+// all other metrics, ordered by priority. It has many writers and a single
+// reader.
+type Buffer struct {
+	signal  chan struct{}
+	ctx     context.Context
+	batcher Batcher
+	latency LatencyRecorder
+
+	mu      sync.Mutex
+	size    int
+	len     int
+	lanes   []*lane
+	current int
+	credit  int
+}
+
+// New creates a Buffer whose lanes together hold size envelopes. Next
+// returns nil once the context is done and the lanes are empty. The latency
+// recorder is given the time each envelope spent travelling from Metron.
+func New(ctx context.Context, size int, weights Weights, batcher Batcher, latency LatencyRecorder) *Buffer {
+	if size < 1 {
+		size = 1
+	}
+
+	b := &Buffer{
+		signal:  make(chan struct{}, 1),
+		ctx:     ctx,
+		batcher: batcher,
+		latency: latency,
+		size:    size,
+	}
+
+	for _, l := range []struct {
+		class  string
+		weight int
+	}{
+		{"logs", weights.Logs},
+		{"containerMetrics", weights.ContainerMetrics},
+		{"metrics", weights.Metrics},
+	} {
+		if l.weight < 1 {
+			l.weight = 1
+		}
+		b.lanes = append(b.lanes, &lane{
+			class:     l.class,
+			weight:    l.weight,
+			envelopes: newQueue(size),
+		})
+	}
+	b.credit = b.lanes[0].weight
+
+	return b
+}
+
+// Set writes the envelope to the lane for its class, stamped with the time
+// it was received. When the buffer is full it sheds the oldest envelope of
+// the lowest priority lane holding any, or the given envelope if every lane
+// holding envelopes has a higher priority than its own. The envelope must not
+// be modified once it has been set.
+func (b *Buffer) Set(envelope *events.Envelope) {
+	l := b.laneFor(envelope)
+	b.recordTransit(envelope)
+	received := marshalled.New(envelope)
+
+	b.mu.Lock()
+	shed, ok := b.makeRoom(l)
+	if ok {
+		l.envelopes.push(received)
+		b.len++
+	}
+	b.mu.Unlock()
+
+	if shed != nil {
+		b.batcher.BatchCounter("doppler.shedEnvelopes").
+			SetTag("class", shed.class).
+			Increment()
+	}
+
+	select {
+	case b.signal <- struct{}{}:
+	default:
+	}
+}
+
+// makeRoom sheds the oldest envelope of the lowest priority lane, down to
+// the lane l, if the buffer is full. It returns the lane an envelope was shed
+// from, if any, and whether there is room for an envelope in l.
+func (b *Buffer) makeRoom(l *lane) (*lane, bool) {
+	if b.len < b.size {
+		return nil, true
+	}
+
+	for i := len(b.lanes) - 1; i >= 0; i-- {
+		victim := b.lanes[i]
+		if _, ok := victim.envelopes.pop(); ok {
+			b.len--
+			return victim, true
+		}
+		if victim == l {
+			break
+		}
+	}
+	return l, false
+}
+
+// recordTransit records the time since Metron wrote the envelope and removes
+// the Metron egress timestamp, which subscribers do not receive.
+func (b *Buffer) recordTransit(envelope *events.Envelope) {
+	egress, ok := envelope.Tags[plumbing.MetronEgressTag]
+	if !ok {
+		return
+	}
+
+	if elapsed, ok := plumbing.Since(egress); ok {
+		b.latency.Record(elapsed)
+	}
+	delete(envelope.Tags, plumbing.MetronEgressTag)
+}
+
+// Next blocks until an envelope is available, reading the lanes in turn by
+// their weights.
+func (b *Buffer) Next() *marshalled.Envelope {
+	for {
+		if envelope := b.tryNext(); envelope != nil {
+			return envelope
+		}
+
+		select {
+		case <-b.signal:
+		case <-b.ctx.Done():
+			return b.tryNext()
+		}
+	}
+}
+
+// Len returns the number of envelopes buffered across the lanes.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.len
+}
+
+func (b *Buffer) tryNext() *marshalled.Envelope {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := 0; i <= len(b.lanes); i++ {
+		if b.credit > 0 {
+			if envelope, ok := b.lanes[b.current].envelopes.pop(); ok {
+				b.credit--
+				b.len--
+				return envelope
+			}
+		}
+
+		b.current = (b.current + 1) % len(b.lanes)
+		b.credit = b.lanes[b.current].weight
+	}
+	return nil
+}
+
+func (b *Buffer) laneFor(envelope *events.Envelope) *lane {
+	switch envelope.GetEventType() {
+	case events.Envelope_LogMessage:
+		return b.lanes[0]
+	case events.Envelope_ContainerMetric:
+		return b.lanes[1]
+	default:
+		return b.lanes[2]
+	}
+}
+
+// queue is a fixed size FIFO ring of envelopes.
+type queue struct {
+	envelopes []*marshalled.Envelope
+	head      int
+	count     int
+}
+
+func newQueue(size int) *queue {
+	return &queue{envelopes: make([]*marshalled.Envelope, size)}
+}
+
+func (q *queue) len() int {
+	return q.count
+}
+
+func (q *queue) push(envelope *marshalled.Envelope) {
+	q.envelopes[(q.head+q.count)%len(q.envelopes)] = envelope
+	q.count++
+}
+
+func (q *queue) pop() (*marshalled.Envelope, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+
+	envelope := q.envelopes[q.head]
+	q.envelopes[q.head] = nil
+	q.head = (q.head + 1) % len(q.envelopes)
+	q.count--
+	return envelope, true
+}
@@ -0,0 +1,252 @@
+package lanes_test
+
+import (
+	"context"
+	"doppler/lanes"
+	"plumbing"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Buffer", func() {
+	var (
+		sender  *fake.FakeMetricSender
+		latency *fakeLatency
+		ctx     context.Context
+		cancel  func()
+		buffer  *lanes.Buffer
+	)
+
+	BeforeEach(func() {
+		sender = fake.NewFakeMetricSender()
+		latency = &fakeLatency{}
+		ctx, cancel = context.WithCancel(context.Background())
+		buffer = lanes.New(
+			ctx,
+			10,
+			lanes.Weights{Logs: 3, ContainerMetrics: 1, Metrics: 1},
+			metricbatcher.New(sender, time.Millisecond),
+			latency,
+		)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("returns the envelopes of every class", func() {
+		for _, eventType := range []events.Envelope_EventType{
+			events.Envelope_LogMessage,
+			events.Envelope_ContainerMetric,
+			events.Envelope_ValueMetric,
+		} {
+			envelope := buildEnvelope(eventType)
+			buffer.Set(envelope)
+			Expect(next(buffer)).To(Equal(envelope))
+		}
+	})
+
+	It("reads the lanes by their weights", func() {
+		for i := 0; i < 5; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_LogMessage))
+			buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+		}
+
+		var eventTypes []events.Envelope_EventType
+		for i := 0; i < 6; i++ {
+			eventTypes = append(eventTypes, next(buffer).GetEventType())
+		}
+
+		Expect(eventTypes).To(Equal([]events.Envelope_EventType{
+			events.Envelope_LogMessage,
+			events.Envelope_LogMessage,
+			events.Envelope_LogMessage,
+			events.Envelope_ValueMetric,
+			events.Envelope_LogMessage,
+			events.Envelope_LogMessage,
+		}))
+	})
+
+	It("does not evict logs with a burst of metrics", func() {
+		logs := []*events.Envelope{
+			buildEnvelope(events.Envelope_LogMessage),
+			buildEnvelope(events.Envelope_LogMessage),
+		}
+		for _, envelope := range logs {
+			buffer.Set(envelope)
+		}
+		for i := 0; i < 20; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_HttpStartStop))
+		}
+
+		Expect(next(buffer)).To(Equal(logs[0]))
+		Expect(next(buffer)).To(Equal(logs[1]))
+	})
+
+	It("does not lose any logs to a flood of metrics", func() {
+		var logs []*events.Envelope
+		for i := 0; i < 10; i++ {
+			logs = append(logs, buildEnvelope(events.Envelope_LogMessage))
+			buffer.Set(logs[i])
+		}
+		for i := 0; i < 1000; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_ContainerMetric))
+			buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+		}
+		cancel()
+
+		var read []*events.Envelope
+		for envelope := next(buffer); envelope != nil; envelope = next(buffer) {
+			read = append(read, envelope)
+		}
+		Expect(read).To(Equal(logs))
+	})
+
+	It("sheds the lowest priority envelopes to make room", func() {
+		for i := 0; i < 10; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+		}
+		for i := 0; i < 3; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_ContainerMetric))
+		}
+		for i := 0; i < 3; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_LogMessage))
+		}
+		cancel()
+
+		counts := make(map[events.Envelope_EventType]int)
+		for envelope := next(buffer); envelope != nil; envelope = next(buffer) {
+			counts[envelope.GetEventType()]++
+		}
+		Expect(counts).To(Equal(map[events.Envelope_EventType]int{
+			events.Envelope_LogMessage:      3,
+			events.Envelope_ContainerMetric: 3,
+			events.Envelope_ValueMetric:     4,
+		}))
+	})
+
+	It("sheds its own oldest envelope when nothing of lower priority is buffered", func() {
+		var logs []*events.Envelope
+		for i := 0; i < 11; i++ {
+			logs = append(logs, buildEnvelope(events.Envelope_LogMessage))
+			buffer.Set(logs[i])
+		}
+		buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+
+		Expect(buffer.Len()).To(Equal(10))
+		Expect(next(buffer)).To(Equal(logs[1]))
+	})
+
+	It("holds its size across all lanes", func() {
+		for i := 0; i < 10; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_LogMessage))
+			buffer.Set(buildEnvelope(events.Envelope_ContainerMetric))
+			buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+		}
+
+		Expect(buffer.Len()).To(Equal(10))
+	})
+
+	It("reports the envelopes shed by class", func() {
+		for i := 0; i < 15; i++ {
+			buffer.Set(buildEnvelope(events.Envelope_ValueMetric))
+		}
+		next(buffer)
+
+		Eventually(func() uint64 {
+			return sender.GetCounter("doppler.shedEnvelopes")
+		}).Should(BeEquivalentTo(5))
+	})
+
+	It("stamps each envelope with the time it was received", func() {
+		before := time.Now()
+		buffer.Set(buildEnvelope(events.Envelope_LogMessage))
+
+		received, ok := buffer.Next().Received()
+		Expect(ok).To(BeTrue())
+		Expect(received).To(BeTemporally(">=", before))
+		Expect(received).To(BeTemporally("<=", time.Now()))
+	})
+
+	It("removes the Metron egress timestamp and records the time since Metron sent the envelope", func() {
+		envelope := buildEnvelope(events.Envelope_LogMessage)
+		envelope.Tags = map[string]string{
+			plumbing.MetronEgressTag: plumbing.Timestamp(time.Now().Add(-time.Second)),
+		}
+		buffer.Set(envelope)
+
+		Expect(next(buffer).GetTags()).ToNot(HaveKey(plumbing.MetronEgressTag))
+		Expect(latency.durations()).To(HaveLen(1))
+		Expect(latency.durations()[0]).To(BeNumerically("~", time.Second, 500*time.Millisecond))
+	})
+
+	It("does not record a transit time for envelopes without a Metron egress timestamp", func() {
+		buffer.Set(buildEnvelope(events.Envelope_LogMessage))
+
+		Expect(next(buffer).GetTags()).To(BeNil())
+		Expect(latency.durations()).To(BeEmpty())
+	})
+
+	It("blocks until an envelope is available", func() {
+		received := make(chan *events.Envelope)
+		go func() {
+			received <- next(buffer)
+		}()
+		Consistently(received).ShouldNot(Receive())
+
+		envelope := buildEnvelope(events.Envelope_LogMessage)
+		buffer.Set(envelope)
+
+		Eventually(received).Should(Receive(Equal(envelope)))
+	})
+
+	It("returns nil once the context is done and the lanes are empty", func() {
+		envelope := buildEnvelope(events.Envelope_LogMessage)
+		buffer.Set(envelope)
+		cancel()
+
+		Expect(next(buffer)).To(Equal(envelope))
+		Expect(next(buffer)).To(BeNil())
+	})
+})
+
+// next returns the next envelope without its received time, or nil.
+func next(buffer *lanes.Buffer) *events.Envelope {
+	envelope := buffer.Next()
+	if envelope == nil {
+		return nil
+	}
+	return envelope.Envelope
+}
+
+type fakeLatency struct {
+	sync.Mutex
+	recorded []time.Duration
+}
+
+func (f *fakeLatency) Record(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	f.recorded = append(f.recorded, d)
+}
+
+func (f *fakeLatency) durations() []time.Duration {
+	f.Lock()
+	defer f.Unlock()
+	return f.recorded
+}
+
+func buildEnvelope(eventType events.Envelope_EventType) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String("some-origin"),
+		EventType: eventType.Enum(),
+	}
+}
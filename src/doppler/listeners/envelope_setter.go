@@ -0,0 +1,9 @@
+package listeners
+
+import "github.com/cloudfoundry/sonde-go/events"
+
+// EnvelopeSetter buffers the envelopes received by the listeners until they
+// are routed.
+type EnvelopeSetter interface {
+	Set(*events.Envelope)
+}
@@ -1,7 +1,6 @@
 package listeners
 
 import (
-	"diodes"
 	"doppler/config"
 	"doppler/grpcmanager/v1"
 	"doppler/grpcmanager/v2"
@@ -27,8 +26,9 @@ func NewGRPCListener(
 	router *v1.Router,
 	sinkmanager *sinkmanager.SinkManager,
 	conf config.GRPC,
-	envelopeBuffer *diodes.ManyToOneEnvelope,
+	envelopeBuffer EnvelopeSetter,
 	batcher *metricbatcher.MetricBatcher,
+	subscriberLatency v1.LatencyRecorder,
 ) (*GRPCListener, error) {
 	tlsConfig, err := plumbingv1.NewMutualTLSConfig(
 		conf.CertFile,
@@ -58,14 +58,13 @@ func NewGRPCListener(
 	// v1 egress
 	plumbingv1.RegisterDopplerServer(
 		grpcServer,
-		v1.New(router, sinkmanager),
+		v1.New(router, sinkmanager, subscriberLatency),
 	)
 
 	// v2 ingress
 	plumbingv2.RegisterDopplerIngressServer(
 		grpcServer,
-		// TODO: add batcher to v2 ingestor
-		v2.NewIngestor(envelopeBuffer),
+		v2.NewIngestor(envelopeBuffer, batcher),
 	)
 
 	return &GRPCListener{
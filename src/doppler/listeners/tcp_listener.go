@@ -2,7 +2,6 @@ package listeners
 
 import (
 	"crypto/tls"
-	"diodes"
 	"encoding/binary"
 	"io"
 	"log"
@@ -18,7 +17,7 @@ import (
 )
 
 type TCPListener struct {
-	envelopesBuffer *diodes.ManyToOneEnvelope
+	envelopesBuffer EnvelopeSetter
 	batcher         Batcher
 	listener        net.Listener
 	protocol        string
@@ -34,7 +33,7 @@ type TCPListener struct {
 func NewTCPListener(
 	metricProto, address string,
 	tlsListenerConfig *config.TLSListenerConfig,
-	envelopesBuffer *diodes.ManyToOneEnvelope,
+	envelopesBuffer EnvelopeSetter,
 	batcher Batcher,
 	deadline time.Duration,
 ) (*TCPListener, error) {
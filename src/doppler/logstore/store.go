@@ -0,0 +1,622 @@
+package logstore
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"marshalled"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	segmentExt       = ".seg"
+	maxPruneInterval = time.Minute
+
+	// segmentsPerApp is the number of segments the per app cap is spread
+	// over. Whole segments are removed at a time, so more segments means
+	// closer adherence to the cap at the cost of more files.
+	segmentsPerApp = 4
+)
+
+var errStopped = errors.New("logstore: stopped")
+
+// Store is an on-disk store of recent log envelopes that survives restarts.
+// Each app has its own directory of append-only segment files. Each record
+// in a segment is a 4 byte big endian length followed by the marshalled
+// envelope.
+//
+// Whole segments are removed once the newer segments hold at least maxPerApp
+// envelopes or once they have not been written to for maxAge. A maxAge of
+// zero keeps envelopes regardless of age. At most maxApps apps are stored;
+// the least recently written app is removed to make room for a new one.
+//
+// The envelopes read from an app's segments are kept in memory until its
+// recent logs have not been requested for a while, so that repeated requests
+// do not read them from disk again. Likewise an app's active segment is
+// closed once it has not been written to for a while.
+type Store struct {
+	dir         string
+	maxPerApp   int
+	maxApps     int
+	maxAge      time.Duration
+	segmentSize int
+	done        chan struct{}
+	stopOnce    sync.Once
+
+	mu      sync.Mutex
+	apps    map[string]*appLog
+	written map[string]time.Time // last write of every app on disk
+	stopped bool
+}
+
+type appLog struct {
+	mu       sync.Mutex
+	dir      string
+	segments []*segment
+	active   *os.File
+	nextID   uint64
+	written  time.Time
+	read     time.Time
+	removed  bool
+}
+
+type segment struct {
+	path     string
+	count    int
+	modified time.Time
+
+	// appendable is set for segments created since the store was opened.
+	// Only they can be reopened once closed, as a segment found on disk
+	// may end in a torn record.
+	appendable bool
+
+	// records holds the segment's envelopes once it has been read.
+	records []*marshalled.Envelope
+	cached  bool
+}
+
+// New opens the store rooted at dir, creating it if necessary.
+func New(dir string, maxPerApp, maxApps int, maxAge time.Duration) (*Store, error) {
+	if maxPerApp < 1 {
+		return nil, fmt.Errorf("invalid max envelopes per app: %d", maxPerApp)
+	}
+	if maxApps < 1 {
+		return nil, fmt.Errorf("invalid max apps: %d", maxApps)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	written, err := lastWrites(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segmentSize := maxPerApp / segmentsPerApp
+	if segmentSize < 1 {
+		segmentSize = 1
+	}
+
+	return &Store{
+		dir:         dir,
+		maxPerApp:   maxPerApp,
+		maxApps:     maxApps,
+		maxAge:      maxAge,
+		segmentSize: segmentSize,
+		done:        make(chan struct{}),
+		apps:        make(map[string]*appLog),
+		written:     written,
+	}, nil
+}
+
+// lastWrites returns when each app stored in dir was last written to.
+func lastWrites(dir string) (map[string]time.Time, error) {
+	finfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	written := make(map[string]time.Time)
+	for _, finfo := range finfos {
+		appID, err := hex.DecodeString(finfo.Name())
+		if err != nil || !finfo.IsDir() {
+			continue
+		}
+
+		last := finfo.ModTime()
+		segments, _ := ioutil.ReadDir(filepath.Join(dir, finfo.Name()))
+		for _, seg := range segments {
+			if strings.HasSuffix(seg.Name(), segmentExt) && seg.ModTime().After(last) {
+				last = seg.ModTime()
+			}
+		}
+		written[string(appID)] = last
+	}
+
+	return written, nil
+}
+
+// Append writes the envelope to the app's active segment.
+func (s *Store) Append(appID string, e *marshalled.Envelope) error {
+	data, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[4:], data)
+
+	a, err := s.lockedAppFor(appID)
+	if err != nil {
+		return err
+	}
+	defer a.mu.Unlock()
+
+	if a.active == nil || a.segments[len(a.segments)-1].count >= s.segmentSize {
+		if err := a.activate(s.segmentSize); err != nil {
+			return err
+		}
+		a.truncate(s.maxPerApp)
+	}
+
+	if _, err := a.active.Write(record); err != nil {
+		return err
+	}
+
+	current := a.segments[len(a.segments)-1]
+	current.count++
+	current.modified = time.Now()
+	if current.cached {
+		current.records = append(current.records, e)
+	}
+	a.written = current.modified
+
+	return nil
+}
+
+// Read returns, oldest first, up to maxPerApp envelopes for the app that
+// are no older than maxAge.
+func (s *Store) Read(appID string) []*marshalled.Envelope {
+	if !s.exists(appID) {
+		return nil
+	}
+
+	a, err := s.appFor(appID)
+	if err != nil {
+		if err != errStopped {
+			log.Printf("logstore: failed to open recent logs for %s: %s", appID, err)
+		}
+		return nil
+	}
+
+	a.mu.Lock()
+	var segments [][]*marshalled.Envelope
+	for _, seg := range a.segments {
+		segments = append(segments, seg.read())
+	}
+	a.read = time.Now()
+	a.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.maxAge).UnixNano()
+	var envelopes []*marshalled.Envelope
+	for _, records := range segments {
+		for _, e := range records {
+			if s.maxAge > 0 && e.GetTimestamp() < cutoff {
+				continue
+			}
+			envelopes = append(envelopes, e)
+		}
+	}
+
+	if len(envelopes) > s.maxPerApp {
+		envelopes = envelopes[len(envelopes)-s.maxPerApp:]
+	}
+
+	return envelopes
+}
+
+// Start periodically closes the active segments of apps that have not been
+// written to since the previous tick, drops the cached envelopes of apps that
+// have not been read since then, and removes segments that have aged out. It
+// blocks until Stop is called.
+func (s *Store) Start() {
+	interval := maxPruneInterval
+	if s.maxAge > 0 && s.maxAge/10 < interval {
+		interval = s.maxAge / 10
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.releaseIdle(time.Now().Add(-interval))
+			if s.maxAge > 0 {
+				s.prune()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop closes the store, whether or not it was started. Appends after Stop
+// fail and reads return nothing.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.close()
+	})
+}
+
+// lockedAppFor returns the app's log for appending with its lock held. It
+// guards against the log having been removed between lookup and locking.
+func (s *Store) lockedAppFor(appID string) (*appLog, error) {
+	for {
+		a, err := s.appForAppend(appID)
+		if err != nil {
+			return nil, err
+		}
+
+		a.mu.Lock()
+		if !a.removed {
+			return a, nil
+		}
+		a.mu.Unlock()
+	}
+}
+
+// exists reports whether the app has been written to, avoiding tracking apps
+// that are only ever read.
+func (s *Store) exists(appID string) bool {
+	s.mu.Lock()
+	_, ok := s.apps[appID]
+	s.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	_, err := os.Stat(s.appDir(appID))
+	return err == nil
+}
+
+func (s *Store) appFor(appID string) (*appLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.appLocked(appID)
+}
+
+// appForAppend returns the app's log and records the write, first removing
+// the least recently written app if a new app would exceed maxApps.
+func (s *Store) appForAppend(appID string) (*appLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, errStopped
+	}
+
+	if _, ok := s.written[appID]; !ok && len(s.written) >= s.maxApps {
+		s.evictOldest()
+	}
+	s.written[appID] = time.Now()
+
+	return s.appLocked(appID)
+}
+
+// appLocked returns the app's log, reading it from disk if necessary. s.mu
+// must be held.
+func (s *Store) appLocked(appID string) (*appLog, error) {
+	if s.stopped {
+		return nil, errStopped
+	}
+
+	if a, ok := s.apps[appID]; ok {
+		return a, nil
+	}
+
+	a, err := loadApp(s.appDir(appID))
+	if err != nil {
+		return nil, err
+	}
+	s.apps[appID] = a
+
+	return a, nil
+}
+
+// prune removes the segments of every app on disk, including those that
+// have not been seen since the last restart, that have not been written to
+// within maxAge.
+func (s *Store) prune() {
+	finfos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("logstore: failed to read %s: %s", s.dir, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-s.maxAge)
+	for _, finfo := range finfos {
+		appID, err := hex.DecodeString(finfo.Name())
+		if err != nil || !finfo.IsDir() {
+			continue
+		}
+
+		s.expire(string(appID), cutoff)
+	}
+}
+
+// releaseIdle closes the active segments of apps that have not been written
+// to since cutoff and drops the cached envelopes of apps that have not been
+// read since then.
+func (s *Store) releaseIdle(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.apps {
+		a.mu.Lock()
+		if a.written.Before(cutoff) {
+			a.closeActive()
+		}
+		if a.read.Before(cutoff) {
+			a.dropCache()
+		}
+		a.mu.Unlock()
+	}
+}
+
+// evictOldest removes the app that was written to least recently. s.mu must
+// be held.
+func (s *Store) evictOldest() {
+	var oldestID string
+	var oldest time.Time
+	for appID, written := range s.written {
+		if oldestID == "" || written.Before(oldest) {
+			oldestID, oldest = appID, written
+		}
+	}
+
+	if a, ok := s.apps[oldestID]; ok {
+		a.mu.Lock()
+		a.closeActive()
+		a.removed = true
+		a.mu.Unlock()
+		delete(s.apps, oldestID)
+	}
+	delete(s.written, oldestID)
+
+	dir := s.appDir(oldestID)
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("logstore: failed to remove %s: %s", dir, err)
+	}
+}
+
+func (s *Store) expire(appID string, cutoff time.Time) {
+	a, err := s.appFor(appID)
+	if err != nil {
+		if err != errStopped {
+			log.Printf("logstore: failed to open recent logs for %s: %s", appID, err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.expire(cutoff)
+	if len(a.segments) > 0 {
+		return
+	}
+
+	a.removed = true
+	delete(s.apps, appID)
+	delete(s.written, appID)
+	if err := os.Remove(a.dir); err != nil && !os.IsNotExist(err) {
+		log.Printf("logstore: failed to remove %s: %s", a.dir, err)
+	}
+}
+
+func (s *Store) appDir(appID string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(appID)))
+}
+
+// close releases every app. Appends already holding an app's log see it as
+// removed and then fail to look it up again.
+func (s *Store) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	for appID, a := range s.apps {
+		a.mu.Lock()
+		a.closeActive()
+		a.removed = true
+		a.mu.Unlock()
+		delete(s.apps, appID)
+	}
+}
+
+// loadApp reads the existing segments for an app. Writes always go to a new
+// segment so that a record torn by a crash is never followed by more data.
+func loadApp(dir string) (*appLog, error) {
+	a := &appLog{dir: dir}
+
+	finfos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, finfo := range finfos {
+		if strings.HasSuffix(finfo.Name(), segmentExt) {
+			names = append(names, finfo.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		finfo, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		a.segments = append(a.segments, &segment{
+			path:     path,
+			count:    len(readSegment(path)),
+			modified: finfo.ModTime(),
+		})
+		a.nextID = id + 1
+	}
+
+	return a, nil
+}
+
+// activate opens the segment to append to. The last segment is reopened if
+// it was closed while it still had room, otherwise a new one is started.
+func (a *appLog) activate(segmentSize int) error {
+	if a.active == nil && len(a.segments) > 0 {
+		last := a.segments[len(a.segments)-1]
+		if last.appendable && last.count < segmentSize {
+			f, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0600)
+			if err == nil {
+				a.active = f
+				return nil
+			}
+		}
+	}
+
+	return a.rotate()
+}
+
+func (a *appLog) rotate() error {
+	if a.active != nil {
+		a.active.Close()
+		a.active = nil
+	}
+
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(a.dir, fmt.Sprintf("%020d%s", a.nextID, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	a.nextID++
+	a.active = f
+	a.segments = append(a.segments, &segment{
+		path:       path,
+		modified:   time.Now(),
+		appendable: true,
+	})
+
+	return nil
+}
+
+// truncate removes the oldest segments while the remaining segments still
+// hold at least max envelopes.
+func (a *appLog) truncate(max int) {
+	total := 0
+	for _, seg := range a.segments {
+		total += seg.count
+	}
+
+	for len(a.segments) > 1 && total-a.segments[0].count >= max {
+		total -= a.segments[0].count
+		a.remove()
+	}
+}
+
+// expire removes the segments that have not been written to since cutoff.
+func (a *appLog) expire(cutoff time.Time) {
+	for len(a.segments) > 0 && a.segments[0].modified.Before(cutoff) {
+		if len(a.segments) == 1 {
+			a.closeActive()
+		}
+		a.remove()
+	}
+}
+
+func (a *appLog) closeActive() {
+	if a.active != nil {
+		a.active.Close()
+		a.active = nil
+	}
+}
+
+func (a *appLog) dropCache() {
+	for _, seg := range a.segments {
+		seg.records = nil
+		seg.cached = false
+	}
+}
+
+func (a *appLog) remove() {
+	if err := os.Remove(a.segments[0].path); err != nil && !os.IsNotExist(err) {
+		log.Printf("logstore: failed to remove segment %s: %s", a.segments[0].path, err)
+	}
+	a.segments = a.segments[1:]
+}
+
+// read returns the segment's envelopes, reading them from disk unless they
+// are cached.
+func (seg *segment) read() []*marshalled.Envelope {
+	if !seg.cached {
+		seg.records = readSegment(seg.path)
+		seg.cached = true
+	}
+	return seg.records
+}
+
+// readSegment returns the envelopes in a segment. A torn or corrupt record
+// ends the segment.
+func readSegment(path string) []*marshalled.Envelope {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var envelopes []*marshalled.Envelope
+	for len(data) >= 4 {
+		size := binary.BigEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			break
+		}
+
+		var e events.Envelope
+		if err := proto.Unmarshal(data[4:4+size], &e); err != nil {
+			break
+		}
+		envelopes = append(envelopes, marshalled.WithBytes(&e, data[4:4+size:4+size]))
+		data = data[4+size:]
+	}
+
+	return envelopes
+}
@@ -0,0 +1,267 @@
+package logstore_test
+
+import (
+	"doppler/logstore"
+	"io/ioutil"
+	"marshalled"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/dropsonde/factories"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir   string
+		store *logstore.Store
+	)
+
+	var logMessage = func(appID, msg string) *events.Envelope {
+		e, err := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, msg, appID, "App"), "origin")
+		Expect(err).ToNot(HaveOccurred())
+		return e
+	}
+
+	var messages = func(envelopes []*marshalled.Envelope) []string {
+		var result []string
+		for _, e := range envelopes {
+			result = append(result, string(e.GetLogMessage().GetMessage()))
+		}
+		return result
+	}
+
+	var segmentCount = func() int {
+		var count int
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && filepath.Ext(path) == ".seg" {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+
+	var openSegments = func() int {
+		fds, err := ioutil.ReadDir("/proc/self/fd")
+		Expect(err).ToNot(HaveOccurred())
+
+		var count int
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+			if err == nil && strings.HasPrefix(target, dir) && filepath.Ext(target) == ".seg" {
+				count++
+			}
+		}
+		return count
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "logstore")
+		Expect(err).ToNot(HaveOccurred())
+
+		store, err = logstore.New(dir, 8, 100, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("returns appended envelopes in order", func() {
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).To(Succeed())
+		Expect(store.Append("app-b", marshalled.New(logMessage("app-b", "3")))).To(Succeed())
+
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"1", "2"}))
+		Expect(messages(store.Read("app-b"))).To(Equal([]string{"3"}))
+	})
+
+	It("returns nothing for unknown apps", func() {
+		Expect(store.Read("unknown")).To(BeEmpty())
+	})
+
+	It("caps the envelopes returned per app", func() {
+		for i := 0; i < 20; i++ {
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", strconv.Itoa(i))))).To(Succeed())
+		}
+
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{
+			"12", "13", "14", "15", "16", "17", "18", "19",
+		}))
+	})
+
+	It("removes old segments once newer segments hold the cap", func() {
+		for i := 0; i < 100; i++ {
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", strconv.Itoa(i))))).To(Succeed())
+		}
+
+		Expect(segmentCount()).To(BeNumerically("<=", 5))
+	})
+
+	It("survives being reopened", func() {
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).To(Succeed())
+
+		reopened, err := logstore.New(dir, 8, 100, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reopened.Append("app-a", marshalled.New(logMessage("app-a", "3")))).To(Succeed())
+
+		Expect(messages(reopened.Read("app-a"))).To(Equal([]string{"1", "2", "3"}))
+	})
+
+	It("ignores a torn record at the end of a segment", func() {
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+
+		matches, err := filepath.Glob(filepath.Join(dir, "*", "*.seg"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matches).To(HaveLen(1))
+		f, err := os.OpenFile(matches[0], os.O_WRONLY|os.O_APPEND, 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.Write([]byte{0, 0, 1, 0, 1, 2})
+		Expect(err).ToNot(HaveOccurred())
+		f.Close()
+
+		reopened, err := logstore.New(dir, 8, 100, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(messages(reopened.Read("app-a"))).To(Equal([]string{"1"}))
+	})
+
+	It("does not return envelopes older than the max age", func() {
+		old := logMessage("app-a", "old")
+		old.Timestamp = proto.Int64(time.Now().Add(-2 * time.Hour).UnixNano())
+		Expect(store.Append("app-a", marshalled.New(old))).To(Succeed())
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "new")))).To(Succeed())
+
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"new"}))
+	})
+
+	It("keeps envelopes regardless of age when the max age is zero", func() {
+		store, err := logstore.New(dir, 8, 100, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		old := logMessage("app-a", "old")
+		old.Timestamp = proto.Int64(time.Now().Add(-2 * time.Hour).UnixNano())
+		Expect(store.Append("app-a", marshalled.New(old))).To(Succeed())
+
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"old"}))
+	})
+
+	It("removes the least recently written app to stay within the max apps", func() {
+		store, err := logstore.New(dir, 8, 2, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+		Expect(store.Append("app-b", marshalled.New(logMessage("app-b", "2")))).To(Succeed())
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "3")))).To(Succeed())
+		Expect(store.Append("app-c", marshalled.New(logMessage("app-c", "4")))).To(Succeed())
+
+		Expect(store.Read("app-b")).To(BeEmpty())
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"1", "3"}))
+		Expect(messages(store.Read("app-c"))).To(Equal([]string{"4"}))
+		dirs, err := ioutil.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dirs).To(HaveLen(2))
+	})
+
+	It("counts the apps already on disk towards the max apps", func() {
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+		Expect(store.Append("app-b", marshalled.New(logMessage("app-b", "2")))).To(Succeed())
+		store.Stop()
+
+		reopened, err := logstore.New(dir, 8, 2, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reopened.Append("app-c", marshalled.New(logMessage("app-c", "3")))).To(Succeed())
+
+		dirs, err := ioutil.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dirs).To(HaveLen(2))
+	})
+
+	It("stops without having been started", func() {
+		done := make(chan struct{})
+		go func() {
+			store.Stop()
+			store.Stop()
+			close(done)
+		}()
+
+		Eventually(done).Should(BeClosed())
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).ToNot(Succeed())
+	})
+
+	It("does not read segments from disk again while the app is in use", func() {
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"1"}))
+
+		matches, err := filepath.Glob(filepath.Join(dir, "*", "*.seg"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(matches).To(HaveLen(1))
+		Expect(ioutil.WriteFile(matches[0], nil, 0600)).To(Succeed())
+
+		Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).To(Succeed())
+		Expect(messages(store.Read("app-a"))).To(Equal([]string{"1", "2"}))
+	})
+
+	Context("when started", func() {
+		BeforeEach(func() {
+			var err error
+			store, err = logstore.New(dir, 8, 100, 100*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			go store.Start()
+		})
+
+		AfterEach(func() {
+			store.Stop()
+		})
+
+		It("removes segments that have not been written to within the max age", func() {
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+			Expect(segmentCount()).To(Equal(1))
+
+			Eventually(segmentCount).Should(Equal(0))
+			Expect(store.Read("app-a")).To(BeEmpty())
+
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).To(Succeed())
+			Expect(messages(store.Read("app-a"))).To(Equal([]string{"2"}))
+		})
+
+		It("closes the segments of idle apps and reopens them on the next append", func() {
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+			Expect(openSegments()).To(Equal(1))
+
+			Eventually(openSegments).Should(Equal(0))
+
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).To(Succeed())
+			Expect(segmentCount()).To(Equal(1))
+			Expect(messages(store.Read("app-a"))).To(Equal([]string{"1", "2"}))
+		})
+	})
+
+	Context("when stopped", func() {
+		BeforeEach(func() {
+			go store.Start()
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "1")))).To(Succeed())
+			store.Stop()
+		})
+
+		It("closes its segments", func() {
+			Expect(openSegments()).To(Equal(0))
+		})
+
+		It("rejects appends", func() {
+			Expect(store.Append("app-a", marshalled.New(logMessage("app-a", "2")))).ToNot(Succeed())
+			Expect(openSegments()).To(Equal(0))
+			Expect(segmentCount()).To(Equal(1))
+		})
+	})
+})
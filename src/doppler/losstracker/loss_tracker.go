@@ -0,0 +1,120 @@
+package losstracker
+
+import (
+	"plumbing"
+	v2 "plumbing/v2"
+	"strconv"
+	"sync"
+
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type Batcher interface {
+	BatchCounter(name string) metricbatcher.BatchCounterChainer
+}
+
+// LossTracker detects gaps in the sequence numbers Metron stamps on its
+// envelopes and emits them as lost envelopes. A LossTracker is shared by all
+// of a Doppler's streams so that envelopes lost when a Metron connection
+// reconnects are counted.
+type LossTracker struct {
+	batcher Batcher
+
+	mu      sync.Mutex
+	sources map[string]*position
+}
+
+// position is the last envelope received from a Metron connection.
+type position struct {
+	stream uint64
+	seq    uint64
+}
+
+func New(batcher Batcher) *LossTracker {
+	return &LossTracker{
+		batcher: batcher,
+		sources: make(map[string]*position),
+	}
+}
+
+// TrackV1 records the sequence number of a v1 envelope and removes the
+// sequence tags from it.
+func (t *LossTracker) TrackV1(e *events.Envelope) {
+	seqTag, ok := e.Tags[plumbing.SequenceTag]
+	if !ok {
+		return
+	}
+	source := e.Tags[plumbing.SourceTag]
+	streamTag := e.Tags[plumbing.StreamTag]
+	delete(e.Tags, plumbing.SequenceTag)
+	delete(e.Tags, plumbing.StreamTag)
+	delete(e.Tags, plumbing.SourceTag)
+
+	seq, err := strconv.ParseUint(seqTag, 10, 64)
+	if err != nil {
+		return
+	}
+	stream, err := strconv.ParseUint(streamTag, 10, 64)
+	if err != nil {
+		return
+	}
+	t.track(source, stream, seq)
+}
+
+// TrackV2 records the sequence number of a v2 envelope and removes the
+// sequence tags from it.
+func (t *LossTracker) TrackV2(e *v2.Envelope) {
+	seqTag, ok := e.Tags[plumbing.SequenceTag]
+	if !ok {
+		return
+	}
+	source := e.Tags[plumbing.SourceTag].GetText()
+	stream := e.Tags[plumbing.StreamTag].GetInteger()
+	delete(e.Tags, plumbing.SequenceTag)
+	delete(e.Tags, plumbing.StreamTag)
+	delete(e.Tags, plumbing.SourceTag)
+
+	seq := seqTag.GetInteger()
+	if seq <= 0 || stream <= 0 {
+		return
+	}
+	t.track(source, uint64(stream), uint64(seq))
+}
+
+func (t *LossTracker) track(source string, stream, seq uint64) {
+	t.mu.Lock()
+	last, ok := t.sources[source]
+	if !ok {
+		last = &position{}
+		t.sources[source] = last
+	}
+
+	if stream < last.stream && seq <= last.seq {
+		// The envelope was delayed on a stream that has since been
+		// replaced.
+		t.mu.Unlock()
+		return
+	}
+
+	// Envelopes are only counted as lost between envelopes received on the
+	// same stream or on consecutive streams. Otherwise the connection's
+	// streams in between went to other Dopplers, or the Metron restarted,
+	// and the envelope starts a new baseline.
+	var gap uint64
+	if seq > last.seq && (stream == last.stream || stream == last.stream+1) {
+		gap = seq - last.seq - 1
+	}
+	last.stream = stream
+	last.seq = seq
+	t.mu.Unlock()
+
+	if gap > 0 {
+		deployment, job, index := plumbing.ParseMetronSource(source)
+		t.batcher.BatchCounter("listeners.lostEnvelopes").
+			SetTag("metron_deployment", deployment).
+			SetTag("metron_job", job).
+			SetTag("metron_index", index).
+			Add(gap)
+	}
+}
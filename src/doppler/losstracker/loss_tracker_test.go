@@ -0,0 +1,160 @@
+//go:generate hel
+
+package losstracker_test
+
+import (
+	"doppler/losstracker"
+	"plumbing"
+	v2 "plumbing/v2"
+	"strconv"
+
+	"github.com/apoydence/eachers/testhelpers"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LossTracker", func() {
+	var (
+		tracker     *losstracker.LossTracker
+		mockBatcher *mockBatcher
+		mockChainer *mockBatchCounterChainer
+	)
+
+	BeforeEach(func() {
+		mockBatcher = newMockBatcher()
+		mockChainer = newMockBatchCounterChainer()
+		testhelpers.AlwaysReturn(mockBatcher.BatchCounterOutput, mockChainer)
+		testhelpers.AlwaysReturn(mockChainer.SetTagOutput, mockChainer)
+
+		tracker = losstracker.New(mockBatcher)
+	})
+
+	Describe("TrackV1", func() {
+		It("removes the sequence tags", func() {
+			e := v1Envelope(1, "1")
+
+			tracker.TrackV1(e)
+
+			Expect(e.Tags).To(Equal(map[string]string{"some-tag": "some-value"}))
+		})
+
+		It("does not emit when there are no gaps", func() {
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(1, "2"))
+
+			Expect(mockBatcher.BatchCounterCalled).To(BeEmpty())
+		})
+
+		It("emits the size of a gap tagged with the source metron", func() {
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(1, "5"))
+
+			Expect(mockBatcher.BatchCounterInput.Name).To(Receive(Equal("listeners.lostEnvelopes")))
+			Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(3))))
+			Expect(mockChainer.SetTagInput.Key).To(Receive(Equal("metron_deployment")))
+			Expect(mockChainer.SetTagInput.Value).To(Receive(Equal("some-deployment")))
+			Expect(mockChainer.SetTagInput.Key).To(Receive(Equal("metron_job")))
+			Expect(mockChainer.SetTagInput.Value).To(Receive(Equal("some-job")))
+			Expect(mockChainer.SetTagInput.Key).To(Receive(Equal("metron_index")))
+			Expect(mockChainer.SetTagInput.Value).To(Receive(Equal("0")))
+		})
+
+		It("counts envelopes lost before the first one received", func() {
+			tracker.TrackV1(v1Envelope(1, "3"))
+
+			Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(2))))
+		})
+
+		It("counts envelopes lost when a stream is replaced by one to the same doppler", func() {
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(2, "4"))
+
+			Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(2))))
+		})
+
+		It("does not count envelopes sent on streams to other dopplers", func() {
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(3, "10"))
+			tracker.TrackV1(v1Envelope(3, "11"))
+
+			Expect(mockBatcher.BatchCounterCalled).To(BeEmpty())
+		})
+
+		It("does not count envelopes before the first received from a later stream", func() {
+			tracker.TrackV1(v1Envelope(2, "10"))
+
+			Expect(mockBatcher.BatchCounterCalled).To(BeEmpty())
+		})
+
+		It("does not count envelopes after the metron restarts", func() {
+			tracker.TrackV1(v1Envelope(3, "10"))
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(1, "2"))
+
+			Expect(mockBatcher.BatchCounterCalled).To(BeEmpty())
+		})
+
+		It("ignores envelopes delayed on a replaced stream", func() {
+			tracker.TrackV1(v1Envelope(1, "1"))
+			tracker.TrackV1(v1Envelope(2, "3"))
+			tracker.TrackV1(v1Envelope(1, "2"))
+			tracker.TrackV1(v1Envelope(2, "4"))
+
+			Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(1))))
+			Expect(mockChainer.AddInput.Value).ToNot(Receive())
+		})
+
+		It("ignores envelopes without a sequence tag", func() {
+			e := &events.Envelope{Origin: proto.String("some-origin")}
+
+			tracker.TrackV1(e)
+
+			Expect(mockBatcher.BatchCounterCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("TrackV2", func() {
+		It("removes the sequence tags and emits gaps", func() {
+			tracker.TrackV2(v2Envelope(1, 1))
+			e := v2Envelope(1, 3)
+
+			tracker.TrackV2(e)
+
+			Expect(e.Tags).To(HaveLen(1))
+			Expect(e.Tags).To(HaveKey("some-tag"))
+			Expect(mockChainer.AddInput.Value).To(Receive(Equal(uint64(1))))
+		})
+	})
+})
+
+func v1Envelope(stream int, seq string) *events.Envelope {
+	return &events.Envelope{
+		Origin: proto.String("some-origin"),
+		Tags: map[string]string{
+			"some-tag":           "some-value",
+			plumbing.SequenceTag: seq,
+			plumbing.StreamTag:   strconv.Itoa(stream),
+			plumbing.SourceTag:   "some-deployment/some-job/0/0",
+		},
+	}
+}
+
+func v2Envelope(stream, seq int64) *v2.Envelope {
+	return &v2.Envelope{
+		Tags: map[string]*v2.Value{
+			"some-tag": {Data: &v2.Value_Text{Text: "some-value"}},
+			plumbing.SequenceTag: {
+				Data: &v2.Value_Integer{Integer: seq},
+			},
+			plumbing.StreamTag: {
+				Data: &v2.Value_Integer{Integer: stream},
+			},
+			plumbing.SourceTag: {
+				Data: &v2.Value_Text{Text: "some-deployment/some-job/0/0"},
+			},
+		},
+	}
+}
@@ -0,0 +1,13 @@
+package losstracker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLossTracker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LossTracker Suite")
+}
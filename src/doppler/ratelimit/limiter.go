@@ -0,0 +1,193 @@
+// Package ratelimit limits the rate at which each app's envelopes are routed
+// by Doppler so that one app cannot crowd out the others.
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/emitter"
+	"github.com/cloudfoundry/dropsonde/envelope_extensions"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+type Batcher interface {
+	BatchCounter(name string) metricbatcher.BatchCounterChainer
+}
+
+// Limit is the rate at which an app's envelopes are allowed, with bursts of
+// up to Burst envelopes. A zero Burst allows a second's worth of envelopes
+// at once. A zero MessagesPerSecond does not limit the app.
+type Limit struct {
+	MessagesPerSecond float64
+	Burst             int
+}
+
+type bucket struct {
+	limit      Limit
+	tokens     float64
+	last       time.Time
+	dropped    uint64
+	lastNotice time.Time
+}
+
+// Limiter is a token bucket per app. Envelopes that do not belong to an app
+// are never limited. Buckets are only kept for apps that have used some of
+// their burst recently, or that are owed a notice.
+type Limiter struct {
+	limit          Limit
+	overrides      map[string]Limit
+	noticeInterval time.Duration
+	origin         string
+	batcher        Batcher
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New creates a Limiter that applies limit to every app except those in
+// overrides, which are keyed by app ID. Throttled apps are sent a notice of
+// the envelopes dropped at most once per noticeInterval.
+func New(limit Limit, overrides map[string]Limit, noticeInterval time.Duration, origin string, batcher Batcher) *Limiter {
+	return &Limiter{
+		limit:          limit,
+		overrides:      overrides,
+		noticeInterval: noticeInterval,
+		origin:         origin,
+		batcher:        batcher,
+		buckets:        make(map[string]*bucket),
+	}
+}
+
+// Allow reports whether an envelope for the app may be routed. While an app
+// has envelopes dropped it also returns a notice for the app saying how many
+// were dropped since its last notice. Drops that are not yet due a notice
+// are reported by Flush.
+func (l *Limiter) Allow(appID string) (bool, *events.Envelope) {
+	if appID == envelope_extensions.SystemAppId {
+		return true, nil
+	}
+
+	limit := l.limitFor(appID)
+	if limit.MessagesPerSecond <= 0 {
+		return true, nil
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[appID]
+	if !ok {
+		b = &bucket{
+			limit:  limit,
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+		l.buckets[appID] = b
+	}
+
+	b.refill(now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	} else {
+		b.dropped++
+		l.batcher.BatchCounter("doppler.rateLimitedEnvelopes").
+			SetTag("app_id", appID).
+			Increment()
+	}
+
+	return allowed, l.dueNotice(appID, b, now)
+}
+
+// Flush returns the notices due to apps whose envelopes were dropped since
+// their last notice, so that an app is told about drops even if it sends
+// nothing more. It also forgets the apps whose buckets have refilled and
+// are owed nothing, which a new bucket would treat the same.
+func (l *Limiter) Flush() []*events.Envelope {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var notices []*events.Envelope
+	for appID, b := range l.buckets {
+		if notice := l.dueNotice(appID, b, now); notice != nil {
+			notices = append(notices, notice)
+		}
+
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) && b.dropped == 0 && now.Sub(b.lastNotice) >= l.noticeInterval {
+			delete(l.buckets, appID)
+		}
+	}
+
+	return notices
+}
+
+// Len returns the number of apps the limiter is keeping a bucket for.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.MessagesPerSecond
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+}
+
+// dueNotice returns a notice of the app's dropped envelopes if it has not
+// been sent one within the notice interval.
+func (l *Limiter) dueNotice(appID string, b *bucket, now time.Time) *events.Envelope {
+	if b.dropped == 0 || now.Sub(b.lastNotice) < l.noticeInterval {
+		return nil
+	}
+
+	notice := l.notice(appID, b.dropped)
+	b.dropped = 0
+	b.lastNotice = now
+	return notice
+}
+
+func (l *Limiter) limitFor(appID string) Limit {
+	limit, ok := l.overrides[appID]
+	if !ok {
+		limit = l.limit
+	}
+
+	if limit.Burst < 1 {
+		limit.Burst = int(limit.MessagesPerSecond)
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return limit
+}
+
+func (l *Limiter) notice(appID string, dropped uint64) *events.Envelope {
+	messageType := events.LogMessage_ERR
+	logMessage := &events.LogMessage{
+		Message:     []byte(fmt.Sprintf("log rate exceeded, %d messages dropped", dropped)),
+		AppId:       proto.String(appID),
+		MessageType: &messageType,
+		SourceType:  proto.String("LGR"),
+		Timestamp:   proto.Int64(time.Now().UnixNano()),
+	}
+
+	env, err := emitter.Wrap(logMessage, l.origin)
+	if err != nil {
+		return nil
+	}
+	return env
+}
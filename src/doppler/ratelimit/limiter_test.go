@@ -0,0 +1,178 @@
+package ratelimit_test
+
+import (
+	"doppler/ratelimit"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/sonde-go/events"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		sender  *fake.FakeMetricSender
+		batcher *metricbatcher.MetricBatcher
+	)
+
+	BeforeEach(func() {
+		sender = fake.NewFakeMetricSender()
+		batcher = metricbatcher.New(sender, time.Millisecond)
+	})
+
+	var allowed = func(limiter *ratelimit.Limiter, appID string, n int) int {
+		var count int
+		for i := 0; i < n; i++ {
+			if ok, _ := limiter.Allow(appID); ok {
+				count++
+			}
+		}
+		return count
+	}
+
+	It("does not limit apps without a rate", func() {
+		limiter := ratelimit.New(ratelimit.Limit{}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "some-app", 100)).To(Equal(100))
+	})
+
+	It("allows a burst and then drops envelopes", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 3}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "some-app", 10)).To(Equal(3))
+	})
+
+	It("allows a second's worth of envelopes at once without a burst", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 5}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "some-app", 10)).To(Equal(5))
+	})
+
+	It("refills the bucket over time", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 100, Burst: 1}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "some-app", 2)).To(Equal(1))
+		time.Sleep(50 * time.Millisecond)
+		Expect(allowed(limiter, "some-app", 1)).To(Equal(1))
+	})
+
+	It("limits each app separately", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 3}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "some-app", 10)).To(Equal(3))
+		Expect(allowed(limiter, "other-app", 10)).To(Equal(3))
+	})
+
+	It("applies the overrides for an app", func() {
+		overrides := map[string]ratelimit.Limit{
+			"noisy-app":     {MessagesPerSecond: 1, Burst: 1},
+			"unlimited-app": {},
+		}
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 3}, overrides, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "noisy-app", 10)).To(Equal(1))
+		Expect(allowed(limiter, "unlimited-app", 10)).To(Equal(10))
+		Expect(allowed(limiter, "some-app", 10)).To(Equal(3))
+	})
+
+	It("does not limit envelopes without an app", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 1}, nil, time.Second, "doppler", batcher)
+
+		Expect(allowed(limiter, "system", 10)).To(Equal(10))
+	})
+
+	It("counts the dropped envelopes", func() {
+		limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 3}, nil, time.Second, "doppler", batcher)
+		allowed(limiter, "some-app", 10)
+
+		Eventually(func() uint64 {
+			return sender.GetCounter("doppler.rateLimitedEnvelopes")
+		}).Should(BeEquivalentTo(7))
+	})
+
+	Describe("notices", func() {
+		var limiter *ratelimit.Limiter
+
+		BeforeEach(func() {
+			limiter = ratelimit.New(ratelimit.Limit{MessagesPerSecond: 1, Burst: 1}, nil, 100*time.Millisecond, "doppler", batcher)
+		})
+
+		It("does not send a notice while nothing is dropped", func() {
+			_, notice := limiter.Allow("some-app")
+			Expect(notice).To(BeNil())
+		})
+
+		It("sends the app a notice when it is first throttled", func() {
+			limiter.Allow("some-app")
+			_, notice := limiter.Allow("some-app")
+
+			Expect(notice).ToNot(BeNil())
+			Expect(notice.GetOrigin()).To(Equal("doppler"))
+			Expect(notice.GetLogMessage().GetAppId()).To(Equal("some-app"))
+			Expect(notice.GetLogMessage().GetSourceType()).To(Equal("LGR"))
+			Expect(notice.GetLogMessage().GetMessageType()).To(Equal(events.LogMessage_ERR))
+			Expect(string(notice.GetLogMessage().GetMessage())).To(Equal("log rate exceeded, 1 messages dropped"))
+		})
+
+		It("sends at most one notice per interval with the drops since the last", func() {
+			limiter.Allow("some-app")
+			limiter.Allow("some-app")
+
+			for i := 0; i < 5; i++ {
+				_, notice := limiter.Allow("some-app")
+				Expect(notice).To(BeNil())
+			}
+
+			time.Sleep(150 * time.Millisecond)
+			_, notice := limiter.Allow("some-app")
+
+			Expect(notice).ToNot(BeNil())
+			Expect(string(notice.GetLogMessage().GetMessage())).To(Equal("log rate exceeded, 6 messages dropped"))
+		})
+
+		It("flushes the drops since the last notice once the interval has passed", func() {
+			limiter.Allow("some-app")
+			limiter.Allow("some-app")
+			limiter.Allow("some-app")
+			Expect(limiter.Flush()).To(BeEmpty())
+
+			time.Sleep(150 * time.Millisecond)
+			notices := limiter.Flush()
+
+			Expect(notices).To(HaveLen(1))
+			Expect(notices[0].GetLogMessage().GetAppId()).To(Equal("some-app"))
+			Expect(string(notices[0].GetLogMessage().GetMessage())).To(Equal("log rate exceeded, 1 messages dropped"))
+			Expect(limiter.Flush()).To(BeEmpty())
+		})
+	})
+
+	Describe("Flush", func() {
+		It("forgets apps whose buckets have refilled", func() {
+			limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 100, Burst: 1}, nil, time.Millisecond, "doppler", batcher)
+			limiter.Allow("some-app")
+			limiter.Allow("other-app")
+			Expect(limiter.Len()).To(Equal(2))
+
+			time.Sleep(50 * time.Millisecond)
+			limiter.Allow("other-app")
+			limiter.Flush()
+
+			Expect(limiter.Len()).To(Equal(1))
+		})
+
+		It("keeps apps that are owed a notice", func() {
+			limiter := ratelimit.New(ratelimit.Limit{MessagesPerSecond: 100, Burst: 1}, nil, time.Minute, "doppler", batcher)
+			limiter.Allow("some-app")
+			limiter.Allow("some-app")
+			limiter.Allow("some-app")
+
+			time.Sleep(50 * time.Millisecond)
+			limiter.Flush()
+
+			Expect(limiter.Len()).To(Equal(1))
+		})
+	})
+})
@@ -1,30 +1,54 @@
 package containermetric
 
 import (
+	"marshalled"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
+// History configures the time series of container metrics retained for each
+// instance. Only the latest metric within each Resolution is kept, for up to
+// Window. It is disabled when Window is zero.
+type History struct {
+	Resolution time.Duration
+	Window     time.Duration
+}
+
 type ContainerMetricSink struct {
 	appID              string
 	ttl                time.Duration
-	metrics            map[int32]*events.Envelope
+	metrics            map[int32]*marshalled.Envelope
+	history            History
+	series             map[int32][]*marshalled.Envelope
 	inactivityDuration time.Duration
 	lock               sync.RWMutex
 }
 
 func NewContainerMetricSink(appID string, ttl time.Duration, inactivityDuration time.Duration) *ContainerMetricSink {
+	return NewContainerMetricSinkWithHistory(appID, ttl, inactivityDuration, History{})
+}
+
+// NewContainerMetricSinkWithHistory returns a sink that also retains a
+// downsampled history of each instance's metrics.
+func NewContainerMetricSinkWithHistory(appID string, ttl, inactivityDuration time.Duration, history History) *ContainerMetricSink {
+	if history.Resolution <= 0 {
+		history.Resolution = time.Minute
+	}
+
 	return &ContainerMetricSink{
 		appID:              appID,
 		ttl:                ttl,
 		inactivityDuration: inactivityDuration,
-		metrics:            make(map[int32]*events.Envelope),
+		metrics:            make(map[int32]*marshalled.Envelope),
+		history:            history,
+		series:             make(map[int32][]*marshalled.Envelope),
 	}
 }
 
-func (sink *ContainerMetricSink) Run(eventChan <-chan *events.Envelope) {
+func (sink *ContainerMetricSink) Run(eventChan <-chan *marshalled.Envelope) {
 
 	timer := time.NewTimer(sink.inactivityDuration)
 	for {
@@ -48,11 +72,11 @@ func (sink *ContainerMetricSink) Run(eventChan <-chan *events.Envelope) {
 	}
 }
 
-func (sink *ContainerMetricSink) GetLatest() []*events.Envelope {
+func (sink *ContainerMetricSink) GetLatest() []*marshalled.Envelope {
 	sink.lock.Lock()
 	defer sink.lock.Unlock()
 
-	envelopes := []*events.Envelope{}
+	envelopes := []*marshalled.Envelope{}
 
 	earliestLiveTimestamp := time.Now().Add(-sink.ttl)
 
@@ -70,6 +94,36 @@ func (sink *ContainerMetricSink) GetLatest() []*events.Envelope {
 	return envelopes
 }
 
+// GetHistory returns the retained metrics with timestamps at or after
+// startTime, ordered by instance index and then by timestamp.
+func (sink *ContainerMetricSink) GetHistory(startTime int64) []*marshalled.Envelope {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	cutoff := time.Now().Add(-sink.history.Window).UnixNano()
+	if startTime > cutoff {
+		cutoff = startTime
+	}
+
+	instances := make([]int, 0, len(sink.series))
+	for instanceIndex := range sink.series {
+		instances = append(instances, int(instanceIndex))
+	}
+	sort.Ints(instances)
+
+	envelopes := []*marshalled.Envelope{}
+	for _, instanceIndex := range instances {
+		series := sink.expire(int32(instanceIndex))
+		for _, env := range series {
+			if env.GetTimestamp() >= cutoff {
+				envelopes = append(envelopes, env)
+			}
+		}
+	}
+
+	return envelopes
+}
+
 func (sink *ContainerMetricSink) AppID() string {
 	return sink.appID
 }
@@ -82,7 +136,7 @@ func (sink *ContainerMetricSink) ShouldReceiveErrors() bool {
 	return false
 }
 
-func (sink *ContainerMetricSink) updateMetric(event *events.Envelope) {
+func (sink *ContainerMetricSink) updateMetric(event *marshalled.Envelope) {
 	sink.lock.Lock()
 	defer sink.lock.Unlock()
 
@@ -93,4 +147,60 @@ func (sink *ContainerMetricSink) updateMetric(event *events.Envelope) {
 	if !ok || oldMetric.GetTimestamp() < event.GetTimestamp() {
 		sink.metrics[instance] = event
 	}
+
+	if sink.history.Window > 0 {
+		sink.record(instance, event)
+	}
+}
+
+// record adds the event to the instance's series, replacing any older metric
+// that falls within the same resolution interval.
+func (sink *ContainerMetricSink) record(instance int32, event *marshalled.Envelope) {
+	series := sink.series[instance]
+	interval := sink.interval(event)
+
+	// Metrics almost always arrive in order, so search from the end.
+	i := len(series)
+	for i > 0 && sink.interval(series[i-1]) > interval {
+		i--
+	}
+
+	if i > 0 && sink.interval(series[i-1]) == interval {
+		if series[i-1].GetTimestamp() < event.GetTimestamp() {
+			series[i-1] = event
+		}
+		return
+	}
+
+	series = append(series, nil)
+	copy(series[i+1:], series[i:])
+	series[i] = event
+	sink.series[instance] = series
+
+	sink.expire(instance)
+}
+
+// expire removes the metrics older than the history window from the
+// instance's series, forgetting the instance once none remain.
+func (sink *ContainerMetricSink) expire(instance int32) []*marshalled.Envelope {
+	series := sink.series[instance]
+	cutoff := time.Now().Add(-sink.history.Window).UnixNano()
+
+	n := 0
+	for n < len(series) && series[n].GetTimestamp() < cutoff {
+		n++
+	}
+
+	if n == len(series) {
+		delete(sink.series, instance)
+		return nil
+	}
+
+	series = series[n:]
+	sink.series[instance] = series
+	return series
+}
+
+func (sink *ContainerMetricSink) interval(event *marshalled.Envelope) int64 {
+	return event.GetTimestamp() / int64(sink.history.Resolution)
 }
@@ -2,6 +2,7 @@ package containermetric_test
 
 import (
 	"doppler/sinks/containermetric"
+	"marshalled"
 	"sync"
 	"time"
 
@@ -15,11 +16,11 @@ import (
 var _ = Describe("Containermetric", func() {
 	var (
 		sink      *containermetric.ContainerMetricSink
-		eventChan chan *events.Envelope
+		eventChan chan *marshalled.Envelope
 	)
 
 	BeforeEach(func() {
-		eventChan = make(chan *events.Envelope)
+		eventChan = make(chan *marshalled.Envelope)
 
 		sink = containermetric.NewContainerMetricSink("myApp", 2*time.Second, 2*time.Second)
 		go sink.Run(eventChan)
@@ -80,9 +81,9 @@ var _ = Describe("Containermetric", func() {
 
 			Eventually(sink.GetLatest).Should(ConsistOf(m1))
 
-			eventChan <- &events.Envelope{
+			eventChan <- marshalled.New(&events.Envelope{
 				EventType: events.Envelope_LogMessage.Enum(),
-			}
+			})
 
 			Consistently(sink.GetLatest).Should(ConsistOf(m1))
 		})
@@ -96,6 +97,84 @@ var _ = Describe("Containermetric", func() {
 		})
 	})
 
+	Describe("GetHistory", func() {
+		var (
+			historySink *containermetric.ContainerMetricSink
+			historyChan chan *marshalled.Envelope
+			base        time.Time
+		)
+
+		BeforeEach(func() {
+			historyChan = make(chan *marshalled.Envelope)
+			historySink = containermetric.NewContainerMetricSinkWithHistory("myApp", 2*time.Second, 2*time.Second, containermetric.History{
+				Resolution: time.Minute,
+				Window:     time.Hour,
+			})
+			go historySink.Run(historyChan)
+
+			base = time.Now().Truncate(time.Minute).Add(-30 * time.Minute)
+		})
+
+		AfterEach(func() {
+			close(historyChan)
+		})
+
+		It("keeps the latest metric within each resolution interval", func() {
+			m1 := metricFor(1, base.Add(10*time.Second), 1, 1, 1)
+			m2 := metricFor(1, base.Add(20*time.Second), 2, 2, 2)
+			m3 := metricFor(1, base.Add(70*time.Second), 3, 3, 3)
+			historyChan <- m1
+			historyChan <- m2
+			historyChan <- m3
+
+			Eventually(func() []*marshalled.Envelope {
+				return historySink.GetHistory(0)
+			}).Should(Equal([]*marshalled.Envelope{m2, m3}))
+		})
+
+		It("orders metrics by instance and then by timestamp", func() {
+			m1 := metricFor(2, base, 1, 1, 1)
+			m2 := metricFor(1, base.Add(2*time.Minute), 2, 2, 2)
+			m3 := metricFor(1, base.Add(time.Minute), 3, 3, 3)
+			historyChan <- m1
+			historyChan <- m2
+			historyChan <- m3
+
+			Eventually(func() []*marshalled.Envelope {
+				return historySink.GetHistory(0)
+			}).Should(Equal([]*marshalled.Envelope{m3, m2, m1}))
+		})
+
+		It("does not return metrics older than the window", func() {
+			m1 := metricFor(1, time.Now().Add(-2*time.Hour), 1, 1, 1)
+			m2 := metricFor(1, base, 2, 2, 2)
+			historyChan <- m1
+			historyChan <- m2
+
+			Eventually(func() []*marshalled.Envelope {
+				return historySink.GetHistory(0)
+			}).Should(Equal([]*marshalled.Envelope{m2}))
+		})
+
+		It("does not return metrics before the start time", func() {
+			m1 := metricFor(1, base, 1, 1, 1)
+			m2 := metricFor(1, base.Add(time.Minute), 2, 2, 2)
+			historyChan <- m1
+			historyChan <- m2
+
+			Eventually(func() []*marshalled.Envelope {
+				return historySink.GetHistory(base.Add(time.Second).UnixNano())
+			}).Should(Equal([]*marshalled.Envelope{m2}))
+		})
+
+		It("retains no history by default", func() {
+			eventChan <- metricFor(1, time.Now().Add(-1*time.Microsecond), 1, 1, 1)
+
+			Eventually(sink.GetLatest).Should(HaveLen(1))
+			Expect(sink.GetHistory(0)).To(BeEmpty())
+		})
+	})
+
 	Describe("Identifier", func() {
 		It("returns 'container-metrics-' plus the application ID", func() {
 			Expect(sink.Identifier()).To(Equal("container-metrics-myApp"))
@@ -112,7 +191,7 @@ var _ = Describe("Containermetric", func() {
 	It("closes after a period of inactivity", func() {
 		containerMetricSink := containermetric.NewContainerMetricSink("myAppId", 2*time.Second, 1*time.Millisecond)
 		containerMetricRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			containerMetricSink.Run(inputChan)
@@ -125,7 +204,7 @@ var _ = Describe("Containermetric", func() {
 	It("closes after input chan is closed", func() {
 		containerMetricSink := containermetric.NewContainerMetricSink("myAppId", 2*time.Second, 10*time.Second)
 		containerMetricRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			containerMetricSink.Run(inputChan)
@@ -141,7 +220,7 @@ var _ = Describe("Containermetric", func() {
 		inactivityDuration := 100 * time.Millisecond
 		containerMetricSink := containermetric.NewContainerMetricSink("myAppId", 2*time.Second, inactivityDuration)
 		containerMetricRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			containerMetricSink.Run(inputChan)
@@ -156,7 +235,7 @@ var _ = Describe("Containermetric", func() {
 	})
 })
 
-func continuouslySend(inputChan chan<- *events.Envelope, message *events.Envelope) (*sync.WaitGroup, chan<- struct{}) {
+func continuouslySend(inputChan chan<- *marshalled.Envelope, message *marshalled.Envelope) (*sync.WaitGroup, chan<- struct{}) {
 	done := make(chan struct{})
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -174,9 +253,9 @@ func continuouslySend(inputChan chan<- *events.Envelope, message *events.Envelop
 	return &wg, done
 }
 
-func metricFor(instanceId int32, timestamp time.Time, cpu float64, mem uint64, disk uint64) *events.Envelope {
+func metricFor(instanceId int32, timestamp time.Time, cpu float64, mem uint64, disk uint64) *marshalled.Envelope {
 	unixTimestamp := timestamp.UnixNano()
-	return &events.Envelope{
+	return marshalled.New(&events.Envelope{
 		EventType: events.Envelope_ContainerMetric.Enum(),
 		Timestamp: proto.Int64(unixTimestamp),
 		ContainerMetric: &events.ContainerMetric{
@@ -186,5 +265,5 @@ func metricFor(instanceId int32, timestamp time.Time, cpu float64, mem uint64, d
 			MemoryBytes:   proto.Uint64(mem),
 			DiskBytes:     proto.Uint64(disk),
 		},
-	}
+	})
 }
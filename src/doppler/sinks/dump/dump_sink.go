@@ -2,16 +2,25 @@ package dump
 
 import (
 	"container/ring"
+	"log"
+	"marshalled"
 	"sync"
 	"time"
 
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
+// Store persists recent logs so that they outlive the sink.
+type Store interface {
+	Append(appID string, e *marshalled.Envelope) error
+	Read(appID string) []*marshalled.Envelope
+}
+
 type DumpSink struct {
 	appId              string
 	messageRing        *ring.Ring
-	inputChan          chan *events.Envelope
+	store              Store
+	inputChan          chan *marshalled.Envelope
 	inactivityDuration time.Duration
 	lock               sync.RWMutex
 }
@@ -25,7 +34,17 @@ func NewDumpSink(appId string, bufferSize uint32, inactivityDuration time.Durati
 	return dumpSink
 }
 
-func (d *DumpSink) Run(inputChan <-chan *events.Envelope) {
+// NewPersistentDumpSink creates a DumpSink that writes through to the store
+// rather than keeping recent logs in memory.
+func NewPersistentDumpSink(appId string, store Store, inactivityDuration time.Duration) *DumpSink {
+	return &DumpSink{
+		appId:              appId,
+		store:              store,
+		inactivityDuration: inactivityDuration,
+	}
+}
+
+func (d *DumpSink) Run(inputChan <-chan *marshalled.Envelope) {
 	timer := time.NewTimer(d.inactivityDuration)
 	defer timer.Stop()
 	for {
@@ -50,7 +69,14 @@ func (d *DumpSink) Run(inputChan <-chan *events.Envelope) {
 	}
 }
 
-func (d *DumpSink) addMsg(msg *events.Envelope) {
+func (d *DumpSink) addMsg(msg *marshalled.Envelope) {
+	if d.store != nil {
+		if err := d.store.Append(d.appId, msg); err != nil {
+			log.Printf("DumpSink: failed to store recent log for %s: %s", d.appId, err)
+		}
+		return
+	}
+
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
@@ -58,17 +84,21 @@ func (d *DumpSink) addMsg(msg *events.Envelope) {
 	d.messageRing.Value = msg
 }
 
-func (d *DumpSink) Dump() []*events.Envelope {
+func (d *DumpSink) Dump() []*marshalled.Envelope {
+	if d.store != nil {
+		return d.store.Read(d.appId)
+	}
+
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 
-	data := make([]*events.Envelope, 0, d.messageRing.Len())
+	data := make([]*marshalled.Envelope, 0, d.messageRing.Len())
 	d.messageRing.Next().Do(func(value interface{}) {
 		if value == nil {
 			return
 		}
 
-		msg := value.(*events.Envelope)
+		msg := value.(*marshalled.Envelope)
 		data = append(data, msg)
 	})
 
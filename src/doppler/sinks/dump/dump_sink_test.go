@@ -2,6 +2,7 @@ package dump_test
 
 import (
 	"doppler/sinks/dump"
+	"marshalled"
 	"runtime"
 	"strconv"
 
@@ -21,7 +22,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 1, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -29,7 +30,7 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hi", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		close(inputChan)
 		<-dumpRunnerDone
@@ -44,7 +45,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 2, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -52,9 +53,9 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "1", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "2", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		close(inputChan)
 		<-dumpRunnerDone
@@ -72,7 +73,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", bufferSize, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -82,7 +83,7 @@ var _ = Describe("Dump Sink", func() {
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hi", "appId", "App"), "origin")
 
 		for i := uint32(0); i < bufferSize+1; i++ {
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -95,7 +96,7 @@ var _ = Describe("Dump Sink", func() {
 
 		dumpRunnerDone := make(chan struct{})
 
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -103,11 +104,11 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "1", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "2", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "3", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		close(inputChan)
 		<-dumpRunnerDone
@@ -123,7 +124,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 2, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -131,11 +132,11 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "1", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "2", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "3", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		close(inputChan)
 		<-dumpRunnerDone
@@ -155,7 +156,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 2, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -163,11 +164,11 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "1", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "2", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "3", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		close(inputChan)
 		<-dumpRunnerDone
@@ -178,7 +179,7 @@ var _ = Describe("Dump Sink", func() {
 		Expect(string(logMessages[1].GetLogMessage().GetMessage())).To(Equal("3"))
 
 		dumpRunnerDone = make(chan struct{})
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -186,7 +187,7 @@ var _ = Describe("Dump Sink", func() {
 		}()
 
 		logMessage, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "4", "appId", "App"), "origin")
-		inputChan <- logMessage
+		inputChan <- marshalled.New(logMessage)
 
 		Eventually(func() string {
 			logMessages = testDump.Dump()
@@ -201,7 +202,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 2, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -210,7 +211,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 0; i < 100; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -222,7 +223,7 @@ var _ = Describe("Dump Sink", func() {
 		Expect(string(logMessages[1].GetLogMessage().GetMessage())).To(Equal("99"))
 
 		dumpRunnerDone = make(chan struct{})
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -231,7 +232,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 100; i < 200; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -252,7 +253,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 200, time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -261,7 +262,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 0; i < 1000; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -273,7 +274,7 @@ var _ = Describe("Dump Sink", func() {
 		Expect(string(logMessages[1].GetLogMessage().GetMessage())).To(Equal("801"))
 
 		dumpRunnerDone = make(chan struct{})
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -282,7 +283,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 1000; i < 2000; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -302,7 +303,7 @@ var _ = Describe("Dump Sink", func() {
 	It("works with lots of messages and large buffer2", func() {
 		testDump := dump.NewDumpSink("myApp", 200, time.Second)
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -311,7 +312,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 0; i < 100; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -324,7 +325,7 @@ var _ = Describe("Dump Sink", func() {
 		Expect(string(logMessages[99].GetLogMessage().GetMessage())).To(Equal("99"))
 
 		dumpRunnerDone = make(chan struct{})
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -333,7 +334,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 100; i < 200; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -344,7 +345,7 @@ var _ = Describe("Dump Sink", func() {
 		Expect(string(logMessages[1].GetLogMessage().GetMessage())).To(Equal("1"))
 
 		dumpRunnerDone = make(chan struct{})
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -353,7 +354,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 200; i < 300; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -368,7 +369,7 @@ var _ = Describe("Dump Sink", func() {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 		testDump := dump.NewDumpSink("myApp", 5, time.Second)
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -377,7 +378,7 @@ var _ = Describe("Dump Sink", func() {
 
 		for i := 0; i < 10; i++ {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, strconv.Itoa(i), "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 		}
 
 		close(inputChan)
@@ -397,7 +398,7 @@ var _ = Describe("Dump Sink", func() {
 	It("closes itself after period of inactivity", func() {
 		testDump := dump.NewDumpSink("myApp", 5, 2*time.Microsecond)
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -410,7 +411,7 @@ var _ = Describe("Dump Sink", func() {
 	It("closes after input chan is closed", func() {
 		testDump := dump.NewDumpSink("myApp", 5, 2*time.Microsecond)
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -426,7 +427,7 @@ var _ = Describe("Dump Sink", func() {
 		inactivityDuration := 1 * time.Millisecond
 		testDump := dump.NewDumpSink("myApp", 5, inactivityDuration)
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope)
+		inputChan := make(chan *marshalled.Envelope)
 
 		logMessage, _ := emitter.Wrap(&events.LogMessage{}, "origin")
 		continuouslySend(inputChan, logMessage, 2*inactivityDuration)
@@ -443,7 +444,7 @@ var _ = Describe("Dump Sink", func() {
 		testDump := dump.NewDumpSink("myApp", 5, 2*time.Second)
 
 		dumpRunnerDone := make(chan struct{})
-		inputChan := make(chan *events.Envelope, 5)
+		inputChan := make(chan *marshalled.Envelope, 5)
 
 		go func() {
 			testDump.Run(inputChan)
@@ -452,25 +453,68 @@ var _ = Describe("Dump Sink", func() {
 
 		var env *events.Envelope
 		env, _ = emitter.Wrap(&events.LogMessage{}, "origin") // should keep this one
-		inputChan <- env
+		inputChan <- marshalled.New(env)
 		env, _ = emitter.Wrap(&events.HttpStartStop{}, "origin")
-		inputChan <- env
+		inputChan <- marshalled.New(env)
 		env, _ = emitter.Wrap(&events.ValueMetric{}, "origin")
-		inputChan <- env
+		inputChan <- marshalled.New(env)
 
 		close(inputChan)
 		<-dumpRunnerDone
 
 		Expect(testDump.Dump()).To(HaveLen(1))
 	})
+
+	Context("with a store", func() {
+		It("writes log messages through to the store and dumps from it", func() {
+			store := &fakeStore{}
+			testDump := dump.NewPersistentDumpSink("myApp", store, time.Second)
+
+			dumpRunnerDone := make(chan struct{})
+			inputChan := make(chan *marshalled.Envelope, 5)
+
+			go func() {
+				testDump.Run(inputChan)
+				close(dumpRunnerDone)
+			}()
+
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hi", "appId", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+			metric, _ := emitter.Wrap(&events.ValueMetric{}, "origin")
+			inputChan <- marshalled.New(metric)
+
+			close(inputChan)
+			<-dumpRunnerDone
+
+			Expect(store.appIDs).To(Equal([]string{"myApp"}))
+			Expect(store.envelopes).To(HaveLen(1))
+			Expect(store.envelopes[0].Envelope).To(Equal(logMessage))
+			Expect(testDump.Dump()).To(Equal(store.envelopes))
+		})
+	})
 })
 
-func continuouslySend(inputChan chan<- *events.Envelope, message *events.Envelope, duration time.Duration) {
+type fakeStore struct {
+	appIDs    []string
+	envelopes []*marshalled.Envelope
+}
+
+func (f *fakeStore) Append(appID string, e *marshalled.Envelope) error {
+	f.appIDs = append(f.appIDs, appID)
+	f.envelopes = append(f.envelopes, e)
+	return nil
+}
+
+func (f *fakeStore) Read(appID string) []*marshalled.Envelope {
+	return f.envelopes
+}
+
+func continuouslySend(inputChan chan<- *marshalled.Envelope, message *events.Envelope, duration time.Duration) {
 	timer := time.NewTimer(duration)
 	defer timer.Stop()
 	for {
 		select {
-		case inputChan <- message:
+		case inputChan <- marshalled.New(message):
 		case <-timer.C:
 			return
 		}
@@ -1,14 +1,13 @@
 package sinks
 
 import (
+	"marshalled"
 	"truncatingbuffer"
-
-	"github.com/cloudfoundry/sonde-go/events"
 )
 
 type Sink interface {
 	AppID() string
-	Run(<-chan *events.Envelope)
+	Run(<-chan *marshalled.Envelope)
 	Identifier() string
 	ShouldReceiveErrors() bool
 }
@@ -18,7 +17,7 @@ type Metric struct {
 	Value int64
 }
 
-func RunTruncatingBuffer(inputChan <-chan *events.Envelope, bufferSize uint, context truncatingbuffer.BufferContext, stopChannel chan struct{}) *truncatingbuffer.TruncatingBuffer {
+func RunTruncatingBuffer(inputChan <-chan *marshalled.Envelope, bufferSize uint, context truncatingbuffer.BufferContext, stopChannel chan struct{}) *truncatingbuffer.TruncatingBuffer {
 	b := truncatingbuffer.NewTruncatingBuffer(inputChan, bufferSize, context, stopChannel)
 	go b.Run()
 	return b
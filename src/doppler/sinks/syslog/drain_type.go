@@ -0,0 +1,67 @@
+package syslog
+
+import (
+	"fmt"
+	"net/url"
+	"truncatingbuffer"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// DrainType selects the envelopes a drain receives. It is given by the
+// drain URL's drain-type query parameter.
+type DrainType int
+
+const (
+	// DrainLogs drains receive the app's LogMessages.
+	DrainLogs DrainType = iota
+	// DrainMetrics drains receive the app's ContainerMetrics and
+	// HttpStartStops.
+	DrainMetrics
+	// DrainAll drains receive both.
+	DrainAll
+)
+
+func (t DrainType) String() string {
+	switch t {
+	case DrainMetrics:
+		return "metrics"
+	case DrainAll:
+		return "all"
+	default:
+		return "logs"
+	}
+}
+
+// ParseDrainType returns the drain type given by the drain URL. Drains
+// without a drain-type receive logs.
+func ParseDrainType(drainURL *url.URL) (DrainType, error) {
+	switch drainType := drainURL.Query().Get("drain-type"); drainType {
+	case "", "logs":
+		return DrainLogs, nil
+	case "metrics":
+		return DrainMetrics, nil
+	case "all":
+		return DrainAll, nil
+	default:
+		return DrainLogs, fmt.Errorf("Invalid drain-type %s, must be logs, metrics or all", drainType)
+	}
+}
+
+func (t DrainType) bufferContext(origin, destination string) truncatingbuffer.BufferContext {
+	switch t {
+	case DrainMetrics:
+		return truncatingbuffer.NewEventTypesAllowedContext(origin, destination,
+			events.Envelope_ContainerMetric,
+			events.Envelope_HttpStartStop,
+		)
+	case DrainAll:
+		return truncatingbuffer.NewEventTypesAllowedContext(origin, destination,
+			events.Envelope_LogMessage,
+			events.Envelope_ContainerMetric,
+			events.Envelope_HttpStartStop,
+		)
+	default:
+		return truncatingbuffer.NewLogAllowedContext(origin, destination)
+	}
+}
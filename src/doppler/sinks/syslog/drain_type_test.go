@@ -0,0 +1,41 @@
+package syslog_test
+
+import (
+	"doppler/sinks/syslog"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseDrainType", func() {
+	parse := func(rawURL string) (syslog.DrainType, error) {
+		drainURL, err := url.Parse(rawURL)
+		Expect(err).ToNot(HaveOccurred())
+		return syslog.ParseDrainType(drainURL)
+	}
+
+	It("defaults to logs", func() {
+		drainType, err := parse("syslog://drain.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(drainType).To(Equal(syslog.DrainLogs))
+	})
+
+	It("reads the drain-type query parameter", func() {
+		for rawType, expected := range map[string]syslog.DrainType{
+			"logs":    syslog.DrainLogs,
+			"metrics": syslog.DrainMetrics,
+			"all":     syslog.DrainAll,
+		} {
+			drainType, err := parse("syslog://drain.example.com?drain-type=" + rawType)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(drainType).To(Equal(expected))
+			Expect(drainType.String()).To(Equal(rawType))
+		}
+	})
+
+	It("returns an error for an unknown drain type", func() {
+		_, err := parse("syslog://drain.example.com?drain-type=traces")
+		Expect(err).To(MatchError("Invalid drain-type traces, must be logs, metrics or all"))
+	})
+})
@@ -0,0 +1,67 @@
+package syslog
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultErrorInterval is how often a failing drain reports its errors to
+// the app's log stream.
+const DefaultErrorInterval = time.Minute
+
+// errorReporter coalesces the errors of a single drain so that a drain that
+// stays down does not flood the app's log stream. The first error is
+// reported straight away, later errors at most once per interval with the
+// number of errors seen since the previous report, and a recovery message
+// once the drain connects again.
+type errorReporter struct {
+	identifier string
+	appID      string
+	interval   time.Duration
+	report     func(errorMessage, appId string)
+
+	failing    bool
+	errors     int
+	suppressed int
+	lastReport time.Time
+}
+
+func newErrorReporter(identifier, appID string, interval time.Duration, report func(string, string)) *errorReporter {
+	return &errorReporter{
+		identifier: identifier,
+		appID:      appID,
+		interval:   interval,
+		report:     report,
+	}
+}
+
+func (r *errorReporter) failed(errorMessage string) {
+	r.failing = true
+	r.errors++
+
+	now := time.Now()
+	if !r.lastReport.IsZero() && now.Sub(r.lastReport) < r.interval {
+		r.suppressed++
+		return
+	}
+
+	if r.suppressed > 0 {
+		errorMessage = fmt.Sprintf("%s (repeated %d times)", errorMessage, r.suppressed+1)
+	}
+	r.suppressed = 0
+	r.lastReport = now
+	r.report(errorMessage, r.appID)
+}
+
+func (r *errorReporter) recovered() {
+	if !r.failing {
+		return
+	}
+
+	r.report(fmt.Sprintf("Syslog Sink %s: Drain recovered after %d errors.", r.identifier, r.errors), r.appID)
+
+	r.failing = false
+	r.errors = 0
+	r.suppressed = 0
+	r.lastReport = time.Time{}
+}
@@ -1,12 +1,17 @@
 package syslog
 
 import (
+	"crypto/sha256"
 	"doppler/sinks"
 	"doppler/sinks/retrystrategy"
 	"doppler/sinks/syslogwriter"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"marshalled"
 	"net/url"
+	"plumbing"
+	"strconv"
 	"sync"
 	"time"
 	"truncatingbuffer"
@@ -14,21 +19,70 @@ import (
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
+// State is the state of a drain's connection.
+type State int
+
+const (
+	// Connecting is the state of a drain before its first connection and
+	// after a failed write, while it reconnects.
+	Connecting State = iota
+	Connected
+	// BackingOff is the state of a drain waiting to retry a failed
+	// connection.
+	BackingOff
+)
+
+func (s State) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case BackingOff:
+		return "backing_off"
+	default:
+		return "connecting"
+	}
+}
+
+// Status describes a drain's connection and how many messages it has sent
+// and dropped. Errors counts the failed connections and writes, and
+// Reconnects the connections made after the first.
+type Status struct {
+	State           State
+	LastError       string
+	LastSuccess     time.Time
+	SentMessages    uint64
+	DroppedMessages uint64
+	Errors          uint64
+	Reconnects      uint64
+}
+
 type SyslogSink struct {
 	appId                  string
 	drainURL               *url.URL
-	sentMessageCount       *uint64
-	sentByteCount          *uint64
+	drainID                string
+	drainType              DrainType
 	messageDrainBufferSize uint
-	listenerChannel        chan *events.Envelope
+	listenerChannel        chan *marshalled.Envelope
 	syslogWriter           syslogwriter.Writer
 	handleSendError        func(errorMessage, appId string)
+	errorInterval          time.Duration
 	disconnectChannel      chan struct{}
 	dropsondeOrigin        string
 	disconnectOnce         sync.Once
+
+	statusLock      sync.RWMutex
+	status          Status
+	connectedBefore bool
+	buffer          *truncatingbuffer.TruncatingBuffer
 }
 
 func NewSyslogSink(appId string, drainURL *url.URL, messageDrainBufferSize uint, syslogWriter syslogwriter.Writer, errorHandler func(string, string), dropsondeOrigin string) *SyslogSink {
+	return NewSyslogSinkWithErrorInterval(appId, drainURL, messageDrainBufferSize, syslogWriter, errorHandler, dropsondeOrigin, DefaultErrorInterval)
+}
+
+// NewSyslogSinkWithErrorInterval creates a SyslogSink that passes its
+// errors to errorHandler at most once per errorInterval.
+func NewSyslogSinkWithErrorInterval(appId string, drainURL *url.URL, messageDrainBufferSize uint, syslogWriter syslogwriter.Writer, errorHandler func(string, string), dropsondeOrigin string, errorInterval time.Duration) *SyslogSink {
 
 	syslogSink := &SyslogSink{
 		appId:                  appId,
@@ -36,23 +90,33 @@ func NewSyslogSink(appId string, drainURL *url.URL, messageDrainBufferSize uint,
 		messageDrainBufferSize: messageDrainBufferSize,
 		syslogWriter:           syslogWriter,
 		handleSendError:        errorHandler,
+		errorInterval:          errorInterval,
 		disconnectChannel:      make(chan struct{}),
 		dropsondeOrigin:        dropsondeOrigin,
 	}
+	syslogSink.drainID = hashDrainID(syslogSink.Identifier())
+	// The sink manager rejects drain URLs with an invalid drain-type, so
+	// the error can only be seen by drains created elsewhere, which then
+	// receive logs.
+	syslogSink.drainType, _ = ParseDrainType(drainURL)
 
 	log.Printf("Syslog Sink %s: Created for appId [%s]", syslogSink.Identifier(), appId)
 	return syslogSink
 }
 
-func (s *SyslogSink) Run(inputChan <-chan *events.Envelope) {
+func (s *SyslogSink) Run(inputChan <-chan *marshalled.Envelope) {
 	syslogIdentifier := s.Identifier()
 	log.Printf("Syslog Sink %s: Running.", syslogIdentifier)
 	defer log.Printf("Syslog Sink %s: Stopped.", syslogIdentifier)
 
 	backoffStrategy := retrystrategy.Exponential()
+	errors := newErrorReporter(syslogIdentifier, s.appId, s.errorInterval, s.handleSendError)
 
-	context := truncatingbuffer.NewLogAllowedContext(s.dropsondeOrigin, syslogIdentifier)
+	context := s.drainType.bufferContext(s.dropsondeOrigin, syslogIdentifier)
 	buffer := sinks.RunTruncatingBuffer(inputChan, s.messageDrainBufferSize, context, s.disconnectChannel)
+	s.statusLock.Lock()
+	s.buffer = buffer
+	s.statusLock.Unlock()
 	timer := time.NewTimer(backoffStrategy(0))
 	connected := false
 	defer timer.Stop()
@@ -70,19 +134,24 @@ func (s *SyslogSink) Run(inputChan <-chan *events.Envelope) {
 			}
 
 			numberOfTries := 0
+			written := 0
 			for {
 				for !connected {
 					err := s.syslogWriter.Connect()
 					if err == nil {
 						log.Printf("Syslog Sink %s: successfully connected.", syslogIdentifier)
 						connected = true
+						s.recordConnected()
+						errors.recovered()
 						break
 					}
 
+					s.recordError(err, BackingOff)
+
 					sleepDuration := backoffStrategy(numberOfTries)
 					errorMsg := fmt.Sprintf("Syslog Sink %s: Error when dialing out. Backing off for %v. Err: %v", syslogIdentifier, sleepDuration, err)
 
-					s.handleSendError(errorMsg, s.appId)
+					errors.failed(errorMsg)
 
 					timer.Reset(sleepDuration)
 					select {
@@ -94,21 +163,30 @@ func (s *SyslogSink) Run(inputChan <-chan *events.Envelope) {
 					numberOfTries++
 				}
 
-				err := s.sendLogMessage(messageEnvelope.GetLogMessage())
+				err := s.send(messageEnvelope.Envelope, &written)
 				if err == nil {
 					connected = true
+					s.recordSent()
 					break
 				}
 
 				connected = false
+				s.recordError(err, Connecting)
 				numberOfTries++
 			}
 		}
 	}
 }
 
+// Disconnect stops the sink, abandoning a write in progress if the writer
+// supports cancelling it.
 func (s *SyslogSink) Disconnect() {
-	s.disconnectOnce.Do(func() { close(s.disconnectChannel) })
+	s.disconnectOnce.Do(func() {
+		close(s.disconnectChannel)
+		if w, ok := s.syslogWriter.(cancellableWriter); ok {
+			w.Cancel()
+		}
+	})
 }
 
 func (s *SyslogSink) Identifier() string {
@@ -122,15 +200,209 @@ func (s *SyslogSink) AppID() string {
 	return s.appId
 }
 
+// DrainID identifies the drain without revealing its URL, so that it can be
+// used to tag the drain's metrics.
+func (s *SyslogSink) DrainID() string {
+	return s.drainID
+}
+
 func (s *SyslogSink) ShouldReceiveErrors() bool {
 	return false
 }
 
-func (s *SyslogSink) sendLogMessage(logMessage *events.LogMessage) error {
-	_, err := s.syslogWriter.Write(messagePriorityValue(logMessage), logMessage.GetMessage(), logMessage.GetSourceType(), logMessage.GetSourceInstance(), *logMessage.Timestamp)
+// Status returns the drain's current connection state, the last error seen
+// while connecting or writing, and its counters.
+func (s *SyslogSink) Status() Status {
+	s.statusLock.RLock()
+	defer s.statusLock.RUnlock()
+
+	status := s.status
+	if s.buffer != nil {
+		status.DroppedMessages = s.buffer.GetDroppedMessageCount()
+	}
+	if w, ok := s.syslogWriter.(droppingWriter); ok {
+		dropped := w.Dropped()
+		if dropped > status.SentMessages {
+			dropped = status.SentMessages
+		}
+		status.SentMessages -= dropped
+		status.DroppedMessages += dropped
+	}
+	return status
+}
+
+// droppingWriter is implemented by writers that accept messages before
+// delivering them, and so may drop messages that were counted as sent.
+type droppingWriter interface {
+	Dropped() uint64
+}
+
+// cancellableWriter is implemented by writers whose writes may block for a
+// long time, e.g. while retrying, and can be abandoned.
+type cancellableWriter interface {
+	Cancel()
+}
+
+func (s *SyslogSink) recordConnected() {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.status.State = Connected
+	if s.connectedBefore {
+		s.status.Reconnects++
+	}
+	s.connectedBefore = true
+}
+
+func (s *SyslogSink) recordSent() {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.status.State = Connected
+	s.status.SentMessages++
+	s.status.LastSuccess = time.Now()
+}
+
+func (s *SyslogSink) recordError(err error, state State) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.status.State = state
+	s.status.LastError = err.Error()
+	s.status.Errors++
+}
+
+func hashDrainID(identifier string) string {
+	sum := sha256.Sum256([]byte(identifier))
+	return hex.EncodeToString(sum[:8])
+}
+
+// DrainType returns the kind of envelopes the drain receives.
+func (s *SyslogSink) DrainType() DrainType {
+	return s.drainType
+}
+
+// send writes the envelope to the drain. written counts the metrics of the
+// envelope already written, so that retrying the envelope after an error
+// does not write them again.
+func (s *SyslogSink) send(envelope *events.Envelope, written *int) error {
+	switch envelope.GetEventType() {
+	case events.Envelope_ContainerMetric:
+		return s.sendContainerMetric(envelope, written)
+	case events.Envelope_HttpStartStop:
+		return s.sendHttpStartStop(envelope)
+	default:
+		return s.sendLogMessage(envelope)
+	}
+}
+
+func (s *SyslogSink) sendLogMessage(envelope *events.Envelope) error {
+	logMessage := envelope.GetLogMessage()
+	_, err := s.syslogWriter.Write(messagePriorityValue(logMessage), logMessage.GetMessage(), logMessage.GetSourceType(), logMessage.GetSourceInstance(), *logMessage.Timestamp, messageTags(envelope))
+	return err
+}
+
+// sendContainerMetric writes each of the container metric's values as a
+// metric of the app instance, skipping the ones already written.
+func (s *SyslogSink) sendContainerMetric(envelope *events.Envelope, written *int) error {
+	containerMetric := envelope.GetContainerMetric()
+	metrics := []syslogwriter.Metric{
+		{Name: "cpu", Value: containerMetric.GetCpuPercentage(), Unit: "percentage"},
+		{Name: "memory", Value: float64(containerMetric.GetMemoryBytes()), Unit: "bytes"},
+		{Name: "disk", Value: float64(containerMetric.GetDiskBytes()), Unit: "bytes"},
+		{Name: "memory_quota", Value: float64(containerMetric.GetMemoryBytesQuota()), Unit: "bytes"},
+		{Name: "disk_quota", Value: float64(containerMetric.GetDiskBytesQuota()), Unit: "bytes"},
+	}
+
+	tags := messageTags(envelope)
+	for *written < len(metrics) {
+		_, err := s.syslogWriter.WriteMetric(metrics[*written], "APP", sourceInstance(envelope), envelope.GetTimestamp(), tags)
+		if err != nil {
+			return err
+		}
+		*written++
+	}
+	return nil
+}
+
+// sendHttpStartStop writes the request's duration in milliseconds, tagged
+// with its method, URI and status code.
+func (s *SyslogSink) sendHttpStartStop(envelope *events.Envelope) error {
+	httpStartStop := envelope.GetHttpStartStop()
+	duration := time.Duration(httpStartStop.GetStopTimestamp() - httpStartStop.GetStartTimestamp())
+	metric := syslogwriter.Metric{
+		Name:  "http",
+		Value: float64(duration) / float64(time.Millisecond),
+		Unit:  "ms",
+	}
+
+	tags := messageTags(envelope)
+	tags["method"] = httpStartStop.GetMethod().String()
+	tags["uri"] = httpStartStop.GetUri()
+	tags["status_code"] = strconv.Itoa(int(httpStartStop.GetStatusCode()))
+
+	_, err := s.syslogWriter.WriteMetric(metric, "RTR", sourceInstance(envelope), envelope.GetTimestamp(), tags)
 	return err
 }
 
+// sourceInstance returns the index of the app instance the envelope is
+// about, or an empty string when it is not about a single instance.
+func sourceInstance(envelope *events.Envelope) string {
+	switch envelope.GetEventType() {
+	case events.Envelope_ContainerMetric:
+		return strconv.Itoa(int(envelope.GetContainerMetric().GetInstanceIndex()))
+	case events.Envelope_HttpStartStop:
+		if envelope.GetHttpStartStop().InstanceIndex == nil {
+			return ""
+		}
+		return strconv.Itoa(int(envelope.GetHttpStartStop().GetInstanceIndex()))
+	default:
+		return envelope.GetLogMessage().GetSourceInstance()
+	}
+}
+
+// plumbingTags are the tags Loggregator uses to carry an envelope's fields
+// through v2 envelopes and to time its transit. They repeat the envelope's
+// fields rather than being given by the emitter, so drains do not see them.
+var plumbingTags = map[string]bool{
+	plumbing.MetronEgressTag: true,
+	"origin":                 true,
+	"source_type":            true,
+	"method":                 true,
+	"peer_type":              true,
+	"request_id":             true,
+	"uri":                    true,
+	"remote_address":         true,
+	"user_agent":             true,
+	"status_code":            true,
+	"content_length":         true,
+	"instance_index":         true,
+	"instance_id":            true,
+	"forwarded":              true,
+}
+
+// messageTags returns where the envelope came from along with the tags given
+// by its emitter, for drains that include them in the message's structured
+// data.
+func messageTags(envelope *events.Envelope) map[string]string {
+	tags := make(map[string]string, len(envelope.GetTags())+5)
+	for k, v := range envelope.GetTags() {
+		if !plumbingTags[k] {
+			tags[k] = v
+		}
+	}
+
+	addTag := func(name, value string) {
+		if value != "" {
+			tags[name] = value
+		}
+	}
+	addTag("deployment", envelope.GetDeployment())
+	addTag("job", envelope.GetJob())
+	addTag("index", envelope.GetIndex())
+	addTag("ip", envelope.GetIp())
+	addTag("source_instance", sourceInstance(envelope))
+
+	return tags
+}
+
 func messagePriorityValue(msg *events.LogMessage) int {
 	switch msg.GetMessageType() {
 	case events.LogMessage_OUT:
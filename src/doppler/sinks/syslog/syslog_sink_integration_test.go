@@ -4,6 +4,8 @@ import (
 	"doppler/sinks/syslog"
 	"doppler/sinks/syslogwriter"
 	"fmt"
+	"io/ioutil"
+	"marshalled"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -33,7 +35,7 @@ var _ = Describe("SyslogSinkIntegration", func() {
 					timestampsInMillis = append(timestampsInMillis, time.Now().UnixNano()/1e6)
 					atomic.AddInt64(&requests, 1)
 				}))
-				url, _ := url.Parse(server.URL)
+				url, _ := url.Parse(server.URL + "?batch-size=1")
 
 				dialer := &net.Dialer{}
 				httpsWriter, err := syslogwriter.NewHttpsWriter(url, appId, true, dialer, 0)
@@ -42,7 +44,7 @@ var _ = Describe("SyslogSinkIntegration", func() {
 				errorHandler := func(errorMsg, appId string) {}
 
 				syslogSink := syslog.NewSyslogSink(appId, url, bufferSize, httpsWriter, errorHandler, "dropsonde-origin")
-				inputChan := make(chan *events.Envelope)
+				inputChan := make(chan *marshalled.Envelope)
 
 				defer syslogSink.Disconnect()
 				go syslogSink.Run(inputChan)
@@ -51,7 +53,7 @@ var _ = Describe("SyslogSinkIntegration", func() {
 					msg := fmt.Sprintf("message number %v", i)
 					logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, msg, appId, "App"), "origin")
 
-					inputChan <- logMessage
+					inputChan <- marshalled.New(logMessage)
 				}
 				close(inputChan)
 
@@ -69,5 +71,62 @@ var _ = Describe("SyslogSinkIntegration", func() {
 				}
 			})
 		})
+
+		Context("with a drain that does not respond", func() {
+			It("stops without waiting for the POST when disconnected", func() {
+				requests := make(chan struct{}, 1)
+				release := make(chan struct{})
+				server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests <- struct{}{}
+					<-release
+				}))
+				defer server.Close()
+				defer close(release)
+				url, _ := url.Parse(server.URL + "?batch-size=1")
+
+				httpsWriter, err := syslogwriter.NewHttpsWriter(url, "appId", true, &net.Dialer{}, time.Minute)
+				Expect(err).ToNot(HaveOccurred())
+
+				syslogSink := syslog.NewSyslogSink("appId", url, 10, httpsWriter, func(string, string) {}, "dropsonde-origin")
+				inputChan := make(chan *marshalled.Envelope, 1)
+				finished := make(chan struct{})
+				go func() {
+					syslogSink.Run(inputChan)
+					close(finished)
+				}()
+
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "appId", "App"), "origin")
+				inputChan <- marshalled.New(logMessage)
+				Eventually(requests).Should(Receive())
+
+				syslogSink.Disconnect()
+				Eventually(finished).Should(BeClosed())
+			})
+		})
+
+		Context("with a CA that did not sign the drain's certificate", func() {
+			It("reports the certificate error as the drain's last error", func() {
+				server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+				defer server.Close()
+				url, _ := url.Parse(server.URL + "?batch-size=1")
+
+				ca, err := ioutil.ReadFile("../syslogwriter/fixtures/loggregator-ca.crt")
+				Expect(err).ToNot(HaveOccurred())
+				writer, err := syslogwriter.NewWriter(url, "appId", true, syslogwriter.Credentials{CA: string(ca)}, time.Second, time.Second, syslogwriter.Format{}, 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				syslogSink := syslog.NewSyslogSink("appId", url, 10, writer, func(string, string) {}, "dropsonde-origin")
+				inputChan := make(chan *marshalled.Envelope, 1)
+				defer syslogSink.Disconnect()
+				go syslogSink.Run(inputChan)
+
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "appId", "App"), "origin")
+				inputChan <- marshalled.New(logMessage)
+
+				Eventually(func() string {
+					return syslogSink.Status().LastError
+				}).Should(ContainSubstring("x509"))
+			})
+		})
 	})
 })
@@ -2,8 +2,10 @@ package syslog_test
 
 import (
 	"doppler/sinks/syslog"
+	"doppler/sinks/syslogwriter"
 	"errors"
 	"fmt"
+	"marshalled"
 	"net"
 	"net/url"
 	"sync"
@@ -28,18 +30,20 @@ var _ = Describe("SyslogSink", func() {
 		syslogSinkRunFinished chan bool
 		errorChannel          chan *events.Envelope
 		errorHandler          func(string, string)
-		inputChan             chan *events.Envelope
+		inputChan             chan *marshalled.Envelope
 		dialer                *net.Dialer
 		drainURL              string
+		errorInterval         time.Duration
 	)
 
 	BeforeEach(func() {
 		syslogSinkRunFinished = make(chan bool)
 		sysLogger = NewSyslogWriterRecorder()
 		errorChannel = make(chan *events.Envelope, 10)
-		inputChan = make(chan *events.Envelope)
+		inputChan = make(chan *marshalled.Envelope)
 		dialer = &net.Dialer{}
 		drainURL = "syslog://using-fake"
+		errorInterval = syslog.DefaultErrorInterval
 
 		errorHandler = func(errorMsg, appId string) {
 			logMessage := factories.NewLogMessage(events.LogMessage_ERR, errorMsg, appId, "LGR")
@@ -55,7 +59,7 @@ var _ = Describe("SyslogSink", func() {
 	JustBeforeEach(func() {
 		drainURL, err := url.Parse(drainURL)
 		Expect(err).ToNot(HaveOccurred())
-		syslogSink = syslog.NewSyslogSink("appId", drainURL, bufferSize, sysLogger, errorHandler, "dropsonde-origin")
+		syslogSink = syslog.NewSyslogSinkWithErrorInterval("appId", drainURL, bufferSize, sysLogger, errorHandler, "dropsonde-origin", errorInterval)
 	})
 
 	Describe("Identifier", func() {
@@ -102,7 +106,7 @@ var _ = Describe("SyslogSink", func() {
 			JustBeforeEach(func() {
 				for i := 0; i < numMessages; i++ {
 					message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, fmt.Sprintf("test message: %d\n", i), "appId", "App"), "origin")
-					inputChan <- message
+					inputChan <- marshalled.New(message)
 				}
 				close(inputChan)
 
@@ -135,7 +139,7 @@ var _ = Describe("SyslogSink", func() {
 			logMessage.SourceInstance = proto.String("123")
 			envelope, _ := emitter.Wrap(logMessage, "origin")
 
-			inputChan <- envelope
+			inputChan <- marshalled.New(envelope)
 			data := <-sysLogger.receivedChannel
 
 			expectedSyslogMessage := fmt.Sprintf(`<14>1 test message ts: \d+ src: App srcId: 123`)
@@ -143,19 +147,105 @@ var _ = Describe("SyslogSink", func() {
 			close(done)
 		})
 
+		It("sends where the message came from and its emitter's tags, but not plumbing tags", func() {
+			logMessage := factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App")
+			logMessage.SourceInstance = proto.String("123")
+			envelope, _ := emitter.Wrap(logMessage, "origin")
+			envelope.Deployment = proto.String("cf")
+			envelope.Job = proto.String("diego-cell")
+			envelope.Tags = map[string]string{
+				"custom":                  "value",
+				"origin":                  "origin",
+				"source_type":             "App",
+				"metron_egress_timestamp": "1",
+			}
+
+			inputChan <- marshalled.New(envelope)
+
+			var tags map[string]string
+			Eventually(sysLogger.receivedTags).Should(Receive(&tags))
+			Expect(tags).To(Equal(map[string]string{
+				"deployment":      "cf",
+				"job":             "diego-cell",
+				"source_instance": "123",
+				"custom":          "value",
+			}))
+		})
+
 		It("does not send non-log messages to the syslog writer", func(done Done) {
 			nonLogMessage := factories.NewValueMetric("value-name", 2.0, "value-unit")
 			envelope, _ := emitter.Wrap(nonLogMessage, "origin")
 
-			inputChan <- envelope
+			inputChan <- marshalled.New(envelope)
 
 			Consistently(sysLogger.receivedChannel).ShouldNot(Receive())
 			close(done)
 		})
 
+		It("is a log drain by default", func() {
+			Expect(syslogSink.DrainType()).To(Equal(syslog.DrainLogs))
+		})
+
+		Context("with drain-type metrics", func() {
+			BeforeEach(func() {
+				drainURL = "syslog://using-fake?drain-type=metrics"
+			})
+
+			It("sends each container metric value as a metric of the app instance", func() {
+				containerMetric, _ := emitter.Wrap(factories.NewContainerMetric("appId", 2, 0.5, 1024, 2048), "origin")
+				inputChan <- marshalled.New(containerMetric)
+
+				Eventually(sysLogger.ReceivedMessages).Should(HaveLen(5))
+				Expect(sysLogger.ReceivedMessages()).To(ConsistOf(
+					MatchRegexp(`^metric cpu 0.5 percentage ts: \d+ src: APP srcId: 2$`),
+					MatchRegexp(`^metric memory 1024 bytes ts: \d+ src: APP srcId: 2$`),
+					MatchRegexp(`^metric disk 2048 bytes ts: \d+ src: APP srcId: 2$`),
+					MatchRegexp(`^metric memory_quota 0 bytes ts: \d+ src: APP srcId: 2$`),
+					MatchRegexp(`^metric disk_quota 0 bytes ts: \d+ src: APP srcId: 2$`),
+				))
+			})
+
+			It("sends the duration of HTTP requests", func() {
+				httpStartStop, _ := emitter.Wrap(&events.HttpStartStop{
+					StartTimestamp: proto.Int64(1000000),
+					StopTimestamp:  proto.Int64(3500000),
+					Method:         events.Method_GET.Enum(),
+					Uri:            proto.String("http://example.com/path"),
+					StatusCode:     proto.Int32(200),
+					InstanceIndex:  proto.Int32(1),
+				}, "origin")
+				inputChan <- marshalled.New(httpStartStop)
+
+				Eventually(sysLogger.receivedChannel).Should(Receive(MatchRegexp(`^metric http 2.5 ms ts: \d+ src: RTR srcId: 1$`)))
+			})
+
+			It("does not send log messages", func() {
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+				inputChan <- marshalled.New(logMessage)
+
+				Consistently(sysLogger.receivedChannel).ShouldNot(Receive())
+			})
+		})
+
+		Context("with drain-type all", func() {
+			BeforeEach(func() {
+				drainURL = "syslog://using-fake?drain-type=all"
+			})
+
+			It("sends log messages and metrics", func() {
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+				containerMetric, _ := emitter.Wrap(factories.NewContainerMetric("appId", 2, 0.5, 1024, 2048), "origin")
+				inputChan <- marshalled.New(logMessage)
+				inputChan <- marshalled.New(containerMetric)
+
+				Eventually(sysLogger.ReceivedMessages).Should(HaveLen(6))
+				Expect(sysLogger.ReceivedMessages()[0]).To(ContainSubstring("test message"))
+			})
+		})
+
 		It("stops sending messages when the disconnect comes in", func(done Done) {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 
 			_, ok := <-sysLogger.receivedChannel
 			Expect(ok).To(BeTrue())
@@ -167,13 +257,64 @@ var _ = Describe("SyslogSink", func() {
 			close(done)
 		})
 
+		It("reports the sent messages in its status", func() {
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+			inputChan <- marshalled.New(logMessage)
+
+			Eventually(func() uint64 {
+				return syslogSink.Status().SentMessages
+			}).Should(BeEquivalentTo(2))
+
+			status := syslogSink.Status()
+			Expect(status.State).To(Equal(syslog.Connected))
+			Expect(status.LastError).To(BeEmpty())
+			Expect(status.LastSuccess).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(status.DroppedMessages).To(BeZero())
+			Expect(status.Errors).To(BeZero())
+			Expect(status.Reconnects).To(BeZero())
+		})
+
+		It("counts the errors and reconnects after a failed write", func() {
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+			Eventually(func() uint64 {
+				return syslogSink.Status().SentMessages
+			}).Should(BeEquivalentTo(1))
+
+			sysLogger.SetDown(true)
+			inputChan <- marshalled.New(logMessage)
+			Eventually(func() syslog.State {
+				return syslogSink.Status().State
+			}).Should(Equal(syslog.BackingOff))
+
+			sysLogger.SetDown(false)
+			Eventually(func() uint64 {
+				return syslogSink.Status().Reconnects
+			}).Should(BeEquivalentTo(1))
+
+			status := syslogSink.Status()
+			Expect(status.Errors).To(BeNumerically(">=", 2))
+			Expect(status.LastError).To(Equal("Error connecting."))
+		})
+
+		It("identifies the drain by a hash of its URL", func() {
+			Expect(syslogSink.DrainID()).To(HaveLen(16))
+			Expect(syslogSink.DrainID()).ToNot(ContainSubstring("using-fake"))
+
+			otherURL, err := url.Parse("syslog://other-drain")
+			Expect(err).ToNot(HaveOccurred())
+			other := syslog.NewSyslogSink("appId", otherURL, bufferSize, sysLogger, errorHandler, "dropsonde-origin")
+			Expect(other.DrainID()).ToNot(Equal(syslogSink.DrainID()))
+		})
+
 		It("uses the timestamp of the logmessage when sending", func(done Done) {
 			message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
 			expectedTimeString := fmt.Sprintf("ts: %d", message.GetLogMessage().GetTimestamp())
 
 			time.Sleep(100 * time.Millisecond) //wait a bit to allow timestamps to differ
 
-			inputChan <- message
+			inputChan <- marshalled.New(message)
 			data := <-sysLogger.receivedChannel
 
 			Expect(string(data)).To(MatchRegexp(expectedTimeString))
@@ -187,16 +328,26 @@ var _ = Describe("SyslogSink", func() {
 
 			It("reports error messages when it's connected", func() {
 				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
-				inputChan <- logMessage
+				inputChan <- marshalled.New(logMessage)
 				errorLog := <-errorChannel
 				errorMsg := string(errorLog.GetLogMessage().GetMessage())
 				Expect(errorMsg).To(MatchRegexp(`Syslog Sink syslog://using-fake: Error when dialing out. Backing off for (\d+(\.\d+)?(m|u|µ)s). Err: Error connecting.`))
 				Expect(errorLog.GetLogMessage().GetSourceType()).To(Equal("LGR"))
 			})
 
+			It("reports the last error in its status", func() {
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+				inputChan <- marshalled.New(logMessage)
+
+				Eventually(func() string {
+					return syslogSink.Status().LastError
+				}).Should(Equal("Error connecting."))
+				Expect(syslogSink.Status().State).To(Equal(syslog.BackingOff))
+			})
+
 			It("stops sending messages when the disconnect comes in", func() {
 				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
-				inputChan <- logMessage
+				inputChan <- marshalled.New(logMessage)
 
 				Eventually(errorChannel).ShouldNot(BeEmpty())
 				syslogSink.Disconnect()
@@ -211,13 +362,57 @@ var _ = Describe("SyslogSink", func() {
 				Expect(errorChannel).To(HaveLen(numErrors))
 			})
 
+			It("coalesces repeated errors", func() {
+				logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+				inputChan <- marshalled.New(logMessage)
+
+				Eventually(errorChannel).Should(HaveLen(1))
+				Eventually(func() uint64 {
+					return syslogSink.Status().Errors
+				}).Should(BeNumerically(">", 3))
+				Consistently(errorChannel).Should(HaveLen(1))
+			})
+
+			Context("with a short error interval", func() {
+				BeforeEach(func() {
+					errorInterval = 100 * time.Millisecond
+				})
+
+				It("reports the number of repeated errors", func() {
+					logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+					inputChan <- marshalled.New(logMessage)
+
+					var errorLog *events.Envelope
+					Eventually(errorChannel).Should(Receive())
+					Eventually(errorChannel, 2).Should(Receive(&errorLog))
+					Expect(string(errorLog.GetLogMessage().GetMessage())).To(MatchRegexp(`Error when dialing out.*\(repeated \d+ times\)$`))
+				})
+
+				It("reports when the drain recovers", func() {
+					logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+					inputChan <- marshalled.New(logMessage)
+
+					Eventually(errorChannel).Should(Receive())
+					sysLogger.SetDown(false)
+
+					Eventually(func() string {
+						select {
+						case errorLog := <-errorChannel:
+							return string(errorLog.GetLogMessage().GetMessage())
+						default:
+							return ""
+						}
+					}, 2).Should(MatchRegexp(`Syslog Sink syslog://using-fake: Drain recovered after \d+ errors.`))
+				})
+			})
+
 			Context("when the buffer overflows", func() {
 				JustBeforeEach(func() {
 					for i := 0; i < bufferSize+5; i++ {
 						msg := fmt.Sprintf("message no %v", i)
 						logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, msg, "appId", "App"), "origin")
 
-						inputChan <- logMessage
+						inputChan <- marshalled.New(logMessage)
 					}
 					close(inputChan)
 				})
@@ -239,6 +434,10 @@ var _ = Describe("SyslogSink", func() {
 						}
 					})
 
+					It("reports the dropped messages in its status", func() {
+						Expect(syslogSink.Status().DroppedMessages).To(BeEquivalentTo(100))
+					})
+
 					It("sends a message about the buffer overflow", func() {
 						data := sysLogger.ReceivedMessages()
 						Expect(len(data)).To(BeNumerically(">", 2))
@@ -249,6 +448,55 @@ var _ = Describe("SyslogSink", func() {
 		})
 	})
 
+	Describe("with a writer that drops batches", func() {
+		It("counts the messages it dropped as dropped rather than sent", func() {
+			writer := &droppingWriterRecorder{SyslogWriterRecorder: NewSyslogWriterRecorder()}
+			drainURL, err := url.Parse("https://using-fake")
+			Expect(err).ToNot(HaveOccurred())
+			sink := syslog.NewSyslogSink("appId", drainURL, bufferSize, writer, errorHandler, "dropsonde-origin")
+			go sink.Run(inputChan)
+			defer sink.Disconnect()
+
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "test message", "appId", "App"), "origin")
+			for i := 0; i < 3; i++ {
+				inputChan <- marshalled.New(logMessage)
+			}
+			Eventually(func() uint64 {
+				return sink.Status().SentMessages
+			}).Should(BeEquivalentTo(3))
+			atomic.StoreUint64(&writer.dropped, 2)
+
+			Eventually(func() uint64 {
+				return sink.Status().DroppedMessages
+			}).Should(BeEquivalentTo(2))
+			Expect(sink.Status().SentMessages).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("with a writer that fails to write a metric", func() {
+		It("does not write the container metric's earlier values again", func() {
+			writer := &failingMetricWriterRecorder{SyslogWriterRecorder: NewSyslogWriterRecorder(), failAt: 3}
+			drainURL, err := url.Parse("syslog://using-fake?drain-type=metrics")
+			Expect(err).ToNot(HaveOccurred())
+			sink := syslog.NewSyslogSink("appId", drainURL, bufferSize, writer, errorHandler, "dropsonde-origin")
+			go sink.Run(inputChan)
+			defer sink.Disconnect()
+
+			containerMetric, _ := emitter.Wrap(factories.NewContainerMetric("appId", 2, 0.5, 1024, 2048), "origin")
+			inputChan <- marshalled.New(containerMetric)
+
+			Eventually(writer.ReceivedMessages, 5).Should(HaveLen(5))
+			Consistently(writer.ReceivedMessages).Should(HaveLen(5))
+			Expect(writer.ReceivedMessages()).To(ConsistOf(
+				HavePrefix("metric cpu "),
+				HavePrefix("metric memory "),
+				HavePrefix("metric disk "),
+				HavePrefix("metric memory_quota "),
+				HavePrefix("metric disk_quota "),
+			))
+		})
+	})
+
 	Describe("Disconnect", func() {
 		It("is idempotent", func() {
 			syslogSink.Disconnect()
@@ -263,6 +511,7 @@ var _ = Describe("SyslogSink", func() {
 		BeforeEach(func() {
 			errors = 0
 			timestamps = []time.Time{}
+			errorInterval = 0
 			errorHandler = func(errorMsg, appId string) {
 				timestamps = append(timestamps, time.Now())
 				atomic.AddInt64(&errors, 1)
@@ -284,7 +533,7 @@ var _ = Describe("SyslogSink", func() {
 
 		It("backsoff when retrying", func() {
 			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "a message", "appId", "App"), "origin")
-			inputChan <- logMessage
+			inputChan <- marshalled.New(logMessage)
 
 			close(inputChan)
 
@@ -304,8 +553,31 @@ var _ = Describe("SyslogSink", func() {
 	})
 })
 
+type droppingWriterRecorder struct {
+	*SyslogWriterRecorder
+	dropped uint64
+}
+
+func (r *droppingWriterRecorder) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+type failingMetricWriterRecorder struct {
+	*SyslogWriterRecorder
+	failAt int32
+	calls  int32
+}
+
+func (r *failingMetricWriterRecorder) WriteMetric(m syslogwriter.Metric, source, sourceId string, timestamp int64, tags map[string]string) (int, error) {
+	if atomic.AddInt32(&r.calls, 1) == r.failAt {
+		return 0, errors.New("Error writing metric.")
+	}
+	return r.SyslogWriterRecorder.WriteMetric(m, source, sourceId, timestamp, tags)
+}
+
 type SyslogWriterRecorder struct {
 	receivedChannel  chan string
+	receivedTags     chan map[string]string
 	receivedMessages []string
 	down             bool
 	connected        bool
@@ -315,6 +587,7 @@ type SyslogWriterRecorder struct {
 func NewSyslogWriterRecorder() *SyslogWriterRecorder {
 	return &SyslogWriterRecorder{
 		receivedChannel: make(chan string, 20),
+		receivedTags:    make(chan map[string]string, 20),
 		connected:       false,
 	}
 }
@@ -331,7 +604,7 @@ func (r *SyslogWriterRecorder) Connect() error {
 	}
 }
 
-func (r *SyslogWriterRecorder) Write(p int, b []byte, source, sourceId string, timestamp int64) (int, error) {
+func (r *SyslogWriterRecorder) Write(p int, b []byte, source, sourceId string, timestamp int64, tags map[string]string) (int, error) {
 	r.Lock()
 	defer r.Unlock()
 
@@ -342,9 +615,24 @@ func (r *SyslogWriterRecorder) Write(p int, b []byte, source, sourceId string, t
 	messageString := fmt.Sprintf("<%d>1 %s ts: %d src: %s srcId: %s", p, string(b), timestamp, source, sourceId)
 	r.receivedMessages = append(r.receivedMessages, messageString)
 	r.receivedChannel <- messageString
+	r.receivedTags <- tags
 	return len(b), nil
 }
 
+func (r *SyslogWriterRecorder) WriteMetric(m syslogwriter.Metric, source, sourceId string, timestamp int64, tags map[string]string) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.down {
+		return 0, errors.New("Error writing to stdout.")
+	}
+
+	messageString := fmt.Sprintf("metric %s %v %s ts: %d src: %s srcId: %s", m.Name, m.Value, m.Unit, timestamp, source, sourceId)
+	r.receivedMessages = append(r.receivedMessages, messageString)
+	r.receivedChannel <- messageString
+	return len(messageString), nil
+}
+
 func (r *SyslogWriterRecorder) SetDown(newState bool) {
 	r.Lock()
 	defer r.Unlock()
@@ -0,0 +1,48 @@
+package syslogwriter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Credentials are the PEM encoded client certificate, private key and CA
+// certificate a drain binding may carry. The syslog-tls and https writers
+// present the client certificate to the drain and verify the drain's
+// certificate against the CA.
+type Credentials struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Empty reports whether the credentials hold neither a client certificate
+// nor a CA.
+func (c Credentials) Empty() bool {
+	return c.Cert == "" && c.Key == "" && c.CA == ""
+}
+
+// Apply adds the client certificate and CA to the given tls.Config. A binding
+// that carries its own CA is always verified against it, even when Doppler is
+// configured to skip certificate verification.
+func (c Credentials) Apply(tlsConfig *tls.Config) error {
+	if c.Cert != "" || c.Key != "" {
+		cert, err := tls.X509KeyPair([]byte(c.Cert), []byte(c.Key))
+		if err != nil {
+			return fmt.Errorf("Invalid client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CA)) {
+			return errors.New("Invalid CA certificate: no PEM encoded certificates found")
+		}
+		tlsConfig.RootCAs = pool
+		tlsConfig.InsecureSkipVerify = false
+	}
+
+	return nil
+}
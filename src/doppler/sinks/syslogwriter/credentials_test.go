@@ -0,0 +1,98 @@
+package syslogwriter_test
+
+import (
+	"doppler/sinks/syslogwriter"
+	"io/ioutil"
+	"plumbing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	var creds syslogwriter.Credentials
+
+	BeforeEach(func() {
+		creds = syslogwriter.Credentials{
+			Cert: readFixture("fixtures/client.crt"),
+			Key:  readFixture("fixtures/client.key"),
+			CA:   readFixture("fixtures/loggregator-ca.crt"),
+		}
+	})
+
+	It("adds the client certificate and CA to the TLS config", func() {
+		tlsConfig := plumbing.NewTLSConfig()
+
+		Expect(creds.Apply(tlsConfig)).To(Succeed())
+
+		Expect(tlsConfig.Certificates).To(HaveLen(1))
+		Expect(tlsConfig.RootCAs.Subjects()).To(HaveLen(1))
+		Expect(string(tlsConfig.RootCAs.Subjects()[0])).To(ContainSubstring("loggregatorCA"))
+	})
+
+	It("verifies the drain against the CA even when verification is skipped", func() {
+		tlsConfig := plumbing.NewTLSConfig()
+		tlsConfig.InsecureSkipVerify = true
+
+		Expect(creds.Apply(tlsConfig)).To(Succeed())
+
+		Expect(tlsConfig.InsecureSkipVerify).To(BeFalse())
+	})
+
+	It("keeps skipping verification without a CA", func() {
+		tlsConfig := plumbing.NewTLSConfig()
+		tlsConfig.InsecureSkipVerify = true
+		creds.CA = ""
+
+		Expect(creds.Apply(tlsConfig)).To(Succeed())
+
+		Expect(tlsConfig.InsecureSkipVerify).To(BeTrue())
+	})
+
+	It("only adds the CA when there is no client certificate", func() {
+		tlsConfig := plumbing.NewTLSConfig()
+		creds.Cert, creds.Key = "", ""
+
+		Expect(creds.Apply(tlsConfig)).To(Succeed())
+
+		Expect(tlsConfig.Certificates).To(BeEmpty())
+		Expect(tlsConfig.RootCAs).ToNot(BeNil())
+	})
+
+	It("leaves the TLS config alone without credentials", func() {
+		tlsConfig := plumbing.NewTLSConfig()
+
+		Expect(syslogwriter.Credentials{}.Empty()).To(BeTrue())
+		Expect(syslogwriter.Credentials{}.Apply(tlsConfig)).To(Succeed())
+
+		Expect(tlsConfig.Certificates).To(BeEmpty())
+		Expect(tlsConfig.RootCAs).To(BeNil())
+	})
+
+	It("returns an error for a certificate without a key", func() {
+		creds.Key = ""
+
+		err := creds.Apply(plumbing.NewTLSConfig())
+		Expect(err).To(MatchError(ContainSubstring("Invalid client certificate")))
+	})
+
+	It("returns an error for a key that does not match the certificate", func() {
+		creds.Key = readFixture("fixtures/key.key")
+
+		err := creds.Apply(plumbing.NewTLSConfig())
+		Expect(err).To(MatchError(ContainSubstring("Invalid client certificate")))
+	})
+
+	It("returns an error for an invalid CA", func() {
+		creds.CA = "not a certificate"
+
+		err := creds.Apply(plumbing.NewTLSConfig())
+		Expect(err).To(MatchError(ContainSubstring("Invalid CA certificate")))
+	})
+})
+
+func readFixture(path string) string {
+	contents, err := ioutil.ReadFile(path)
+	Expect(err).ToNot(HaveOccurred())
+	return string(contents)
+}
@@ -0,0 +1,193 @@
+package syslogwriter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	defaultHostname = "loggregator"
+
+	// structuredDataID is the SD-ID of the element carrying the envelope
+	// tags. 47450 is the Cloud Foundry Foundation's private enterprise
+	// number.
+	structuredDataID = "tags@47450"
+
+	maxHostnameLength  = 255
+	maxParamNameLength = 32
+
+	// maxCachedHostnames bounds the HOSTNAMEs kept for message sources.
+	maxCachedHostnames = 10000
+)
+
+// Format configures the optional parts of the RFC5424 messages written to
+// drains. The zero Format writes the HOSTNAME "loggregator" and no
+// STRUCTURED-DATA.
+type Format struct {
+	structuredData bool
+	hostname       *template.Template
+	hostnames      *hostnameCache
+}
+
+// NewFormat returns a Format that includes an SD-ELEMENT with the envelope
+// tags when structuredData is true, and fills HOSTNAME from hostnameTemplate.
+// The template is executed with the fields of HostnameData. An empty
+// template keeps the default HOSTNAME.
+//
+// The HOSTNAME is computed once per app and source (deployment, job, index,
+// IP and source instance). Templates that use Tags are executed for every
+// message instead, since custom tags may differ between messages.
+func NewFormat(structuredData bool, hostnameTemplate string) (Format, error) {
+	f := Format{structuredData: structuredData}
+	if hostnameTemplate == "" {
+		return f, nil
+	}
+
+	t, err := template.New("hostname").Option("missingkey=zero").Parse(hostnameTemplate)
+	if err != nil {
+		return Format{}, fmt.Errorf("invalid hostname template: %s", err)
+	}
+	f.hostname = t
+	if !strings.Contains(hostnameTemplate, "Tags") {
+		f.hostnames = &hostnameCache{hostnames: make(map[hostnameKey]string)}
+	}
+	return f, nil
+}
+
+// hostnameCache holds the HOSTNAME for each message source. It is shared by
+// every drain's writer.
+type hostnameCache struct {
+	mu        sync.Mutex
+	hostnames map[hostnameKey]string
+}
+
+type hostnameKey struct {
+	appID, deployment, job, index, ip, sourceInstance string
+}
+
+// HostnameData is the data available to a HOSTNAME template, e.g.
+// "{{.Deployment}}.{{.Job}}.{{.Index}}".
+type HostnameData struct {
+	AppID          string
+	Deployment     string
+	Job            string
+	Index          string
+	IP             string
+	SourceInstance string
+	Tags           map[string]string
+}
+
+func (f Format) hostnameFor(appId string, tags map[string]string) string {
+	if f.hostname == nil {
+		return defaultHostname
+	}
+
+	data := HostnameData{
+		AppID:          appId,
+		Deployment:     tags["deployment"],
+		Job:            tags["job"],
+		Index:          tags["index"],
+		IP:             tags["ip"],
+		SourceInstance: tags["source_instance"],
+	}
+
+	if f.hostnames == nil {
+		data.Tags = tags
+		return f.executeHostname(data)
+	}
+
+	key := hostnameKey{
+		appID:          data.AppID,
+		deployment:     data.Deployment,
+		job:            data.Job,
+		index:          data.Index,
+		ip:             data.IP,
+		sourceInstance: data.SourceInstance,
+	}
+	f.hostnames.mu.Lock()
+	defer f.hostnames.mu.Unlock()
+
+	hostname, ok := f.hostnames.hostnames[key]
+	if !ok {
+		if len(f.hostnames.hostnames) >= maxCachedHostnames {
+			f.hostnames.hostnames = make(map[hostnameKey]string)
+		}
+		hostname = f.executeHostname(data)
+		f.hostnames.hostnames[key] = hostname
+	}
+	return hostname
+}
+
+func (f Format) executeHostname(data HostnameData) string {
+	var buf bytes.Buffer
+	if err := f.hostname.Execute(&buf, data); err != nil {
+		return defaultHostname
+	}
+
+	hostname := printableASCII(buf.String())
+	if len(hostname) > maxHostnameLength {
+		hostname = hostname[:maxHostnameLength]
+	}
+	if hostname == "" {
+		return "-"
+	}
+	return hostname
+}
+
+// structuredDataFor returns the STRUCTURED-DATA field for a message with
+// the given tags, see https://tools.ietf.org/html/rfc5424#section-6.3.
+func (f Format) structuredDataFor(tags map[string]string) string {
+	if !f.structuredData || len(tags) == 0 {
+		return "-"
+	}
+
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sd bytes.Buffer
+	sd.WriteString("[" + structuredDataID)
+	for _, name := range names {
+		paramName := sdName(name)
+		if paramName == "" {
+			continue
+		}
+		fmt.Fprintf(&sd, ` %s="%s"`, paramName, sdValueEscaper.Replace(tags[name]))
+	}
+	sd.WriteString("]")
+	return sd.String()
+}
+
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName strips the characters RFC5424 does not allow in a PARAM-NAME.
+func sdName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, printableASCII(name))
+
+	if len(name) > maxParamNameLength {
+		name = name[:maxParamNameLength]
+	}
+	return name
+}
+
+// printableASCII strips the characters outside of PRINTUSASCII, which
+// includes spaces.
+func printableASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,122 @@
+package syslogwriter_test
+
+import (
+	"doppler/sinks/syslogwriter"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Format", func() {
+	var (
+		server      *httptest.Server
+		requestChan chan []byte
+		timestamp   int64
+	)
+
+	BeforeEach(func() {
+		requestChan = make(chan []byte, 1)
+		serveMux := http.NewServeMux()
+		serveMux.HandleFunc("/drain/", syslogHandler(requestChan, http.StatusOK))
+		server = httptest.NewTLSServer(serveMux)
+
+		parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+		Expect(err).ToNot(HaveOccurred())
+		timestamp = parsedTime.UnixNano()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	write := func(format syslogwriter.Format, tags map[string]string) string {
+		outputUrl, err := url.Parse(server.URL + "/drain/?batch-size=1")
+		Expect(err).ToNot(HaveOccurred())
+
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", true, syslogwriter.Credentials{}, time.Second, 0, format, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = w.Write(standardErrorPriority, []byte("Message"), "APP", "2", timestamp, tags)
+		Expect(err).ToNot(HaveOccurred())
+
+		var msg []byte
+		Eventually(requestChan).Should(Receive(&msg))
+		return string(msg)
+	}
+
+	tags := map[string]string{
+		"deployment":      "cf",
+		"job":             "diego-cell",
+		"index":           "0",
+		"source_instance": "2",
+		"custom":          `a "quoted" \value]`,
+	}
+
+	It("writes no structured data and the default hostname by default", func() {
+		Expect(write(syslogwriter.Format{}, tags)).To(HaveSuffix(
+			" loggregator appId [APP/2] - - Message\n",
+		))
+	})
+
+	It("writes the tags as an SD-ELEMENT", func() {
+		format, err := syslogwriter.NewFormat(true, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, tags)).To(HaveSuffix(
+			` loggregator appId [APP/2] - ` +
+				`[tags@47450 custom="a \"quoted\" \\value\]" deployment="cf" index="0" job="diego-cell" source_instance="2"] Message` + "\n",
+		))
+	})
+
+	It("writes no SD-ELEMENT without tags", func() {
+		format, err := syslogwriter.NewFormat(true, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, nil)).To(ContainSubstring("[APP/2] - - Message"))
+	})
+
+	It("fills the hostname from the template", func() {
+		format, err := syslogwriter.NewFormat(false, "{{.Deployment}}.{{.Job}}.{{.Index}} {{.Tags.custom}}")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, map[string]string{
+			"deployment": "cf",
+			"job":        "diego-cell",
+			"index":      "0",
+			"custom":     "x",
+		})).To(ContainSubstring(" cf.diego-cell.0x appId "))
+	})
+
+	It("fills the hostname for each source", func() {
+		format, err := syslogwriter.NewFormat(false, "{{.Job}}.{{.Index}}")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, map[string]string{"job": "diego-cell", "index": "0"})).To(ContainSubstring(" diego-cell.0 appId "))
+		Expect(write(format, map[string]string{"job": "diego-cell", "index": "1"})).To(ContainSubstring(" diego-cell.1 appId "))
+		Expect(write(format, map[string]string{"job": "diego-cell", "index": "0"})).To(ContainSubstring(" diego-cell.0 appId "))
+	})
+
+	It("fills the hostname from each message's tags", func() {
+		format, err := syslogwriter.NewFormat(false, "{{.Tags.custom}}")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, map[string]string{"custom": "x"})).To(ContainSubstring(" x appId "))
+		Expect(write(format, map[string]string{"custom": "y"})).To(ContainSubstring(" y appId "))
+	})
+
+	It("writes a nil value for an empty hostname", func() {
+		format, err := syslogwriter.NewFormat(false, "{{.Deployment}}")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(write(format, nil)).To(MatchRegexp(`:\d{2} - appId \[APP/2\]`))
+	})
+
+	It("rejects an invalid hostname template", func() {
+		_, err := syslogwriter.NewFormat(false, "{{.Deployment")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -6,7 +6,9 @@
 package syslogwriter
 
 import (
+	"bytes"
 	"crypto/tls"
+	"doppler/sinks/retrystrategy"
 	"errors"
 	"fmt"
 	"io"
@@ -15,20 +17,62 @@ import (
 	"net/http"
 	"net/url"
 	"plumbing"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultBatchSize     = 100
+	defaultBatchBytes    = 256 * 1024
+	defaultBatchInterval = time.Second
+
+	maxPostAttempts = 3
+	maxRetryAfter   = 30 * time.Second
+
+	logsContentType    = "text/plain"
+	metricsContentType = "application/x-ndjson"
+)
+
+// httpsWriter POSTs messages to an https drain. It buffers messages and
+// POSTs them newline-delimited once the batch holds batch-size messages or
+// batch-bytes bytes, or batch-interval after its first message. These are
+// set by the drain URL's query parameters of the same names; a batch-size of
+// 1 POSTs every message on its own. Syslog messages and JSON metrics are
+// batched separately and POSTed with their own Content-Type. Only one batch
+// is POSTed at a time.
 type httpsWriter struct {
 	appId     string
 	outputUrl *url.URL
+	format    Format
+
+	batchSize     int
+	batchBytes    int
+	batchInterval time.Duration
+	retryBackoff  retrystrategy.RetryStrategy
 
-	mu sync.Mutex // guards lastError
+	flushMu   sync.Mutex // held while a batch is taken and POSTed
+	mu        sync.Mutex // guards logs, metrics and lastError
+	logs      *httpsBatch
+	metrics   *httpsBatch
+	lastError error
+	dropped   uint64
+
+	done       chan struct{} // closed by Close to stop waiting to retry
+	closeOnce  sync.Once
+	cancelled  chan struct{} // closed by Cancel to abandon POSTs
+	cancelOnce sync.Once
 
 	TlsConfig *tls.Config
 	client    *http.Client
-	lastError error
+}
+
+type httpsBatch struct {
+	contentType string
+	buf         bytes.Buffer
+	len         int
+	flushTimer  *time.Timer
 }
 
 func NewHttpsWriter(outputUrl *url.URL, appId string, skipCertVerify bool, dialer *net.Dialer, timeout time.Duration) (w *httpsWriter, err error) {
@@ -40,6 +84,28 @@ func NewHttpsWriter(outputUrl *url.URL, appId string, skipCertVerify bool, diale
 		return nil, errors.New(fmt.Sprintf("Invalid scheme %s, httpsWriter only supports https", outputUrl.Scheme))
 	}
 
+	postURL := *outputUrl
+	query := postURL.Query()
+	batchSize, err := intParam(query, "batch-size", defaultBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	batchBytes, err := intParam(query, "batch-bytes", defaultBatchBytes)
+	if err != nil {
+		return nil, err
+	}
+	batchInterval := defaultBatchInterval
+	if v := query.Get("batch-interval"); v != "" {
+		batchInterval, err = time.ParseDuration(v)
+		if err != nil || batchInterval <= 0 {
+			return nil, fmt.Errorf("Invalid batch-interval %s", v)
+		}
+	}
+	for _, param := range []string{"batch-size", "batch-bytes", "batch-interval", "drain-type"} {
+		query.Del(param)
+	}
+	postURL.RawQuery = query.Encode()
+
 	tlsConfig := plumbing.NewTLSConfig()
 	tlsConfig.InsecureSkipVerify = skipCertVerify
 	tr := &http.Transport{
@@ -52,13 +118,36 @@ func NewHttpsWriter(outputUrl *url.URL, appId string, skipCertVerify bool, diale
 	}
 	client := &http.Client{Transport: tr, Timeout: timeout}
 	return &httpsWriter{
-		appId:     appId,
-		outputUrl: outputUrl,
-		TlsConfig: tlsConfig,
-		client:    client,
+		appId:         appId,
+		outputUrl:     &postURL,
+		batchSize:     batchSize,
+		batchBytes:    batchBytes,
+		batchInterval: batchInterval,
+		retryBackoff:  retrystrategy.CappedDouble(100*time.Millisecond, 5*time.Second),
+		logs:          &httpsBatch{contentType: logsContentType},
+		metrics:       &httpsBatch{contentType: metricsContentType},
+		done:          make(chan struct{}),
+		cancelled:     make(chan struct{}),
+		TlsConfig:     tlsConfig,
+		client:        client,
 	}, nil
 }
 
+func intParam(query url.Values, name string, defaultValue int) (int, error) {
+	v := query.Get(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("Invalid %s %s", name, v)
+	}
+	return i, nil
+}
+
+// Connect returns the error of the last failed POST, so that the drain
+// backs off before writing to it again.
 func (w *httpsWriter) Connect() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -70,30 +159,188 @@ func (w *httpsWriter) Connect() error {
 	return nil
 }
 
-func (w *httpsWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64) (int, error) {
-	syslogMsg := createMessage(p, w.appId, source, sourceId, b, timestamp)
-	bytesWritten, err := w.writeHttp(syslogMsg)
+func (w *httpsWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64, tags map[string]string) (int, error) {
+	return w.write(w.logs, createMessage(w.format, p, w.appId, source, sourceId, b, timestamp, tags))
+}
+
+// WriteMetric POSTs the metric as a JSON object, batched apart from syslog
+// messages.
+func (w *httpsWriter) WriteMetric(m Metric, source string, sourceId string, timestamp int64, tags map[string]string) (int, error) {
+	msg, err := createJSONMetric(w.appId, source, sourceId, m, timestamp, tags)
+	if err != nil {
+		return 0, err
+	}
+	return w.write(w.metrics, msg)
+}
+
+func (w *httpsWriter) write(batch *httpsBatch, msg string) (int, error) {
 	w.mu.Lock()
-	w.lastError = err
+	if w.lastError != nil {
+		err := w.lastError
+		w.mu.Unlock()
+		return 0, err
+	}
+	batch.buf.WriteString(msg)
+	batch.len++
+	full := batch.len >= w.batchSize || batch.buf.Len() >= w.batchBytes
+	if !full && batch.flushTimer == nil {
+		batch.flushTimer = time.AfterFunc(w.batchInterval, func() { w.flush(batch, 0) })
+	}
 	w.mu.Unlock()
-	return bytesWritten, err
+
+	if !full {
+		return len(msg), nil
+	}
+
+	// The message is returned the error if the batch is dropped, and so is
+	// written again rather than counted as dropped.
+	err := w.flush(batch, 1)
+	return len(msg), err
 }
 
+// Close POSTs any buffered messages.
 func (w *httpsWriter) Close() error {
-	return nil
+	w.closeOnce.Do(func() { close(w.done) })
+	err := w.flush(w.logs, 0)
+	if metricsErr := w.flush(w.metrics, 0); err == nil {
+		err = metricsErr
+	}
+	return err
+}
+
+// Cancel abandons the POST in progress and any later ones, so that a sink
+// being removed is not held up by a slow or throttling drain. The messages
+// not yet delivered are dropped.
+func (w *httpsWriter) Cancel() {
+	w.cancelOnce.Do(func() { close(w.cancelled) })
+}
+
+// Dropped returns the number of messages accepted by Write or WriteMetric
+// that were then dropped with a batch that could not be delivered.
+func (w *httpsWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
 }
 
-func (w *httpsWriter) writeHttp(finalMsg string) (byteCount int, err error) {
-	resp, err := w.client.Post(w.outputUrl.String(), "text/plain", strings.NewReader(finalMsg))
-	byteCount = len(finalMsg)
+// flush POSTs the messages buffered in the batch. A batch that cannot be
+// delivered is dropped and its error returned by the next Write or Connect.
+// Its messages are counted as dropped, except for the last rewritten messages
+// whose writer is returned the error.
+func (w *httpsWriter) flush(batch *httpsBatch, rewritten int) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	if batch.flushTimer != nil {
+		batch.flushTimer.Stop()
+		batch.flushTimer = nil
+	}
+	if batch.len == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	msgs := make([]byte, batch.buf.Len())
+	copy(msgs, batch.buf.Bytes())
+	batchLen := batch.len
+	batch.buf.Reset()
+	batch.len = 0
+	w.mu.Unlock()
+
+	err := w.post(batch.contentType, msgs)
+	if err != nil && batchLen > rewritten {
+		atomic.AddUint64(&w.dropped, uint64(batchLen-rewritten))
+	}
+
+	w.mu.Lock()
+	w.lastError = err
+	w.mu.Unlock()
+	return err
+}
+
+// post POSTs the messages, retrying when the drain responds with 429 or a
+// 5XX status code. It waits as long as the response's Retry-After header
+// asks, or backs off exponentially when there is none.
+func (w *httpsWriter) post(contentType string, msgs []byte) error {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-w.cancelled:
+			return errors.New("syslog https writer: cancelled")
+		default:
+		}
+
+		retryAfter, err := w.writeHttp(contentType, msgs)
+		if err == nil || retryAfter < 0 || attempt+1 >= maxPostAttempts {
+			return err
+		}
+
+		if retryAfter == 0 {
+			retryAfter = w.retryBackoff(attempt)
+		}
+
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-w.done:
+			timer.Stop()
+			return err
+		case <-w.cancelled:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// writeHttp POSTs the messages once. When the POST can be retried it returns
+// how long the drain asked to wait, zero if it did not say, and -1 when the
+// POST should not be retried.
+func (w *httpsWriter) writeHttp(contentType string, msgs []byte) (time.Duration, error) {
+	req, err := http.NewRequest("POST", w.outputUrl.String(), bytes.NewReader(msgs))
 	if err != nil {
-		return byteCount, errors.New("syslog https writer: failed to connect")
+		return -1, err
 	}
+	req.Header.Set("Content-Type", contentType)
+	req.Cancel = w.cancelled
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		err = errors.New("Syslog Writer: Post responded with a non 2XX status code")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The url.Error would repeat the drain URL, which may hold secrets.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return -1, fmt.Errorf("syslog https writer: failed to connect: %s", err)
 	}
 	io.Copy(ioutil.Discard, resp.Body)
 	resp.Body.Close()
-	return byteCount, err
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return 0, nil
+	}
+
+	err = errors.New("Syslog Writer: Post responded with a non 2XX status code")
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After")), err
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date, capped at maxRetryAfter.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	var d time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		d = t.Sub(time.Now())
+	}
+
+	if d <= 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
 }
@@ -3,11 +3,14 @@ package syslogwriter_test
 import (
 	"crypto/tls"
 	"doppler/sinks/syslogwriter"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -69,31 +72,31 @@ var _ = Describe("HttpsWriter", func() {
 		})
 
 		It("HTTP POSTs each log message to the HTTPS syslog endpoint", func() {
-			outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/")
+			outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=1")
 
 			w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
 			err := w.Connect()
 			Expect(err).ToNot(HaveOccurred())
 
 			parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
-			_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano())
+			_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
 			Expect(err).ToNot(HaveOccurred())
 			Eventually(requestChan).Should(Receive(ContainSubstring("loggregator appId [TEST] - - Message")))
 		})
 
 		It("returns an error when unable to HTTP POST the log message", func() {
-			outputUrl, _ := url.Parse("https://")
+			outputUrl, _ := url.Parse("https://?batch-size=1")
 
 			w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
-			_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano())
+			_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("holds onto the last error when unable to POST a log message", func() {
-			outputUrl, _ := url.Parse("https://")
+			outputUrl, _ := url.Parse("https://?batch-size=1")
 
 			w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
-			_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano())
+			_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
 
 			conErr := w.Connect()
 			Expect(conErr).To(Equal(err))
@@ -101,7 +104,7 @@ var _ = Describe("HttpsWriter", func() {
 		})
 
 		It("should close connections and return an error if status code returned is not 2XX", func() {
-			outputUrl, _ := url.Parse(server.URL + "/doesnotexist")
+			outputUrl, _ := url.Parse(server.URL + "/doesnotexist?batch-size=1")
 
 			w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
 			err := w.Connect()
@@ -109,7 +112,7 @@ var _ = Describe("HttpsWriter", func() {
 
 			parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
 			for i := 0; i < 10; i++ {
-				_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano())
+				_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
 				Expect(err).To(HaveOccurred())
 			}
 		})
@@ -127,7 +130,7 @@ var _ = Describe("HttpsWriter", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
-				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano())
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
@@ -146,7 +149,7 @@ var _ = Describe("HttpsWriter", func() {
 			})
 
 			It("times out", func() {
-				outputUrl, _ := url.Parse("https://" + listener.Addr().String() + "/")
+				outputUrl, _ := url.Parse("https://" + listener.Addr().String() + "/?batch-size=1")
 				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
 				Expect(err).NotTo(HaveOccurred())
 
@@ -154,7 +157,7 @@ var _ = Describe("HttpsWriter", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
-				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano())
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -175,13 +178,13 @@ var _ = Describe("HttpsWriter", func() {
 			})
 
 			It("returns a timeout error", func() {
-				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/")
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=1")
 				w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
 				err := w.Connect()
 				Expect(err).ToNot(HaveOccurred())
 
 				parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
-				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano())
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -194,19 +197,270 @@ var _ = Describe("HttpsWriter", func() {
 				serveMux.Handle("/pause/", requester)
 			})
 
-			It("only keeps one idle connection", func() {
-				outputUrl, _ := url.Parse(server.URL + "/pause/")
+			It("POSTs one batch at a time over a single connection", func() {
+				outputUrl, _ := url.Parse(server.URL + "/pause/?batch-size=1")
 				w, _ := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
 				err := w.Connect()
 				Expect(err).ToNot(HaveOccurred())
 
-				// one connection per request
 				requester.concurrentWriteRequests(2, w)
-				Expect(listener.GetHistoryLength()).To(Equal(2))
+				Expect(listener.GetHistoryLength()).To(Equal(1))
 
-				// one pooled connection, one new connection
 				requester.concurrentWriteRequests(2, w)
-				Expect(listener.GetHistoryLength()).To(BeNumerically("<=", 3))
+				Expect(listener.GetHistoryLength()).To(Equal(1))
+			})
+		})
+
+		Context("with batching", func() {
+			var parsedTime time.Time
+
+			BeforeEach(func() {
+				queuedRequests = 10
+				parsedTime, _ = time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+			})
+
+			It("POSTs the messages newline-delimited once the batch is full", func() {
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=3&batch-interval=1h")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				for i := 0; i < 3; i++ {
+					Consistently(requestChan, 100*time.Millisecond).ShouldNot(Receive())
+					_, err = w.Write(standardErrorPriority, []byte(fmt.Sprintf("Message %d", i)), "test", "TEST", parsedTime.UnixNano(), nil)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				var body []byte
+				Eventually(requestChan).Should(Receive(&body))
+				lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+				Expect(lines).To(HaveLen(3))
+				for i, line := range lines {
+					Expect(line).To(HaveSuffix(fmt.Sprintf("loggregator appId [TEST] - - Message %d", i)))
+				}
+			})
+
+			It("POSTs the messages once the batch reaches its size in bytes", func() {
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=100&batch-bytes=100&batch-interval=1h")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Consistently(requestChan, 100*time.Millisecond).ShouldNot(Receive())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Eventually(requestChan).Should(Receive())
+			})
+
+			It("POSTs the messages after the batch interval", func() {
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=100&batch-interval=50ms")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(requestChan).Should(Receive(ContainSubstring("loggregator appId [TEST] - - Message")))
+			})
+
+			It("buffers messages by default", func() {
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				for i := 0; i < 2; i++ {
+					_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Consistently(requestChan, 100*time.Millisecond).ShouldNot(Receive())
+
+				var body []byte
+				Eventually(requestChan, 2).Should(Receive(&body))
+				Expect(strings.Count(string(body), "\n")).To(Equal(2))
+			})
+
+			It("POSTs the buffered messages when it is closed", func() {
+				outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=100&batch-interval=1h")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(w.Close()).To(Succeed())
+
+				Expect(requestChan).To(Receive(ContainSubstring("loggregator appId [TEST] - - Message")))
+			})
+
+			It("does not pass the batch and drain-type parameters on to the drain", func() {
+				queries := make(chan string, 1)
+				serveMux.HandleFunc("/query/", func(rw http.ResponseWriter, r *http.Request) {
+					queries <- r.URL.RawQuery
+				})
+
+				outputUrl, _ := url.Parse(server.URL + "/query/?token=abc&batch-size=1&batch-bytes=10&batch-interval=1s&drain-type=all")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(queries).To(Receive(Equal("token=abc")))
+			})
+
+			Context("when a batch cannot be delivered", func() {
+				BeforeEach(func() {
+					statusCode = http.StatusBadRequest
+				})
+
+				It("counts the messages written before the batch was full as dropped", func() {
+					outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=3&batch-interval=1h")
+					w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+					Expect(err).ToNot(HaveOccurred())
+
+					for i := 0; i < 2; i++ {
+						_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+						Expect(err).ToNot(HaveOccurred())
+					}
+					_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+					Expect(err).To(HaveOccurred())
+
+					Expect(w.Dropped()).To(BeEquivalentTo(2))
+				})
+
+				It("counts the messages of a batch POSTed after the batch interval as dropped", func() {
+					outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?batch-size=100&batch-interval=50ms")
+					w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+					Expect(err).ToNot(HaveOccurred())
+
+					for i := 0; i < 2; i++ {
+						_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", parsedTime.UnixNano(), nil)
+						Expect(err).ToNot(HaveOccurred())
+					}
+
+					Eventually(w.Dropped).Should(BeEquivalentTo(2))
+				})
+			})
+
+			It("rejects invalid batch parameters", func() {
+				for _, query := range []string{"batch-size=0", "batch-size=many", "batch-bytes=-1", "batch-interval=soon"} {
+					outputUrl, _ := url.Parse(server.URL + "/234-bxg-234/?" + query)
+					_, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+					Expect(err).To(HaveOccurred(), query)
+				}
+			})
+		})
+
+		Context("when the drain asks to retry", func() {
+			var (
+				requests   int64
+				retryAfter string
+				statuses   chan int
+			)
+
+			BeforeEach(func() {
+				atomic.StoreInt64(&requests, 0)
+				retryAfter = ""
+				statuses = make(chan int, 10)
+			})
+
+			JustBeforeEach(func() {
+				serveMux.HandleFunc("/retry/", func(rw http.ResponseWriter, r *http.Request) {
+					atomic.AddInt64(&requests, 1)
+					if retryAfter != "" {
+						rw.Header().Set("Retry-After", retryAfter)
+					}
+
+					select {
+					case status := <-statuses:
+						rw.WriteHeader(status)
+					default:
+						rw.WriteHeader(http.StatusOK)
+					}
+				})
+			})
+
+			write := func() error {
+				outputUrl, _ := url.Parse(server.URL + "/retry/?batch-size=1")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
+				return err
+			}
+
+			It("retries on 5XX status codes", func() {
+				statuses <- http.StatusServiceUnavailable
+
+				Expect(write()).To(Succeed())
+				Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(2))
+			})
+
+			It("retries on 429 after the time given by Retry-After", func() {
+				statuses <- http.StatusTooManyRequests
+				retryAfter = "1"
+
+				start := time.Now()
+				Expect(write()).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically(">=", time.Second))
+				Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(2))
+			})
+
+			It("gives up after three attempts", func() {
+				for i := 0; i < 3; i++ {
+					statuses <- http.StatusInternalServerError
+				}
+
+				Expect(write()).ToNot(Succeed())
+				Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(3))
+			})
+
+			It("stops retrying when it is cancelled", func() {
+				statuses <- http.StatusTooManyRequests
+				retryAfter = "30"
+
+				outputUrl, _ := url.Parse(server.URL + "/retry/?batch-size=1")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				errs := make(chan error, 1)
+				go func() {
+					_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
+					errs <- err
+				}()
+				Eventually(func() int64 { return atomic.LoadInt64(&requests) }).Should(BeEquivalentTo(1))
+
+				w.Cancel()
+				Eventually(errs).Should(Receive(HaveOccurred()))
+				Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(1))
+			})
+
+			It("abandons a POST in progress when it is cancelled", func() {
+				release := make(chan struct{})
+				defer close(release)
+				serveMux.HandleFunc("/slow/", func(rw http.ResponseWriter, r *http.Request) {
+					<-release
+				})
+
+				outputUrl, _ := url.Parse(server.URL + "/slow/?batch-size=1")
+				w, err := syslogwriter.NewHttpsWriter(outputUrl, "appId", true, dialer, timeout)
+				Expect(err).ToNot(HaveOccurred())
+
+				errs := make(chan error, 1)
+				go func() {
+					_, err := w.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
+					errs <- err
+				}()
+				Consistently(errs, 100*time.Millisecond).ShouldNot(Receive())
+
+				w.Cancel()
+				Eventually(errs).Should(Receive(HaveOccurred()))
+			})
+
+			It("does not retry on other status codes", func() {
+				statuses <- http.StatusBadRequest
+
+				Expect(write()).ToNot(Succeed())
+				Expect(atomic.LoadInt64(&requests)).To(BeEquivalentTo(1))
 			})
 		})
 
@@ -283,7 +537,7 @@ func (c *concurrentWriteRequestSimulator) concurrentWriteRequests(count int, wri
 
 	for i := 0; i < count; i++ {
 		go func() {
-			writer.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano())
+			writer.Write(standardErrorPriority, []byte("Message"), "test", "TEST", time.Now().UnixNano(), nil)
 			wg.Done()
 		}()
 	}
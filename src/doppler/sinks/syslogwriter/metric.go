@@ -0,0 +1,76 @@
+package syslogwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// metricStructuredDataID is the SD-ID of the element carrying a
+	// metric's name, value and unit.
+	metricStructuredDataID = "gauge@47450"
+
+	// metricPriority is user.info, the priority of app stdout logs.
+	metricPriority = 14
+)
+
+// Metric is a measurement written to a drain, e.g. one of an app instance's
+// container metrics or the duration of an HTTP request.
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  string
+}
+
+func (m Metric) formattedValue() string {
+	return strconv.FormatFloat(m.Value, 'g', -1, 64)
+}
+
+// structuredData returns the SD-ELEMENT holding the metric, e.g.
+// [gauge@47450 name="cpu" value="0.5" unit="percentage"].
+func (m Metric) structuredData() string {
+	return fmt.Sprintf(`[%s name="%s" value="%s" unit="%s"]`,
+		metricStructuredDataID,
+		sdValueEscaper.Replace(m.Name),
+		m.formattedValue(),
+		sdValueEscaper.Replace(m.Unit),
+	)
+}
+
+// createMetricMessage returns the syslog message for a metric. Its MSG
+// repeats the metric for drains that ignore STRUCTURED-DATA.
+func createMetricMessage(format Format, appId string, source string, sourceId string, m Metric, timestamp int64, tags map[string]string) string {
+	msg := []byte(fmt.Sprintf("%s %s %s", m.Name, m.formattedValue(), m.Unit))
+	return formatMessage(format, metricPriority, appId, source, sourceId, m.structuredData(), msg, timestamp, tags)
+}
+
+type jsonMetric struct {
+	Timestamp      int64             `json:"timestamp"`
+	AppID          string            `json:"app_id"`
+	SourceType     string            `json:"source_type"`
+	SourceInstance string            `json:"source_instance"`
+	Name           string            `json:"name"`
+	Value          float64           `json:"value"`
+	Unit           string            `json:"unit"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+// createJSONMetric returns the newline terminated JSON object an https
+// drain receives for a metric.
+func createJSONMetric(appId string, source string, sourceId string, m Metric, timestamp int64, tags map[string]string) (string, error) {
+	b, err := json.Marshal(jsonMetric{
+		Timestamp:      timestamp,
+		AppID:          appId,
+		SourceType:     source,
+		SourceInstance: sourceId,
+		Name:           m.Name,
+		Value:          m.Value,
+		Unit:           m.Unit,
+		Tags:           tags,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
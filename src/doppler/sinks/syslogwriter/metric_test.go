@@ -0,0 +1,153 @@
+package syslogwriter_test
+
+import (
+	"doppler/sinks/syslogwriter"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WriteMetric", func() {
+	var (
+		metric    syslogwriter.Metric
+		tags      map[string]string
+		timestamp int64
+	)
+
+	BeforeEach(func() {
+		metric = syslogwriter.Metric{Name: "cpu", Value: 0.5, Unit: "percentage"}
+		tags = map[string]string{"index": "0", "source_instance": "2"}
+
+		parsedTime, err := time.Parse(time.RFC3339, "2006-01-02T15:04:05Z")
+		Expect(err).ToNot(HaveOccurred())
+		timestamp = parsedTime.UnixNano()
+	})
+
+	Context("with a syslog drain", func() {
+		var conn net.PacketConn
+
+		BeforeEach(func() {
+			var err error
+			conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			conn.Close()
+		})
+
+		write := func(format syslogwriter.Format) string {
+			outputURL := &url.URL{Scheme: "syslog-udp", Host: conn.LocalAddr().String()}
+			w, err := syslogwriter.NewWriter(outputURL, "appId", false, syslogwriter.Credentials{}, time.Second, 0, format, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(w.Connect()).To(Succeed())
+			defer w.Close()
+
+			_, err = w.WriteMetric(metric, "APP", "2", timestamp, tags)
+			Expect(err).ToNot(HaveOccurred())
+
+			buffer := make([]byte, 65536)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buffer)
+			Expect(err).ToNot(HaveOccurred())
+			return string(buffer[:n])
+		}
+
+		It("writes the metric as an SD-ELEMENT and in the message", func() {
+			Expect(write(syslogwriter.Format{})).To(MatchRegexp(
+				`^<14>1 \S+ loggregator appId \[APP/2\] - \[gauge@47450 name="cpu" value="0.5" unit="percentage"\] cpu 0.5 percentage\n$`,
+			))
+		})
+
+		It("writes the tags after the metric", func() {
+			format, err := syslogwriter.NewFormat(true, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(write(format)).To(HaveSuffix(
+				` [gauge@47450 name="cpu" value="0.5" unit="percentage"][tags@47450 index="0" source_instance="2"] cpu 0.5 percentage` + "\n",
+			))
+		})
+	})
+
+	Context("with an https drain", func() {
+		var (
+			server      *httptest.Server
+			serveMux    *http.ServeMux
+			requestChan chan []byte
+		)
+
+		BeforeEach(func() {
+			requestChan = make(chan []byte, 1)
+			serveMux = http.NewServeMux()
+			serveMux.HandleFunc("/drain/", syslogHandler(requestChan, http.StatusOK))
+			server = httptest.NewTLSServer(serveMux)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("POSTs the metric as JSON", func() {
+			outputURL, err := url.Parse(server.URL + "/drain/?drain-type=metrics&batch-size=1")
+			Expect(err).ToNot(HaveOccurred())
+			w, err := syslogwriter.NewWriter(outputURL, "appId", true, syslogwriter.Credentials{}, time.Second, 0, syslogwriter.Format{}, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = w.WriteMetric(metric, "APP", "2", timestamp, tags)
+			Expect(err).ToNot(HaveOccurred())
+
+			var body []byte
+			Eventually(requestChan).Should(Receive(&body))
+			Expect(body).To(MatchJSON(`{
+				"timestamp": 1136214245000000000,
+				"app_id": "appId",
+				"source_type": "APP",
+				"source_instance": "2",
+				"name": "cpu",
+				"value": 0.5,
+				"unit": "percentage",
+				"tags": {"index": "0", "source_instance": "2"}
+			}`))
+		})
+
+		It("POSTs metrics and logs in separate batches with their own Content-Type", func() {
+			type post struct {
+				contentType string
+				body        string
+			}
+			posts := make(chan post, 2)
+			serveMux.HandleFunc("/all/", func(rw http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				posts <- post{contentType: r.Header.Get("Content-Type"), body: string(body)}
+			})
+
+			outputURL, err := url.Parse(server.URL + "/all/?drain-type=all&batch-size=2&batch-interval=1h")
+			Expect(err).ToNot(HaveOccurred())
+			w, err := syslogwriter.NewWriter(outputURL, "appId", true, syslogwriter.Credentials{}, time.Second, 0, syslogwriter.Format{}, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 2; i++ {
+				_, err = w.Write(14, []byte("log"), "APP", "2", timestamp, nil)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = w.WriteMetric(metric, "APP", "2", timestamp, nil)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			var logs, metrics post
+			Eventually(posts).Should(Receive(&logs))
+			Eventually(posts).Should(Receive(&metrics))
+			Expect(logs.contentType).To(Equal("text/plain"))
+			Expect(logs.body).To(ContainSubstring("- - log\n"))
+			Expect(logs.body).ToNot(ContainSubstring("{"))
+			Expect(metrics.contentType).To(Equal("application/x-ndjson"))
+			Expect(metrics.body).To(HavePrefix(`{"timestamp"`))
+			Expect(metrics.body).ToNot(ContainSubstring("- - log"))
+		})
+	})
+})
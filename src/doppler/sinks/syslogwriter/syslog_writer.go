@@ -18,6 +18,7 @@ type syslogWriter struct {
 	appId  string
 	host   string
 	dialer *net.Dialer
+	format Format
 
 	mu           sync.Mutex // guards conn
 	conn         *net.TCPConn
@@ -64,8 +65,15 @@ func (w *syslogWriter) Connect() error {
 	return nil
 }
 
-func (w *syslogWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64) (byteCount int, err error) {
-	syslogMsg := createMessage(p, w.appId, source, sourceId, b, timestamp)
+func (w *syslogWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMessage(w.format, p, w.appId, source, sourceId, b, timestamp, tags))
+}
+
+func (w *syslogWriter) WriteMetric(m Metric, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMetricMessage(w.format, w.appId, source, sourceId, m, timestamp, tags))
+}
+
+func (w *syslogWriter) write(syslogMsg string) (byteCount int, err error) {
 	// Frame msg with Octet Counting: https://tools.ietf.org/html/rfc6587#section-3.4.1
 	finalMsg := []byte(fmt.Sprintf("%d %s", len(syslogMsg), syslogMsg))
 
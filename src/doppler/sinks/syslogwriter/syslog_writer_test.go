@@ -48,19 +48,19 @@ var _ = Describe("SyslogWriter", func() {
 
 	Context("Message Format", func() {
 		It("sends messages in the proper format", func() {
-			sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+			sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 
 			Eventually(syslogServerSession, 5).Should(gbytes.Say(`\d <\d+>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{1,6}([-+]\d{2}:\d{2}) loggregator appId \[APP/2\] - - just a test\n`))
 		}, 10)
 
 		It("sends messages in the proper format with source type APP/<AnyThing>", func() {
-			sysLogWriter.Write(standardOutPriority, []byte("just a test"), "APP/PROC/BLAH", "2", time.Now().UnixNano())
+			sysLogWriter.Write(standardOutPriority, []byte("just a test"), "APP/PROC/BLAH", "2", time.Now().UnixNano(), nil)
 
 			Eventually(syslogServerSession, 5).Should(gbytes.Say(`\d <\d+>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{1,6}([-+]\d{2}:\d{2}) loggregator appId \[APP/PROC/BLAH/2\] - - just a test\n`))
 		}, 10)
 
 		It("strips null termination char from message", func() {
-			sysLogWriter.Write(standardOutPriority, []byte(string(0)+" hi"), "appId", "", time.Now().UnixNano())
+			sysLogWriter.Write(standardOutPriority, []byte(string(0)+" hi"), "appId", "", time.Now().UnixNano(), nil)
 
 			Expect(syslogServerSession).ToNot(gbytes.Say("\000"))
 		})
@@ -71,14 +71,14 @@ var _ = Describe("SyslogWriter", func() {
 			syslogServerSession.Kill().Wait()
 
 			Eventually(func() error {
-				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				return err
 			}).Should(HaveOccurred())
 		})
 
 		It("returns an error if not connected", func() {
 			sysLogWriter.Close()
-			_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+			_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -107,14 +107,14 @@ var _ = Describe("SyslogWriter", func() {
 			syslogServerSession.Kill().Wait()
 
 			Eventually(func() error {
-				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				return err
 			}).Should(HaveOccurred())
 		})
 
 		It("returns an error if not connected", func() {
 			sysLogWriter.Close()
-			_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+			_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -159,7 +159,7 @@ var _ = Describe("SyslogWriter", func() {
 			})
 
 			It("returns an error after the write deadline expires", func() {
-				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				opErr := err.(*net.OpError)
 				Expect(opErr.Timeout()).To(BeTrue())
 			})
@@ -167,7 +167,7 @@ var _ = Describe("SyslogWriter", func() {
 
 		Context("when the server connection closes", func() {
 			It("gets detected by watch connection", func() {
-				written, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				written, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(written).NotTo(Equal(0))
 
@@ -178,14 +178,14 @@ var _ = Describe("SyslogWriter", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				Eventually(func() error {
-					_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+					_, err := sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 					return err
 				}).Should(MatchError("Connection to syslog sink lost"))
 
 				err = sysLogWriter.Connect()
 				Expect(err).NotTo(HaveOccurred())
 
-				written, err = sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				written, err = sysLogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(written).NotTo(Equal(0))
 			})
@@ -17,8 +17,9 @@ import (
 )
 
 type tlsWriter struct {
-	appId string
-	host  string
+	appId  string
+	host   string
+	format Format
 
 	mu        sync.Mutex // guards conn
 	conn      net.Conn
@@ -76,8 +77,15 @@ func (w *tlsWriter) Close() error {
 	return nil
 }
 
-func (w *tlsWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64) (byteCount int, err error) {
-	syslogMsg := createMessage(p, w.appId, source, sourceId, b, timestamp)
+func (w *tlsWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMessage(w.format, p, w.appId, source, sourceId, b, timestamp, tags))
+}
+
+func (w *tlsWriter) WriteMetric(m Metric, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMetricMessage(w.format, w.appId, source, sourceId, m, timestamp, tags))
+}
+
+func (w *tlsWriter) write(syslogMsg string) (byteCount int, err error) {
 	// Frame msg with Octet Counting: https://tools.ietf.org/html/rfc6587#section-3.4.1
 	finalMsg := []byte(fmt.Sprintf("%d %s", len(syslogMsg), syslogMsg))
 
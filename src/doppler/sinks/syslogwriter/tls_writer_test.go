@@ -95,7 +95,7 @@ var _ = Describe("TLSWriter", func() {
 				return err
 			}, 5, 1).ShouldNot(HaveOccurred())
 
-			_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "test", "", ts)
+			_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "test", "", ts, nil)
 			Expect(err).ToNot(HaveOccurred())
 
 			Eventually(syslogServerSession, 3).Should(gbytes.Say("just a test"))
@@ -113,7 +113,7 @@ var _ = Describe("TLSWriter", func() {
 					return err
 				}, 5, 1).ShouldNot(HaveOccurred())
 
-				_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "test", "", time.Now().UnixNano())
+				_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "test", "", time.Now().UnixNano(), nil)
 				Expect(err).To(HaveOccurred())
 				netErr := err.(*net.OpError)
 				Expect(netErr.Timeout()).To(BeTrue())
@@ -125,14 +125,14 @@ var _ = Describe("TLSWriter", func() {
 				syslogServerSession.Kill().Wait()
 
 				Eventually(func() error {
-					_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+					_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 					return err
 				}, 5).Should(HaveOccurred())
 			}, 10)
 
 			It("returns an error if not connected", func() {
 				syslogWriter.Close()
-				_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano())
+				_, err := syslogWriter.Write(standardOutPriority, []byte("just a test"), "App", "2", time.Now().UnixNano(), nil)
 				Expect(err).To(HaveOccurred())
 			}, 5)
 		})
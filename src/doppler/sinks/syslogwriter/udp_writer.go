@@ -0,0 +1,121 @@
+package syslogwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultUDPMTU is the largest syslog message sent in a single datagram
+// when no MTU is configured. RFC5426 requires receivers to accept messages
+// of at least 480 bytes and recommends that they accept 2048.
+const DefaultUDPMTU = 1024
+
+// udpWriter writes to a syslog-udp drain as described in
+// https://tools.ietf.org/html/rfc5426, one message per datagram. Messages
+// longer than the MTU are truncated.
+type udpWriter struct {
+	appId  string
+	host   string
+	dialer *net.Dialer
+	format Format
+	mtu    int
+
+	mu           sync.Mutex // guards conn
+	conn         net.Conn
+	writeTimeout time.Duration
+}
+
+func NewUDPWriter(outputUrl *url.URL, appId string, dialer *net.Dialer, writeTimeout time.Duration, mtu int) (w *udpWriter, err error) {
+	if dialer == nil {
+		return nil, errors.New("cannot construct a writer with a nil dialer")
+	}
+
+	if outputUrl.Scheme != "syslog-udp" {
+		return nil, errors.New(fmt.Sprintf("Invalid scheme %s, udpWriter only supports syslog-udp", outputUrl.Scheme))
+	}
+
+	if mtu <= 0 {
+		mtu = DefaultUDPMTU
+	}
+
+	return &udpWriter{
+		appId:        appId,
+		host:         outputUrl.Host,
+		dialer:       dialer,
+		mtu:          mtu,
+		writeTimeout: writeTimeout,
+	}, nil
+}
+
+func (w *udpWriter) Connect() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		// ignore err from close, it makes sense to continue anyway
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	c, err := w.dialer.Dial("udp", w.host)
+	if err != nil {
+		return err
+	}
+	w.conn = c
+
+	return nil
+}
+
+func (w *udpWriter) Write(p int, b []byte, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMessage(w.format, p, w.appId, source, sourceId, b, timestamp, tags))
+}
+
+func (w *udpWriter) WriteMetric(m Metric, source string, sourceId string, timestamp int64, tags map[string]string) (byteCount int, err error) {
+	return w.write(createMetricMessage(w.format, w.appId, source, sourceId, m, timestamp, tags))
+}
+
+func (w *udpWriter) write(msg string) (byteCount int, err error) {
+	syslogMsg := truncate([]byte(msg), w.mtu)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return 0, errors.New("Connection to syslog-udp sink lost")
+	}
+	if w.writeTimeout != 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	return w.conn.Write(syslogMsg)
+}
+
+func (w *udpWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		err := w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// truncate shortens msg to at most max bytes without splitting a UTF-8
+// encoded character.
+func truncate(msg []byte, max int) []byte {
+	if len(msg) <= max {
+		return msg
+	}
+
+	end := max
+	for end > 0 && !utf8.RuneStart(msg[end]) {
+		end--
+	}
+	return msg[:end]
+}
@@ -0,0 +1,97 @@
+package syslogwriter_test
+
+import (
+	"doppler/sinks/syslogwriter"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UDPWriter", func() {
+	var (
+		conn      net.PacketConn
+		outputURL *url.URL
+		dialer    *net.Dialer
+	)
+
+	BeforeEach(func() {
+		var err error
+		conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		outputURL = &url.URL{Scheme: "syslog-udp", Host: conn.LocalAddr().String()}
+		dialer = &net.Dialer{Timeout: 500 * time.Millisecond}
+	})
+
+	AfterEach(func() {
+		conn.Close()
+	})
+
+	readDatagram := func() string {
+		buffer := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buffer)
+		Expect(err).ToNot(HaveOccurred())
+		return string(buffer[:n])
+	}
+
+	It("sends each message in its own datagram without framing", func() {
+		w, err := syslogwriter.NewUDPWriter(outputURL, "appId", dialer, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Connect()).To(Succeed())
+		defer w.Close()
+
+		_, err = w.Write(standardOutPriority, []byte("first"), "App", "2", time.Now().UnixNano(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write(standardOutPriority, []byte("second"), "App", "2", time.Now().UnixNano(), nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readDatagram()).To(MatchRegexp(`^<14>1 \S+ loggregator appId \[APP/2\] - - first\n$`))
+		Expect(readDatagram()).To(MatchRegexp(`^<14>1 \S+ loggregator appId \[APP/2\] - - second\n$`))
+	})
+
+	It("truncates messages longer than the MTU", func() {
+		w, err := syslogwriter.NewUDPWriter(outputURL, "appId", dialer, 0, 100)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Connect()).To(Succeed())
+		defer w.Close()
+
+		_, err = w.Write(standardOutPriority, []byte(strings.Repeat("a", 200)), "App", "2", time.Now().UnixNano(), nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readDatagram()).To(HaveLen(100))
+	})
+
+	It("does not split a character when truncating", func() {
+		w, err := syslogwriter.NewUDPWriter(outputURL, "appId", dialer, 0, 100)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Connect()).To(Succeed())
+		defer w.Close()
+
+		_, err = w.Write(standardOutPriority, []byte(strings.Repeat("é", 100)), "App", "2", time.Now().UnixNano(), nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		msg := readDatagram()
+		Expect(len(msg)).To(BeNumerically("<=", 100))
+		Expect(utf8.ValidString(msg)).To(BeTrue())
+	})
+
+	It("returns an error when it is not connected", func() {
+		w, err := syslogwriter.NewUDPWriter(outputURL, "appId", dialer, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = w.Write(standardOutPriority, []byte("message"), "App", "2", time.Now().UnixNano(), nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects other schemes", func() {
+		outputURL.Scheme = "syslog"
+		_, err := syslogwriter.NewUDPWriter(outputURL, "appId", dialer, 0, 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -20,21 +20,60 @@ var newLine = []byte("\n")
 
 type Writer interface {
 	Connect() error
-	Write(p int, b []byte, source, sourceId string, timestamp int64) (int, error)
+	Write(p int, b []byte, source, sourceId string, timestamp int64, tags map[string]string) (int, error)
+	WriteMetric(m Metric, source, sourceId string, timestamp int64, tags map[string]string) (int, error)
 	Close() error
 }
 
-func NewWriter(outputUrl *url.URL, appId string, skipCertVerify bool, dialTimeout time.Duration, ioTimeout time.Duration) (Writer, error) {
+// NewWriter returns the Writer for the drain URL's scheme, writing messages
+// in the given format. The syslog-tls and https writers connect with the
+// given credentials; the other schemes do not accept any. Messages to
+// syslog-udp drains are truncated to udpMTU bytes.
+func NewWriter(outputUrl *url.URL, appId string, skipCertVerify bool, creds Credentials, dialTimeout time.Duration, ioTimeout time.Duration, format Format, udpMTU int) (Writer, error) {
 	dialer := &net.Dialer{Timeout: dialTimeout}
 	switch outputUrl.Scheme {
 	case "https":
-		return NewHttpsWriter(outputUrl, appId, skipCertVerify, dialer, ioTimeout)
+		w, err := NewHttpsWriter(outputUrl, appId, skipCertVerify, dialer, ioTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if err := creds.Apply(w.TlsConfig); err != nil {
+			return nil, err
+		}
+		w.format = format
+		return w, nil
 	case "syslog":
-		return NewSyslogWriter(outputUrl, appId, dialer, ioTimeout)
+		if !creds.Empty() {
+			return nil, errors.New("TLS credentials are only supported for syslog-tls and https drains")
+		}
+		w, err := NewSyslogWriter(outputUrl, appId, dialer, ioTimeout)
+		if err != nil {
+			return nil, err
+		}
+		w.format = format
+		return w, nil
 	case "syslog-tls":
-		return NewTlsWriter(outputUrl, appId, skipCertVerify, dialer, ioTimeout)
+		w, err := NewTlsWriter(outputUrl, appId, skipCertVerify, dialer, ioTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if err := creds.Apply(w.TlsConfig); err != nil {
+			return nil, err
+		}
+		w.format = format
+		return w, nil
+	case "syslog-udp":
+		if !creds.Empty() {
+			return nil, errors.New("TLS credentials are only supported for syslog-tls and https drains")
+		}
+		w, err := NewUDPWriter(outputUrl, appId, dialer, ioTimeout, udpMTU)
+		if err != nil {
+			return nil, err
+		}
+		w.format = format
+		return w, nil
 	default:
-		return nil, errors.New(fmt.Sprintf("Invalid scheme type %s, must be https, syslog-tls or syslog", outputUrl.Scheme))
+		return nil, errors.New(fmt.Sprintf("Invalid scheme type %s, must be https, syslog-tls, syslog or syslog-udp", outputUrl.Scheme))
 	}
 }
 
@@ -42,7 +81,13 @@ func clean(in []byte) []byte {
 	return bytes.Replace(in, badBytes, emptyBytes, -1)
 }
 
-func createMessage(p int, appId string, source string, sourceId string, msg []byte, timestamp int64) string {
+func createMessage(format Format, p int, appId string, source string, sourceId string, msg []byte, timestamp int64, tags map[string]string) string {
+	return formatMessage(format, p, appId, source, sourceId, "", msg, timestamp, tags)
+}
+
+// formatMessage returns the syslog message with the given SD-ELEMENT
+// preceding the one for the tags. An empty structuredData adds no element.
+func formatMessage(format Format, p int, appId string, source string, sourceId string, structuredData string, msg []byte, timestamp int64, tags map[string]string) string {
 	// ensure it ends in a \n
 	nl := ""
 	if !bytes.HasSuffix(msg, newLine) {
@@ -61,6 +106,14 @@ func createMessage(p int, appId string, source string, sourceId string, msg []by
 		formattedSource = fmt.Sprintf("[%s]", source)
 	}
 
+	sd := format.structuredDataFor(tags)
+	if structuredData != "" {
+		if sd == "-" {
+			sd = ""
+		}
+		sd = structuredData + sd
+	}
+
 	// syslog format https://tools.ietf.org/html/rfc5424#section-6
-	return fmt.Sprintf("<%d>1 %s %s %s %s - - %s%s", p, timeString, "loggregator", appId, formattedSource, msg, nl)
+	return fmt.Sprintf("<%d>1 %s %s %s %s - %s %s%s", p, timeString, format.hostnameFor(appId, tags), appId, formattedSource, sd, msg, nl)
 }
@@ -15,7 +15,7 @@ var _ = Describe("Writer", func() {
 
 	It("returns an syslogWriter for syslog scheme", func() {
 		outputUrl, _ := url.Parse("syslog://localhost:9999")
-		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, 1*time.Second, 0)
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, syslogwriter.Credentials{}, 1*time.Second, 0, syslogwriter.Format{}, 0)
 		Expect(err).ToNot(HaveOccurred())
 		writerType := reflect.TypeOf(w).String()
 		Expect(writerType).To(Equal("*syslogwriter.syslogWriter"))
@@ -23,7 +23,7 @@ var _ = Describe("Writer", func() {
 
 	It("returns an tlsWriter for syslog-tls scheme", func() {
 		outputUrl, _ := url.Parse("syslog-tls://localhost:9999")
-		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, 1*time.Second, 0)
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, syslogwriter.Credentials{}, 1*time.Second, 0, syslogwriter.Format{}, 0)
 		Expect(err).ToNot(HaveOccurred())
 		writerType := reflect.TypeOf(w).String()
 		Expect(writerType).To(Equal("*syslogwriter.tlsWriter"))
@@ -31,15 +31,60 @@ var _ = Describe("Writer", func() {
 
 	It("returns an httpsWriter for https scheme", func() {
 		outputUrl, _ := url.Parse("https://localhost:9999")
-		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, 1*time.Second, 0)
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, syslogwriter.Credentials{}, 1*time.Second, 0, syslogwriter.Format{}, 0)
 		Expect(err).ToNot(HaveOccurred())
 		writerType := reflect.TypeOf(w).String()
 		Expect(writerType).To(Equal("*syslogwriter.httpsWriter"))
 	})
 
+	It("returns an udpWriter for syslog-udp scheme", func() {
+		outputUrl, _ := url.Parse("syslog-udp://localhost:9999")
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, syslogwriter.Credentials{}, 1*time.Second, 0, syslogwriter.Format{}, 0)
+		Expect(err).ToNot(HaveOccurred())
+		writerType := reflect.TypeOf(w).String()
+		Expect(writerType).To(Equal("*syslogwriter.udpWriter"))
+	})
+
+	Context("with TLS credentials", func() {
+		var creds syslogwriter.Credentials
+
+		BeforeEach(func() {
+			creds = syslogwriter.Credentials{
+				Cert: readFixture("fixtures/client.crt"),
+				Key:  readFixture("fixtures/client.key"),
+				CA:   readFixture("fixtures/loggregator-ca.crt"),
+			}
+		})
+
+		It("accepts them for syslog-tls and https drains", func() {
+			for _, drainURL := range []string{"syslog-tls://localhost:9999", "https://localhost:9999"} {
+				outputUrl, _ := url.Parse(drainURL)
+				_, err := syslogwriter.NewWriter(outputUrl, "appId", false, creds, 1*time.Second, 0, syslogwriter.Format{}, 0)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("returns an error for other schemes", func() {
+			for _, drainURL := range []string{"syslog://localhost:9999", "syslog-udp://localhost:9999"} {
+				outputUrl, _ := url.Parse(drainURL)
+				w, err := syslogwriter.NewWriter(outputUrl, "appId", false, creds, 1*time.Second, 0, syslogwriter.Format{}, 0)
+				Expect(err).To(MatchError(ContainSubstring("only supported for syslog-tls and https drains")))
+				Expect(w).To(BeNil())
+			}
+		})
+
+		It("returns an error for an invalid client certificate", func() {
+			creds.Cert = "not a certificate"
+			outputUrl, _ := url.Parse("syslog-tls://localhost:9999")
+			w, err := syslogwriter.NewWriter(outputUrl, "appId", false, creds, 1*time.Second, 0, syslogwriter.Format{}, 0)
+			Expect(err).To(MatchError(ContainSubstring("Invalid client certificate")))
+			Expect(w).To(BeNil())
+		})
+	})
+
 	It("returns an error for invalid scheme", func() {
 		outputUrl, _ := url.Parse("notValid://localhost:9999")
-		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, 1*time.Second, 0)
+		w, err := syslogwriter.NewWriter(outputUrl, "appId", false, syslogwriter.Credentials{}, 1*time.Second, 0, syslogwriter.Format{}, 0)
 		Expect(err).To(HaveOccurred())
 		Expect(w).To(BeNil())
 	})
@@ -3,13 +3,13 @@ package websocket
 import (
 	"doppler/sinks"
 	"log"
+	"marshalled"
 	"net"
 	"time"
 
 	"truncatingbuffer"
 
 	"github.com/cloudfoundry/sonde-go/events"
-	"github.com/gogo/protobuf/proto"
 	gorilla "github.com/gorilla/websocket"
 )
 
@@ -35,6 +35,8 @@ type WebsocketSink struct {
 	writeTimeout           time.Duration
 	dropsondeOrigin        string
 	counter                Counter
+	appAffinity            bool
+	memberID               string
 }
 
 func NewWebsocketSink(appID string, ws remoteMessageWriter, messageDrainBufferSize uint, writeTimeout time.Duration, dropsondeOrigin string) *WebsocketSink {
@@ -53,6 +55,27 @@ func (sink *WebsocketSink) SetCounter(counter Counter) {
 	sink.counter = counter
 }
 
+// SetAppAffinity sets whether a firehose sink asks for every message for an
+// app to be sent to the same sink in its subscription.
+func (sink *WebsocketSink) SetAppAffinity(appAffinity bool) {
+	sink.appAffinity = appAffinity
+}
+
+func (sink *WebsocketSink) AppAffinity() bool {
+	return sink.appAffinity
+}
+
+// SetMemberID sets the ID the subscriber gives for itself, which app
+// affinity sharding uses instead of the remote address so that every
+// Doppler assigns an app to the same subscriber.
+func (sink *WebsocketSink) SetMemberID(memberID string) {
+	sink.memberID = memberID
+}
+
+func (sink *WebsocketSink) MemberID() string {
+	return sink.memberID
+}
+
 func (sink *WebsocketSink) Identifier() string {
 	return sink.ws.RemoteAddr().String()
 }
@@ -65,7 +88,7 @@ func (sink *WebsocketSink) ShouldReceiveErrors() bool {
 	return true
 }
 
-func (sink *WebsocketSink) Run(inputChan <-chan *events.Envelope) {
+func (sink *WebsocketSink) Run(inputChan <-chan *marshalled.Envelope) {
 	stopChan := make(chan struct{})
 	log.Printf("Websocket Sink %s: Running for streamId [%s]", sink.clientAddress, sink.appID)
 	context := truncatingbuffer.NewDefaultContext(sink.dropsondeOrigin, sink.Identifier())
@@ -79,7 +102,7 @@ func (sink *WebsocketSink) Run(inputChan <-chan *events.Envelope) {
 			return
 		}
 
-		messageBytes, err := proto.Marshal(messageEnvelope)
+		messageBytes, err := messageEnvelope.Marshal()
 		if err != nil {
 			log.Printf("Websocket Sink %s: Error marshalling %s envelope from origin %s: %s", sink.clientAddress, messageEnvelope.GetEventType(), messageEnvelope.GetOrigin(), err.Error())
 			continue
@@ -3,6 +3,7 @@ package websocket_test
 import (
 	"doppler/sinks/websocket"
 	"fmt"
+	"marshalled"
 	"time"
 
 	"github.com/cloudfoundry/dropsonde/emitter"
@@ -46,10 +47,10 @@ var _ = Describe("WebsocketSink", func() {
 	})
 
 	Describe("Run", func() {
-		var inputChan chan *events.Envelope
+		var inputChan chan *marshalled.Envelope
 
 		BeforeEach(func() {
-			inputChan = make(chan *events.Envelope, 10)
+			inputChan = make(chan *marshalled.Envelope, 10)
 		})
 
 		It("forwards messages", func(done Done) {
@@ -59,13 +60,13 @@ var _ = Describe("WebsocketSink", func() {
 			message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hello world", "appId", "App"), "origin")
 			messageBytes, _ := proto.Marshal(message)
 
-			inputChan <- message
+			inputChan <- marshalled.New(message)
 			Eventually(fakeWebsocket.ReadMessages).Should(HaveLen(1))
 			Expect(fakeWebsocket.ReadMessages()[0]).To(Equal(messageBytes))
 
 			messageTwo, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "goodbye world", "appId", "App"), "origin")
 			messageTwoBytes, _ := proto.Marshal(messageTwo)
-			inputChan <- messageTwo
+			inputChan <- marshalled.New(messageTwo)
 			Eventually(fakeWebsocket.ReadMessages).Should(HaveLen(2))
 			Expect(fakeWebsocket.ReadMessages()[1]).To(Equal(messageTwoBytes))
 		})
@@ -73,7 +74,7 @@ var _ = Describe("WebsocketSink", func() {
 		It("sets write deadline", func() {
 			go websocketSink.Run(inputChan)
 			message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hello world", "appId", "App"), "origin")
-			inputChan <- message
+			inputChan <- marshalled.New(message)
 			Eventually(fakeWebsocket.WriteDeadline).Should(BeTemporally("~", time.Now().Add(writeTimeout), time.Millisecond*50))
 		})
 
@@ -95,7 +96,7 @@ var _ = Describe("WebsocketSink", func() {
 				It("increments", func() {
 					go websocketSink.Run(inputChan)
 					message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hello world", "appId", "App"), "origin")
-					inputChan <- message
+					inputChan <- marshalled.New(message)
 					Eventually(counter.incrementCalls).Should(Receive(Equal(events.Envelope_LogMessage)))
 				})
 			})
@@ -108,7 +109,7 @@ var _ = Describe("WebsocketSink", func() {
 				It("does not increment", func() {
 					go websocketSink.Run(inputChan)
 					message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "hello world", "appId", "App"), "origin")
-					inputChan <- message
+					inputChan <- marshalled.New(message)
 					Consistently(counter.incrementCalls).ShouldNot(Receive())
 				})
 			})
@@ -42,6 +42,12 @@ var _ = Describe("UrlBlacklistManager", func() {
 			Expect(err.Error()).To(Equal("Syslog Drain URL is blacklisted"))
 		})
 
+		It("returns blacklist error if a syslog-udp URL is blacklisted", func() {
+			_, err := urlBlacklistManager.CheckUrl("syslog-udp://14.15.16.18:514")
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(Equal("Syslog Drain URL is blacklisted"))
+		})
+
 		It("returns incomplete URL error if the URL is invalid", func() {
 			_, err := urlBlacklistManager.CheckUrl("http://")
 
@@ -1,43 +1,161 @@
 package sinkserver
 
 import (
-	"diodes"
+	"doppler/ratelimit"
+	"hash/fnv"
 	"log"
+	"marshalled"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/cloudfoundry/dropsonde/envelope_extensions"
 	"github.com/cloudfoundry/dropsonde/metrics"
-	"github.com/cloudfoundry/sonde-go/events"
 )
 
+const (
+	shardBufferSize = 1000
+	metricsInterval = time.Second
+)
+
+// MessageRouter routes envelopes to the sink managers. Routing is split
+// across workers sharded by app ID so that envelopes for one app stay in
+// order while different apps are routed concurrently. A full shard blocks
+// the router rather than shedding, leaving the reader's buffer to decide
+// which envelopes are shed.
 type MessageRouter struct {
 	sinkManagers []sinkManager
-	done         chan struct{}
-	stopOnce     sync.Once
+	limiter      *ratelimit.Limiter
+	workers      int
 }
 
 type sinkManager interface {
-	SendTo(string, *events.Envelope)
+	SendTo(string, *marshalled.Envelope)
+}
+
+type envelopeReader interface {
+	Next() *marshalled.Envelope
 }
 
-func NewMessageRouter(sinkManagers ...sinkManager) *MessageRouter {
+// NewMessageRouter creates a MessageRouter with the given number of workers.
+// Each app's envelopes are dropped once they exceed the limiter's rate, which
+// may be nil to route every envelope. The limiter's pending notices are
+// flushed every second.
+func NewMessageRouter(workers int, limiter *ratelimit.Limiter, sinkManagers ...sinkManager) *MessageRouter {
+	if workers < 1 {
+		workers = 1
+	}
+
 	return &MessageRouter{
 		sinkManagers: sinkManagers,
-		done:         make(chan struct{}),
+		limiter:      limiter,
+		workers:      workers,
 	}
 }
 
-func (r *MessageRouter) Start(incomingLog *diodes.ManyToOneEnvelope) {
+// Start routes envelopes from the reader until it returns nil. It returns
+// once every worker has routed the envelopes already sharded to it.
+func (r *MessageRouter) Start(incomingLog envelopeReader) {
 	log.Print("MessageRouter:Starting")
+
+	shards := make([]chan *marshalled.Envelope, r.workers)
+
+	var wg sync.WaitGroup
+	wg.Add(len(shards))
+	for i := range shards {
+		shards[i] = make(chan *marshalled.Envelope, shardBufferSize)
+		go func(shard chan *marshalled.Envelope) {
+			defer wg.Done()
+			r.route(shard)
+		}(shards[i])
+	}
+
+	done := make(chan struct{})
+	ticked := make(chan struct{})
+	go func() {
+		defer close(ticked)
+		r.tick(shards, done)
+	}()
+
 	for {
 		envelope := incomingLog.Next()
+		if envelope == nil {
+			break
+		}
+
 		metrics.BatchIncrementCounter("httpServer.receivedMessages")
+
+		appId := envelope_extensions.GetAppId(envelope.Envelope)
+		shard := shards[shardFor(appId, len(shards))]
+
+		if r.limiter != nil {
+			allowed, notice := r.limiter.Allow(appId)
+			if notice != nil {
+				shard <- marshalled.New(notice)
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		shard <- envelope
+	}
+
+	close(done)
+	<-ticked
+	for _, shard := range shards {
+		close(shard)
+	}
+	wg.Wait()
+	log.Print("MessageRouter:Stopped")
+}
+
+func (r *MessageRouter) route(shard chan *marshalled.Envelope) {
+	for envelope := range shard {
 		r.send(envelope)
 	}
 }
 
-func (r *MessageRouter) send(envelope *events.Envelope) {
-	appId := envelope_extensions.GetAppId(envelope)
+// tick emits the depth of each shard and routes the rate limit notices that
+// have become due.
+func (r *MessageRouter) tick(shards []chan *marshalled.Envelope, done chan struct{}) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for i, shard := range shards {
+				err := metrics.Value("messageRouter.queueDepth", float64(len(shard)), "envelopes").
+					SetTag("shard", strconv.Itoa(i)).
+					Send()
+				if err != nil {
+					log.Printf("MessageRouter: failed to emit queue depth: %s", err)
+				}
+			}
+
+			if r.limiter == nil {
+				continue
+			}
+			for _, notice := range r.limiter.Flush() {
+				appId := envelope_extensions.GetAppId(notice)
+				shards[shardFor(appId, len(shards))] <- marshalled.New(notice)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// shardFor returns the index of the worker that routes the app's envelopes.
+func shardFor(appId string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(appId))
+	return int(h.Sum32() % uint32(shards))
+}
+
+func (r *MessageRouter) send(envelope *marshalled.Envelope) {
+	appId := envelope_extensions.GetAppId(envelope.Envelope)
 
 	for _, sm := range r.sinkManagers {
 		sm.SendTo(appId, envelope)
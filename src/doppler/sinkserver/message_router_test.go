@@ -1,12 +1,23 @@
 package sinkserver_test
 
 import (
-	"diodes"
+	"context"
+	"doppler/lanes"
+	"doppler/ratelimit"
 	"doppler/sinkserver"
+	"fmt"
+	"marshalled"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/cloudfoundry/dropsonde/emitter"
+	fakeemitter "github.com/cloudfoundry/dropsonde/emitter/fake"
 	"github.com/cloudfoundry/dropsonde/factories"
+	"github.com/cloudfoundry/dropsonde/metric_sender"
+	"github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metricbatcher"
+	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/sonde-go/events"
 
 	. "github.com/onsi/ginkgo"
@@ -19,10 +30,10 @@ type fakeSinkManager struct {
 	receivedDrains   [][]string
 }
 
-func (f *fakeSinkManager) SendTo(appId string, receivedMessage *events.Envelope) {
+func (f *fakeSinkManager) SendTo(appId string, receivedMessage *marshalled.Envelope) {
 	f.Lock()
 	defer f.Unlock()
-	f.receivedMessages = append(f.receivedMessages, receivedMessage)
+	f.receivedMessages = append(f.receivedMessages, receivedMessage.Envelope)
 }
 
 func (f *fakeSinkManager) ManageSyslogSinks(appId string, syslogSinkUrls []string) {
@@ -43,6 +54,41 @@ func (f *fakeSinkManager) drains() [][]string {
 	return f.receivedDrains
 }
 
+type blockingSinkManager struct {
+	fakeSinkManager
+	blockedAppId string
+	unblock      chan struct{}
+}
+
+func (f *blockingSinkManager) SendTo(appId string, receivedMessage *marshalled.Envelope) {
+	if appId == f.blockedAppId {
+		<-f.unblock
+	}
+	f.fakeSinkManager.SendTo(appId, receivedMessage)
+}
+
+type fakeLatency struct {
+	sync.Mutex
+	recorded []time.Duration
+}
+
+func (f *fakeLatency) Record(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	f.recorded = append(f.recorded, d)
+}
+
+// newBuffer creates the envelope buffer the router reads from in Doppler.
+func newBuffer(ctx context.Context) *lanes.Buffer {
+	return lanes.New(
+		ctx,
+		1000,
+		lanes.Weights{Logs: 1, ContainerMetrics: 1, Metrics: 1},
+		metricbatcher.New(fake.NewFakeMetricSender(), time.Millisecond),
+		&fakeLatency{},
+	)
+}
+
 var _ = Describe("Message Router", func() {
 
 	var (
@@ -62,15 +108,37 @@ var _ = Describe("Message Router", func() {
 			receivedDrains:   make([][]string, 0),
 		}
 
-		messageRouter = sinkserver.NewMessageRouter(fakeManagerA, fakeManagerB)
+		messageRouter = sinkserver.NewMessageRouter(4, nil, fakeManagerA, fakeManagerB)
 	})
 
 	Describe("Start", func() {
 		Context("with an incoming message", func() {
-			var incoming *diodes.ManyToOneEnvelope
+			var (
+				incoming *lanes.Buffer
+				cancel   func()
+				stopped  chan struct{}
+			)
+
 			BeforeEach(func() {
-				incoming = diodes.NewManyToOneEnvelope(5, nil)
-				go messageRouter.Start(incoming)
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				incoming = newBuffer(ctx)
+
+				stopped = make(chan struct{})
+				go func() {
+					defer close(stopped)
+					messageRouter.Start(incoming)
+				}()
+			})
+
+			AfterEach(func() {
+				cancel()
+			})
+
+			It("stops once the incoming buffer's context is done", func() {
+				cancel()
+
+				Eventually(stopped).Should(BeClosed())
 			})
 
 			It("sends the message to each sender if it is an app message", func() {
@@ -81,6 +149,147 @@ var _ = Describe("Message Router", func() {
 				Expect(fakeManagerA.received()[0].GetLogMessage()).To(Equal(message.GetLogMessage()))
 				Expect(fakeManagerB.received()[0].GetLogMessage()).To(Equal(message.GetLogMessage()))
 			})
+
+			It("does not add tags to envelopes without them", func() {
+				message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "testMessage", "app", "App"), "origin")
+				incoming.Set(message)
+
+				Eventually(fakeManagerA.received).Should(HaveLen(1))
+				Expect(fakeManagerA.received()[0].GetTags()).To(BeNil())
+			})
+
+			It("keeps the envelopes for an app in order", func() {
+				for i := 0; i < 100; i++ {
+					message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, fmt.Sprint(i), "app-a", "App"), "origin")
+					incoming.Set(message)
+					message, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, fmt.Sprint(i), "app-b", "App"), "origin")
+					incoming.Set(message)
+				}
+
+				Eventually(fakeManagerA.received).Should(HaveLen(200))
+				var next = map[string]int{}
+				for _, e := range fakeManagerA.received() {
+					appId := e.GetLogMessage().GetAppId()
+					Expect(string(e.GetLogMessage().GetMessage())).To(Equal(fmt.Sprint(next[appId])))
+					next[appId]++
+				}
+			})
+		})
+
+		Context("with an app whose sink manager is blocked", func() {
+			var (
+				blocked  *blockingSinkManager
+				incoming *lanes.Buffer
+				cancel   func()
+			)
+
+			BeforeEach(func() {
+				blocked = &blockingSinkManager{
+					blockedAppId: "app-a",
+					unblock:      make(chan struct{}),
+				}
+				messageRouter = sinkserver.NewMessageRouter(4, nil, blocked)
+
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				incoming = newBuffer(ctx)
+				go messageRouter.Start(incoming)
+			})
+
+			AfterEach(func() {
+				close(blocked.unblock)
+				cancel()
+			})
+
+			It("routes the envelopes of other apps", func() {
+				message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "blocked", "app-a", "App"), "origin")
+				incoming.Set(message)
+				message, _ = emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "routed", "app-b", "App"), "origin")
+				incoming.Set(message)
+
+				Eventually(blocked.received).Should(HaveLen(1))
+				Expect(blocked.received()[0].GetLogMessage().GetAppId()).To(Equal("app-b"))
+			})
+		})
+
+		Context("with a rate limiter", func() {
+			var cancel func()
+
+			BeforeEach(func() {
+				sender := fake.NewFakeMetricSender()
+				limiter := ratelimit.New(
+					ratelimit.Limit{MessagesPerSecond: 1, Burst: 1},
+					nil,
+					time.Minute,
+					"doppler",
+					metricbatcher.New(sender, time.Millisecond),
+				)
+				messageRouter = sinkserver.NewMessageRouter(4, limiter, fakeManagerA)
+
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				incoming := newBuffer(ctx)
+				go messageRouter.Start(incoming)
+
+				for i := 0; i < 3; i++ {
+					message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, fmt.Sprint(i), "app-a", "App"), "origin")
+					incoming.Set(message)
+				}
+			})
+
+			AfterEach(func() {
+				cancel()
+			})
+
+			It("drops the app's envelopes over the limit and sends it a notice", func() {
+				Eventually(fakeManagerA.received).Should(HaveLen(2))
+				Consistently(fakeManagerA.received).Should(HaveLen(2))
+
+				received := fakeManagerA.received()
+				Expect(string(received[0].GetLogMessage().GetMessage())).To(Equal("0"))
+				Expect(received[1].GetLogMessage().GetAppId()).To(Equal("app-a"))
+				Expect(received[1].GetLogMessage().GetSourceType()).To(Equal("LGR"))
+				Expect(string(received[1].GetLogMessage().GetMessage())).To(Equal("log rate exceeded, 1 messages dropped"))
+			})
+		})
+
+		Context("with metrics", func() {
+			var (
+				eventEmitter *fakeemitter.FakeEventEmitter
+				cancel       func()
+			)
+
+			BeforeEach(func() {
+				eventEmitter = fakeemitter.NewFakeEventEmitter("doppler")
+				sender := metric_sender.NewMetricSender(eventEmitter)
+				metrics.Initialize(sender, metricbatcher.New(sender, time.Millisecond))
+
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				incoming := newBuffer(ctx)
+				go messageRouter.Start(incoming)
+			})
+
+			AfterEach(func() {
+				cancel()
+			})
+
+			It("emits the queue depth of each shard tagged with the shard", func() {
+				shards := func() []string {
+					var tags []string
+					for _, e := range eventEmitter.GetEnvelopes() {
+						metric := e.GetValueMetric()
+						if metric.GetName() == "messageRouter.queueDepth" && metric.GetUnit() == "envelopes" {
+							tags = append(tags, e.GetTags()["shard"])
+						}
+					}
+					return tags
+				}
+
+				for i := 0; i < 4; i++ {
+					Eventually(shards, 3).Should(ContainElement(strconv.Itoa(i)))
+				}
+			})
 		})
 	})
 })
@@ -5,6 +5,8 @@ import (
 	"doppler/sinks/dump"
 	"doppler/sinks/syslog"
 	"doppler/sinks/websocket"
+	"log"
+	"sync"
 	"time"
 
 	"doppler/sinks/containermetric"
@@ -13,6 +15,9 @@ import (
 	"github.com/cloudfoundry/dropsonde/metrics"
 )
 
+// DrainMetricsInterval is how often the metrics for each drain are emitted.
+const DrainMetricsInterval = 10 * time.Second
+
 type SinkManagerMetrics struct {
 	dumpSinks        int32
 	websocketSinks   int32
@@ -20,14 +25,25 @@ type SinkManagerMetrics struct {
 	firehoseSinks    int32
 	containerMetrics int32
 	done             chan struct{}
+
+	drainsLock sync.Mutex
+	// drains holds the status last emitted for each drain, from which the
+	// counters' deltas are taken.
+	drains map[*syslog.SyslogSink]syslog.Status
 }
 
 func NewSinkManagerMetrics() *SinkManagerMetrics {
+	return NewSinkManagerMetricsWithDrainInterval(DrainMetricsInterval)
+}
+
+func NewSinkManagerMetricsWithDrainInterval(drainInterval time.Duration) *SinkManagerMetrics {
 	mgr := &SinkManagerMetrics{
-		done: make(chan struct{}),
+		done:   make(chan struct{}),
+		drains: make(map[*syslog.SyslogSink]syslog.Status),
 	}
 	ticker := time.NewTicker(time.Second)
 	go mgr.run(ticker)
+	go mgr.runDrains(time.NewTicker(drainInterval))
 	return mgr
 }
 
@@ -47,16 +63,99 @@ func (s *SinkManagerMetrics) run(ticker *time.Ticker) {
 	}
 }
 
+func (s *SinkManagerMetrics) runDrains(ticker *time.Ticker) {
+	for range ticker.C {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		s.emitDrainMetrics()
+	}
+}
+
+// emitDrainMetrics emits each drain's counters, its state and the seconds
+// since it last sent a message, tagged with its app ID and drain ID.
+func (s *SinkManagerMetrics) emitDrainMetrics() {
+	s.drainsLock.Lock()
+	defer s.drainsLock.Unlock()
+
+	for sink, last := range s.drains {
+		status := sink.Status()
+		tags := drainTags(sink)
+		addDrainCounters(status, last, tags)
+
+		sendDrainValue("syslogDrain.connected", boolValue(status.State == syslog.Connected), "state", tags)
+		sendDrainValue("syslogDrain.backingOff", boolValue(status.State == syslog.BackingOff), "state", tags)
+		if !status.LastSuccess.IsZero() {
+			sendDrainValue("syslogDrain.secondsSinceLastSuccess", time.Since(status.LastSuccess).Seconds(), "s", tags)
+		}
+
+		s.drains[sink] = status
+	}
+}
+
+func drainTags(sink *syslog.SyslogSink) map[string]string {
+	return map[string]string{
+		"app_id":   sink.AppID(),
+		"drain_id": sink.DrainID(),
+	}
+}
+
+// addDrainCounters emits the growth of the drain's counters since the last
+// status emitted.
+func addDrainCounters(status, last syslog.Status, tags map[string]string) {
+	addDrainCounter("syslogDrain.sentMessages", status.SentMessages, last.SentMessages, tags)
+	addDrainCounter("syslogDrain.droppedMessages", status.DroppedMessages, last.DroppedMessages, tags)
+	addDrainCounter("syslogDrain.errors", status.Errors, last.Errors, tags)
+	addDrainCounter("syslogDrain.reconnects", status.Reconnects, last.Reconnects, tags)
+}
+
+func addDrainCounter(name string, total, last uint64, tags map[string]string) {
+	if total <= last {
+		return
+	}
+
+	counter := metrics.Counter(name)
+	for k, v := range tags {
+		counter = counter.SetTag(k, v)
+	}
+	if err := counter.Add(total - last); err != nil {
+		log.Printf("failed to emit %s: %s", name, err)
+	}
+}
+
+func sendDrainValue(name string, value float64, unit string, tags map[string]string) {
+	metric := metrics.Value(name, value, unit)
+	for k, v := range tags {
+		metric = metric.SetTag(k, v)
+	}
+	if err := metric.Send(); err != nil {
+		log.Printf("failed to emit %s: %s", name, err)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (s *SinkManagerMetrics) UpdateDroppedMessageCount(delta int64) {
 	metrics.BatchAddCounter("messageRouter.totalDroppedMessages", uint64(delta))
 }
 
 func (s *SinkManagerMetrics) Inc(sink sinks.Sink) {
-	switch sink.(type) {
+	switch sink := sink.(type) {
 	case *dump.DumpSink:
 		atomic.AddInt32(&s.dumpSinks, 1)
 	case *syslog.SyslogSink:
 		atomic.AddInt32(&s.syslogSinks, 1)
+		s.drainsLock.Lock()
+		s.drains[sink] = syslog.Status{}
+		s.drainsLock.Unlock()
 	case *websocket.WebsocketSink:
 		atomic.AddInt32(&s.websocketSinks, 1)
 	case *containermetric.ContainerMetricSink:
@@ -64,12 +163,20 @@ func (s *SinkManagerMetrics) Inc(sink sinks.Sink) {
 	}
 }
 
+// Dec stops counting the sink. A drain's counters are emitted one last time
+// so that its activity since the last tick is not lost.
 func (s *SinkManagerMetrics) Dec(sink sinks.Sink) {
-	switch sink.(type) {
+	switch sink := sink.(type) {
 	case *dump.DumpSink:
 		atomic.AddInt32(&s.dumpSinks, -1)
 	case *syslog.SyslogSink:
 		atomic.AddInt32(&s.syslogSinks, -1)
+		s.drainsLock.Lock()
+		if last, ok := s.drains[sink]; ok {
+			addDrainCounters(sink.Status(), last, drainTags(sink))
+			delete(s.drains, sink)
+		}
+		s.drainsLock.Unlock()
 	case *websocket.WebsocketSink:
 		atomic.AddInt32(&s.websocketSinks, -1)
 	case *containermetric.ContainerMetricSink:
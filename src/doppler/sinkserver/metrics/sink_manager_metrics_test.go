@@ -5,11 +5,16 @@ import (
 	"doppler/sinks/containermetric"
 	"doppler/sinks/dump"
 	"doppler/sinks/syslog"
+	"doppler/sinks/syslogwriter"
 	"doppler/sinks/websocket"
 	"doppler/sinkserver/metrics"
+	"marshalled"
+	"net/url"
 	"time"
 
+	"github.com/cloudfoundry/dropsonde/emitter"
 	"github.com/cloudfoundry/dropsonde/emitter/fake"
+	"github.com/cloudfoundry/dropsonde/factories"
 	"github.com/cloudfoundry/dropsonde/metric_sender"
 	"github.com/cloudfoundry/dropsonde/metricbatcher"
 	"github.com/cloudfoundry/sonde-go/events"
@@ -183,6 +188,106 @@ var _ = Describe("SinkManagerMetrics", func() {
 		Eventually(fakeEventEmitter.GetMessages, 2).Should(ContainElement(expected))
 	})
 
+	Describe("drain metrics", func() {
+		var (
+			drainMetrics *metrics.SinkManagerMetrics
+			drain        *syslog.SyslogSink
+			inputChan    chan *marshalled.Envelope
+		)
+
+		BeforeEach(func() {
+			drainMetrics = metrics.NewSinkManagerMetricsWithDrainInterval(10 * time.Millisecond)
+
+			drainURL, err := url.Parse("syslog://drain.example.com")
+			Expect(err).ToNot(HaveOccurred())
+			drain = syslog.NewSyslogSink("app-id", drainURL, 100, &fakeSyslogWriter{}, func(string, string) {}, "origin")
+
+			inputChan = make(chan *marshalled.Envelope)
+			go drain.Run(inputChan)
+			drainMetrics.Inc(drain)
+		})
+
+		AfterEach(func() {
+			drainMetrics.Stop()
+			drain.Disconnect()
+		})
+
+		drainEnvelopes := func(name string) func() []*events.Envelope {
+			return func() []*events.Envelope {
+				var envelopes []*events.Envelope
+				for _, e := range fakeEventEmitter.GetEnvelopes() {
+					if e.GetCounterEvent().GetName() != name && e.GetValueMetric().GetName() != name {
+						continue
+					}
+					if e.GetTags()["app_id"] == "app-id" && e.GetTags()["drain_id"] == drain.DrainID() {
+						envelopes = append(envelopes, e)
+					}
+				}
+				return envelopes
+			}
+		}
+
+		It("emits the sent messages tagged with the app and drain IDs", func() {
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "app-id", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+			inputChan <- marshalled.New(logMessage)
+
+			Eventually(func() uint64 {
+				var total uint64
+				for _, e := range drainEnvelopes("syslogDrain.sentMessages")() {
+					total += e.GetCounterEvent().GetDelta()
+				}
+				return total
+			}).Should(BeEquivalentTo(2))
+		})
+
+		It("emits whether the drain is connected", func() {
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "app-id", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+
+			Eventually(func() float64 {
+				envelopes := drainEnvelopes("syslogDrain.connected")()
+				if len(envelopes) == 0 {
+					return 0
+				}
+				return envelopes[len(envelopes)-1].GetValueMetric().GetValue()
+			}).Should(Equal(1.0))
+			Eventually(drainEnvelopes("syslogDrain.secondsSinceLastSuccess")).ShouldNot(BeEmpty())
+		})
+
+		It("emits the counters since the last tick when a drain is removed", func() {
+			drainMetrics.Dec(drain)
+			slowMetrics := metrics.NewSinkManagerMetricsWithDrainInterval(time.Hour)
+			defer slowMetrics.Stop()
+			slowMetrics.Inc(drain)
+			fakeEventEmitter.Reset()
+
+			logMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "message", "app-id", "App"), "origin")
+			inputChan <- marshalled.New(logMessage)
+			inputChan <- marshalled.New(logMessage)
+			Eventually(func() uint64 {
+				return drain.Status().SentMessages
+			}).Should(BeEquivalentTo(2))
+
+			slowMetrics.Dec(drain)
+
+			Eventually(func() uint64 {
+				var total uint64
+				for _, e := range drainEnvelopes("syslogDrain.sentMessages")() {
+					total += e.GetCounterEvent().GetDelta()
+				}
+				return total
+			}).Should(BeEquivalentTo(2))
+		})
+
+		It("stops emitting for a removed drain", func() {
+			drainMetrics.Dec(drain)
+			fakeEventEmitter.Reset()
+
+			Consistently(drainEnvelopes("syslogDrain.connected"), 100*time.Millisecond).Should(BeEmpty())
+		})
+	})
+
 	It("updates dropped message count", func() {
 		var delta int64 = 25
 		sinkManagerMetrics.UpdateDroppedMessageCount(delta)
@@ -194,3 +299,21 @@ var _ = Describe("SinkManagerMetrics", func() {
 		}))
 	})
 })
+
+type fakeSyslogWriter struct{}
+
+func (*fakeSyslogWriter) Connect() error {
+	return nil
+}
+
+func (*fakeSyslogWriter) Write(int, []byte, string, string, int64, map[string]string) (int, error) {
+	return 0, nil
+}
+
+func (*fakeSyslogWriter) WriteMetric(syslogwriter.Metric, string, string, int64, map[string]string) (int, error) {
+	return 0, nil
+}
+
+func (*fakeSyslogWriter) Close() error {
+	return nil
+}
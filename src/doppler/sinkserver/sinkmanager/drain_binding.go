@@ -0,0 +1,56 @@
+package sinkmanager
+
+import (
+	"doppler/sinks/syslogwriter"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// drainBinding is a syslog drain binding as the syslog drain binder stores
+// it in etcd: either the bare drain URL, or a JSON object holding the URL
+// and the PEM encoded TLS credentials to connect to the drain with.
+type drainBinding struct {
+	URL  string `json:"url"`
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	CA   string `json:"ca"`
+}
+
+func parseDrainBinding(value string) (drainBinding, error) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return drainBinding{URL: value}, nil
+	}
+
+	var binding drainBinding
+	err := json.Unmarshal([]byte(value), &binding)
+	return binding, err
+}
+
+func (b drainBinding) credentials() syslogwriter.Credentials {
+	return syslogwriter.Credentials{
+		Cert: b.Cert,
+		Key:  b.Key,
+		CA:   b.CA,
+	}
+}
+
+// drainID returns the drain's URL without its query and credentials, which is
+// how the syslog sink for the drain identifies itself.
+func (b drainBinding) drainID() string {
+	parsedURL, err := url.Parse(b.URL)
+	if err != nil {
+		return b.URL
+	}
+	return drainID(parsedURL)
+}
+
+func drainID(drainURL *url.URL) string {
+	return fmt.Sprintf("%s://%s%s", drainURL.Scheme, drainURL.Host, drainURL.Path)
+}
+
+type drainKey struct {
+	appID string
+	url   string
+}
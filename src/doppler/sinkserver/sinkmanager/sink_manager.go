@@ -11,6 +11,7 @@ import (
 	"doppler/sinkserver/metrics"
 	"fmt"
 	"log"
+	"marshalled"
 	"sync"
 	"time"
 
@@ -25,18 +26,27 @@ type SinkManager struct {
 	messageDrainBufferSize uint
 	dropsondeOrigin        string
 
-	metrics        *metrics.SinkManagerMetrics
-	recentLogCount uint32
+	metrics         *metrics.SinkManagerMetrics
+	recentLogCount  uint32
+	recentLogsStore dump.Store
 
 	doneChannel         chan struct{}
-	errorChannel        chan *events.Envelope
+	errorChannel        chan *marshalled.Envelope
 	urlBlacklistManager *blacklist.URLBlacklistManager
 	sinks               *groupedsinks.GroupedSinks
 	skipCertVerify      bool
 	sinkTimeout         time.Duration
 	sinkIOTimeout       time.Duration
 	metricTTL           time.Duration
+	metricHistory       containermetric.History
 	dialTimeout         time.Duration
+	syslogFormat        syslogwriter.Format
+	udpMTU              int
+
+	// bindingsMu guards bindings, the etcd value each registered drain
+	// was bound with, and serializes adding and removing drains.
+	bindingsMu sync.Mutex
+	bindings   map[drainKey]string
 
 	stopOnce sync.Once
 }
@@ -51,21 +61,30 @@ func New(
 	sinkIOTimeout,
 	metricTTL,
 	dialTimeout time.Duration,
+	recentLogsStore dump.Store,
+	metricHistory containermetric.History,
+	syslogFormat syslogwriter.Format,
+	udpMTU int,
 ) *SinkManager {
 	return &SinkManager{
 		doneChannel:            make(chan struct{}),
-		errorChannel:           make(chan *events.Envelope, 100),
+		errorChannel:           make(chan *marshalled.Envelope, 100),
 		urlBlacklistManager:    blackListManager,
-		sinks:                  groupedsinks.NewGroupedSinks(),
+		sinks:                  groupedsinks.NewGroupedSinks(dropsondeOrigin),
 		skipCertVerify:         skipCertVerify,
 		recentLogCount:         maxRetainedLogMessages,
+		recentLogsStore:        recentLogsStore,
 		metrics:                metrics.NewSinkManagerMetrics(),
 		messageDrainBufferSize: messageDrainBufferSize,
 		dropsondeOrigin:        dropsondeOrigin,
 		sinkTimeout:            sinkTimeout,
 		sinkIOTimeout:          sinkIOTimeout,
 		metricTTL:              metricTTL,
+		metricHistory:          metricHistory,
 		dialTimeout:            dialTimeout,
+		syslogFormat:           syslogFormat,
+		udpMTU:                 udpMTU,
+		bindings:               make(map[drainKey]string),
 	}
 }
 
@@ -84,14 +103,19 @@ func (sm *SinkManager) Stop() {
 	})
 }
 
-func (sm *SinkManager) SendTo(appID string, msg *events.Envelope) {
+func (sm *SinkManager) SendTo(appID string, msg *marshalled.Envelope) {
 	sm.ensureRecentLogsSinkFor(appID)
 	sm.ensureContainerMetricsSinkFor(appID)
 	sm.sinks.Broadcast(appID, msg)
 }
 
+// AppSinks returns the sinks registered for each app, keyed by app ID.
+func (sm *SinkManager) AppSinks() map[string][]sinks.Sink {
+	return sm.sinks.AppSinks()
+}
+
 func (sm *SinkManager) RegisterSink(sink sinks.Sink) bool {
-	inputChan := make(chan *events.Envelope, 128)
+	inputChan := make(chan *marshalled.Envelope, 128)
 	ok := sm.sinks.RegisterAppSink(inputChan, sink)
 	if !ok {
 		return false
@@ -129,7 +153,7 @@ func (sm *SinkManager) IsFirehoseRegistered(sink sinks.Sink) bool {
 }
 
 func (sm *SinkManager) RegisterFirehoseSink(sink sinks.Sink) bool {
-	inputChan := make(chan *events.Envelope, 128)
+	inputChan := make(chan *marshalled.Envelope, 128)
 	ok := sm.sinks.RegisterFirehoseSink(inputChan, sink)
 	if !ok {
 		return false
@@ -157,7 +181,14 @@ func (sm *SinkManager) UnregisterFirehoseSink(sink sinks.Sink) {
 	log.Printf("SinkManager: Firehose Sink with identifier %s requested closing. Closed it.", sink.Identifier())
 }
 
-func (sm *SinkManager) RecentLogsFor(appId string) []*events.Envelope {
+// RecentLogsFor returns the recent logs for an app. When recent logs are
+// persisted they are read from the store, as the app's dump sink may have
+// been removed for inactivity or lost to a restart.
+func (sm *SinkManager) RecentLogsFor(appId string) []*marshalled.Envelope {
+	if sm.recentLogsStore != nil {
+		return sm.recentLogsStore.Read(appId)
+	}
+
 	if sink := sm.sinks.DumpFor(appId); sink != nil {
 		return sink.Dump()
 	}
@@ -165,14 +196,33 @@ func (sm *SinkManager) RecentLogsFor(appId string) []*events.Envelope {
 	return nil
 }
 
-func (sm *SinkManager) LatestContainerMetrics(appId string) []*events.Envelope {
+func (sm *SinkManager) LatestContainerMetrics(appId string) []*marshalled.Envelope {
 	if sink := sm.sinks.ContainerMetricsFor(appId); sink != nil {
 		return sink.GetLatest()
 	} else {
-		return []*events.Envelope{}
+		return []*marshalled.Envelope{}
 	}
 }
 
+// ContainerMetricsHistory returns the retained history of the app's container
+// metrics from startTime onwards.
+func (sm *SinkManager) ContainerMetricsHistory(appId string, startTime int64) []*marshalled.Envelope {
+	if sink := sm.sinks.ContainerMetricsFor(appId); sink != nil {
+		return sink.GetHistory(startTime)
+	}
+
+	return []*marshalled.Envelope{}
+}
+
+// DrainsFor returns the syslog drains registered for an app.
+func (sm *SinkManager) DrainsFor(appId string) []*syslog.SyslogSink {
+	var drains []*syslog.SyslogSink
+	for _, sink := range sm.sinks.DrainsFor(appId) {
+		drains = append(drains, sink.(*syslog.SyslogSink))
+	}
+	return drains
+}
+
 func (sm *SinkManager) SendSyslogErrorToLoggregator(errorMsg string, appId string) {
 	log.Printf("SendSyslogError: %s", errorMsg)
 
@@ -183,7 +233,7 @@ func (sm *SinkManager) SendSyslogErrorToLoggregator(errorMsg string, appId strin
 		return
 	}
 
-	sm.errorChannel <- envelope
+	sm.errorChannel <- marshalled.New(envelope)
 }
 
 func (sm *SinkManager) listenForNewAppServices(newAppServiceChan <-chan appservice.AppService) {
@@ -203,10 +253,7 @@ func (sm *SinkManager) listenForDeletedAppServices(deletedAppServiceChan <-chan
 		case <-sm.doneChannel:
 			return
 		case appService := <-deletedAppServiceChan:
-			syslogSink := sm.sinks.DrainFor(appService.AppId, appService.Url)
-			if syslogSink != nil {
-				sm.UnregisterSink(syslogSink)
-			}
+			sm.unregisterSyslogSink(appService.AppId, appService.Url)
 		}
 	}
 }
@@ -220,22 +267,38 @@ func (sm *SinkManager) listenForErrorMessages() {
 			if !ok {
 				return
 			}
-			appId := envelope_extensions.GetAppId(errorMessage)
+			appId := envelope_extensions.GetAppId(errorMessage.Envelope)
 			sm.sinks.BroadcastError(appId, errorMessage)
 		}
 	}
 }
 
-func (sm *SinkManager) registerNewSyslogSink(appId string, syslogSinkURL string) {
-	parsedSyslogDrainURL, err := sm.urlBlacklistManager.CheckUrl(syslogSinkURL)
+// registerNewSyslogSink registers the drain for the given binding. A drain
+// already registered for the same URL with a different binding, e.g. one
+// with rotated credentials or another drain-type, is replaced. URLs are
+// compared without their query, the same way syslog sinks identify
+// themselves.
+func (sm *SinkManager) registerNewSyslogSink(appId string, binding string) {
+	drain, err := parseDrainBinding(binding)
 	if err != nil {
-		sm.SendSyslogErrorToLoggregator(invalidSyslogURLErrorMsg(appId, syslogSinkURL, err), appId)
+		sm.SendSyslogErrorToLoggregator(fmt.Sprintf("SinkManager: Invalid syslog drain binding for application %s. Err: %v", appId, err), appId)
 		return
 	}
 
-	syslogWriter, err := syslogwriter.NewWriter(parsedSyslogDrainURL, appId, sm.skipCertVerify, sm.dialTimeout, sm.sinkIOTimeout)
+	parsedSyslogDrainURL, err := sm.urlBlacklistManager.CheckUrl(drain.URL)
+	if err != nil {
+		sm.SendSyslogErrorToLoggregator(invalidSyslogURLErrorMsg(appId, drain.URL, err), appId)
+		return
+	}
+
+	logURL := drainID(parsedSyslogDrainURL)
+	if _, err := syslog.ParseDrainType(parsedSyslogDrainURL); err != nil {
+		sm.SendSyslogErrorToLoggregator(invalidSyslogURLErrorMsg(appId, logURL, err), appId)
+		return
+	}
+
+	syslogWriter, err := syslogwriter.NewWriter(parsedSyslogDrainURL, appId, sm.skipCertVerify, drain.credentials(), sm.dialTimeout, sm.sinkIOTimeout, sm.syslogFormat, sm.udpMTU)
 	if err != nil {
-		logURL := fmt.Sprintf("%s://%s%s", parsedSyslogDrainURL.Scheme, parsedSyslogDrainURL.Host, parsedSyslogDrainURL.Path)
 		sm.SendSyslogErrorToLoggregator(invalidSyslogURLErrorMsg(appId, logURL, err), appId)
 		return
 	}
@@ -249,8 +312,40 @@ func (sm *SinkManager) registerNewSyslogSink(appId string, syslogSinkURL string)
 		sm.dropsondeOrigin,
 	)
 
-	sm.RegisterSink(syslogSink)
+	sm.bindingsMu.Lock()
+	defer sm.bindingsMu.Unlock()
+
+	key := drainKey{appID: appId, url: logURL}
+	if existing := sm.sinks.DrainFor(appId, logURL); existing != nil && sm.bindings[key] != binding {
+		sm.UnregisterSink(existing)
+	}
+
+	if sm.RegisterSink(syslogSink) {
+		sm.bindings[key] = binding
+	}
+}
+
+// unregisterSyslogSink removes the drain for the given binding, unless the
+// drain has since been replaced by a newer binding for the same URL.
+func (sm *SinkManager) unregisterSyslogSink(appId string, binding string) {
+	drain, err := parseDrainBinding(binding)
+	if err != nil {
+		return
+	}
+
+	sm.bindingsMu.Lock()
+	defer sm.bindingsMu.Unlock()
 
+	key := drainKey{appID: appId, url: drain.drainID()}
+	if registered, ok := sm.bindings[key]; ok && registered != binding {
+		return
+	}
+	delete(sm.bindings, key)
+
+	syslogSink := sm.sinks.DrainFor(appId, key.url)
+	if syslogSink != nil {
+		sm.UnregisterSink(syslogSink)
+	}
 }
 
 func invalidSyslogURLErrorMsg(appId string, syslogSinkURL string, err error) string {
@@ -262,11 +357,20 @@ func (sm *SinkManager) ensureRecentLogsSinkFor(appId string) {
 		return
 	}
 
-	sink := dump.NewDumpSink(
-		appId,
-		sm.recentLogCount,
-		sm.sinkTimeout,
-	)
+	var sink *dump.DumpSink
+	if sm.recentLogsStore != nil {
+		sink = dump.NewPersistentDumpSink(
+			appId,
+			sm.recentLogsStore,
+			sm.sinkTimeout,
+		)
+	} else {
+		sink = dump.NewDumpSink(
+			appId,
+			sm.recentLogCount,
+			sm.sinkTimeout,
+		)
+	}
 
 	sm.RegisterSink(sink)
 }
@@ -276,10 +380,11 @@ func (sm *SinkManager) ensureContainerMetricsSinkFor(appId string) {
 		return
 	}
 
-	sink := containermetric.NewContainerMetricSink(
+	sink := containermetric.NewContainerMetricSinkWithHistory(
 		appId,
 		sm.metricTTL,
 		sm.sinkTimeout,
+		sm.metricHistory,
 	)
 
 	sm.RegisterSink(sink)
@@ -2,14 +2,19 @@ package sinkmanager_test
 
 import (
 	"doppler/iprange"
+	"doppler/logstore"
 	"doppler/sinks"
+	"doppler/sinks/containermetric"
 	"doppler/sinks/dump"
 	"doppler/sinks/syslog"
 	"doppler/sinks/syslogwriter"
 	"doppler/sinkserver/blacklist"
 	"doppler/sinkserver/sinkmanager"
+	"io/ioutil"
+	"marshalled"
 	"net"
 	"net/url"
+	"os"
 	"sync"
 	"time"
 
@@ -32,7 +37,7 @@ var _ = Describe("SinkManager", func() {
 	BeforeEach(func() {
 		fakeMetricSender.Reset()
 
-		sinkManager = sinkmanager.New(1, true, blackListManager, 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 1*time.Second)
+		sinkManager = sinkmanager.New(1, true, blackListManager, 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 1*time.Second, nil, containermetric.History{}, syslogwriter.Format{}, 0)
 
 		newAppServiceChan = make(chan appservice.AppService)
 		deletedAppServiceChan = make(chan appservice.AppService)
@@ -65,7 +70,7 @@ var _ = Describe("SinkManager", func() {
 
 			expectedMessageString := "Some Data"
 			expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-			go sinkManager.SendTo("myApp", expectedMessage)
+			go sinkManager.SendTo("myApp", marshalled.New(expectedMessage))
 
 			Eventually(sink1.Received).Should(HaveLen(1))
 			Eventually(sink2.Received).Should(HaveLen(1))
@@ -88,7 +93,7 @@ var _ = Describe("SinkManager", func() {
 
 			expectedMessageString := "Some Data"
 			expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-			go sinkManager.SendTo("myApp1", expectedMessage)
+			go sinkManager.SendTo("myApp1", marshalled.New(expectedMessage))
 
 			Eventually(sink1.Received).Should(HaveLen(1))
 			Expect(sink1.Received()[0]).To(Equal(expectedMessage))
@@ -103,7 +108,7 @@ var _ = Describe("SinkManager", func() {
 
 			expectedMessageString := "Some Data"
 			expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-			go sinkManager.SendTo("myApp1", expectedMessage)
+			go sinkManager.SendTo("myApp1", marshalled.New(expectedMessage))
 
 			Eventually(sink1.Received).Should(ContainElement(expectedMessage))
 		})
@@ -116,7 +121,7 @@ var _ = Describe("SinkManager", func() {
 
 			expectedMessageString := "Some Data"
 			expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-			go sinkManager.SendTo("myApp", expectedMessage)
+			go sinkManager.SendTo("myApp", marshalled.New(expectedMessage))
 
 			sinkManager.RegisterSink(sink1)
 
@@ -129,7 +134,7 @@ var _ = Describe("SinkManager", func() {
 
 			expectedMessageString := "Some Data"
 			expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-			go sinkManager.SendTo("myApp1", expectedMessage)
+			go sinkManager.SendTo("myApp1", marshalled.New(expectedMessage))
 
 			sinkManager.RegisterFirehoseSink(sink1)
 
@@ -152,8 +157,8 @@ var _ = Describe("SinkManager", func() {
 				expectedFirstMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedFirstMessageString, "myApp", "App"), "origin")
 				expectedSecondMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedSecondMessageString, "myApp", "App"), "origin")
 
-				sinkManager.SendTo("myApp", expectedFirstMessage)
-				sinkManager.SendTo("myApp", expectedSecondMessage)
+				sinkManager.SendTo("myApp", marshalled.New(expectedFirstMessage))
+				sinkManager.SendTo("myApp", marshalled.New(expectedSecondMessage))
 
 				close(ready)
 
@@ -206,6 +211,13 @@ var _ = Describe("SinkManager", func() {
 						Expect(string(errorMsg.GetLogMessage().GetMessage())).To(MatchRegexp("Invalid syslog drain URL"))
 					})
 
+					It("sends an error message if the drain type is invalid", func() {
+						newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: "syslog://127.0.1.1:885?drain-type=traces"}
+						Eventually(errorSink.Received).Should(HaveLen(1))
+						errorMsg := errorSink.Received()[0]
+						Expect(string(errorMsg.GetLogMessage().GetMessage())).To(ContainSubstring("Invalid drain-type traces"))
+					})
+
 					It("sends an error message if the drain URL is invalid", func() {
 						newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: "syslog//invalid"}
 						Eventually(errorSink.Received).Should(HaveLen(1))
@@ -215,6 +227,89 @@ var _ = Describe("SinkManager", func() {
 				})
 			})
 
+			Context("when the binding carries TLS credentials", func() {
+				var errorSink *channelSink
+
+				BeforeEach(func() {
+					errorSink = &channelSink{appId: "aptastic",
+						identifier: "myAppChan1",
+						done:       make(chan struct{}),
+					}
+					sinkManager.RegisterSink(errorSink)
+				})
+
+				It("sends an error message if the client certificate is invalid", func() {
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: `{"url": "syslog-tls://127.0.1.1:885", "cert": "invalid", "key": "invalid"}`}
+					Eventually(errorSink.Received).Should(HaveLen(1))
+					errorMsg := string(errorSink.Received()[0].GetLogMessage().GetMessage())
+					Expect(errorMsg).To(ContainSubstring("Invalid client certificate"))
+					Expect(errorMsg).ToNot(ContainSubstring(`"cert"`))
+				})
+
+				It("sends an error message if the binding is not valid JSON", func() {
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: `{"url": "syslog-tls://127.0.1.1:885"`}
+					Eventually(errorSink.Received).Should(HaveLen(1))
+					errorMsg := errorSink.Received()[0]
+					Expect(string(errorMsg.GetLogMessage().GetMessage())).To(ContainSubstring("Invalid syslog drain binding"))
+				})
+			})
+
+			Context("when the binding for a drain URL changes", func() {
+				const (
+					oldBinding = "syslog-tls://127.0.1.1:887"
+					newBinding = `{"url": "syslog-tls://127.0.1.1:887"}`
+				)
+
+				var numSinks = func() float64 {
+					return fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value
+				}
+
+				It("replaces the drain and ignores the deletion of the old binding", func() {
+					initialNumSinks := numSinks()
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: oldBinding}
+					Eventually(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(HaveLen(1))
+					oldSink := sinkManager.DrainsFor("aptastic")[0]
+
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: newBinding}
+					Eventually(func() bool {
+						drains := sinkManager.DrainsFor("aptastic")
+						return len(drains) == 1 && drains[0] != oldSink
+					}).Should(BeTrue())
+					Consistently(numSinks).Should(Equal(initialNumSinks + 1))
+
+					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: oldBinding}
+					Consistently(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(HaveLen(1))
+
+					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: newBinding}
+					Eventually(numSinks).Should(Equal(initialNumSinks))
+				})
+			})
+
+			Context("when the drain type for a drain URL changes", func() {
+				const (
+					oldBinding = "syslog://127.0.1.1:887?drain-type=logs"
+					newBinding = "syslog://127.0.1.1:887?drain-type=metrics"
+				)
+
+				It("replaces the drain and ignores the deletion of the old binding", func() {
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: oldBinding}
+					Eventually(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(HaveLen(1))
+					oldSink := sinkManager.DrainsFor("aptastic")[0]
+
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: newBinding}
+					Eventually(func() bool {
+						drains := sinkManager.DrainsFor("aptastic")
+						return len(drains) == 1 && drains[0] != oldSink
+					}).Should(BeTrue())
+
+					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: oldBinding}
+					Consistently(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(HaveLen(1))
+
+					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: newBinding}
+					Eventually(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(BeEmpty())
+				})
+			})
+
 			Context("when a delete update is received", func() {
 				It("deletes the corresponding syslog sink if it exists", func() {
 					initialNumSinks := fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value
@@ -227,6 +322,17 @@ var _ = Describe("SinkManager", func() {
 					Eventually(func() float64 { return fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value }, 2).Should(Equal(initialNumSinks))
 				})
 
+				It("deletes the syslog sink for a drain URL with a query", func() {
+					initialNumSinks := fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value
+					newAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: "syslog://127.0.1.1:886?drain-type=all"}
+					Eventually(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(HaveLen(1))
+
+					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: "syslog://127.0.1.1:886?drain-type=all"}
+
+					Eventually(func() []*syslog.SyslogSink { return sinkManager.DrainsFor("aptastic") }).Should(BeEmpty())
+					Eventually(func() float64 { return fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value }, 2).Should(Equal(initialNumSinks))
+				})
+
 				It("handles a delete for a nonexistent sink", func() {
 					initialNumSinks := fakeMetricSender.GetValue("messageRouter.numberOfSyslogSinks").Value
 					deletedAppServiceChan <- appservice.AppService{AppId: "aptastic", Url: "syslog://127.0.1.1:886"}
@@ -273,15 +379,15 @@ var _ = Describe("SinkManager", func() {
 			It("clears the recent logs buffer", func() {
 				expectedMessageString := "Some Data"
 				expectedMessage, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, expectedMessageString, "myApp", "App"), "origin")
-				sinkManager.SendTo("appId", expectedMessage)
+				sinkManager.SendTo("appId", marshalled.New(expectedMessage))
 
-				Eventually(func() []*events.Envelope {
+				Eventually(func() []*marshalled.Envelope {
 					return sinkManager.RecentLogsFor("appId")
 				}).Should(HaveLen(1))
 
 				sinkManager.UnregisterSink(dumpSink)
 
-				Eventually(func() []*events.Envelope {
+				Eventually(func() []*marshalled.Envelope {
 					return sinkManager.RecentLogsFor("appId")
 				}).Should(HaveLen(0))
 			})
@@ -335,6 +441,20 @@ var _ = Describe("SinkManager", func() {
 		})
 	})
 
+	Describe("DrainsFor", func() {
+		It("returns the syslog drains registered for an app", func() {
+			url := &url.URL{Scheme: "syslog", Host: "localhost:9998"}
+			writer, _ := syslogwriter.NewSyslogWriter(url, "appId", &net.Dialer{Timeout: 500 * time.Millisecond}, 0)
+			syslogSink := syslog.NewSyslogSink("appId", url, 100, writer, func(string, string) {}, "dropsonde-origin")
+
+			sinkManager.RegisterSink(syslogSink)
+			sinkManager.RegisterSink(dump.NewDumpSink("appId", 1, time.Hour))
+
+			Expect(sinkManager.DrainsFor("appId")).To(ConsistOf(syslogSink))
+			Expect(sinkManager.DrainsFor("otherAppId")).To(BeEmpty())
+		})
+	})
+
 	Describe("RegisterFirehoseSink", func() {
 		It("runs the sink, updates metrics and returns true for registering a new firehose sink", func() {
 			sink := &channelSink{done: make(chan struct{}), appId: "firehose-a"}
@@ -436,9 +556,79 @@ var _ = Describe("SinkManager", func() {
 				},
 			}
 
-			sinkManager.SendTo("myApp", env)
+			msg := marshalled.New(env)
+			sinkManager.SendTo("myApp", msg)
+
+			Eventually(func() []*marshalled.Envelope { return sinkManager.LatestContainerMetrics("myApp") }).Should(ConsistOf(msg))
+		})
+
+		It("sends the container metrics history for a given app", func() {
+			historyManager := sinkmanager.New(1, true, blackListManager, 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 1*time.Second, nil, containermetric.History{
+				Resolution: time.Minute,
+				Window:     time.Hour,
+			}, syslogwriter.Format{}, 0)
+			defer historyManager.Stop()
+
+			env := &events.Envelope{
+				EventType: events.Envelope_ContainerMetric.Enum(),
+				Timestamp: proto.Int64(time.Now().UnixNano()),
+				ContainerMetric: &events.ContainerMetric{
+					ApplicationId: proto.String("myApp"),
+					InstanceIndex: proto.Int32(1),
+					CpuPercentage: proto.Float64(73),
+					MemoryBytes:   proto.Uint64(2),
+					DiskBytes:     proto.Uint64(3),
+				},
+			}
+
+			msg := marshalled.New(env)
+			historyManager.SendTo("myApp", msg)
 
-			Eventually(func() []*events.Envelope { return sinkManager.LatestContainerMetrics("myApp") }).Should(ConsistOf(env))
+			Eventually(func() []*marshalled.Envelope { return historyManager.ContainerMetricsHistory("myApp", 0) }).Should(ConsistOf(msg))
+		})
+
+		It("sends no container metrics history for an unknown app", func() {
+			Expect(sinkManager.ContainerMetricsHistory("unknownApp", 0)).To(BeEmpty())
+		})
+	})
+
+	Describe("Recent Logs", func() {
+		Context("with a recent logs store", func() {
+			var (
+				dir          string
+				storeManager *sinkmanager.SinkManager
+			)
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "recentlogs")
+				Expect(err).ToNot(HaveOccurred())
+
+				store, err := logstore.New(dir, 10, 100, time.Hour)
+				Expect(err).ToNot(HaveOccurred())
+
+				storeManager = sinkmanager.New(1, true, blackListManager, 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 1*time.Second, store, containermetric.History{}, syslogwriter.Format{}, 0)
+			})
+
+			AfterEach(func() {
+				storeManager.Stop()
+				os.RemoveAll(dir)
+			})
+
+			It("keeps recent logs after the dump sink is removed", func() {
+				message, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "Some Data", "myApp", "App"), "origin")
+				storeManager.SendTo("myApp", marshalled.New(message))
+
+				recentLogs := func() []*marshalled.Envelope {
+					return storeManager.RecentLogsFor("myApp")
+				}
+				Eventually(recentLogs).Should(HaveLen(1))
+
+				storeManager.Stop()
+
+				Consistently(recentLogs).Should(HaveLen(1))
+				Expect(recentLogs()[0].GetLogMessage().GetMessage()).To(BeEquivalentTo("Some Data"))
+			})
 		})
 	})
 
@@ -471,7 +661,7 @@ type channelSink struct {
 }
 
 func (c *channelSink) AppID() string { return c.appId }
-func (c *channelSink) Run(msgChan <-chan *events.Envelope) {
+func (c *channelSink) Run(msgChan <-chan *marshalled.Envelope) {
 	if c.ready != nil {
 		<-c.ready
 	}
@@ -487,7 +677,7 @@ func (c *channelSink) Run(msgChan <-chan *events.Envelope) {
 				return
 			}
 			c.Lock()
-			c.received = append(c.received, msg)
+			c.received = append(c.received, msg.Envelope)
 			c.Unlock()
 		case <-c.stop:
 			return
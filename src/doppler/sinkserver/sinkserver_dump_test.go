@@ -1,7 +1,10 @@
 package sinkserver_test
 
 import (
-	"diodes"
+	"context"
+	"doppler/lanes"
+	"doppler/sinks/containermetric"
+	"doppler/sinks/syslogwriter"
 	"doppler/sinkserver"
 	"doppler/sinkserver/blacklist"
 	"doppler/sinkserver/sinkmanager"
@@ -29,7 +32,7 @@ var _ = Describe("Dumping", func() {
 		sinkManager         *sinkmanager.SinkManager
 		TestMessageRouter   *sinkserver.MessageRouter
 		TestWebsocketServer *websocketserver.WebsocketServer
-		dataRead            *diodes.ManyToOneEnvelope
+		dataRead            *lanes.Buffer
 		services            sync.WaitGroup
 		serverPort          string
 		mockBatcher         *mockBatcher
@@ -44,14 +47,14 @@ var _ = Describe("Dumping", func() {
 
 		port := 9081 + config.GinkgoConfig.ParallelNode
 		serverPort = strconv.Itoa(port)
-		dataRead = diodes.NewManyToOneEnvelope(5, nil)
+		dataRead = lanes.New(context.Background(), 5, lanes.Weights{}, mockBatcher, &fakeLatency{})
 
 		newAppServiceChan := make(chan appservice.AppService)
 		deletedAppServiceChan := make(chan appservice.AppService)
 
 		emptyBlacklist := blacklist.New(nil)
 		sinkManager = sinkmanager.New(1024, false, emptyBlacklist, 100, "dropsonde-origin",
-			2*time.Second, 0, 1*time.Second, 500*time.Millisecond)
+			2*time.Second, 0, 1*time.Second, 500*time.Millisecond, nil, containermetric.History{}, syslogwriter.Format{}, 0)
 
 		tempSink := sinkManager
 		services.Add(1)
@@ -60,7 +63,7 @@ var _ = Describe("Dumping", func() {
 			tempSink.Start(newAppServiceChan, deletedAppServiceChan)
 		}()
 
-		TestMessageRouter = sinkserver.NewMessageRouter(sinkManager)
+		TestMessageRouter = sinkserver.NewMessageRouter(1, nil, sinkManager)
 		tempMessageRouter := TestMessageRouter
 
 		go func() {
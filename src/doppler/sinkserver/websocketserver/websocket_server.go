@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"marshalled"
 	"net"
 	"net/http"
 	"strings"
@@ -15,7 +16,6 @@ import (
 	"github.com/cloudfoundry/dropsonde/metricbatcher"
 	"github.com/cloudfoundry/loggregatorlib/server"
 	"github.com/cloudfoundry/sonde-go/events"
-	"github.com/gogo/protobuf/proto"
 	gorilla "github.com/gorilla/websocket"
 )
 
@@ -152,8 +152,21 @@ func (w *WebsocketServer) firehoseHandler(paths []string, writer http.ResponseWr
 		return nil, fmt.Errorf("missing subscription id in firehose request: (returning %d) %s", http.StatusBadRequest, request.URL.Path)
 	}
 	firehoseSubscriptionId := paths[2]
+
+	var appAffinity bool
+	switch shardType := request.URL.Query().Get("shard_type"); shardType {
+	case "", "random":
+	case "app_affinity":
+		appAffinity = true
+	default:
+		http.Error(writer, "invalid shard_type: "+shardType, http.StatusBadRequest)
+		return nil, fmt.Errorf("invalid shard_type in firehose request: (returning %d) %s", http.StatusBadRequest, shardType)
+	}
+
+	memberID := request.URL.Query().Get("member_id")
+
 	f := func(ws *gorilla.Conn) {
-		w.streamFirehose(firehoseSubscriptionId, ws)
+		w.streamFirehose(firehoseSubscriptionId, appAffinity, memberID, ws)
 	}
 	return f, nil
 }
@@ -212,7 +225,7 @@ func (w *WebsocketServer) streamLogs(appId string, websocketConnection *gorilla.
 	w.streamWebsocket(websocketSink, websocketConnection, w.sinkManager.RegisterSink, w.sinkManager.UnregisterSink)
 }
 
-func (w *WebsocketServer) streamFirehose(subscriptionId string, websocketConnection *gorilla.Conn) {
+func (w *WebsocketServer) streamFirehose(subscriptionId string, appAffinity bool, memberID string, websocketConnection *gorilla.Conn) {
 	websocketSink := websocket.NewWebsocketSink(
 		subscriptionId,
 		websocketConnection,
@@ -223,6 +236,8 @@ func (w *WebsocketServer) streamFirehose(subscriptionId string, websocketConnect
 
 	firehoseCounter := newFirehoseCounter(subscriptionId, w.batcher)
 	websocketSink.SetCounter(firehoseCounter)
+	websocketSink.SetAppAffinity(appAffinity)
+	websocketSink.SetMemberID(memberID)
 
 	w.streamWebsocket(websocketSink, websocketConnection, w.sinkManager.RegisterFirehoseSink, w.sinkManager.UnregisterFirehoseSink)
 }
@@ -245,9 +260,9 @@ func (w *WebsocketServer) latestContainerMetrics(appId string, websocketConnecti
 	sendMessagesToWebsocket("containermetrics", metrics, websocketConnection, w.batcher)
 }
 
-func sendMessagesToWebsocket(endpoint string, envelopes []*events.Envelope, websocketConnection *gorilla.Conn, batcher Batcher) {
+func sendMessagesToWebsocket(endpoint string, envelopes []*marshalled.Envelope, websocketConnection *gorilla.Conn, batcher Batcher) {
 	for _, messageEnvelope := range envelopes {
-		envelopeBytes, err := proto.Marshal(messageEnvelope)
+		envelopeBytes, err := messageEnvelope.Marshal()
 		if err != nil {
 			log.Printf("Websocket Server %s: Error marshalling %s envelope from origin %s: %s", websocketConnection.RemoteAddr(), messageEnvelope.GetEventType().String(), messageEnvelope.GetOrigin(), err.Error())
 			continue
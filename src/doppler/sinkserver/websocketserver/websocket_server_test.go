@@ -1,11 +1,14 @@
 package websocketserver_test
 
 import (
+	"doppler/sinks/containermetric"
+	"doppler/sinks/syslogwriter"
 	"doppler/sinkserver/blacklist"
 	"doppler/sinkserver/sinkmanager"
 	"doppler/sinkserver/websocketserver"
 	"fmt"
 	"io/ioutil"
+	"marshalled"
 	"net"
 	"net/http"
 	"strconv"
@@ -26,7 +29,7 @@ import (
 var _ = Describe("WebsocketServer", func() {
 	var (
 		server         *websocketserver.WebsocketServer
-		sinkManager    = sinkmanager.New(1024, false, blacklist.New(nil), 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 500*time.Millisecond)
+		sinkManager    = sinkmanager.New(1024, false, blacklist.New(nil), 100, "dropsonde-origin", 1*time.Second, 0, 1*time.Second, 500*time.Millisecond, nil, containermetric.History{}, syslogwriter.Format{}, 0)
 		appId          = "my-app"
 		wsReceivedChan chan []byte
 		apiEndpoint    string
@@ -85,7 +88,7 @@ var _ = Describe("WebsocketServer", func() {
 
 	It("dumps buffer data to the websocket client with /recentlogs", func() {
 		lm, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "my message", appId, "App"), "origin")
-		sinkManager.SendTo(appId, lm)
+		sinkManager.SendTo(appId, marshalled.New(lm))
 
 		_, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/recentlogs", apiEndpoint, appId))
 		defer cleanup()
@@ -97,7 +100,7 @@ var _ = Describe("WebsocketServer", func() {
 
 	It("sends sentEnvelopes metrics for /recentlogs", func() {
 		lm, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "my message", appId, "App"), "origin")
-		sinkManager.SendTo(appId, lm)
+		sinkManager.SendTo(appId, marshalled.New(lm))
 
 		_, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/recentlogs", apiEndpoint, appId))
 		defer cleanup()
@@ -116,7 +119,7 @@ var _ = Describe("WebsocketServer", func() {
 	It("dumps container metric data to the websocket client with /containermetrics", func() {
 		cm := factories.NewContainerMetric(appId, 0, 42.42, 1234, 123412341234)
 		envelope, _ := emitter.Wrap(cm, "origin")
-		sinkManager.SendTo(appId, envelope)
+		sinkManager.SendTo(appId, marshalled.New(envelope))
 
 		_, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/containermetrics", apiEndpoint, appId))
 		defer cleanup()
@@ -129,7 +132,7 @@ var _ = Describe("WebsocketServer", func() {
 	It("sends sentEnvelopes metrics for /containermetrics", func() {
 		cm := factories.NewContainerMetric(appId, 0, 42.42, 1234, 123412341234)
 		envelope, _ := emitter.Wrap(cm, "origin")
-		sinkManager.SendTo(appId, envelope)
+		sinkManager.SendTo(appId, marshalled.New(envelope))
 
 		_, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/containermetrics", apiEndpoint, appId))
 		defer cleanup()
@@ -149,7 +152,7 @@ var _ = Describe("WebsocketServer", func() {
 		cm := factories.NewContainerMetric(appId, 0, 42.42, 1234, 123412341234)
 		cm.InstanceIndex = nil
 		envelope, _ := emitter.Wrap(cm, "origin")
-		sinkManager.SendTo(appId, envelope)
+		sinkManager.SendTo(appId, marshalled.New(envelope))
 
 		_, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/containermetrics", apiEndpoint, appId))
 		defer cleanup()
@@ -160,7 +163,7 @@ var _ = Describe("WebsocketServer", func() {
 		stopKeepAlive, _, cleanup := addWSSink(wsReceivedChan, fmt.Sprintf("ws://%s/apps/%s/stream", apiEndpoint, appId))
 		defer cleanup()
 		lm, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "my message", appId, "App"), "origin")
-		sinkManager.SendTo(appId, lm)
+		sinkManager.SendTo(appId, marshalled.New(lm))
 
 		rlm, err := receiveEnvelope(wsReceivedChan)
 		Expect(err).NotTo(HaveOccurred())
@@ -191,7 +194,7 @@ var _ = Describe("WebsocketServer", func() {
 		})
 
 		It("sends data to the websocket firehose client", func() {
-			sinkManager.SendTo(appId, lm)
+			sinkManager.SendTo(appId, marshalled.New(lm))
 
 			rlm, err := receiveEnvelope(wsReceivedChan)
 			Expect(err).NotTo(HaveOccurred())
@@ -200,7 +203,7 @@ var _ = Describe("WebsocketServer", func() {
 
 		Context("when data is sent to the websocket firehose client", func() {
 			JustBeforeEach(func() {
-				sinkManager.SendTo(appId, lm)
+				sinkManager.SendTo(appId, marshalled.New(lm))
 			})
 
 			It("emits counter metric sentMessagesFirehose", func() {
@@ -240,7 +243,7 @@ var _ = Describe("WebsocketServer", func() {
 		lm, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "my message", appId, "App"), "origin")
 
 		for i := 0; i < 2; i++ {
-			sinkManager.SendTo(appId, lm)
+			sinkManager.SendTo(appId, marshalled.New(lm))
 		}
 
 		Eventually(func() int {
@@ -274,7 +277,7 @@ var _ = Describe("WebsocketServer", func() {
 		Consistently(connectionDropped, 0.2).ShouldNot(BeClosed())
 
 		lm, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, "my message", appId, "App"), "origin")
-		sinkManager.SendTo(appId, lm)
+		sinkManager.SendTo(appId, marshalled.New(lm))
 
 		rlm, err := receiveEnvelope(wsReceivedChan)
 		Expect(err).NotTo(HaveOccurred())
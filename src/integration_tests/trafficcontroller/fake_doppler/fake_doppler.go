@@ -12,26 +12,30 @@ import (
 )
 
 type FakeDoppler struct {
-	GrpcEndpoint             string
-	grpcListener             net.Listener
-	grpcOut                  chan []byte
-	SubscriptionRequests     chan *plumbing.SubscriptionRequest
-	ContainerMetricsRequests chan *plumbing.ContainerMetricsRequest
-	RecentLogsRequests       chan *plumbing.RecentLogsRequest
-	SubscribeServers         chan plumbing.Doppler_SubscribeServer
-	done                     chan struct{}
+	GrpcEndpoint                    string
+	grpcListener                    net.Listener
+	grpcOut                         chan []byte
+	SubscriptionRequests            chan *plumbing.SubscriptionRequest
+	ContainerMetricsRequests        chan *plumbing.ContainerMetricsRequest
+	RecentLogsRequests              chan *plumbing.RecentLogsRequest
+	ContainerMetricsHistoryRequests chan *plumbing.ContainerMetricsHistoryRequest
+	DrainStatusRequests             chan *plumbing.DrainStatusRequest
+	SubscribeServers                chan plumbing.Doppler_SubscribeServer
+	done                            chan struct{}
 	sync.RWMutex
 }
 
 func New() *FakeDoppler {
 	return &FakeDoppler{
-		GrpcEndpoint:             "127.0.0.1:1236",
-		grpcOut:                  make(chan []byte, 100),
-		SubscriptionRequests:     make(chan *plumbing.SubscriptionRequest, 100),
-		ContainerMetricsRequests: make(chan *plumbing.ContainerMetricsRequest, 100),
-		RecentLogsRequests:       make(chan *plumbing.RecentLogsRequest, 100),
-		SubscribeServers:         make(chan plumbing.Doppler_SubscribeServer, 100),
-		done:                     make(chan struct{}),
+		GrpcEndpoint:                    "127.0.0.1:1236",
+		grpcOut:                         make(chan []byte, 100),
+		SubscriptionRequests:            make(chan *plumbing.SubscriptionRequest, 100),
+		ContainerMetricsRequests:        make(chan *plumbing.ContainerMetricsRequest, 100),
+		RecentLogsRequests:              make(chan *plumbing.RecentLogsRequest, 100),
+		ContainerMetricsHistoryRequests: make(chan *plumbing.ContainerMetricsHistoryRequest, 100),
+		DrainStatusRequests:             make(chan *plumbing.DrainStatusRequest, 100),
+		SubscribeServers:                make(chan plumbing.Doppler_SubscribeServer, 100),
+		done:                            make(chan struct{}),
 	}
 }
 
@@ -112,3 +116,18 @@ func (fakeDoppler *FakeDoppler) RecentLogs(ctx context.Context, request *plumbin
 
 	return resp, nil
 }
+
+func (fakeDoppler *FakeDoppler) ContainerMetricsHistory(ctx context.Context, request *plumbing.ContainerMetricsHistoryRequest) (*plumbing.ContainerMetricsHistoryResponse, error) {
+	fakeDoppler.ContainerMetricsHistoryRequests <- request
+	resp := new(plumbing.ContainerMetricsHistoryResponse)
+	for msg := range fakeDoppler.grpcOut {
+		resp.Payload = append(resp.Payload, msg)
+	}
+
+	return resp, nil
+}
+
+func (fakeDoppler *FakeDoppler) DrainStatus(ctx context.Context, request *plumbing.DrainStatusRequest) (*plumbing.DrainStatusResponse, error) {
+	fakeDoppler.DrainStatusRequests <- request
+	return new(plumbing.DrainStatusResponse), nil
+}
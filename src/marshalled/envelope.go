@@ -0,0 +1,52 @@
+// Package marshalled carries envelopes through Doppler together with their
+// serialized form, so that the egress paths delivering an envelope to many
+// subscribers share the bytes instead of each marshalling it again.
+package marshalled
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Envelope is an envelope that is marshalled at most once. The wrapped
+// envelope must not be modified once it has been wrapped.
+type Envelope struct {
+	*events.Envelope
+
+	received time.Time
+	once     sync.Once
+	data     []byte
+	err      error
+}
+
+// New wraps an envelope as it enters Doppler.
+func New(envelope *events.Envelope) *Envelope {
+	return &Envelope{Envelope: envelope, received: time.Now()}
+}
+
+// WithBytes wraps an envelope whose serialized form is already known, e.g.
+// because it was read from disk.
+func WithBytes(envelope *events.Envelope, data []byte) *Envelope {
+	e := &Envelope{Envelope: envelope, data: data}
+	e.once.Do(func() {})
+	return e
+}
+
+// Marshal returns the serialized envelope, marshalling it on the first
+// call. The returned bytes are shared and must not be modified.
+func (e *Envelope) Marshal() ([]byte, error) {
+	e.once.Do(func() {
+		e.data, e.err = proto.Marshal(e.Envelope)
+	})
+	return e.data, e.err
+}
+
+// Received returns the time New wrapped the envelope. It is used to measure
+// how long envelopes spend in Doppler without adding to the envelope that
+// subscribers receive. Envelopes wrapped WithBytes have no received time.
+func (e *Envelope) Received() (time.Time, bool) {
+	return e.received, !e.received.IsZero()
+}
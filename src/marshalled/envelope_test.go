@@ -0,0 +1,87 @@
+package marshalled_test
+
+import (
+	"marshalled"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Envelope", func() {
+	It("returns the marshalled envelope", func() {
+		envelope := buildEnvelope("some-origin")
+
+		data, err := marshalled.New(envelope).Marshal()
+		Expect(err).ToNot(HaveOccurred())
+
+		expected, err := proto.Marshal(envelope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(expected))
+	})
+
+	It("marshals the envelope once", func() {
+		e := marshalled.New(buildEnvelope("some-origin"))
+
+		var wg sync.WaitGroup
+		results := make([][]byte, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				data, err := e.Marshal()
+				Expect(err).ToNot(HaveOccurred())
+				results[i] = data
+			}(i)
+		}
+		wg.Wait()
+
+		for _, data := range results[1:] {
+			Expect(&data[0]).To(BeIdenticalTo(&results[0][0]))
+		}
+	})
+
+	It("returns the bytes it was given", func() {
+		data := []byte("some-bytes")
+
+		marshalledData, err := marshalled.WithBytes(buildEnvelope("some-origin"), data).Marshal()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(&marshalledData[0]).To(BeIdenticalTo(&data[0]))
+	})
+
+	It("records when the envelope was received", func() {
+		before := time.Now()
+		received, ok := marshalled.New(buildEnvelope("some-origin")).Received()
+		Expect(ok).To(BeTrue())
+		Expect(received).To(BeTemporally(">=", before))
+
+		_, ok = marshalled.WithBytes(buildEnvelope("some-origin"), []byte("some-bytes")).Received()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("returns an error for an envelope that cannot be marshalled", func() {
+		e := marshalled.New(&events.Envelope{})
+
+		_, err := e.Marshal()
+		Expect(err).To(HaveOccurred())
+		_, err = e.Marshal()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func buildEnvelope(origin string) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String(origin),
+		EventType: events.Envelope_LogMessage.Enum(),
+		LogMessage: &events.LogMessage{
+			Message:     []byte("some-message"),
+			MessageType: events.LogMessage_OUT.Enum(),
+			Timestamp:   proto.Int64(1),
+		},
+	}
+}
@@ -0,0 +1,13 @@
+package marshalled_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMarshalled(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Marshalled Suite")
+}
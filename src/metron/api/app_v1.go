@@ -6,9 +6,12 @@ import (
 	"math/rand"
 	clientpool "metron/clientpool/v1"
 	"metron/config"
+	"metron/debugtap"
 	"metron/eventwriter"
+	"metron/httprollup"
 	"metron/legacyclientpool"
 	"metron/networkreader"
+	"metron/writers"
 	"metron/writers/dopplerforwarder"
 	"metron/writers/eventmarshaller"
 	"metron/writers/eventunmarshaller"
@@ -25,7 +28,9 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
-type AppV1 struct{}
+type AppV1 struct {
+	Tap *debugtap.Tap
+}
 
 func (a *AppV1) Start(config *config.Config) {
 	if config.DisableUDP {
@@ -41,11 +46,25 @@ func (a *AppV1) Start(config *config.Config) {
 		log.Panic(fmt.Errorf("Could not initialize doppler connection pool: %s", err))
 	}
 
-	messageTagger := tagger.New(config.Deployment, config.Job, config.Index, marshaller)
+	var taggerOutput writers.EnvelopeWriter = marshaller
+	if a.Tap != nil {
+		taggerOutput = debugtap.NewEnvelopeWriter(a.Tap, debugtap.PostTag, marshaller)
+	}
+	messageTagger := tagger.New(config.Deployment, config.Job, config.Index, taggerOutput)
 	aggregator := messageaggregator.New(messageTagger)
 	eventWriter.SetWriter(aggregator)
 
-	dropsondeUnmarshaller := eventunmarshaller.New(aggregator, batcher)
+	var unmarshallerOutput writers.EnvelopeWriter = aggregator
+	if config.HTTPRollup.Enabled {
+		rollup := httprollup.NewV1Writer("MetronAgent", config.HTTPRollup.SampleRate, aggregator)
+		go rollup.Run(time.Duration(config.HTTPRollup.IntervalSeconds) * time.Second)
+		unmarshallerOutput = rollup
+	}
+	if a.Tap != nil {
+		unmarshallerOutput = debugtap.NewEnvelopeWriter(a.Tap, debugtap.PreTag, unmarshallerOutput)
+	}
+
+	dropsondeUnmarshaller := eventunmarshaller.New(unmarshallerOutput, batcher)
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", config.IncomingUDPPort)
 	dropsondeReader, err := networkreader.New(metronAddress, "dropsondeAgentListener", dropsondeUnmarshaller)
 	if err != nil {
@@ -105,7 +124,8 @@ func (a *AppV1) setupGRPC(conf *config.Config) []legacyclientpool.Pool {
 
 	var connManagers []clientpool.Conn
 	for i := 0; i < 5; i++ {
-		connManagers = append(connManagers, clientpool.NewConnManager(connector, 10000+rand.Int63n(1000)))
+		source := plumbing.MetronSource(conf.Deployment, conf.Job, conf.Index, i)
+		connManagers = append(connManagers, clientpool.NewConnManager(connector, 10000+rand.Int63n(1000), source))
 	}
 
 	pool := clientpool.New(connManagers...)
@@ -1,15 +1,20 @@
 package api
 
 import (
+	"context"
 	"diodes"
 	"fmt"
 	"log"
 	"math/rand"
+	"time"
 
 	clientpool "metron/clientpool/v2"
 	"metron/config"
+	"metron/debugtap"
 	"metron/egress"
+	"metron/httprollup"
 	"metron/ingress"
+	"metron/statsdreader"
 	"plumbing"
 	v2 "plumbing/v2"
 
@@ -17,7 +22,9 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
-type AppV2 struct{}
+type AppV2 struct {
+	Tap *debugtap.Tap
+}
 
 func (a *AppV2) Start(conf *config.Config) {
 	tlsConfig, err := plumbing.NewMutualTLSConfig(
@@ -30,21 +37,49 @@ func (a *AppV2) Start(conf *config.Config) {
 		log.Panicf("Failed to load TLS config: %s", err)
 	}
 
-	envelopeBuffer := diodes.NewManyToOneEnvelopeV2(10000, diodes.AlertFunc(func(missed int) {
-		// TODO Emit metric
-		log.Printf("Dropped %d v2 envelopes", missed)
-	}))
+	envelopeBuffer := diodes.NewWaitingManyToOneEnvelopeV2(
+		context.Background(),
+		10000,
+		diodes.NewMetricAlerter("MetronAgent.droppedV2Envelopes"),
+	)
 
-	pool := a.initializePool(conf)
-	tx := egress.NewTransponder(envelopeBuffer, pool)
+	var writer egress.Writer = a.initializePool(conf)
+	if a.Tap != nil {
+		writer = debugtap.NewV2Writer(a.Tap, debugtap.PostTag, writer)
+	}
+	tx := egress.NewTransponder(envelopeBuffer, writer)
 	go tx.Start()
 
-	rx := ingress.NewReceiver(envelopeBuffer)
+	var setter ingress.DataSetter = envelopeBuffer
+	if conf.HTTPRollup.Enabled {
+		rollup := httprollup.NewV2Setter("MetronAgent", conf.HTTPRollup.SampleRate, envelopeBuffer)
+		go rollup.Run(time.Duration(conf.HTTPRollup.IntervalSeconds) * time.Second)
+		setter = rollup
+	}
+
+	if a.Tap != nil {
+		setter = debugtap.NewV2DataSetter(a.Tap, debugtap.PreTag, setter)
+	}
+
+	if conf.EnableStatsd {
+		a.startStatsdReader(conf, setter)
+	}
+
+	rx := ingress.NewReceiver(setter)
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", conf.GRPC.Port)
 	ingressServer := ingress.NewServer(metronAddress, rx, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	ingressServer.Start()
 }
 
+func (a *AppV2) startStatsdReader(conf *config.Config, setter statsdreader.DataSetter) {
+	statsdAddress := fmt.Sprintf("127.0.0.1:%d", conf.StatsdPort)
+	reader, err := statsdreader.New(statsdAddress, conf.Deployment, conf.Job, conf.Index, setter)
+	if err != nil {
+		log.Panicf("Failed to listen for statsd on %s: %s", statsdAddress, err)
+	}
+	go reader.Start()
+}
+
 func (a *AppV2) initializePool(conf *config.Config) *clientpool.ClientPool {
 	tlsConfig, err := plumbing.NewMutualTLSConfig(
 		conf.GRPC.CertFile,
@@ -66,7 +101,8 @@ func (a *AppV2) initializePool(conf *config.Config) *clientpool.ClientPool {
 
 	var connManagers []clientpool.Conn
 	for i := 0; i < 5; i++ {
-		connManagers = append(connManagers, clientpool.NewConnManager(connector, 10000+rand.Int63n(1000)))
+		source := plumbing.MetronSource(conf.Deployment, conf.Job, conf.Index, i)
+		connManagers = append(connManagers, clientpool.NewConnManager(connector, 10000+rand.Int63n(1000), source))
 	}
 
 	return clientpool.New(connManagers...)
@@ -6,6 +6,8 @@ import (
 	"io"
 	"log"
 	"plumbing"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -20,18 +22,27 @@ type grpcConn struct {
 	client plumbing.DopplerIngestor_PusherClient
 	closer io.Closer
 	writes int64
+	stream uint64
 }
 
 type ConnManager struct {
 	conn      unsafe.Pointer
 	maxWrites int64
 	connector Connector
+	source    string
+
+	// mu serializes sends and guards the sequence and stream count, which
+	// continue across reconnects.
+	mu      sync.Mutex
+	seq     uint64
+	streams uint64
 }
 
-func NewConnManager(c Connector, maxWrites int64) *ConnManager {
+func NewConnManager(c Connector, maxWrites int64, source string) *ConnManager {
 	m := &ConnManager{
 		maxWrites: maxWrites,
 		connector: c,
+		source:    source,
 	}
 	go m.maintainConn()
 	return m
@@ -44,9 +55,16 @@ func (m *ConnManager) Write(data []byte) error {
 	}
 
 	gRPCConn := (*grpcConn)(conn)
+	m.mu.Lock()
+	m.seq++
 	err := gRPCConn.client.Send(&plumbing.EnvelopeData{
-		Payload: data,
+		Payload: m.stamp(data, gRPCConn.stream, m.seq),
 	})
+	if err != nil {
+		// The envelope was not sent, so its sequence number is reused.
+		m.seq--
+	}
+	m.mu.Unlock()
 
 	// TODO: This block is untested because we don't know how to
 	// induce an error from the stream via the test
@@ -79,10 +97,27 @@ func (m *ConnManager) maintainConn() {
 			continue
 		}
 
+		m.mu.Lock()
+		m.streams++
+		stream := m.streams
+		m.mu.Unlock()
+
 		atomic.StorePointer(&m.conn, unsafe.Pointer(&grpcConn{
 			name:   fmt.Sprintf("%s", m.connector),
 			client: pusherClient,
 			closer: closer,
+			stream: stream,
 		}))
 	}
 }
+
+// stamp appends the sequence, stream, source and egress timestamp tags to
+// the marshalled envelope.
+func (m *ConnManager) stamp(data []byte, stream, seq uint64) []byte {
+	stamped := make([]byte, len(data), len(data)+len(m.source)+128)
+	copy(stamped, data)
+	stamped = plumbing.AppendTag(stamped, plumbing.SequenceTag, strconv.FormatUint(seq, 10))
+	stamped = plumbing.AppendTag(stamped, plumbing.StreamTag, strconv.FormatUint(stream, 10))
+	stamped = plumbing.AppendTag(stamped, plumbing.SourceTag, m.source)
+	return plumbing.AppendTag(stamped, plumbing.MetronEgressTag, plumbing.Timestamp(time.Now()))
+}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"metron/clientpool/v1"
 	"plumbing"
+	"time"
 
 	"github.com/apoydence/eachers/testhelpers"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -20,7 +23,7 @@ var _ = Describe("ConnManager", func() {
 
 	BeforeEach(func() {
 		mockConnector = newMockConnector()
-		connManager = clientpool.NewConnManager(mockConnector, 5)
+		connManager = clientpool.NewConnManager(mockConnector, 5, "some-deployment/some-job/0")
 		mockCloser = newMockCloser()
 		mockPusherClient = newMockDopplerIngestor_PusherClient()
 	})
@@ -38,17 +41,57 @@ var _ = Describe("ConnManager", func() {
 			})
 
 			It("sends the message down the connection", func() {
-				msg := []byte("some-data")
+				msg := marshalEnvelope("some-origin")
 				f := func() error {
 					return connManager.Write(msg)
 				}
 				Eventually(f).Should(Succeed())
 
-				Eventually(mockPusherClient.SendInput.Arg0).Should(Receive(Equal(
-					&plumbing.EnvelopeData{
-						Payload: msg,
-					},
-				)))
+				var data *plumbing.EnvelopeData
+				Eventually(mockPusherClient.SendInput.Arg0).Should(Receive(&data))
+
+				var e events.Envelope
+				Expect(proto.Unmarshal(data.Payload, &e)).To(Succeed())
+				Expect(e.GetOrigin()).To(Equal("some-origin"))
+			})
+
+			It("stamps each message with a sequence number, stream and source", func() {
+				msg := marshalEnvelope("some-origin")
+				f := func() error {
+					return connManager.Write(msg)
+				}
+				Eventually(f).Should(Succeed())
+				Expect(connManager.Write(msg)).To(Succeed())
+
+				for _, seq := range []string{"1", "2"} {
+					var data *plumbing.EnvelopeData
+					Eventually(mockPusherClient.SendInput.Arg0).Should(Receive(&data))
+
+					var e events.Envelope
+					Expect(proto.Unmarshal(data.Payload, &e)).To(Succeed())
+					Expect(e.GetTags()).To(HaveKeyWithValue("some-tag", "some-value"))
+					Expect(e.GetTags()).To(HaveKeyWithValue(plumbing.SequenceTag, seq))
+					Expect(e.GetTags()).To(HaveKeyWithValue(plumbing.StreamTag, "1"))
+					Expect(e.GetTags()).To(HaveKeyWithValue(plumbing.SourceTag, "some-deployment/some-job/0"))
+				}
+			})
+
+			It("stamps each message with the egress timestamp", func() {
+				msg := marshalEnvelope("some-origin")
+				f := func() error {
+					return connManager.Write(msg)
+				}
+				Eventually(f).Should(Succeed())
+
+				var data *plumbing.EnvelopeData
+				Eventually(mockPusherClient.SendInput.Arg0).Should(Receive(&data))
+
+				var e events.Envelope
+				Expect(proto.Unmarshal(data.Payload, &e)).To(Succeed())
+				Expect(e.GetTags()).To(HaveKey(plumbing.MetronEgressTag))
+				elapsed, ok := plumbing.Since(e.GetTags()[plumbing.MetronEgressTag])
+				Expect(ok).To(BeTrue())
+				Expect(elapsed).To(BeNumerically("<", time.Second))
 			})
 
 			Describe("connection recycling", func() {
@@ -69,6 +112,28 @@ var _ = Describe("ConnManager", func() {
 
 					Expect(len(mockCloser.CloseCalled)).ToNot(BeZero())
 				})
+
+				It("continues the sequence on the next stream", func() {
+					msg := marshalEnvelope("some-origin")
+					f := func() error {
+						return connManager.Write(msg)
+					}
+					Eventually(f).Should(Succeed())
+					for i := 0; i < 4; i++ {
+						Expect(connManager.Write(msg)).To(Succeed())
+					}
+					Eventually(f).Should(Succeed())
+
+					var data *plumbing.EnvelopeData
+					for i := 0; i < 6; i++ {
+						Eventually(mockPusherClient.SendInput.Arg0).Should(Receive(&data))
+					}
+
+					var e events.Envelope
+					Expect(proto.Unmarshal(data.Payload, &e)).To(Succeed())
+					Expect(e.GetTags()).To(HaveKeyWithValue(plumbing.SequenceTag, "6"))
+					Expect(e.GetTags()).To(HaveKeyWithValue(plumbing.StreamTag, "2"))
+				})
 			})
 		})
 
@@ -107,3 +172,20 @@ var _ = Describe("ConnManager", func() {
 		})
 	})
 })
+
+func marshalEnvelope(origin string) []byte {
+	data, err := proto.Marshal(&events.Envelope{
+		Origin:    proto.String(origin),
+		EventType: events.Envelope_ValueMetric.Enum(),
+		ValueMetric: &events.ValueMetric{
+			Name:  proto.String("some-metric"),
+			Value: proto.Float64(1),
+			Unit:  proto.String("some-unit"),
+		},
+		Tags: map[string]string{
+			"some-tag": "some-value",
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return data
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"plumbing"
 	loggregator "plumbing/v2"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -20,18 +22,27 @@ type v2GRPCConn struct {
 	client loggregator.DopplerIngress_SenderClient
 	closer io.Closer
 	writes int64
+	stream int64
 }
 
 type ConnManager struct {
 	conn      unsafe.Pointer
 	maxWrites int64
 	connector Connector
+	source    string
+
+	// mu serializes sends and guards the sequence and stream count, which
+	// continue across reconnects.
+	mu      sync.Mutex
+	seq     int64
+	streams int64
 }
 
-func NewConnManager(c Connector, maxWrites int64) *ConnManager {
+func NewConnManager(c Connector, maxWrites int64, source string) *ConnManager {
 	m := &ConnManager{
 		maxWrites: maxWrites,
 		connector: c,
+		source:    source,
 	}
 	go m.maintainConn()
 	return m
@@ -44,7 +55,14 @@ func (m *ConnManager) Write(envelope *loggregator.Envelope) error {
 	}
 
 	gRPCConn := (*v2GRPCConn)(conn)
-	err := gRPCConn.client.Send(envelope)
+	m.mu.Lock()
+	m.seq++
+	err := gRPCConn.client.Send(m.stamp(envelope, gRPCConn.stream, m.seq))
+	if err != nil {
+		// The envelope was not sent, so its sequence number is reused.
+		m.seq--
+	}
+	m.mu.Unlock()
 
 	// TODO: This block is untested because we don't know how to
 	// induce an error from the stream via the test
@@ -77,10 +95,42 @@ func (m *ConnManager) maintainConn() {
 			continue
 		}
 
+		m.mu.Lock()
+		m.streams++
+		stream := m.streams
+		m.mu.Unlock()
+
 		atomic.StorePointer(&m.conn, unsafe.Pointer(&v2GRPCConn{
 			name:   fmt.Sprintf("%s", m.connector),
 			client: pusherClient,
 			closer: closer,
+			stream: stream,
 		}))
 	}
 }
+
+// stamp returns a copy of the envelope with the sequence, stream, source and
+// egress timestamp tags added. The original envelope is left untouched as it
+// may be retried on another connection.
+func (m *ConnManager) stamp(e *loggregator.Envelope, stream, seq int64) *loggregator.Envelope {
+	tags := make(map[string]*loggregator.Value, len(e.Tags)+4)
+	for k, v := range e.Tags {
+		tags[k] = v
+	}
+	tags[plumbing.SequenceTag] = &loggregator.Value{
+		Data: &loggregator.Value_Integer{Integer: seq},
+	}
+	tags[plumbing.StreamTag] = &loggregator.Value{
+		Data: &loggregator.Value_Integer{Integer: stream},
+	}
+	tags[plumbing.SourceTag] = &loggregator.Value{
+		Data: &loggregator.Value_Text{Text: m.source},
+	}
+	tags[plumbing.MetronEgressTag] = &loggregator.Value{
+		Data: &loggregator.Value_Integer{Integer: time.Now().UnixNano()},
+	}
+
+	stamped := *e
+	stamped.Tags = tags
+	return &stamped
+}
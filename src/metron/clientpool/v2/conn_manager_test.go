@@ -3,7 +3,9 @@ package clientpool_test
 import (
 	"errors"
 	"metron/clientpool/v2"
+	"plumbing"
 	"plumbing/v2"
+	"time"
 
 	"github.com/apoydence/eachers/testhelpers"
 	. "github.com/onsi/ginkgo"
@@ -20,7 +22,7 @@ var _ = Describe("ConnManager", func() {
 
 	BeforeEach(func() {
 		mockConnector = newMockV2Connector()
-		connManager = clientpool.NewConnManager(mockConnector, 5)
+		connManager = clientpool.NewConnManager(mockConnector, 5, "some-deployment/some-job/0")
 		mockCloser = newMockCloser()
 		mockSenderClient = newMockDopplerIngress_SenderClient()
 	})
@@ -44,9 +46,40 @@ var _ = Describe("ConnManager", func() {
 				}
 				Eventually(f).Should(Succeed())
 
-				Eventually(mockSenderClient.SendInput.Arg0).Should(Receive(Equal(
-					&loggregator.Envelope{SourceUuid: "some-uuid"},
-				)))
+				var sent *loggregator.Envelope
+				Eventually(mockSenderClient.SendInput.Arg0).Should(Receive(&sent))
+				Expect(sent.SourceUuid).To(Equal("some-uuid"))
+			})
+
+			It("stamps each message with a sequence number, stream and source", func() {
+				e := &loggregator.Envelope{SourceUuid: "some-uuid"}
+				f := func() error {
+					return connManager.Write(e)
+				}
+				Eventually(f).Should(Succeed())
+				Expect(connManager.Write(e)).To(Succeed())
+
+				for _, seq := range []int64{1, 2} {
+					var sent *loggregator.Envelope
+					Eventually(mockSenderClient.SendInput.Arg0).Should(Receive(&sent))
+					Expect(sent.Tags[plumbing.SequenceTag].GetInteger()).To(Equal(seq))
+					Expect(sent.Tags[plumbing.StreamTag].GetInteger()).To(Equal(int64(1)))
+					Expect(sent.Tags[plumbing.SourceTag].GetText()).To(Equal("some-deployment/some-job/0"))
+				}
+				Expect(e.Tags).To(BeEmpty())
+			})
+
+			It("stamps each message with the egress timestamp", func() {
+				e := &loggregator.Envelope{SourceUuid: "some-uuid"}
+				f := func() error {
+					return connManager.Write(e)
+				}
+				Eventually(f).Should(Succeed())
+
+				var sent *loggregator.Envelope
+				Eventually(mockSenderClient.SendInput.Arg0).Should(Receive(&sent))
+				stamp := time.Unix(0, sent.Tags[plumbing.MetronEgressTag].GetInteger())
+				Expect(stamp).To(BeTemporally("~", time.Now(), time.Second))
 			})
 
 			Describe("connection recycling", func() {
@@ -67,6 +100,25 @@ var _ = Describe("ConnManager", func() {
 
 					Expect(len(mockCloser.CloseCalled)).ToNot(BeZero())
 				})
+
+				It("continues the sequence on the next stream", func() {
+					e := &loggregator.Envelope{SourceUuid: "some-uuid"}
+					f := func() error {
+						return connManager.Write(e)
+					}
+					Eventually(f).Should(Succeed())
+					for i := 0; i < 4; i++ {
+						Expect(connManager.Write(e)).To(Succeed())
+					}
+					Eventually(f).Should(Succeed())
+
+					var sent *loggregator.Envelope
+					for i := 0; i < 6; i++ {
+						Eventually(mockSenderClient.SendInput.Arg0).Should(Receive(&sent))
+					}
+					Expect(sent.Tags[plumbing.SequenceTag].GetInteger()).To(Equal(int64(6)))
+					Expect(sent.Tags[plumbing.StreamTag].GetInteger()).To(Equal(int64(2)))
+				})
 			})
 		})
 
@@ -104,12 +104,18 @@ var _ = Describe("Metron", func() {
 			var rx v2.DopplerIngress_SenderServer
 			Expect(consumerServer.V2.SenderInput.Arg0).Should(Receive(&rx))
 
-			f := func() *v2.Envelope {
-				envelope, err := rx.Recv()
+			var envelope *v2.Envelope
+			f := func() *v2.Envelope_Log {
+				var err error
+				envelope, err = rx.Recv()
 				Expect(err).ToNot(HaveOccurred())
-				return envelope
+				log, _ := envelope.Message.(*v2.Envelope_Log)
+				return log
 			}
-			Eventually(f).Should(Equal(emitEnvelope))
+			Eventually(f).Should(Equal(emitEnvelope.Message))
+			Expect(envelope.Tags).To(HaveKey(plumbing.SequenceTag))
+			Expect(envelope.Tags).To(HaveKey(plumbing.StreamTag))
+			Expect(envelope.Tags).To(HaveKey(plumbing.SourceTag))
 		})
 	})
 
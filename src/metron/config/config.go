@@ -20,6 +20,12 @@ type GRPC struct {
 	KeyFile  string
 }
 
+type HTTPRollup struct {
+	Enabled         bool
+	IntervalSeconds uint
+	SampleRate      float64
+}
+
 type Config struct {
 	Syslog     string
 	Deployment string
@@ -32,6 +38,13 @@ type Config struct {
 
 	GRPC GRPC
 
+	EnableStatsd bool
+	StatsdPort   uint16
+
+	HTTPRollup HTTPRollup
+
+	DebugTapPort uint16
+
 	SharedSecret string // TODO: Delete when UDP is removed
 
 	DopplerAddr    string
@@ -57,6 +70,10 @@ func Parse(reader io.Reader) (*Config, error) {
 	config := &Config{
 		MetricBatchIntervalMilliseconds:  5000,
 		RuntimeStatsIntervalMilliseconds: 15000,
+		StatsdPort:                       8125,
+		HTTPRollup: HTTPRollup{
+			IntervalSeconds: 60,
+		},
 	}
 	err := json.NewDecoder(reader).Decode(config)
 	if err != nil {
@@ -0,0 +1,286 @@
+package debugtap
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "plumbing/v2"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	PreTag  = "pre-tag"
+	PostTag = "post-tag"
+
+	defaultRate = 10
+	bufferSize  = 100
+)
+
+// Tap streams copies of the envelopes flowing through Metron to local
+// subscribers as newline delimited JSON.
+type Tap struct {
+	mu            sync.RWMutex
+	subscriptions map[*subscription]struct{}
+	count         int64
+}
+
+type entry struct {
+	Pipeline string      `json:"pipeline"`
+	Stage    string      `json:"stage"`
+	Envelope interface{} `json:"envelope"`
+}
+
+func New() *Tap {
+	return &Tap{
+		subscriptions: make(map[*subscription]struct{}),
+	}
+}
+
+// Start serves the tap on the given port of the loopback interface.
+func (t *Tap) Start(port uint16) {
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Failed to start debug tap on %s: %s", addr, err)
+		return
+	}
+
+	log.Printf("Debug tap listening on %s", addr)
+	if err := http.Serve(lis, t); err != nil {
+		log.Printf("Debug tap stopped: %s", err)
+	}
+}
+
+// ObserveV1 offers a v1 envelope at the given stage to all subscribers.
+func (t *Tap) ObserveV1(stage string, e *events.Envelope) {
+	if atomic.LoadInt64(&t.count) == 0 {
+		return
+	}
+
+	t.observe(stage, "v1", e, filterable{
+		origin:    e.GetOrigin(),
+		eventType: e.GetEventType().String(),
+		names:     v1Names(e),
+	})
+}
+
+// ObserveV2 offers a v2 envelope at the given stage to all subscribers.
+func (t *Tap) ObserveV2(stage string, e *v2.Envelope) {
+	if atomic.LoadInt64(&t.count) == 0 {
+		return
+	}
+
+	eventType, names := v2TypeAndNames(e)
+	t.observe(stage, "v2", e, filterable{
+		origin:    e.GetTags()["origin"].GetText(),
+		eventType: eventType,
+		names:     names,
+	})
+}
+
+func (t *Tap) observe(stage, pipeline string, e interface{}, f filterable) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var data []byte
+	for s := range t.subscriptions {
+		if !s.matches(f) || !s.allow() {
+			continue
+		}
+
+		if data == nil {
+			var err error
+			data, err = json.Marshal(entry{
+				Pipeline: pipeline,
+				Stage:    stage,
+				Envelope: e,
+			})
+			if err != nil {
+				return
+			}
+		}
+
+		select {
+		case s.data <- data:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams matching envelopes until the client disconnects. The
+// origin, type and name query parameters filter the stream and rate limits
+// the number of envelopes sent per second.
+func (t *Tap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	s, err := newSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t.add(s)
+	defer t.remove(s)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var closed <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed = cn.CloseNotify()
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case data := <-s.data:
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (t *Tap) add(s *subscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.subscriptions[s] = struct{}{}
+	atomic.AddInt64(&t.count, 1)
+}
+
+func (t *Tap) remove(s *subscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.subscriptions, s)
+	atomic.AddInt64(&t.count, -1)
+}
+
+type filterable struct {
+	origin    string
+	eventType string
+	names     []string
+}
+
+type subscription struct {
+	origin    string
+	eventType string
+	name      string
+	rate      int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	sent        int
+
+	data chan []byte
+}
+
+func newSubscription(r *http.Request) (*subscription, error) {
+	query := r.URL.Query()
+
+	rate := defaultRate
+	if v := query.Get("rate"); v != "" {
+		var err error
+		rate, err = strconv.Atoi(v)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate: %s", v)
+		}
+	}
+
+	return &subscription{
+		origin:    query.Get("origin"),
+		eventType: strings.ToLower(query.Get("type")),
+		name:      query.Get("name"),
+		rate:      rate,
+		data:      make(chan []byte, bufferSize),
+	}, nil
+}
+
+func (s *subscription) matches(f filterable) bool {
+	if s.origin != "" && s.origin != f.origin {
+		return false
+	}
+
+	if s.eventType != "" && s.eventType != strings.ToLower(f.eventType) {
+		return false
+	}
+
+	if s.name == "" {
+		return true
+	}
+
+	for _, name := range f.names {
+		if name == s.name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscription) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= time.Second {
+		s.windowStart = now
+		s.sent = 0
+	}
+
+	if s.sent >= s.rate {
+		return false
+	}
+	s.sent++
+	return true
+}
+
+func v1Names(e *events.Envelope) []string {
+	switch e.GetEventType() {
+	case events.Envelope_ValueMetric:
+		return []string{e.GetValueMetric().GetName()}
+	case events.Envelope_CounterEvent:
+		return []string{e.GetCounterEvent().GetName()}
+	}
+	return nil
+}
+
+func v2TypeAndNames(e *v2.Envelope) (string, []string) {
+	switch m := e.GetMessage().(type) {
+	case *v2.Envelope_Log:
+		return "log", nil
+	case *v2.Envelope_Counter:
+		if m.Counter == nil {
+			return "counter", nil
+		}
+		return "counter", []string{m.Counter.Name}
+	case *v2.Envelope_Gauge:
+		var names []string
+		for name := range m.Gauge.GetMetrics() {
+			names = append(names, name)
+		}
+		return "gauge", names
+	case *v2.Envelope_Timer:
+		if m.Timer == nil {
+			return "timer", nil
+		}
+		return "timer", []string{m.Timer.Name}
+	}
+	return "", nil
+}
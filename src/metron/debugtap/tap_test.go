@@ -0,0 +1,126 @@
+package debugtap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"metron/debugtap"
+	"net/http"
+	"net/http/httptest"
+	v2 "plumbing/v2"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tap", func() {
+	var (
+		tap    *debugtap.Tap
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		tap = debugtap.New()
+		server = httptest.NewServer(tap)
+	})
+
+	AfterEach(func() {
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	var subscribe = func(query string) <-chan map[string]interface{} {
+		resp, err := http.Get(server.URL + "?" + query)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		entries := make(chan map[string]interface{}, 100)
+		go func() {
+			defer resp.Body.Close()
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				var entry map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					continue
+				}
+				entries <- entry
+			}
+		}()
+		return entries
+	}
+
+	It("streams v1 envelopes that match the filter", func() {
+		entries := subscribe("origin=some-origin&type=valuemetric&name=some-metric")
+
+		Eventually(func() int {
+			tap.ObserveV1(debugtap.PreTag, valueMetric("other-origin", "some-metric"))
+			tap.ObserveV1(debugtap.PreTag, valueMetric("some-origin", "other-metric"))
+			tap.ObserveV1(debugtap.PreTag, valueMetric("some-origin", "some-metric"))
+			return len(entries)
+		}).Should(BeNumerically(">", 0))
+
+		var entry map[string]interface{}
+		Expect(entries).To(Receive(&entry))
+		Expect(entry["pipeline"]).To(Equal("v1"))
+		Expect(entry["stage"]).To(Equal(debugtap.PreTag))
+
+		envelope := entry["envelope"].(map[string]interface{})
+		Expect(envelope["origin"]).To(Equal("some-origin"))
+	})
+
+	It("streams v2 envelopes that match the filter", func() {
+		entries := subscribe("type=counter")
+
+		Eventually(func() int {
+			tap.ObserveV2(debugtap.PostTag, &v2.Envelope{
+				Message: &v2.Envelope_Log{Log: &v2.Log{}},
+			})
+			tap.ObserveV2(debugtap.PostTag, &v2.Envelope{
+				Message: &v2.Envelope_Counter{Counter: &v2.Counter{Name: "some-counter"}},
+			})
+			return len(entries)
+		}).Should(BeNumerically(">", 0))
+
+		var entry map[string]interface{}
+		Expect(entries).To(Receive(&entry))
+		Expect(entry["pipeline"]).To(Equal("v2"))
+		Expect(entry["stage"]).To(Equal(debugtap.PostTag))
+
+		envelope := entry["envelope"].(map[string]interface{})
+		Expect(envelope["Message"]).To(HaveKey("Counter"))
+	})
+
+	It("limits the rate of envelopes sent to a subscriber", func() {
+		entries := subscribe("rate=1")
+
+		Eventually(func() int {
+			tap.ObserveV1(debugtap.PreTag, valueMetric("some-origin", "some-metric"))
+			return len(entries)
+		}).Should(Equal(1))
+
+		for i := 0; i < 10; i++ {
+			tap.ObserveV1(debugtap.PreTag, valueMetric("some-origin", "some-metric"))
+		}
+		Consistently(entries).Should(HaveLen(1))
+	})
+
+	It("rejects an invalid rate", func() {
+		resp, err := http.Get(server.URL + "?rate=fast")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
+
+func valueMetric(origin, name string) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String(origin),
+		EventType: events.Envelope_ValueMetric.Enum(),
+		ValueMetric: &events.ValueMetric{
+			Name:  proto.String(name),
+			Value: proto.Float64(1),
+			Unit:  proto.String("some-unit"),
+		},
+	}
+}
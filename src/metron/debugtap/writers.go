@@ -0,0 +1,81 @@
+package debugtap
+
+import (
+	"metron/writers"
+	v2 "plumbing/v2"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// EnvelopeWriter offers each v1 envelope to the tap before handing it to the
+// next writer in the pipeline.
+type EnvelopeWriter struct {
+	tap          *Tap
+	stage        string
+	outputWriter writers.EnvelopeWriter
+}
+
+func NewEnvelopeWriter(tap *Tap, stage string, outputWriter writers.EnvelopeWriter) *EnvelopeWriter {
+	return &EnvelopeWriter{
+		tap:          tap,
+		stage:        stage,
+		outputWriter: outputWriter,
+	}
+}
+
+func (w *EnvelopeWriter) Write(e *events.Envelope) {
+	w.tap.ObserveV1(w.stage, e)
+	w.outputWriter.Write(e)
+}
+
+// DataSetter accepts v2 envelopes.
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// V2DataSetter offers each v2 envelope to the tap before handing it to the
+// wrapped DataSetter.
+type V2DataSetter struct {
+	tap    *Tap
+	stage  string
+	setter DataSetter
+}
+
+func NewV2DataSetter(tap *Tap, stage string, setter DataSetter) *V2DataSetter {
+	return &V2DataSetter{
+		tap:    tap,
+		stage:  stage,
+		setter: setter,
+	}
+}
+
+func (s *V2DataSetter) Set(e *v2.Envelope) {
+	s.tap.ObserveV2(s.stage, e)
+	s.setter.Set(e)
+}
+
+// V2Writer offers each v2 envelope to the tap before handing it to the
+// wrapped egress writer.
+type V2Writer struct {
+	tap    *Tap
+	stage  string
+	writer Writer
+}
+
+// Writer writes v2 envelopes to Doppler.
+type Writer interface {
+	Write(e *v2.Envelope) error
+}
+
+func NewV2Writer(tap *Tap, stage string, writer Writer) *V2Writer {
+	return &V2Writer{
+		tap:    tap,
+		stage:  stage,
+		writer: writer,
+	}
+}
+
+func (w *V2Writer) Write(e *v2.Envelope) error {
+	w.tap.ObserveV2(w.stage, e)
+	return w.writer.Write(e)
+}
@@ -24,9 +24,14 @@ func NewTransponder(n Nexter, w Writer) *Transponder {
 	}
 }
 
+// Start writes envelopes until the nexter returns nil.
 func (t *Transponder) Start() {
 	for {
 		envelope := t.nexter.Next()
+		if envelope == nil {
+			return
+		}
+
 		// TODO: emit a metric here
 		t.writer.Write(envelope)
 	}
@@ -23,4 +23,20 @@ var _ = Describe("Transponder", func() {
 		Eventually(writer.WriteCalled).Should(Receive())
 		Eventually(<-writer.WriteInput.Msg).Should(Equal(envelope))
 	})
+
+	It("stops once the buffer returns nil", func() {
+		nexter := newMockNexter()
+		nexter.NextOutput.Ret0 <- nil
+		writer := newMockWriter()
+		tx := egress.NewTransponder(nexter, writer)
+
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			tx.Start()
+		}()
+
+		Eventually(stopped).Should(BeClosed())
+		Expect(writer.WriteCalled).ToNot(Receive())
+	})
 })
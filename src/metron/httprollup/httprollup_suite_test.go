@@ -0,0 +1,13 @@
+package httprollup_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHTTPRollup(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HTTPRollup Suite")
+}
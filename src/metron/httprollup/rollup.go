@@ -0,0 +1,137 @@
+package httprollup
+
+import (
+	"fmt"
+	"math/rand"
+	"monitor"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSamples bounds the number of latencies retained per app and status
+	// class within a single window. Beyond this, latencies are reservoir
+	// sampled.
+	maxSamples = 1024
+
+	// maxIdleWindows is the number of consecutive windows without requests
+	// after which the running total for an app and status class is dropped.
+	// A total that reappears starts again from zero.
+	maxIdleWindows = 10
+)
+
+// Summary is the aggregate of the HTTP requests seen for a single app and
+// status class during one window.
+type Summary struct {
+	AppID       string
+	StatusClass string
+	Count       uint64
+	Total       uint64
+	Percentiles map[string]time.Duration
+}
+
+type key struct {
+	appID       string
+	statusClass string
+}
+
+type stats struct {
+	count     uint64
+	latencies *monitor.Reservoir
+}
+
+type total struct {
+	value       uint64
+	idleWindows int
+}
+
+// Rollup accumulates request counts and latencies per app and status class.
+type Rollup struct {
+	mu     sync.Mutex
+	stats  map[key]*stats
+	totals map[key]*total
+}
+
+func NewRollup() *Rollup {
+	return &Rollup{
+		stats:  make(map[key]*stats),
+		totals: make(map[key]*total),
+	}
+}
+
+// Record adds a single request to the current window.
+func (r *Rollup) Record(appID string, statusCode int, latency time.Duration) {
+	k := key{
+		appID:       appID,
+		statusClass: statusClass(statusCode),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stats[k]
+	if !ok {
+		s = &stats{latencies: monitor.NewReservoir(maxSamples)}
+		r.stats[k] = s
+	}
+	s.count++
+	s.latencies.Record(latency)
+
+	t, ok := r.totals[k]
+	if !ok {
+		t = &total{}
+		r.totals[k] = t
+	}
+	t.value++
+}
+
+// Flush returns the summaries for the current window and starts a new one.
+func (r *Rollup) Flush() []Summary {
+	r.mu.Lock()
+	current := r.stats
+	r.stats = make(map[key]*stats)
+	totals := make(map[key]uint64, len(current))
+	for k, t := range r.totals {
+		if _, ok := current[k]; ok {
+			t.idleWindows = 0
+			totals[k] = t.value
+			continue
+		}
+
+		t.idleWindows++
+		if t.idleWindows >= maxIdleWindows {
+			delete(r.totals, k)
+		}
+	}
+	r.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(current))
+	for k, s := range current {
+		summaries = append(summaries, Summary{
+			AppID:       k.appID,
+			StatusClass: k.statusClass,
+			Count:       s.count,
+			Total:       totals[k],
+			Percentiles: map[string]time.Duration{
+				"p50": s.latencies.Percentile(50),
+				"p95": s.latencies.Percentile(95),
+				"p99": s.latencies.Percentile(99),
+			},
+		})
+	}
+
+	return summaries
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// sample reports whether a raw event should be forwarded given the configured
+// sample rate.
+func sample(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
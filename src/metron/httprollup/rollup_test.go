@@ -0,0 +1,104 @@
+package httprollup_test
+
+import (
+	"metron/httprollup"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rollup", func() {
+	var rollup *httprollup.Rollup
+
+	BeforeEach(func() {
+		rollup = httprollup.NewRollup()
+	})
+
+	It("groups requests by app and status class", func() {
+		rollup.Record("app-a", 200, time.Millisecond)
+		rollup.Record("app-a", 204, time.Millisecond)
+		rollup.Record("app-a", 503, time.Millisecond)
+		rollup.Record("app-b", 200, time.Millisecond)
+
+		summaries := rollup.Flush()
+
+		Expect(summaries).To(ConsistOf(
+			MatchSummary("app-a", "2xx", 2),
+			MatchSummary("app-a", "5xx", 1),
+			MatchSummary("app-b", "2xx", 1),
+		))
+	})
+
+	It("calculates latency percentiles", func() {
+		for i := 1; i <= 100; i++ {
+			rollup.Record("app-a", 200, time.Duration(i)*time.Millisecond)
+		}
+
+		summaries := rollup.Flush()
+
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].Percentiles).To(Equal(map[string]time.Duration{
+			"p50": 50 * time.Millisecond,
+			"p95": 95 * time.Millisecond,
+			"p99": 99 * time.Millisecond,
+		}))
+	})
+
+	It("starts a new window on every flush", func() {
+		rollup.Record("app-a", 200, time.Millisecond)
+		rollup.Flush()
+
+		Expect(rollup.Flush()).To(BeEmpty())
+	})
+
+	It("keeps a running total across windows", func() {
+		rollup.Record("app-a", 200, time.Millisecond)
+		rollup.Flush()
+		rollup.Record("app-a", 200, time.Millisecond)
+
+		summaries := rollup.Flush()
+
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].Count).To(Equal(uint64(1)))
+		Expect(summaries[0].Total).To(Equal(uint64(2)))
+	})
+
+	It("drops the running total of an app that has been idle for 10 windows", func() {
+		rollup.Record("app-a", 200, time.Millisecond)
+		for i := 0; i < 10; i++ {
+			rollup.Flush()
+		}
+		rollup.Record("app-a", 200, time.Millisecond)
+
+		summaries := rollup.Flush()
+
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].Total).To(Equal(uint64(1)))
+	})
+
+	It("keeps the running total of an app that was idle for fewer than 10 windows", func() {
+		rollup.Record("app-a", 200, time.Millisecond)
+		for i := 0; i < 9; i++ {
+			rollup.Flush()
+		}
+		rollup.Record("app-a", 200, time.Millisecond)
+
+		summaries := rollup.Flush()
+
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].Total).To(Equal(uint64(2)))
+	})
+
+	It("classifies invalid status codes as unknown", func() {
+		rollup.Record("app-a", 0, time.Millisecond)
+
+		Expect(rollup.Flush()).To(ConsistOf(MatchSummary("app-a", "unknown", 1)))
+	})
+})
+
+func MatchSummary(appID, statusClass string, count uint64) OmegaMatcher {
+	return WithTransform(func(s httprollup.Summary) []interface{} {
+		return []interface{}{s.AppID, s.StatusClass, s.Count}
+	}, Equal([]interface{}{appID, statusClass, count}))
+}
@@ -0,0 +1,95 @@
+package httprollup
+
+import (
+	"time"
+
+	"metron/writers"
+
+	"github.com/cloudfoundry/dropsonde/envelope_extensions"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+// V1Writer intercepts HttpStartStop envelopes in the v1 pipeline and replaces
+// them with periodic request count and latency rollups. All other envelopes
+// are passed through unchanged.
+type V1Writer struct {
+	rollup       *Rollup
+	origin       string
+	sampleRate   float64
+	outputWriter writers.EnvelopeWriter
+}
+
+func NewV1Writer(origin string, sampleRate float64, outputWriter writers.EnvelopeWriter) *V1Writer {
+	return &V1Writer{
+		rollup:       NewRollup(),
+		origin:       origin,
+		sampleRate:   sampleRate,
+		outputWriter: outputWriter,
+	}
+}
+
+func (w *V1Writer) Write(envelope *events.Envelope) {
+	if envelope.GetEventType() != events.Envelope_HttpStartStop {
+		w.outputWriter.Write(envelope)
+		return
+	}
+
+	httpStartStop := envelope.GetHttpStartStop()
+	w.rollup.Record(
+		envelope_extensions.GetAppId(envelope),
+		int(httpStartStop.GetStatusCode()),
+		time.Duration(httpStartStop.GetStopTimestamp()-httpStartStop.GetStartTimestamp()),
+	)
+
+	if sample(w.sampleRate) {
+		w.outputWriter.Write(envelope)
+	}
+}
+
+// Run flushes the rollups every interval. It does not return.
+func (w *V1Writer) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		w.Flush()
+	}
+}
+
+// Flush writes the rollups for the current window to the output writer.
+func (w *V1Writer) Flush() {
+	now := time.Now().UnixNano()
+	for _, s := range w.rollup.Flush() {
+		tags := map[string]string{
+			"app_id":       s.AppID,
+			"status_class": s.StatusClass,
+		}
+
+		w.outputWriter.Write(&events.Envelope{
+			Origin:    proto.String(w.origin),
+			Timestamp: proto.Int64(now),
+			EventType: events.Envelope_CounterEvent.Enum(),
+			CounterEvent: &events.CounterEvent{
+				Name:  proto.String("http.requests"),
+				Delta: proto.Uint64(s.Count),
+			},
+			Tags: tags,
+		})
+
+		for name, latency := range s.Percentiles {
+			w.outputWriter.Write(&events.Envelope{
+				Origin:    proto.String(w.origin),
+				Timestamp: proto.Int64(now),
+				EventType: events.Envelope_ValueMetric.Enum(),
+				ValueMetric: &events.ValueMetric{
+					Name:  proto.String("http.latency." + name),
+					Value: proto.Float64(toMillis(latency)),
+					Unit:  proto.String("ms"),
+				},
+				Tags: tags,
+			})
+		}
+	}
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
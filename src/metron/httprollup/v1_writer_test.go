@@ -0,0 +1,98 @@
+package httprollup_test
+
+import (
+	"metron/httprollup"
+	"metron/writers/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+var _ = Describe("V1Writer", func() {
+	var (
+		mockWriter *mocks.MockEnvelopeWriter
+		writer     *httprollup.V1Writer
+	)
+
+	BeforeEach(func() {
+		mockWriter = &mocks.MockEnvelopeWriter{}
+		writer = httprollup.NewV1Writer("MetronAgent", 0, mockWriter)
+	})
+
+	It("passes through envelopes that are not HttpStartStop", func() {
+		envelope := &events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_ValueMetric.Enum(),
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String("some-metric"),
+				Value: proto.Float64(1),
+				Unit:  proto.String("some-unit"),
+			},
+		}
+
+		writer.Write(envelope)
+
+		Expect(mockWriter.Events).To(ConsistOf(envelope))
+	})
+
+	It("does not pass through HttpStartStop envelopes", func() {
+		writer.Write(httpStartStop(200, 2000000))
+
+		Expect(mockWriter.Events).To(BeEmpty())
+	})
+
+	It("passes through every HttpStartStop with a sample rate of 1", func() {
+		writer = httprollup.NewV1Writer("MetronAgent", 1, mockWriter)
+		envelope := httpStartStop(200, 2000000)
+
+		writer.Write(envelope)
+
+		Expect(mockWriter.Events).To(ConsistOf(envelope))
+	})
+
+	It("writes counters and latency gauges on flush", func() {
+		writer.Write(httpStartStop(200, 2000000))
+		writer.Write(httpStartStop(201, 4000000))
+
+		writer.Flush()
+
+		Expect(mockWriter.Events).To(HaveLen(4))
+
+		counter := mockWriter.Events[0]
+		Expect(counter.GetOrigin()).To(Equal("MetronAgent"))
+		Expect(counter.GetCounterEvent().GetName()).To(Equal("http.requests"))
+		Expect(counter.GetCounterEvent().GetDelta()).To(Equal(uint64(2)))
+		Expect(counter.GetTags()).To(HaveKeyWithValue("status_class", "2xx"))
+		Expect(counter.GetTags()).To(HaveKey("app_id"))
+
+		var names []string
+		for _, e := range mockWriter.Events[1:] {
+			Expect(e.GetValueMetric().GetUnit()).To(Equal("ms"))
+			names = append(names, e.GetValueMetric().GetName())
+		}
+		Expect(names).To(ConsistOf("http.latency.p50", "http.latency.p95", "http.latency.p99"))
+	})
+})
+
+func httpStartStop(statusCode int32, durationNanos int64) *events.Envelope {
+	return &events.Envelope{
+		Origin:    proto.String("gorouter"),
+		EventType: events.Envelope_HttpStartStop.Enum(),
+		HttpStartStop: &events.HttpStartStop{
+			StartTimestamp: proto.Int64(1000),
+			StopTimestamp:  proto.Int64(1000 + durationNanos),
+			PeerType:       events.PeerType_Client.Enum(),
+			Method:         events.Method_GET.Enum(),
+			Uri:            proto.String("http://example.com"),
+			StatusCode:     proto.Int32(statusCode),
+			ContentLength:  proto.Int64(10),
+			ApplicationId: &events.UUID{
+				Low:  proto.Uint64(1),
+				High: proto.Uint64(2),
+			},
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package httprollup
+
+import (
+	"time"
+
+	v2 "plumbing/v2"
+)
+
+// httpTimerName is the name of the Timer the router emits for each HTTP
+// request it proxies.
+const httpTimerName = "http"
+
+// DataSetter accepts v2 envelopes for egress.
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// V2Setter intercepts HTTP Timer envelopes in the v2 pipeline and replaces them
+// with periodic request count and latency rollups. All other envelopes are
+// passed through unchanged.
+type V2Setter struct {
+	rollup     *Rollup
+	origin     string
+	sampleRate float64
+	setter     DataSetter
+}
+
+func NewV2Setter(origin string, sampleRate float64, setter DataSetter) *V2Setter {
+	return &V2Setter{
+		rollup:     NewRollup(),
+		origin:     origin,
+		sampleRate: sampleRate,
+		setter:     setter,
+	}
+}
+
+func (s *V2Setter) Set(e *v2.Envelope) {
+	timer := e.GetTimer()
+	if timer == nil || timer.Name != httpTimerName {
+		s.setter.Set(e)
+		return
+	}
+
+	s.rollup.Record(
+		e.SourceUuid,
+		int(e.GetTags()["status_code"].GetInteger()),
+		time.Duration(timer.Stop-timer.Start),
+	)
+
+	if sample(s.sampleRate) {
+		s.setter.Set(e)
+	}
+}
+
+// Run flushes the rollups every interval. It does not return.
+func (s *V2Setter) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.Flush()
+	}
+}
+
+// Flush writes the rollups for the current window to the DataSetter.
+func (s *V2Setter) Flush() {
+	now := time.Now().UnixNano()
+	for _, summary := range s.rollup.Flush() {
+		s.setter.Set(&v2.Envelope{
+			Timestamp:  now,
+			SourceUuid: summary.AppID,
+			Tags:       s.tags(summary),
+			Message: &v2.Envelope_Counter{
+				Counter: &v2.Counter{
+					Name: "http.requests",
+					Value: &v2.Counter_Total{
+						Total: summary.Total,
+					},
+				},
+			},
+		})
+
+		for name, latency := range summary.Percentiles {
+			s.setter.Set(&v2.Envelope{
+				Timestamp:  now,
+				SourceUuid: summary.AppID,
+				Tags:       s.tags(summary),
+				Message: &v2.Envelope_Gauge{
+					Gauge: &v2.Gauge{
+						Metrics: map[string]*v2.GaugeValue{
+							"http.latency." + name: {
+								Unit:  "ms",
+								Value: toMillis(latency),
+							},
+						},
+					},
+				},
+			})
+		}
+	}
+}
+
+func (s *V2Setter) tags(summary Summary) map[string]*v2.Value {
+	return map[string]*v2.Value{
+		"origin":       textValue(s.origin),
+		"app_id":       textValue(summary.AppID),
+		"status_class": textValue(summary.StatusClass),
+	}
+}
+
+func textValue(s string) *v2.Value {
+	return &v2.Value{
+		Data: &v2.Value_Text{
+			Text: s,
+		},
+	}
+}
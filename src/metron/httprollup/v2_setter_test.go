@@ -0,0 +1,93 @@
+//go:generate hel
+
+package httprollup_test
+
+import (
+	"metron/httprollup"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("V2Setter", func() {
+	var (
+		mockSetter *mockDataSetter
+		setter     *httprollup.V2Setter
+	)
+
+	BeforeEach(func() {
+		mockSetter = newMockDataSetter()
+		setter = httprollup.NewV2Setter("MetronAgent", 0, mockSetter)
+	})
+
+	It("passes through envelopes that are not timers", func() {
+		e := &v2.Envelope{
+			SourceUuid: "some-app",
+			Message: &v2.Envelope_Log{
+				Log: &v2.Log{Payload: []byte("hello")},
+			},
+		}
+
+		setter.Set(e)
+
+		Expect(mockSetter.SetInput.E).To(Receive(Equal(e)))
+	})
+
+	It("passes through timers that are not HTTP timers", func() {
+		e := timer("some-app", 200, 2000000)
+		e.GetTimer().Name = "db-query"
+
+		setter.Set(e)
+
+		Expect(mockSetter.SetInput.E).To(Receive(Equal(e)))
+	})
+
+	It("does not pass through HTTP timers", func() {
+		setter.Set(timer("some-app", 200, 2000000))
+
+		Expect(mockSetter.SetCalled).ToNot(Receive())
+	})
+
+	It("writes a counter total and latency gauges on flush", func() {
+		setter.Set(timer("some-app", 404, 2000000))
+		setter.Set(timer("some-app", 404, 2000000))
+
+		setter.Flush()
+
+		var e *v2.Envelope
+		Expect(mockSetter.SetInput.E).To(Receive(&e))
+		Expect(e.SourceUuid).To(Equal("some-app"))
+		Expect(e.GetCounter().Name).To(Equal("http.requests"))
+		Expect(e.GetCounter().GetTotal()).To(Equal(uint64(2)))
+		Expect(e.Tags["status_class"].GetText()).To(Equal("4xx"))
+		Expect(e.Tags["origin"].GetText()).To(Equal("MetronAgent"))
+
+		var names []string
+		for i := 0; i < 3; i++ {
+			Expect(mockSetter.SetInput.E).To(Receive(&e))
+			for name, value := range e.GetGauge().GetMetrics() {
+				Expect(value.Unit).To(Equal("ms"))
+				Expect(value.Value).To(Equal(2.0))
+				names = append(names, name)
+			}
+		}
+		Expect(names).To(ConsistOf("http.latency.p50", "http.latency.p95", "http.latency.p99"))
+	})
+})
+
+func timer(appID string, statusCode int64, durationNanos int64) *v2.Envelope {
+	return &v2.Envelope{
+		SourceUuid: appID,
+		Tags: map[string]*v2.Value{
+			"status_code": {Data: &v2.Value_Integer{Integer: statusCode}},
+		},
+		Message: &v2.Envelope_Timer{
+			Timer: &v2.Timer{
+				Name:  "http",
+				Start: 1000,
+				Stop:  1000 + durationNanos,
+			},
+		},
+	}
+}
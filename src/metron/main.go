@@ -9,6 +9,7 @@ import (
 
 	"metron/api"
 	"metron/config"
+	"metron/debugtap"
 )
 
 func main() {
@@ -26,10 +27,16 @@ func main() {
 		log.Fatalf("Unable to parse config: %s", err)
 	}
 
-	appV1 := &api.AppV1{}
+	var tap *debugtap.Tap
+	if config.DebugTapPort != 0 {
+		tap = debugtap.New()
+		go tap.Start(config.DebugTapPort)
+	}
+
+	appV1 := &api.AppV1{Tap: tap}
 	go appV1.Start(config)
 
-	appV2 := &api.AppV2{}
+	appV2 := &api.AppV2{Tap: tap}
 	go appV2.Start(config)
 
 	// We start the profiler last so that we can definitively say that we're
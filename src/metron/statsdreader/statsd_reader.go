@@ -0,0 +1,141 @@
+package statsdreader
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"net"
+
+	v2 "plumbing/v2"
+	"statsd-injector/statsdlistener"
+
+	"code.cloudfoundry.org/localip"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// DataSetter accepts v2 envelopes for egress.
+type DataSetter interface {
+	Set(e *v2.Envelope)
+}
+
+// StatsdReader listens for statsd lines over UDP and writes the parsed
+// metrics to its DataSetter, counters as v2 counter envelopes holding their
+// running total and gauges and timers as v2 gauge envelopes.
+type StatsdReader struct {
+	connection net.PacketConn
+	parser     *statsdlistener.Parser
+	setter     DataSetter
+
+	deployment string
+	job        string
+	index      string
+	ip         string
+}
+
+func New(address, deployment, job, index string, setter DataSetter) (*StatsdReader, error) {
+	connection, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Listening for statsd on %s", address)
+
+	ip, _ := localip.LocalIP()
+	return &StatsdReader{
+		connection: connection,
+		parser:     statsdlistener.NewParser(),
+		setter:     setter,
+		deployment: deployment,
+		job:        job,
+		index:      index,
+		ip:         ip,
+	}, nil
+}
+
+func (r *StatsdReader) Start() {
+	readBuffer := make([]byte, 65535) //buffer with size = max theoretical UDP size
+	for {
+		readCount, _, err := r.connection.ReadFrom(readBuffer)
+		if err != nil {
+			log.Printf("Error while reading statsd: %s", err)
+			return
+		}
+		metrics.BatchIncrementCounter("statsdReader.receivedMessageCount")
+		metrics.BatchAddCounter("statsdReader.receivedByteCount", uint64(readCount))
+
+		scanner := bufio.NewScanner(bytes.NewBuffer(readBuffer[:readCount]))
+		for scanner.Scan() {
+			line := scanner.Text()
+			envelope, err := r.parser.Parse(line)
+			if err != nil {
+				log.Printf("Error parsing stat line \"%s\": %s", line, err)
+				continue
+			}
+
+			r.setter.Set(r.convert(envelope))
+		}
+	}
+}
+
+func (r *StatsdReader) Addr() net.Addr {
+	return r.connection.LocalAddr()
+}
+
+func (r *StatsdReader) Stop() {
+	r.connection.Close()
+}
+
+func (r *StatsdReader) convert(e *events.Envelope) *v2.Envelope {
+	vm := e.GetValueMetric()
+	envelope := &v2.Envelope{
+		Timestamp: e.GetTimestamp(),
+		Tags: map[string]*v2.Value{
+			"origin":     textValue(e.GetOrigin()),
+			"deployment": textValue(r.deployment),
+			"job":        textValue(r.job),
+			"index":      textValue(r.index),
+			"ip":         textValue(r.ip),
+		},
+	}
+
+	if vm.GetUnit() == "counter" {
+		envelope.Message = &v2.Envelope_Counter{
+			Counter: &v2.Counter{
+				Name: vm.GetName(),
+				Value: &v2.Counter_Total{
+					Total: counterTotal(vm.GetValue()),
+				},
+			},
+		}
+		return envelope
+	}
+
+	envelope.Message = &v2.Envelope_Gauge{
+		Gauge: &v2.Gauge{
+			Metrics: map[string]*v2.GaugeValue{
+				vm.GetName(): {
+					Unit:  vm.GetUnit(),
+					Value: vm.GetValue(),
+				},
+			},
+		},
+	}
+	return envelope
+}
+
+// counterTotal converts the parser's running total of a counter, which
+// statsd allows to be decremented, to a v2 counter total.
+func counterTotal(value float64) uint64 {
+	if value < 0 {
+		return 0
+	}
+	return uint64(value)
+}
+
+func textValue(s string) *v2.Value {
+	return &v2.Value{
+		Data: &v2.Value_Text{
+			Text: s,
+		},
+	}
+}
@@ -0,0 +1,82 @@
+//go:generate hel
+
+package statsdreader_test
+
+import (
+	"metron/statsdreader"
+	"net"
+	v2 "plumbing/v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsdReader", func() {
+	var (
+		reader     *statsdreader.StatsdReader
+		dataSetter *mockDataSetter
+		conn       net.Conn
+	)
+
+	BeforeEach(func() {
+		dataSetter = newMockDataSetter()
+
+		var err error
+		reader, err = statsdreader.New("127.0.0.1:0", "some-deployment", "some-job", "some-index", dataSetter)
+		Expect(err).ToNot(HaveOccurred())
+		go reader.Start()
+
+		conn, err = net.Dial("udp4", reader.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		reader.Stop()
+	})
+
+	It("writes each statsd line as a tagged envelope", func() {
+		_, err := conn.Write([]byte("fake-origin.test.gauge:23|g\nfake-origin.test.counter:5|c"))
+		Expect(err).ToNot(HaveOccurred())
+
+		var e *v2.Envelope
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetGauge().GetMetrics()).To(HaveKeyWithValue("test.gauge", &v2.GaugeValue{
+			Unit:  "gauge",
+			Value: 23,
+		}))
+		Expect(e.Tags["origin"].GetText()).To(Equal("fake-origin"))
+		Expect(e.Tags["deployment"].GetText()).To(Equal("some-deployment"))
+		Expect(e.Tags["job"].GetText()).To(Equal("some-job"))
+		Expect(e.Tags["index"].GetText()).To(Equal("some-index"))
+		Expect(e.Timestamp).ToNot(BeZero())
+
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetCounter().Name).To(Equal("test.counter"))
+		Expect(e.GetCounter().GetTotal()).To(BeEquivalentTo(5))
+		Expect(e.Tags["origin"].GetText()).To(Equal("fake-origin"))
+	})
+
+	It("writes the running total of counters", func() {
+		_, err := conn.Write([]byte("fake-origin.test.counter:5|c\nfake-origin.test.counter:3|c\nfake-origin.test.counter:-10|c"))
+		Expect(err).ToNot(HaveOccurred())
+
+		var e *v2.Envelope
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetCounter().GetTotal()).To(BeEquivalentTo(5))
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetCounter().GetTotal()).To(BeEquivalentTo(8))
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetCounter().GetTotal()).To(BeZero())
+	})
+
+	It("drops lines that are not valid statsd", func() {
+		_, err := conn.Write([]byte("garbage\nfake-origin.test.gauge:23|g"))
+		Expect(err).ToNot(HaveOccurred())
+
+		var e *v2.Envelope
+		Eventually(dataSetter.SetInput.E).Should(Receive(&e))
+		Expect(e.GetGauge().GetMetrics()).To(HaveKey("test.gauge"))
+		Consistently(dataSetter.SetInput.E).ShouldNot(Receive())
+	})
+})
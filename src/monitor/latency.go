@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/dropsonde/metrics"
+)
+
+// maxLatencySamples bounds the number of durations retained per interval.
+// Beyond this, durations are reservoir sampled.
+const maxLatencySamples = 1024
+
+// Latency is a histogram of durations. Each interval it emits the p50, p95
+// and p99 of the durations recorded since the last interval as value metrics
+// in milliseconds, e.g. <name>.p99.
+type Latency struct {
+	name     string
+	interval time.Duration
+	done     chan chan struct{}
+
+	mu      sync.Mutex
+	samples *Reservoir
+}
+
+func NewLatency(name string, interval time.Duration) *Latency {
+	return &Latency{
+		name:     name,
+		interval: interval,
+		done:     make(chan chan struct{}),
+		samples:  NewReservoir(maxLatencySamples),
+	}
+}
+
+// Record adds a duration to the current interval. Negative durations, which
+// are the result of clock skew between hosts, are discarded.
+func (l *Latency) Record(d time.Duration) {
+	if d < 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples.Record(d)
+}
+
+func (l *Latency) Start() {
+	ticker := time.NewTicker(l.interval)
+
+	for {
+		select {
+		case <-ticker.C:
+			l.emit()
+		case stopped := <-l.done:
+			ticker.Stop()
+			close(stopped)
+			return
+		}
+	}
+}
+
+func (l *Latency) Stop() {
+	stopped := make(chan struct{})
+	l.done <- stopped
+	<-stopped
+}
+
+func (l *Latency) emit() {
+	l.mu.Lock()
+	samples := l.samples
+	l.samples = NewReservoir(maxLatencySamples)
+	l.mu.Unlock()
+
+	if samples.Len() == 0 {
+		return
+	}
+
+	metrics.SendValue(l.name+".p50", milliseconds(samples.Percentile(50)), "ms")
+	metrics.SendValue(l.name+".p95", milliseconds(samples.Percentile(95)), "ms")
+	metrics.SendValue(l.name+".p99", milliseconds(samples.Percentile(99)), "ms")
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,68 @@
+package monitor_test
+
+import (
+	"monitor"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+var _ = Describe("Latency", func() {
+	var (
+		latency *monitor.Latency
+		wg      sync.WaitGroup
+	)
+
+	var fetchValueMetric = func(name string) func() *events.ValueMetric {
+		return func() *events.ValueMetric {
+			for _, m := range fakeEventEmitter.GetMessages() {
+				e, ok := m.Event.(*events.ValueMetric)
+				if ok && e.GetName() == name {
+					return e
+				}
+			}
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		fakeEventEmitter.Reset()
+		latency = monitor.NewLatency("transit.test", interval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency.Start()
+		}()
+	})
+
+	AfterEach(func() {
+		latency.Stop()
+		wg.Wait()
+	})
+
+	It("emits percentiles of the recorded durations in milliseconds", func() {
+		for i := 1; i <= 100; i++ {
+			latency.Record(time.Duration(i) * time.Millisecond)
+		}
+
+		Eventually(fetchValueMetric("transit.test.p50")).ShouldNot(BeNil())
+		Expect(fetchValueMetric("transit.test.p50")().GetValue()).To(Equal(50.0))
+		Expect(fetchValueMetric("transit.test.p50")().GetUnit()).To(Equal("ms"))
+		Expect(fetchValueMetric("transit.test.p95")().GetValue()).To(Equal(95.0))
+		Expect(fetchValueMetric("transit.test.p99")().GetValue()).To(Equal(99.0))
+	})
+
+	It("does not emit when nothing has been recorded", func() {
+		Consistently(fakeEventEmitter.GetMessages, 3*interval).Should(BeEmpty())
+	})
+
+	It("discards negative durations", func() {
+		latency.Record(-time.Second)
+
+		Consistently(fakeEventEmitter.GetMessages, 3*interval).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Reservoir is a uniform sample of at most size durations. It is not safe
+// for concurrent use.
+type Reservoir struct {
+	size    int
+	count   int64
+	samples []time.Duration
+}
+
+func NewReservoir(size int) *Reservoir {
+	return &Reservoir{
+		size:    size,
+		samples: make([]time.Duration, 0, size),
+	}
+}
+
+// Record adds a duration to the sample. Once the reservoir is full each
+// recorded duration replaces a random sample with decreasing probability,
+// so that every duration recorded is equally likely to be retained.
+func (r *Reservoir) Record(d time.Duration) {
+	r.count++
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, d)
+		return
+	}
+
+	if i := rand.Int63n(r.count); i < int64(r.size) {
+		r.samples[i] = d
+	}
+}
+
+// Len returns the number of samples retained.
+func (r *Reservoir) Len() int {
+	return len(r.samples)
+}
+
+// Percentile returns the pth percentile of the samples using the nearest
+// rank method, or 0 if nothing has been recorded.
+func (r *Reservoir) Percentile(p int) time.Duration {
+	if len(r.samples) == 0 {
+		return 0
+	}
+
+	if !sort.IsSorted(durations(r.samples)) {
+		sort.Sort(durations(r.samples))
+	}
+
+	idx := (len(r.samples)*p+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return r.samples[idx]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
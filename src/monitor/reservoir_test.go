@@ -0,0 +1,36 @@
+package monitor_test
+
+import (
+	"monitor"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reservoir", func() {
+	It("returns percentiles of the recorded durations", func() {
+		reservoir := monitor.NewReservoir(1024)
+		for i := 100; i >= 1; i-- {
+			reservoir.Record(time.Duration(i))
+		}
+
+		Expect(reservoir.Len()).To(Equal(100))
+		Expect(reservoir.Percentile(50)).To(Equal(time.Duration(50)))
+		Expect(reservoir.Percentile(95)).To(Equal(time.Duration(95)))
+		Expect(reservoir.Percentile(99)).To(Equal(time.Duration(99)))
+	})
+
+	It("returns zero when nothing has been recorded", func() {
+		Expect(monitor.NewReservoir(1024).Percentile(50)).To(BeZero())
+	})
+
+	It("retains at most size samples", func() {
+		reservoir := monitor.NewReservoir(10)
+		for i := 0; i < 100; i++ {
+			reservoir.Record(time.Duration(i))
+		}
+
+		Expect(reservoir.Len()).To(Equal(10))
+	})
+})
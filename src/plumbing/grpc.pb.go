@@ -6,9 +6,11 @@
 Package plumbing is a generated protocol buffer package.
 
 It is generated from these files:
+
 	grpc.proto
 
 It has these top-level messages:
+
 	EnvelopeData
 	PushResponse
 	SubscriptionRequest
@@ -18,6 +20,11 @@ It has these top-level messages:
 	ContainerMetricsResponse
 	RecentLogsRequest
 	RecentLogsResponse
+	ContainerMetricsHistoryRequest
+	ContainerMetricsHistoryResponse
+	DrainStatusRequest
+	DrainStatus
+	DrainStatusResponse
 */
 package plumbing
 
@@ -41,6 +48,76 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type SubscriptionRequest_ShardType int32
+
+const (
+	SubscriptionRequest_RANDOM       SubscriptionRequest_ShardType = 0
+	SubscriptionRequest_APP_AFFINITY SubscriptionRequest_ShardType = 1
+)
+
+var SubscriptionRequest_ShardType_name = map[int32]string{
+	0: "RANDOM",
+	1: "APP_AFFINITY",
+}
+var SubscriptionRequest_ShardType_value = map[string]int32{
+	"RANDOM":       0,
+	"APP_AFFINITY": 1,
+}
+
+func (x SubscriptionRequest_ShardType) String() string {
+	return proto.EnumName(SubscriptionRequest_ShardType_name, int32(x))
+}
+func (SubscriptionRequest_ShardType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{2, 0}
+}
+
+type RecentLogsRequest_LogType int32
+
+const (
+	RecentLogsRequest_OUT RecentLogsRequest_LogType = 0
+	RecentLogsRequest_ERR RecentLogsRequest_LogType = 1
+)
+
+var RecentLogsRequest_LogType_name = map[int32]string{
+	0: "OUT",
+	1: "ERR",
+}
+var RecentLogsRequest_LogType_value = map[string]int32{
+	"OUT": 0,
+	"ERR": 1,
+}
+
+func (x RecentLogsRequest_LogType) String() string {
+	return proto.EnumName(RecentLogsRequest_LogType_name, int32(x))
+}
+func (RecentLogsRequest_LogType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{7, 0}
+}
+
+type DrainStatus_State int32
+
+const (
+	DrainStatus_CONNECTING  DrainStatus_State = 0
+	DrainStatus_CONNECTED   DrainStatus_State = 1
+	DrainStatus_BACKING_OFF DrainStatus_State = 2
+)
+
+var DrainStatus_State_name = map[int32]string{
+	0: "CONNECTING",
+	1: "CONNECTED",
+	2: "BACKING_OFF",
+}
+var DrainStatus_State_value = map[string]int32{
+	"CONNECTING":  0,
+	"CONNECTED":   1,
+	"BACKING_OFF": 2,
+}
+
+func (x DrainStatus_State) String() string {
+	return proto.EnumName(DrainStatus_State_name, int32(x))
+}
+func (DrainStatus_State) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{12, 0} }
+
 type EnvelopeData struct {
 	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
 }
@@ -61,6 +138,14 @@ func (*PushResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []in
 type SubscriptionRequest struct {
 	ShardID string  `protobuf:"bytes,1,opt,name=shardID" json:"shardID,omitempty"`
 	Filter  *Filter `protobuf:"bytes,2,opt,name=filter" json:"filter,omitempty"`
+	// shardType selects how envelopes are divided between the subscribers
+	// sharing a shardID. APP_AFFINITY sends every envelope for an app, or for
+	// an origin without an app, to the same subscriber.
+	ShardType SubscriptionRequest_ShardType `protobuf:"varint,3,opt,name=shardType,enum=plumbing.SubscriptionRequest_ShardType" json:"shardType,omitempty"`
+	// memberID identifies the subscriber within its shard for APP_AFFINITY
+	// sharding. Every Doppler must see the same ID for a subscriber so that
+	// they all assign an app to the same subscriber.
+	MemberID string `protobuf:"bytes,4,opt,name=memberID" json:"memberID,omitempty"`
 }
 
 func (m *SubscriptionRequest) Reset()                    { *m = SubscriptionRequest{} }
@@ -77,6 +162,13 @@ func (m *SubscriptionRequest) GetFilter() *Filter {
 
 type Filter struct {
 	AppID string `protobuf:"bytes,1,opt,name=appID" json:"appID,omitempty"`
+	// eventTypes, sourceTypes and origins restrict the envelopes sent to those
+	// matching any of the given values. Empty matches every envelope.
+	// eventTypes are dropsonde event type names, e.g. "LogMessage". Only
+	// LogMessages have a source type.
+	EventTypes  []string `protobuf:"bytes,2,rep,name=eventTypes" json:"eventTypes,omitempty"`
+	SourceTypes []string `protobuf:"bytes,3,rep,name=sourceTypes" json:"sourceTypes,omitempty"`
+	Origins     []string `protobuf:"bytes,4,rep,name=origins" json:"origins,omitempty"`
 }
 
 func (m *Filter) Reset()                    { *m = Filter{} }
@@ -88,6 +180,10 @@ func (*Filter) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 // want to pay the cost of planning an upgrade path for this to be renamed.
 type Response struct {
 	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	// egressTimestamp is when Doppler sent the envelope, in nanoseconds since
+	// the epoch. It is used to measure how long envelopes take to reach
+	// subscribers.
+	EgressTimestamp int64 `protobuf:"varint,2,opt,name=egressTimestamp" json:"egressTimestamp,omitempty"`
 }
 
 func (m *Response) Reset()                    { *m = Response{} }
@@ -115,6 +211,17 @@ func (*ContainerMetricsResponse) Descriptor() ([]byte, []int) { return fileDescr
 
 type RecentLogsRequest struct {
 	AppID string `protobuf:"bytes,1,opt,name=appID" json:"appID,omitempty"`
+	// startTime and endTime are nanoseconds since the epoch. startTime is
+	// inclusive and endTime is exclusive. Zero leaves the range unbounded.
+	StartTime int64 `protobuf:"varint,2,opt,name=startTime" json:"startTime,omitempty"`
+	EndTime   int64 `protobuf:"varint,3,opt,name=endTime" json:"endTime,omitempty"`
+	// limit is the maximum number of the most recent logs to return. Zero
+	// returns all of them.
+	Limit uint32 `protobuf:"varint,4,opt,name=limit" json:"limit,omitempty"`
+	// sourceTypes and logTypes restrict the logs returned to those matching
+	// any of the given types. Empty matches every type.
+	SourceTypes []string                    `protobuf:"bytes,5,rep,name=sourceTypes" json:"sourceTypes,omitempty"`
+	LogTypes    []RecentLogsRequest_LogType `protobuf:"varint,6,rep,packed,name=logTypes,enum=plumbing.RecentLogsRequest_LogType" json:"logTypes,omitempty"`
 }
 
 func (m *RecentLogsRequest) Reset()                    { *m = RecentLogsRequest{} }
@@ -131,6 +238,75 @@ func (m *RecentLogsResponse) String() string            { return proto.CompactTe
 func (*RecentLogsResponse) ProtoMessage()               {}
 func (*RecentLogsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
+type ContainerMetricsHistoryRequest struct {
+	AppID string `protobuf:"bytes,1,opt,name=appID" json:"appID,omitempty"`
+	// startTime is nanoseconds since the epoch. Zero returns the whole history
+	// retained by Doppler.
+	StartTime int64 `protobuf:"varint,2,opt,name=startTime" json:"startTime,omitempty"`
+}
+
+func (m *ContainerMetricsHistoryRequest) Reset()                    { *m = ContainerMetricsHistoryRequest{} }
+func (m *ContainerMetricsHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*ContainerMetricsHistoryRequest) ProtoMessage()               {}
+func (*ContainerMetricsHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+type ContainerMetricsHistoryResponse struct {
+	Payload [][]byte `protobuf:"bytes,1,rep,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *ContainerMetricsHistoryResponse) Reset()         { *m = ContainerMetricsHistoryResponse{} }
+func (m *ContainerMetricsHistoryResponse) String() string { return proto.CompactTextString(m) }
+func (*ContainerMetricsHistoryResponse) ProtoMessage()    {}
+func (*ContainerMetricsHistoryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{10}
+}
+
+type DrainStatusRequest struct {
+	AppID string `protobuf:"bytes,1,opt,name=appID" json:"appID,omitempty"`
+}
+
+func (m *DrainStatusRequest) Reset()                    { *m = DrainStatusRequest{} }
+func (m *DrainStatusRequest) String() string            { return proto.CompactTextString(m) }
+func (*DrainStatusRequest) ProtoMessage()               {}
+func (*DrainStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type DrainStatus struct {
+	// url is the drain's URL without its credentials or query.
+	Url string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+	// drainID identifies the drain in its metrics.
+	DrainID   string            `protobuf:"bytes,2,opt,name=drainID" json:"drainID,omitempty"`
+	State     DrainStatus_State `protobuf:"varint,3,opt,name=state,enum=plumbing.DrainStatus_State" json:"state,omitempty"`
+	LastError string            `protobuf:"bytes,4,opt,name=lastError" json:"lastError,omitempty"`
+	// lastSuccess is the time in nanoseconds since the epoch that the drain
+	// last sent a message. Zero if it has not sent one.
+	LastSuccess     int64  `protobuf:"varint,5,opt,name=lastSuccess" json:"lastSuccess,omitempty"`
+	SentMessages    uint64 `protobuf:"varint,6,opt,name=sentMessages" json:"sentMessages,omitempty"`
+	DroppedMessages uint64 `protobuf:"varint,7,opt,name=droppedMessages" json:"droppedMessages,omitempty"`
+	Errors          uint64 `protobuf:"varint,8,opt,name=errors" json:"errors,omitempty"`
+	Reconnects      uint64 `protobuf:"varint,9,opt,name=reconnects" json:"reconnects,omitempty"`
+}
+
+func (m *DrainStatus) Reset()                    { *m = DrainStatus{} }
+func (m *DrainStatus) String() string            { return proto.CompactTextString(m) }
+func (*DrainStatus) ProtoMessage()               {}
+func (*DrainStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+type DrainStatusResponse struct {
+	Drains []*DrainStatus `protobuf:"bytes,1,rep,name=drains" json:"drains,omitempty"`
+}
+
+func (m *DrainStatusResponse) Reset()                    { *m = DrainStatusResponse{} }
+func (m *DrainStatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*DrainStatusResponse) ProtoMessage()               {}
+func (*DrainStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *DrainStatusResponse) GetDrains() []*DrainStatus {
+	if m != nil {
+		return m.Drains
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*EnvelopeData)(nil), "plumbing.EnvelopeData")
 	proto.RegisterType((*PushResponse)(nil), "plumbing.PushResponse")
@@ -141,6 +317,14 @@ func init() {
 	proto.RegisterType((*ContainerMetricsResponse)(nil), "plumbing.ContainerMetricsResponse")
 	proto.RegisterType((*RecentLogsRequest)(nil), "plumbing.RecentLogsRequest")
 	proto.RegisterType((*RecentLogsResponse)(nil), "plumbing.RecentLogsResponse")
+	proto.RegisterType((*ContainerMetricsHistoryRequest)(nil), "plumbing.ContainerMetricsHistoryRequest")
+	proto.RegisterType((*ContainerMetricsHistoryResponse)(nil), "plumbing.ContainerMetricsHistoryResponse")
+	proto.RegisterType((*DrainStatusRequest)(nil), "plumbing.DrainStatusRequest")
+	proto.RegisterType((*DrainStatus)(nil), "plumbing.DrainStatus")
+	proto.RegisterType((*DrainStatusResponse)(nil), "plumbing.DrainStatusResponse")
+	proto.RegisterEnum("plumbing.SubscriptionRequest_ShardType", SubscriptionRequest_ShardType_name, SubscriptionRequest_ShardType_value)
+	proto.RegisterEnum("plumbing.RecentLogsRequest_LogType", RecentLogsRequest_LogType_name, RecentLogsRequest_LogType_value)
+	proto.RegisterEnum("plumbing.DrainStatus_State", DrainStatus_State_name, DrainStatus_State_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -157,6 +341,8 @@ type DopplerClient interface {
 	Subscribe(ctx context.Context, in *SubscriptionRequest, opts ...grpc.CallOption) (Doppler_SubscribeClient, error)
 	ContainerMetrics(ctx context.Context, in *ContainerMetricsRequest, opts ...grpc.CallOption) (*ContainerMetricsResponse, error)
 	RecentLogs(ctx context.Context, in *RecentLogsRequest, opts ...grpc.CallOption) (*RecentLogsResponse, error)
+	ContainerMetricsHistory(ctx context.Context, in *ContainerMetricsHistoryRequest, opts ...grpc.CallOption) (*ContainerMetricsHistoryResponse, error)
+	DrainStatus(ctx context.Context, in *DrainStatusRequest, opts ...grpc.CallOption) (*DrainStatusResponse, error)
 }
 
 type dopplerClient struct {
@@ -217,12 +403,32 @@ func (c *dopplerClient) RecentLogs(ctx context.Context, in *RecentLogsRequest, o
 	return out, nil
 }
 
+func (c *dopplerClient) ContainerMetricsHistory(ctx context.Context, in *ContainerMetricsHistoryRequest, opts ...grpc.CallOption) (*ContainerMetricsHistoryResponse, error) {
+	out := new(ContainerMetricsHistoryResponse)
+	err := grpc.Invoke(ctx, "/plumbing.Doppler/ContainerMetricsHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dopplerClient) DrainStatus(ctx context.Context, in *DrainStatusRequest, opts ...grpc.CallOption) (*DrainStatusResponse, error) {
+	out := new(DrainStatusResponse)
+	err := grpc.Invoke(ctx, "/plumbing.Doppler/DrainStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Doppler service
 
 type DopplerServer interface {
 	Subscribe(*SubscriptionRequest, Doppler_SubscribeServer) error
 	ContainerMetrics(context.Context, *ContainerMetricsRequest) (*ContainerMetricsResponse, error)
 	RecentLogs(context.Context, *RecentLogsRequest) (*RecentLogsResponse, error)
+	ContainerMetricsHistory(context.Context, *ContainerMetricsHistoryRequest) (*ContainerMetricsHistoryResponse, error)
+	DrainStatus(context.Context, *DrainStatusRequest) (*DrainStatusResponse, error)
 }
 
 func RegisterDopplerServer(s *grpc.Server, srv DopplerServer) {
@@ -286,6 +492,42 @@ func _Doppler_RecentLogs_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Doppler_ContainerMetricsHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContainerMetricsHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DopplerServer).ContainerMetricsHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plumbing.Doppler/ContainerMetricsHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DopplerServer).ContainerMetricsHistory(ctx, req.(*ContainerMetricsHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Doppler_DrainStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DopplerServer).DrainStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plumbing.Doppler/DrainStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DopplerServer).DrainStatus(ctx, req.(*DrainStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Doppler_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "plumbing.Doppler",
 	HandlerType: (*DopplerServer)(nil),
@@ -298,6 +540,14 @@ var _Doppler_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RecentLogs",
 			Handler:    _Doppler_RecentLogs_Handler,
 		},
+		{
+			MethodName: "ContainerMetricsHistory",
+			Handler:    _Doppler_ContainerMetricsHistory_Handler,
+		},
+		{
+			MethodName: "DrainStatus",
+			Handler:    _Doppler_DrainStatus_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -410,27 +660,58 @@ var _DopplerIngestor_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("grpc.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 343 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x52, 0x4d, 0x4f, 0x83, 0x40,
-	0x10, 0x2d, 0x1a, 0x69, 0x3b, 0x36, 0x5a, 0x47, 0xa3, 0x04, 0x3f, 0x52, 0x89, 0x07, 0xbc, 0xa0,
-	0xa9, 0x1e, 0x3d, 0x69, 0x35, 0x69, 0xa2, 0xd1, 0xe0, 0xc9, 0x78, 0x02, 0x3a, 0x52, 0x12, 0xdc,
-	0x5d, 0x77, 0x17, 0x13, 0x7f, 0xb8, 0x77, 0xd3, 0x0f, 0x0a, 0x56, 0x6c, 0x8f, 0xf3, 0xf5, 0xe6,
-	0xcd, 0x9b, 0x07, 0x10, 0x4b, 0x11, 0x79, 0x42, 0x72, 0xcd, 0xb1, 0x21, 0xd2, 0xec, 0x3d, 0x4c,
-	0x58, 0xec, 0xb8, 0xd0, 0xba, 0x65, 0x9f, 0x94, 0x72, 0x41, 0xbd, 0x40, 0x07, 0x68, 0x41, 0x5d,
-	0x04, 0x5f, 0x29, 0x0f, 0x06, 0x96, 0xd1, 0x31, 0xdc, 0x96, 0x9f, 0x87, 0xce, 0x06, 0xb4, 0x9e,
-	0x32, 0x35, 0xf4, 0x49, 0x09, 0xce, 0x14, 0x39, 0x2f, 0xb0, 0xfd, 0x9c, 0x85, 0x2a, 0x92, 0x89,
-	0xd0, 0x09, 0x67, 0x3e, 0x7d, 0x64, 0xa4, 0xf4, 0x08, 0x40, 0x0d, 0x03, 0x39, 0xe8, 0xf7, 0xc6,
-	0x00, 0x4d, 0x3f, 0x0f, 0xd1, 0x05, 0xf3, 0x2d, 0x49, 0x35, 0x49, 0x6b, 0xa5, 0x63, 0xb8, 0xeb,
-	0xdd, 0xb6, 0x97, 0xb3, 0xf0, 0xee, 0xc6, 0x79, 0x7f, 0x5a, 0x77, 0x8e, 0xc0, 0x9c, 0x64, 0x70,
-	0x07, 0xd6, 0x02, 0x21, 0x66, 0x58, 0x93, 0xc0, 0x39, 0x81, 0x46, 0x4e, 0x63, 0x01, 0xe1, 0x33,
-	0xd8, 0xbb, 0xe1, 0x4c, 0x07, 0x09, 0x23, 0xf9, 0x40, 0x5a, 0x26, 0x91, 0xca, 0x49, 0x56, 0xc3,
-	0x5e, 0x82, 0xf5, 0x77, 0xa0, 0x6a, 0xcd, 0x6a, 0x79, 0xcd, 0x29, 0x6c, 0xf9, 0x14, 0x11, 0xd3,
-	0xf7, 0x3c, 0x5e, 0xb2, 0xc0, 0x03, 0x2c, 0xb7, 0x2e, 0x83, 0xee, 0x7e, 0x1b, 0x50, 0xef, 0x71,
-	0x21, 0x52, 0x92, 0x78, 0x0d, 0xcd, 0xa9, 0xdc, 0x21, 0xe1, 0x61, 0x21, 0x5d, 0xc5, 0x0f, 0x6c,
-	0x2c, 0xca, 0xb3, 0x77, 0xd5, 0xce, 0x0d, 0x7c, 0x85, 0xf6, 0xfc, 0x81, 0x78, 0x5c, 0xf4, 0xfe,
-	0xa3, 0x96, 0xed, 0x2c, 0x6a, 0xc9, 0xe1, 0xb1, 0x0f, 0x50, 0x1c, 0x87, 0xfb, 0x65, 0x0a, 0x73,
-	0xea, 0xd8, 0x07, 0xd5, 0xc5, 0x1c, 0xaa, 0xfb, 0x08, 0x9b, 0xd3, 0xb3, 0xfb, 0x2c, 0x26, 0xa5,
-	0xb9, 0xc4, 0x2b, 0x30, 0x47, 0xee, 0x23, 0x89, 0xbb, 0xc5, 0x70, 0xd9, 0xb9, 0x76, 0x29, 0xff,
-	0xcb, 0xa7, 0x35, 0xd7, 0x08, 0xcd, 0xb1, 0xed, 0x2f, 0x7e, 0x02, 0x00, 0x00, 0xff, 0xff, 0x81,
-	0xea, 0x3c, 0x01, 0x04, 0x03, 0x00, 0x00,
+	// 834 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0x51, 0x6f, 0xe3, 0x44,
+	0x10, 0x8e, 0xeb, 0x36, 0x89, 0x27, 0xb9, 0xd6, 0xec, 0xc1, 0x9d, 0x95, 0xf6, 0x8e, 0x60, 0x1e,
+	0xf0, 0x21, 0x11, 0x20, 0x20, 0xf1, 0x00, 0x12, 0xea, 0xd5, 0xc9, 0x61, 0xd1, 0x26, 0xd5, 0x36,
+	0x3c, 0x20, 0x1e, 0x4e, 0x8e, 0x33, 0xe4, 0x2c, 0x39, 0xf6, 0xb2, 0xbb, 0xa9, 0x54, 0xfe, 0x17,
+	0xbf, 0x89, 0x07, 0x7e, 0x00, 0xaf, 0x68, 0x37, 0x76, 0xec, 0xa4, 0x49, 0x8b, 0x74, 0x4f, 0xf1,
+	0x7c, 0xf3, 0xed, 0xcc, 0xec, 0x7c, 0x3b, 0xbb, 0x01, 0x98, 0x73, 0x16, 0xf5, 0x18, 0xcf, 0x64,
+	0x46, 0x9a, 0x2c, 0x59, 0x2e, 0xa6, 0x71, 0x3a, 0x77, 0x3d, 0x68, 0x0f, 0xd2, 0x5b, 0x4c, 0x32,
+	0x86, 0x7e, 0x28, 0x43, 0xe2, 0x40, 0x83, 0x85, 0x77, 0x49, 0x16, 0xce, 0x1c, 0xa3, 0x6b, 0x78,
+	0x6d, 0x5a, 0x98, 0xee, 0x31, 0xb4, 0xaf, 0x97, 0xe2, 0x1d, 0x45, 0xc1, 0xb2, 0x54, 0xa0, 0xfb,
+	0xb7, 0x01, 0x4f, 0x6f, 0x96, 0x53, 0x11, 0xf1, 0x98, 0xc9, 0x38, 0x4b, 0x29, 0xfe, 0xb1, 0x44,
+	0x21, 0x55, 0x04, 0xf1, 0x2e, 0xe4, 0xb3, 0xc0, 0xd7, 0x11, 0x2c, 0x5a, 0x98, 0xc4, 0x83, 0xfa,
+	0xef, 0x71, 0x22, 0x91, 0x3b, 0x07, 0x5d, 0xc3, 0x6b, 0xf5, 0xed, 0x5e, 0x51, 0x46, 0x6f, 0xa8,
+	0x71, 0x9a, 0xfb, 0xc9, 0x00, 0x2c, 0xbd, 0x68, 0x72, 0xc7, 0xd0, 0x31, 0xbb, 0x86, 0x77, 0xdc,
+	0xff, 0xac, 0x24, 0xef, 0xc8, 0xda, 0xbb, 0x29, 0xe8, 0xb4, 0x5c, 0x49, 0x3a, 0xd0, 0x5c, 0xe0,
+	0x62, 0x8a, 0x3c, 0xf0, 0x9d, 0x43, 0x5d, 0xcb, 0xda, 0x76, 0x5f, 0x81, 0xb5, 0x5e, 0x43, 0x00,
+	0xea, 0xf4, 0x7c, 0xe4, 0x8f, 0xaf, 0xec, 0x1a, 0xb1, 0xa1, 0x7d, 0x7e, 0x7d, 0xfd, 0xf6, 0x7c,
+	0x38, 0x0c, 0x46, 0xc1, 0xe4, 0x57, 0xdb, 0x70, 0xff, 0x84, 0xfa, 0xaa, 0x3e, 0xf2, 0x21, 0x1c,
+	0x85, 0x8c, 0xad, 0x77, 0xb6, 0x32, 0xc8, 0x4b, 0x00, 0xbc, 0xc5, 0x54, 0xaa, 0x50, 0xc2, 0x39,
+	0xe8, 0x9a, 0x9e, 0x45, 0x2b, 0x08, 0xe9, 0x42, 0x4b, 0x64, 0x4b, 0x1e, 0xe1, 0x8a, 0x60, 0x6a,
+	0x42, 0x15, 0x52, 0x3d, 0xcb, 0x78, 0x3c, 0x8f, 0x53, 0xe1, 0x1c, 0x6a, 0x6f, 0x61, 0xba, 0x23,
+	0x68, 0x16, 0x1d, 0xdf, 0xaf, 0x0d, 0xf1, 0xe0, 0x04, 0xe7, 0x1c, 0x85, 0x98, 0xc4, 0x0b, 0x14,
+	0x32, 0x5c, 0x30, 0xdd, 0x62, 0x93, 0x6e, 0xc3, 0xee, 0x97, 0xf0, 0xfc, 0x22, 0x4b, 0x65, 0x18,
+	0xa7, 0xc8, 0xaf, 0x50, 0xf2, 0x38, 0x12, 0x85, 0x70, 0x3b, 0x37, 0xe7, 0x7e, 0x0b, 0xce, 0xfd,
+	0x05, 0xbb, 0x0a, 0x32, 0xab, 0x87, 0xe5, 0x5f, 0x03, 0x3e, 0xa0, 0x18, 0x61, 0x2a, 0x2f, 0xb3,
+	0xf9, 0xc3, 0x19, 0xc8, 0x19, 0x58, 0x42, 0x86, 0x5c, 0xaa, 0x22, 0xf3, 0xb2, 0x4b, 0x40, 0xe5,
+	0xc0, 0x74, 0xa6, 0x7d, 0xa6, 0xf6, 0x15, 0xa6, 0x8a, 0x96, 0xc4, 0x8b, 0x58, 0x6a, 0x69, 0x9f,
+	0xd0, 0x95, 0xb1, 0xdd, 0xec, 0xa3, 0xfb, 0xcd, 0xfe, 0x11, 0x9a, 0x49, 0x36, 0x5f, 0xb9, 0xeb,
+	0x5d, 0xd3, 0x3b, 0xee, 0x7f, 0x5a, 0x9e, 0xad, 0x7b, 0x45, 0xf7, 0x2e, 0x57, 0x5c, 0xba, 0x5e,
+	0xe4, 0x9e, 0x42, 0x23, 0x07, 0x49, 0x03, 0xcc, 0xf1, 0x2f, 0x13, 0xbb, 0xa6, 0x3e, 0x06, 0x94,
+	0xda, 0x86, 0xdb, 0x03, 0x52, 0x8d, 0xf1, 0x68, 0xa7, 0x26, 0xf0, 0x72, 0xbb, 0xbf, 0x3f, 0xc5,
+	0x42, 0x66, 0xfc, 0xee, 0x3d, 0xba, 0xe6, 0x7e, 0x0f, 0x1f, 0xef, 0x8d, 0xfa, 0x68, 0x49, 0x9f,
+	0x03, 0xf1, 0x79, 0x18, 0xa7, 0x37, 0x32, 0x94, 0xcb, 0x47, 0x8e, 0xc7, 0x3f, 0x07, 0xd0, 0xaa,
+	0x90, 0x89, 0x0d, 0xe6, 0x92, 0x27, 0x39, 0x47, 0x7d, 0xaa, 0x3c, 0x33, 0x45, 0x08, 0x7c, 0x5d,
+	0xa6, 0x45, 0x0b, 0x93, 0x7c, 0x0d, 0x47, 0x42, 0x86, 0xb2, 0x98, 0xf0, 0xd3, 0x52, 0x85, 0x4a,
+	0xc4, 0x9e, 0xfa, 0x41, 0xba, 0x62, 0xaa, 0x5d, 0x27, 0xa1, 0x90, 0x03, 0xce, 0x33, 0x9e, 0x8f,
+	0x74, 0x09, 0x28, 0xed, 0x95, 0x71, 0xb3, 0x8c, 0x22, 0x14, 0x4a, 0x7b, 0xd5, 0x95, 0x2a, 0x44,
+	0x5c, 0x68, 0x0b, 0x4c, 0xe5, 0x15, 0x0a, 0x11, 0xce, 0xb5, 0xfe, 0x86, 0x77, 0x48, 0x37, 0x30,
+	0x35, 0x4c, 0x33, 0x9e, 0x31, 0x86, 0xb3, 0x35, 0xad, 0xa1, 0x69, 0xdb, 0x30, 0x79, 0x06, 0x75,
+	0x54, 0x89, 0x85, 0xd3, 0xd4, 0x84, 0xdc, 0x52, 0x17, 0x02, 0xc7, 0x28, 0x4b, 0x53, 0x8c, 0xa4,
+	0x70, 0x2c, 0xed, 0xab, 0x20, 0xee, 0x77, 0x70, 0xa4, 0x77, 0x45, 0x8e, 0x01, 0x2e, 0xc6, 0xa3,
+	0xd1, 0xe0, 0x62, 0x12, 0x8c, 0xde, 0xd8, 0x35, 0xf2, 0x04, 0xac, 0xdc, 0x1e, 0xf8, 0xb6, 0x41,
+	0x4e, 0xa0, 0xf5, 0xfa, 0xfc, 0xe2, 0xe7, 0x60, 0xf4, 0xe6, 0xed, 0x78, 0x38, 0xb4, 0x0f, 0x5c,
+	0x1f, 0x9e, 0x6e, 0x28, 0x93, 0x4b, 0xf9, 0x05, 0xd4, 0x75, 0x4f, 0x85, 0x56, 0xb2, 0xd5, 0xff,
+	0x68, 0x67, 0x27, 0x69, 0x4e, 0xea, 0xff, 0x65, 0x42, 0xc3, 0xcf, 0x18, 0x4b, 0x90, 0x93, 0xd7,
+	0x60, 0xe5, 0xd7, 0xe9, 0x14, 0xc9, 0x8b, 0x07, 0xef, 0xd8, 0x0e, 0xa9, 0x8e, 0x49, 0xfe, 0x0a,
+	0xd4, 0xbe, 0x32, 0xc8, 0x6f, 0x60, 0x6f, 0x1f, 0x36, 0xf2, 0x49, 0xc9, 0xdd, 0x73, 0xdf, 0x74,
+	0xdc, 0x87, 0x28, 0x45, 0x78, 0x12, 0x00, 0x94, 0xf3, 0x44, 0x4e, 0x1f, 0x98, 0xd4, 0xce, 0xd9,
+	0x6e, 0xe7, 0x3a, 0x14, 0x83, 0xe7, 0x7b, 0x86, 0x82, 0x78, 0xfb, 0x6b, 0xd9, 0x9c, 0xc6, 0xce,
+	0xab, 0xff, 0xc1, 0x5c, 0x67, 0xbc, 0xdc, 0x1c, 0x8e, 0xb3, 0xdd, 0xba, 0xe4, 0x91, 0x5f, 0xec,
+	0xf1, 0x16, 0xd1, 0xfa, 0x63, 0x38, 0xc9, 0x65, 0x0b, 0xd2, 0x39, 0xaa, 0x5c, 0xe4, 0x07, 0xa8,
+	0xab, 0x47, 0x19, 0x39, 0x79, 0x56, 0xae, 0xae, 0x3e, 0xe8, 0x9d, 0x0a, 0xbe, 0xf1, 0x7c, 0xd7,
+	0x3c, 0x63, 0x5a, 0xd7, 0xff, 0x06, 0xbe, 0xf9, 0x6f, 0x00, 0x2f, 0xd8, 0x81, 0xf6, 0x1b, 0x08,
+	0x00, 0x00,
 }
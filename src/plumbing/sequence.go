@@ -0,0 +1,37 @@
+package plumbing
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Metron stamps these tags on every envelope it forwards over gRPC so that
+// Doppler can detect envelopes lost in transit. Doppler removes them on
+// ingress.
+//
+// Each of a Metron's connections numbers its envelopes with a single
+// sequence that continues across reconnects, and numbers the streams it
+// opens. When a connection's next stream reaches the same Doppler as its
+// last, a gap in the sequence is the envelopes lost when the last stream
+// broke.
+const (
+	SequenceTag = "metron_sequence"
+	StreamTag   = "metron_stream"
+	SourceTag   = "metron_source"
+)
+
+// MetronSource identifies one of a Metron's connections by the deployment,
+// job and index the Metron is running on and the connection's index.
+func MetronSource(deployment, job, index string, conn int) string {
+	return strings.Join([]string{deployment, job, index, strconv.Itoa(conn)}, "/")
+}
+
+// ParseMetronSource splits a value created by MetronSource into the
+// Metron's deployment, job and index.
+func ParseMetronSource(source string) (deployment, job, index string) {
+	parts := strings.SplitN(source, "/", 4)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2]
+}
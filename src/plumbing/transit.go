@@ -0,0 +1,52 @@
+package plumbing
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+)
+
+// MetronEgressTag carries the time, in nanoseconds since the epoch, that
+// Metron wrote the envelope to Doppler. Doppler uses it to measure how long
+// envelopes take to arrive and removes it before routing the envelope.
+const MetronEgressTag = "metron_egress_timestamp"
+
+// envelopeTagsField is the field number of the tags map in the dropsonde
+// Envelope message.
+const envelopeTagsField = 17
+
+// Timestamp formats t as the value of a transit tag.
+func Timestamp(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Since returns the time elapsed since the timestamp held in a transit tag.
+func Since(timestamp string) (time.Duration, bool) {
+	ns, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, ns)), true
+}
+
+// AppendTag adds a tag to a marshalled dropsonde Envelope. Protobuf merges
+// repeated occurrences of a map field, so this avoids unmarshalling and
+// re-marshalling the envelope. The given slice may be modified.
+func AppendTag(data []byte, key, value string) []byte {
+	var entry []byte
+	entry = appendBytesField(entry, 1, key)
+	entry = appendBytesField(entry, 2, value)
+	return appendBytesField(data, envelopeTagsField, string(entry))
+}
+
+func appendBytesField(data []byte, field uint64, value string) []byte {
+	data = appendVarint(data, field<<3|2)
+	data = appendVarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendVarint(data []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(data, buf[:n]...)
+}
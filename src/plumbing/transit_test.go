@@ -0,0 +1,63 @@
+package plumbing_test
+
+import (
+	"plumbing"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transit", func() {
+	var envelope *events.Envelope
+
+	BeforeEach(func() {
+		envelope = &events.Envelope{
+			Origin:    proto.String("some-origin"),
+			EventType: events.Envelope_LogMessage.Enum(),
+			Timestamp: proto.Int64(1234),
+			LogMessage: &events.LogMessage{
+				Message:     []byte("some-message"),
+				MessageType: events.LogMessage_OUT.Enum(),
+				Timestamp:   proto.Int64(1234),
+				AppId:       proto.String("some-app-id"),
+			},
+			Tags: map[string]string{
+				"some-tag": "some-value",
+			},
+		}
+	})
+
+	Describe("AppendTag", func() {
+		It("adds the tag to the marshalled envelope", func() {
+			data, err := proto.Marshal(envelope)
+			Expect(err).ToNot(HaveOccurred())
+
+			data = plumbing.AppendTag(data, "other-tag", "other-value")
+
+			var result events.Envelope
+			Expect(proto.Unmarshal(data, &result)).To(Succeed())
+			Expect(result.GetTags()).To(Equal(map[string]string{
+				"some-tag":  "some-value",
+				"other-tag": "other-value",
+			}))
+			Expect(result.GetLogMessage()).To(Equal(envelope.GetLogMessage()))
+		})
+	})
+
+	Describe("Since", func() {
+		It("returns the time elapsed since the timestamp", func() {
+			elapsed, ok := plumbing.Since(plumbing.Timestamp(time.Now().Add(-time.Second)))
+			Expect(ok).To(BeTrue())
+			Expect(elapsed).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+		})
+
+		It("rejects invalid timestamps", func() {
+			_, ok := plumbing.Since("not-a-timestamp")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
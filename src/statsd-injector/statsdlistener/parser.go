@@ -0,0 +1,120 @@
+package statsdlistener
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Parser turns statsd lines into dropsonde ValueMetric envelopes. It is not
+// safe for concurrent use.
+type Parser struct {
+	gaugeValues   map[string]float64 // key is "origin.name"
+	counterValues map[string]float64 // key is "origin.name"
+}
+
+func NewParser() *Parser {
+	return &Parser{
+		gaugeValues:   make(map[string]float64),
+		counterValues: make(map[string]float64),
+	}
+}
+
+var statsdRegexp = regexp.MustCompile(`([^.]+)\.([^:]+):([+-]?)(\d+(\.\d+)?)\|(ms|g|c)(\|@(\d+(\.\d+)?))?`)
+
+// Parse converts a single statsd line into a ValueMetric envelope. Gauge and
+// counter values are accumulated across calls, keyed by origin and name.
+func (p *Parser) Parse(data string) (*events.Envelope, error) {
+	parts := statsdRegexp.FindStringSubmatch(data)
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("Input line '%s' was not a valid statsd line.", data)
+	}
+
+	// parts[0] is complete matched string
+	origin := parts[1]
+	name := parts[2]
+	incrementSign := parts[3]
+	valueString := parts[4]
+	// parts[5] is the decimal part of valueString
+	statType := parts[6]
+	// parts[7] is the full sampling substring
+	sampleRateString := parts[8]
+	// parts[9] is decimal part of sampleRate
+
+	value, _ := strconv.ParseFloat(valueString, 64)
+
+	var sampleRate float64
+	if len(sampleRateString) != 0 {
+		sampleRate, _ = strconv.ParseFloat(sampleRateString, 64)
+	} else {
+		sampleRate = 1
+	}
+
+	value = value / sampleRate
+
+	var unit string
+	switch statType {
+	case "ms":
+		unit = "ms"
+	case "c":
+		unit = "counter"
+		value = p.counterValue(origin, name, value, incrementSign)
+	default:
+		unit = "gauge"
+		value = p.gaugeValue(origin, name, value, incrementSign)
+	}
+
+	env := &events.Envelope{
+		Origin:    &origin,
+		Timestamp: proto.Int64(time.Now().UnixNano()),
+		EventType: events.Envelope_ValueMetric.Enum(),
+
+		ValueMetric: &events.ValueMetric{
+			Name:  &name,
+			Value: &value,
+			Unit:  &unit,
+		},
+	}
+
+	return env, nil
+}
+
+func (p *Parser) counterValue(origin string, name string, value float64, incrementSign string) float64 {
+	key := fmt.Sprintf("%s.%s", origin, name)
+	oldVal := p.counterValues[key]
+	var newVal float64
+
+	switch incrementSign {
+	case "-":
+		newVal = oldVal - value
+	default:
+		newVal = oldVal + value
+	}
+
+	p.counterValues[key] = newVal
+	return newVal
+}
+
+func (p *Parser) gaugeValue(origin string, name string, value float64, incrementSign string) float64 {
+
+	key := fmt.Sprintf("%s.%s", origin, name)
+	oldVal := p.gaugeValues[key]
+	var newVal float64
+
+	switch incrementSign {
+	case "+":
+		newVal = oldVal + value
+	case "-":
+		newVal = oldVal - value
+	default:
+		newVal = value
+	}
+
+	p.gaugeValues[key] = newVal
+	return newVal
+}
@@ -0,0 +1,47 @@
+package statsdlistener_test
+
+import (
+	"statsd-injector/statsdlistener"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parser", func() {
+	var parser *statsdlistener.Parser
+
+	BeforeEach(func() {
+		parser = statsdlistener.NewParser()
+	})
+
+	It("parses a gauge", func() {
+		envelope, err := parser.Parse("fake-origin.test.gauge:23|g")
+		Expect(err).ToNot(HaveOccurred())
+
+		checkValueMetric(envelope, "fake-origin", "test.gauge", 23, "gauge")
+	})
+
+	It("scales values by the sample rate", func() {
+		envelope, err := parser.Parse("fake-origin.test.timing:71|ms|@0.1")
+		Expect(err).ToNot(HaveOccurred())
+
+		checkValueMetric(envelope, "fake-origin", "test.timing", 710, "ms")
+	})
+
+	It("accumulates counters per origin and name", func() {
+		_, err := parser.Parse("fake-origin.test.counter:23|c")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = parser.Parse("other-origin.test.counter:100|c")
+		Expect(err).ToNot(HaveOccurred())
+
+		envelope, err := parser.Parse("fake-origin.test.counter:-5|c")
+		Expect(err).ToNot(HaveOccurred())
+
+		checkValueMetric(envelope, "fake-origin", "test.counter", 18, "counter")
+	})
+
+	It("returns an error for an invalid line", func() {
+		_, err := parser.Parse("not-a-stat")
+		Expect(err).To(HaveOccurred())
+	})
+})
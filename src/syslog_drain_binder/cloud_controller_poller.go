@@ -34,15 +34,15 @@ func Timeout(t time.Duration) func(*PollOptions) {
 	}
 }
 
-// Poll gets all the app's syslog drain urls from the cloud controller.
+// Poll gets all the app's syslog drain bindings from the cloud controller.
 func Poll(
 	urlBase string,
 	username string,
 	password string,
 	batchSize int,
 	options ...func(*PollOptions),
-) (map[shared_types.AppID][]shared_types.DrainURL, error) {
-	drainURLs := make(map[shared_types.AppID][]shared_types.DrainURL)
+) (map[shared_types.AppID][]shared_types.DrainBinding, error) {
+	drainBindings := make(map[shared_types.AppID][]shared_types.DrainBinding)
 	nextID := 0
 
 	opts := PollOptions{
@@ -71,11 +71,11 @@ func Poll(
 
 		ccResponse, err := pollAndDecode(client, request)
 		if err != nil {
-			return drainURLs, err
+			return drainBindings, err
 		}
 
-		for appID, urls := range ccResponse.Results {
-			drainURLs[appID] = urls
+		for appID, bindings := range ccResponse.Results {
+			drainBindings[appID] = bindings
 		}
 
 		if ccResponse.NextID == nil {
@@ -84,7 +84,7 @@ func Poll(
 		nextID = *ccResponse.NextID
 	}
 
-	return drainURLs, nil
+	return drainBindings, nil
 }
 
 func pollAndDecode(client *http.Client, request *http.Request) (*cloudControllerResponse, error) {
@@ -107,8 +107,8 @@ func pollAndDecode(client *http.Client, request *http.Request) (*cloudController
 }
 
 type cloudControllerResponse struct {
-	Results map[shared_types.AppID][]shared_types.DrainURL `json:"results"`
-	NextID  *int                                           `json:"next_id"`
+	Results map[shared_types.AppID][]shared_types.DrainBinding `json:"results"`
+	NextID  *int                                               `json:"next_id"`
 }
 
 func buildUrl(baseURL string, batchSize int, nextID int) string {
@@ -56,7 +56,7 @@ var _ = Describe("CloudControllerPoller", func() {
 			Expect(fakeCloudController.requestCount).To(Equal(6))
 
 			for _, entry := range appDrains {
-				Expect(drainUrls).To(HaveKeyWithValue(entry.appID, entry.urls))
+				Expect(drainUrls).To(HaveKeyWithValue(entry.appID, bindings(entry.urls)))
 			}
 		})
 
@@ -67,10 +67,33 @@ var _ = Describe("CloudControllerPoller", func() {
 			Expect(fakeCloudController.requestCount).To(Equal(5))
 
 			for _, entry := range appDrains {
-				Expect(drainUrls).To(HaveKeyWithValue(entry.appID, entry.urls))
+				Expect(drainUrls).To(HaveKeyWithValue(entry.appID, bindings(entry.urls)))
 			}
 		})
 
+		It("reads the TLS credentials of drain bindings", func() {
+			ccServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{
+					"results": {
+						"app0": [
+							"syslog://drain.example.com",
+							{"url": "syslog-tls://drain.example.com", "cert": "some-cert", "key": "some-key", "ca": "some-ca"}
+						]
+					},
+					"next_id": null
+				}`))
+			}))
+			defer ccServer.Close()
+
+			drainBindings, err := syslog_drain_binder.Poll(ccServer.URL, "user", "pass", 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(drainBindings).To(HaveKeyWithValue(shared_types.AppID("app0"), []shared_types.DrainBinding{
+				{URL: "syslog://drain.example.com"},
+				{URL: "syslog-tls://drain.example.com", Cert: "some-cert", Key: "some-key", CA: "some-ca"},
+			}))
+		})
+
 		Context("when CC becomes unreachable before finishing", func() {
 			BeforeEach(func() {
 				fakeCloudController.failOn = 4
@@ -84,12 +107,12 @@ var _ = Describe("CloudControllerPoller", func() {
 
 				for i := 0; i < 8; i++ {
 					entry := appDrains[i]
-					Expect(drainUrls).To(HaveKeyWithValue(entry.appID, entry.urls))
+					Expect(drainUrls).To(HaveKeyWithValue(entry.appID, bindings(entry.urls)))
 				}
 
 				for i := 8; i < 10; i++ {
 					entry := appDrains[i]
-					Expect(drainUrls).NotTo(HaveKeyWithValue(entry.appID, entry.urls))
+					Expect(drainUrls).NotTo(HaveKeyWithValue(entry.appID, bindings(entry.urls)))
 				}
 			})
 		})
@@ -177,6 +200,14 @@ type appEntry struct {
 	urls  []shared_types.DrainURL
 }
 
+func bindings(urls []shared_types.DrainURL) []shared_types.DrainBinding {
+	var drainBindings []shared_types.DrainBinding
+	for _, url := range urls {
+		drainBindings = append(drainBindings, shared_types.DrainBinding{URL: url})
+	}
+	return drainBindings
+}
+
 var appDrains = []appEntry{
 	{appID: "app0", urls: []shared_types.DrainURL{"urlA"}},
 	{appID: "app1", urls: []shared_types.DrainURL{"urlB"}},
@@ -2,6 +2,7 @@ package etcd_syslog_drain_store
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -24,10 +25,10 @@ func NewEtcdSyslogDrainStore(storeAdapter storeadapter.StoreAdapter, ttl time.Du
 	}
 }
 
-func (store *EtcdSyslogDrainStore) UpdateDrains(appDrainUrlMap map[shared_types.AppID][]shared_types.DrainURL) error {
+func (store *EtcdSyslogDrainStore) UpdateDrains(appDrainBindingMap map[shared_types.AppID][]shared_types.DrainBinding) error {
 
-	for appId, drainUrls := range appDrainUrlMap {
-		err := store.updateAppDrains(appId, drainUrls)
+	for appId, drainBindings := range appDrainBindingMap {
+		err := store.updateAppDrains(appId, drainBindings)
 		if err != nil {
 			return err
 		}
@@ -36,24 +37,35 @@ func (store *EtcdSyslogDrainStore) UpdateDrains(appDrainUrlMap map[shared_types.
 	return nil
 }
 
-func (store *EtcdSyslogDrainStore) updateAppDrains(appId shared_types.AppID, drainUrls []shared_types.DrainURL) error {
+// updateAppDrains writes the app's drain bindings and deletes the ones it no
+// longer has, such as a binding superseded by one with rotated credentials.
+// Otherwise a Doppler that starts before the superseded binding expires may
+// connect with either of them.
+func (store *EtcdSyslogDrainStore) updateAppDrains(appId shared_types.AppID, drainBindings []shared_types.DrainBinding) error {
 	var nodes []storeadapter.StoreNode
+	keys := make(map[string]bool)
 
-	for _, drainUrl := range drainUrls {
+	for _, binding := range drainBindings {
 
-		if strings.TrimSpace(string(drainUrl)) == "" {
-			log.Printf("UpdateDrains: attempted to add whitespace-only drain url '%s' for app %s. Skipping.", drainUrl, appId)
+		if strings.TrimSpace(string(binding.URL)) == "" {
+			log.Printf("UpdateDrains: attempted to add whitespace-only drain url '%s' for app %s. Skipping.", binding.URL, appId)
 			continue
 		}
 
-		log.Printf("UpdateDrains: adding drain %s to app %s", drainUrl, appId)
+		value, err := drainValue(binding)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("UpdateDrains: adding drain %s to app %s", binding.URL, appId)
 		node := storeadapter.StoreNode{
-			Key:   drainKey(appId, drainUrl),
-			Value: []byte(drainUrl),
+			Key:   drainKey(appId, value),
+			Value: value,
 			TTL:   uint64(store.ttl.Seconds()),
 		}
 
 		nodes = append(nodes, node)
+		keys[node.Key] = true
 	}
 
 	if len(nodes) > 0 {
@@ -63,14 +75,49 @@ func (store *EtcdSyslogDrainStore) updateAppDrains(appId shared_types.AppID, dra
 		}
 	}
 
-	return nil
+	return store.deleteStaleDrains(appId, keys)
+}
+
+func (store *EtcdSyslogDrainStore) deleteStaleDrains(appId shared_types.AppID, keys map[string]bool) error {
+	appNode, err := store.storeAdapter.ListRecursively(appKey(appId))
+	if err != nil {
+		if storeErr, ok := err.(storeadapter.Error); ok && storeErr.Type() == storeadapter.ErrorKeyNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var stale []string
+	for _, node := range appNode.ChildNodes {
+		if !keys[node.Key] {
+			log.Printf("UpdateDrains: removing drain %s from app %s", node.Key, appId)
+			stale = append(stale, node.Key)
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+	return store.storeAdapter.Delete(stale...)
+}
+
+// drainValue is the bare drain URL for bindings without credentials, which
+// keeps them readable by Dopplers that predate drain credentials, and the
+// JSON encoded binding otherwise.
+func drainValue(binding shared_types.DrainBinding) ([]byte, error) {
+	if !binding.HasCredentials() {
+		return []byte(binding.URL), nil
+	}
+	return json.Marshal(binding)
 }
 
 func appKey(appId shared_types.AppID) string {
 	return fmt.Sprintf("/loggregator/services/%s", appId)
 }
 
-func drainKey(appId shared_types.AppID, drainUrl shared_types.DrainURL) string {
-	hash := sha1.Sum([]byte(drainUrl))
+// drainKey hashes the whole drain value, so that a binding whose credentials
+// change is stored under a new key and Doppler is notified of it.
+func drainKey(appId shared_types.AppID, drainValue []byte) string {
+	hash := sha1.Sum(drainValue)
 	return fmt.Sprintf("%s/%x", appKey(appId), hash)
 }
@@ -2,6 +2,7 @@ package etcd_syslog_drain_store_test
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"syslog_drain_binder/etcd_syslog_drain_store"
@@ -28,8 +29,8 @@ var _ = Describe("EtcdSyslogDrainStore", func() {
 
 	Describe("UpdateDrains", func() {
 		It("writes drain urls to the store adapter", func() {
-			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainURL{
-				"app-id": {"url1", "url2"},
+			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {{URL: "url1"}, {URL: "url2"}},
 			}
 
 			err := syslogDrainStore.UpdateDrains(appDrainUrlMap)
@@ -43,9 +44,71 @@ var _ = Describe("EtcdSyslogDrainStore", func() {
 			Expect(node.Value).To(BeEquivalentTo("url2"))
 		})
 
+		It("writes drain bindings with credentials as JSON", func() {
+			binding := shared_types.DrainBinding{
+				URL:  "syslog-tls://drain.example.com",
+				Cert: "some-cert",
+				Key:  "some-key",
+				CA:   "some-ca",
+			}
+			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {binding},
+			}
+
+			err := syslogDrainStore.UpdateDrains(appDrainUrlMap)
+			Expect(err).ToNot(HaveOccurred())
+
+			value, err := json.Marshal(binding)
+			Expect(err).ToNot(HaveOccurred())
+			node, err := fakeStoreAdapter.Get(drainKey("app-id", string(value)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node.Value).To(MatchJSON(`{
+				"url": "syslog-tls://drain.example.com",
+				"cert": "some-cert",
+				"key": "some-key",
+				"ca": "some-ca"
+			}`))
+		})
+
+		It("stores a binding under a new key when its credentials change", func() {
+			binding := shared_types.DrainBinding{URL: "syslog-tls://drain.example.com", CA: "some-ca"}
+			rotated := shared_types.DrainBinding{URL: "syslog-tls://drain.example.com", CA: "rotated-ca"}
+
+			err := syslogDrainStore.UpdateDrains(map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {binding, rotated},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeStoreAdapter.SetKeyCounters).To(HaveLen(2))
+		})
+
+		It("deletes the bindings an app no longer has", func() {
+			binding := shared_types.DrainBinding{URL: "syslog-tls://drain.example.com", CA: "some-ca"}
+			rotated := shared_types.DrainBinding{URL: "syslog-tls://drain.example.com", CA: "rotated-ca"}
+
+			err := syslogDrainStore.UpdateDrains(map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id":    {binding, {URL: "url1"}},
+				"other-app": {{URL: "url2"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = syslogDrainStore.UpdateDrains(map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {rotated},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			node, err := fakeStoreAdapter.ListRecursively("/loggregator/services/app-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node.ChildNodes).To(HaveLen(1))
+			Expect(node.ChildNodes[0].Value).To(MatchJSON(`{"url": "syslog-tls://drain.example.com", "ca": "rotated-ca"}`))
+
+			_, err = fakeStoreAdapter.Get(drainKey("other-app", "url2"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
 		It("sets TTL on the app node if there are drain changes", func() {
-			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainURL{
-				"app-id": {"url1"},
+			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {{URL: "url1"}},
 			}
 			syslogDrainStore.UpdateDrains(appDrainUrlMap)
 			node, _ := fakeStoreAdapter.Get(drainKey("app-id", "url1"))
@@ -55,16 +118,16 @@ var _ = Describe("EtcdSyslogDrainStore", func() {
 		It("returns an error if adapter.SetMulti fails", func() {
 			fakeError := errors.New("fake error")
 			fakeStoreAdapter.SetErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector(".*", fakeError)
-			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainURL{
-				"app-id": {"url1"},
+			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {{URL: "url1"}},
 			}
 			err := syslogDrainStore.UpdateDrains(appDrainUrlMap)
 			Expect(err).To(Equal(fakeError))
 		})
 
 		It("does not store drain nodes if they have an empty URL", func() {
-			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainURL{
-				"app-id": {" ", "", "\t"},
+			appDrainUrlMap := map[shared_types.AppID][]shared_types.DrainBinding{
+				"app-id": {{URL: " "}, {URL: ""}, {URL: "\t"}},
 			}
 			syslogDrainStore.UpdateDrains(appDrainUrlMap)
 			Expect(fakeStoreAdapter.SetKeyCounters).NotTo(HaveKey(drainKey("app-id", "")))
@@ -106,7 +169,7 @@ func appKey(appId shared_types.AppID) string {
 	return fmt.Sprintf("/loggregator/services/%s", appId)
 }
 
-func drainKey(appId shared_types.AppID, drainUrl shared_types.DrainURL) string {
-	hash := sha1.Sum([]byte(drainUrl))
+func drainKey(appId shared_types.AppID, drainValue string) string {
+	hash := sha1.Sum([]byte(drainValue))
 	return fmt.Sprintf("%s/%x", appKey(appId), hash)
 }
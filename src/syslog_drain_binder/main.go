@@ -83,7 +83,7 @@ func main() {
 				}
 			}
 
-			drainUrls, err := Poll(
+			drainBindings, err := Poll(
 				conf.CloudControllerAddress,
 				conf.BulkApiUsername,
 				conf.BulkApiPassword,
@@ -99,12 +99,12 @@ func main() {
 			metrics.IncrementCounter("pollCount")
 
 			var totalDrains int
-			for _, drainList := range drainUrls {
+			for _, drainList := range drainBindings {
 				totalDrains += len(drainList)
 			}
 
 			metrics.SendValue("totalDrains", float64(totalDrains), "drains")
-			err = store.UpdateDrains(drainUrls)
+			err = store.UpdateDrains(drainBindings)
 			if err != nil {
 				log.Printf("Error when updating ETCD: %s", err.Error())
 				politician.Vacate()
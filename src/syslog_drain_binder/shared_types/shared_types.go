@@ -1,7 +1,38 @@
 package shared_types
 
+import "encoding/json"
+
 // AppID represents an app ID.
 type AppID string
 
 // DrainURL represents a drain URL.
 type DrainURL string
+
+// DrainBinding is a drain URL together with the optional PEM encoded client
+// certificate, private key and CA Doppler uses to connect to syslog-tls and
+// https drains.
+type DrainBinding struct {
+	URL  DrainURL `json:"url"`
+	Cert string   `json:"cert,omitempty"`
+	Key  string   `json:"key,omitempty"`
+	CA   string   `json:"ca,omitempty"`
+}
+
+// HasCredentials reports whether the binding carries a client certificate
+// or CA.
+func (b DrainBinding) HasCredentials() bool {
+	return b.Cert != "" || b.Key != "" || b.CA != ""
+}
+
+// UnmarshalJSON accepts either a bare drain URL or an object holding the
+// URL and credentials.
+func (b *DrainBinding) UnmarshalJSON(data []byte) error {
+	var url DrainURL
+	if err := json.Unmarshal(data, &url); err == nil {
+		*b = DrainBinding{URL: url}
+		return nil
+	}
+
+	type drainBinding DrainBinding
+	return json.Unmarshal(data, (*drainBinding)(b))
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gorilla/mux"
+	"github.com/nu7hatch/gouuid"
 	"golang.org/x/net/context"
 )
 
@@ -42,7 +43,8 @@ type Proxy struct {
 type grpcConnector interface {
 	Subscribe(ctx context.Context, req *plumbing.SubscriptionRequest) (grpcconnector.Receiver, error)
 	ContainerMetrics(ctx context.Context, appID string) [][]byte
-	RecentLogs(ctx context.Context, appID string) [][]byte
+	RecentLogs(ctx context.Context, req *plumbing.RecentLogsRequest) [][]byte
+	DrainStatus(ctx context.Context, appID string) []*plumbing.DrainStatus
 }
 
 func NewDopplerProxy(
@@ -64,6 +66,7 @@ func NewDopplerProxy(
 	p.HandleFunc("/apps/{appID}/stream", p.stream)
 	p.HandleFunc("/apps/{appID}/recentlogs", p.recentlogs)
 	p.HandleFunc("/apps/{appID}/containermetrics", p.containermetrics)
+	p.HandleFunc("/apps/{appID}/drains", p.drains)
 	p.HandleFunc("/firehose/{subID}", p.firehose)
 	p.HandleFunc("/set-cookie", p.setcookie)
 
@@ -104,6 +107,11 @@ func (p *Proxy) containermetrics(w http.ResponseWriter, r *http.Request) {
 	sendLatencyMetric("containermetrics", time.Now())
 }
 
+func (p *Proxy) drains(w http.ResponseWriter, r *http.Request) {
+	p.serveAppLogs("drains", mux.Vars(r)["appID"], w, r)
+	sendLatencyMetric("drains", time.Now())
+}
+
 func (p *Proxy) setcookie(w http.ResponseWriter, r *http.Request) {
 	p.serveSetCookie(w, r, p.cookieDomain)
 }
@@ -119,11 +127,34 @@ func (p *Proxy) serveFirehose(firehoseSubscriptionId string, writer http.Respons
 		return
 	}
 
+	shardType, err := subscriptionShardType(request.URL.Query())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every Doppler must assign an app to the same nozzle, so the nozzle is
+	// identified by an ID sent to all of them rather than by its connection
+	// to each.
+	var memberID string
+	if shardType == plumbing.SubscriptionRequest_APP_AFFINITY {
+		id, err := uuid.NewV4()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			log.Printf("error occurred when generating firehose member id: %s", err)
+			return
+		}
+		memberID = id.String()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	client, err := p.grpcConn.Subscribe(ctx, &plumbing.SubscriptionRequest{
-		ShardID: firehoseSubscriptionId,
+		ShardID:   firehoseSubscriptionId,
+		ShardType: shardType,
+		MemberID:  memberID,
+		Filter:    subscriptionFilter("", request.URL.Query()),
 	})
 	if err != nil {
 		writer.WriteHeader(http.StatusServiceUnavailable)
@@ -134,7 +165,39 @@ func (p *Proxy) serveFirehose(firehoseSubscriptionId string, writer http.Respons
 	p.serveWS(FIREHOSE_ID, firehoseSubscriptionId, writer, request, client.Recv)
 }
 
-// "^/apps/(.*)/(recentlogs|stream|containermetrics)$"
+// subscriptionFilter builds the filter Doppler applies to a subscription from
+// the optional event_type, source_type and origin query parameters. It
+// returns nil when there is nothing to filter.
+func subscriptionFilter(appID string, query url.Values) *plumbing.Filter {
+	filter := &plumbing.Filter{
+		AppID:       appID,
+		EventTypes:  query["event_type"],
+		SourceTypes: query["source_type"],
+		Origins:     query["origin"],
+	}
+
+	if filter.AppID == "" && len(filter.EventTypes) == 0 && len(filter.SourceTypes) == 0 && len(filter.Origins) == 0 {
+		return nil
+	}
+
+	return filter
+}
+
+// subscriptionShardType returns how Doppler divides a firehose between the
+// nozzles sharing its subscription ID. The shard_type query parameter
+// "app_affinity" sends every envelope for an app to the same nozzle.
+func subscriptionShardType(query url.Values) (plumbing.SubscriptionRequest_ShardType, error) {
+	switch query.Get("shard_type") {
+	case "", "random":
+		return plumbing.SubscriptionRequest_RANDOM, nil
+	case "app_affinity":
+		return plumbing.SubscriptionRequest_APP_AFFINITY, nil
+	default:
+		return 0, fmt.Errorf("invalid shard_type: %s", query.Get("shard_type"))
+	}
+}
+
+// "^/apps/(.*)/(recentlogs|stream|containermetrics|drains)$"
 func (p *Proxy) serveAppLogs(requestPath, appID string, writer http.ResponseWriter, request *http.Request) {
 	authToken := getAuthToken(request)
 
@@ -160,14 +223,21 @@ func (p *Proxy) serveAppLogs(requestPath, appID string, writer http.ResponseWrit
 
 	switch requestPath {
 	case "recentlogs":
+		req, err := recentLogsRequest(appID, request.URL.Query())
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(err.Error()))
+			return
+		}
+
 		ctx, _ = context.WithDeadline(ctx, time.Now().Add(p.timeout))
-		resp := p.grpcConn.RecentLogs(ctx, appID)
+		resp := p.grpcConn.RecentLogs(ctx, req)
 		if err := ctx.Err(); err != nil {
 			writer.WriteHeader(http.StatusServiceUnavailable)
 			log.Printf("recentlogs request encountered an error: %s", err)
 			return
 		}
-		p.serveMultiPartResponse(writer, resp)
+		p.serveMultiPartResponse(writer, limitRecentLogs(resp, req.Limit))
 		return
 	case "containermetrics":
 		ctx, _ = context.WithDeadline(ctx, time.Now().Add(p.timeout))
@@ -179,11 +249,19 @@ func (p *Proxy) serveAppLogs(requestPath, appID string, writer http.ResponseWrit
 		}
 		p.serveMultiPartResponse(writer, resp)
 		return
+	case "drains":
+		ctx, _ = context.WithDeadline(ctx, time.Now().Add(p.timeout))
+		resp := p.grpcConn.DrainStatus(ctx, appID)
+		if err := ctx.Err(); err != nil {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			log.Printf("drains request encountered an error: %s", err)
+			return
+		}
+		serveDrainStatus(writer, resp)
+		return
 	case "stream":
 		client, err := p.grpcConn.Subscribe(ctx, &plumbing.SubscriptionRequest{
-			Filter: &plumbing.Filter{
-				AppID: appID,
-			},
+			Filter: subscriptionFilter(appID, request.URL.Query()),
 		})
 		if err != nil {
 			writer.WriteHeader(http.StatusServiceUnavailable)
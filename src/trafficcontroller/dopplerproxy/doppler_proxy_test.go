@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
@@ -320,6 +321,127 @@ var _ = Describe("ServeHTTP()", func() {
 				Expect(partBytes).To(Equal(payload))
 			}
 		})
+
+		It("forwards the recent logs filters", func() {
+			req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs?start_time=100&end_time=200&limit=10&source_type=RTR&source_type=APP&log_type=ERR", nil)
+			req.Header.Add("Authorization", "token")
+			mockGrpcConnector.RecentLogsOutput.Ret0 <- nil
+
+			proxy.ServeHTTP(recorder, req)
+
+			Expect(mockGrpcConnector.RecentLogsInput.Req).To(Receive(Equal(&plumbing.RecentLogsRequest{
+				AppID:       "abc123",
+				StartTime:   100,
+				EndTime:     200,
+				Limit:       10,
+				SourceTypes: []string{"RTR", "APP"},
+				LogTypes:    []plumbing.RecentLogsRequest_LogType{plumbing.RecentLogsRequest_ERR},
+			})))
+		})
+
+		It("rejects invalid recent logs filters", func() {
+			req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs?log_type=INFO", nil)
+			req.Header.Add("Authorization", "token")
+
+			proxy.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(mockGrpcConnector.RecentLogsCalled).ToNot(Receive())
+		})
+
+		It("limits the recent logs from every doppler to the most recent", func() {
+			req, _ := http.NewRequest("GET", "/apps/abc123/recentlogs?limit=2", nil)
+			req.Header.Add("Authorization", "token")
+			recentLogResp := [][]byte{
+				buildLogMessage(300),
+				buildLogMessage(100),
+				buildLogMessage(400),
+				buildLogMessage(200),
+			}
+			mockGrpcConnector.RecentLogsOutput.Ret0 <- recentLogResp
+
+			proxy.ServeHTTP(recorder, req)
+
+			boundaryRegexp := regexp.MustCompile("boundary=(.*)")
+			matches := boundaryRegexp.FindStringSubmatch(recorder.Header().Get("Content-Type"))
+			Expect(matches).To(HaveLen(2))
+			reader := multipart.NewReader(recorder.Body, matches[1])
+
+			for _, payload := range [][]byte{recentLogResp[0], recentLogResp[2]} {
+				part, err := reader.NextPart()
+				Expect(err).ToNot(HaveOccurred())
+
+				partBytes, err := ioutil.ReadAll(part)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(partBytes).To(Equal(payload))
+			}
+			_, err := reader.NextPart()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("returns the status of the app's drains", func() {
+			req, _ := http.NewRequest("GET", "/apps/abc123/drains", nil)
+			req.Header.Add("Authorization", "token")
+			lastSuccess := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+			mockGrpcConnector.DrainStatusOutput.Ret0 <- []*plumbing.DrainStatus{
+				{
+					Url:          "syslog://drain-b.example.com:514",
+					DrainID:      "drain-b",
+					State:        plumbing.DrainStatus_BACKING_OFF,
+					LastError:    "connection refused",
+					LastSuccess:  lastSuccess.UnixNano(),
+					SentMessages: 10,
+					Errors:       2,
+				},
+				{
+					Url:     "syslog://drain-a.example.com:514",
+					DrainID: "drain-a",
+					State:   plumbing.DrainStatus_CONNECTING,
+				},
+			}
+
+			proxy.ServeHTTP(recorder, req)
+
+			Expect(mockGrpcConnector.DrainStatusInput.AppID).To(Receive(Equal("abc123")))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"drains": [
+					{
+						"url": "syslog://drain-a.example.com:514",
+						"drain_id": "drain-a",
+						"state": "connecting",
+						"sent_messages": 0,
+						"dropped_messages": 0,
+						"errors": 0,
+						"reconnects": 0
+					},
+					{
+						"url": "syslog://drain-b.example.com:514",
+						"drain_id": "drain-b",
+						"state": "backing_off",
+						"last_error": "connection refused",
+						"last_success": "2017-01-02T03:04:05Z",
+						"sent_messages": 10,
+						"dropped_messages": 0,
+						"errors": 2,
+						"reconnects": 0
+					}
+				]
+			}`))
+		})
+
+		It("does not return drain status for an unauthorized app", func() {
+			auth.Result = AuthorizerResult{Status: http.StatusForbidden, ErrorMessage: http.StatusText(http.StatusForbidden)}
+
+			req, _ := http.NewRequest("GET", "/apps/abc123/drains", nil)
+			req.Header.Add("Authorization", "token")
+
+			proxy.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(mockGrpcConnector.DrainStatusCalled).ToNot(Receive())
+		})
 	})
 
 	Context("Firehose", func() {
@@ -336,6 +458,46 @@ var _ = Describe("ServeHTTP()", func() {
 				Eventually(mockGrpcConnector.SubscribeInput.Req).Should(BeCalled(With(expectedRequest)))
 			})
 
+			It("forwards the subscription filters to doppler", func() {
+				req, _ := http.NewRequest("GET", "/firehose/abc-123?event_type=LogMessage&source_type=RTR&origin=gorouter", nil)
+				req.Header.Add("Authorization", "token")
+
+				proxy.ServeHTTP(recorder, req)
+
+				expectedRequest := &plumbing.SubscriptionRequest{
+					ShardID: "abc-123",
+					Filter: &plumbing.Filter{
+						EventTypes:  []string{"LogMessage"},
+						SourceTypes: []string{"RTR"},
+						Origins:     []string{"gorouter"},
+					},
+				}
+				Eventually(mockGrpcConnector.SubscribeInput.Req).Should(BeCalled(With(expectedRequest)))
+			})
+
+			It("requests app affinity sharding from doppler with a member id for the nozzle", func() {
+				req, _ := http.NewRequest("GET", "/firehose/abc-123?shard_type=app_affinity", nil)
+				req.Header.Add("Authorization", "token")
+
+				proxy.ServeHTTP(recorder, req)
+
+				var subscriptionRequest *plumbing.SubscriptionRequest
+				Eventually(mockGrpcConnector.SubscribeInput.Req).Should(Receive(&subscriptionRequest))
+				Expect(subscriptionRequest.ShardID).To(Equal("abc-123"))
+				Expect(subscriptionRequest.ShardType).To(Equal(plumbing.SubscriptionRequest_APP_AFFINITY))
+				Expect(subscriptionRequest.MemberID).ToNot(BeEmpty())
+			})
+
+			It("returns a bad request status for an invalid shard type", func() {
+				req, _ := http.NewRequest("GET", "/firehose/abc-123?shard_type=bogus", nil)
+				req.Header.Add("Authorization", "token")
+
+				proxy.ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(mockGrpcConnector.SubscribeCalled).ToNot(BeCalled())
+			})
+
 			It("returns an unauthorized status and sets the WWW-Authenticate header if authorization fails", func() {
 				adminAuth.Result = AuthorizerResult{Status: http.StatusUnauthorized, ErrorMessage: "Error: Invalid authorization"}
 
@@ -574,3 +736,19 @@ func buildContainerMetric(appID string, t time.Time) (*events.Envelope, []byte)
 	Expect(err).ToNot(HaveOccurred())
 	return envelope, data
 }
+
+func buildLogMessage(timestamp int64) []byte {
+	envelope := &events.Envelope{
+		Origin:    proto.String("doppler"),
+		EventType: events.Envelope_LogMessage.Enum(),
+		Timestamp: proto.Int64(timestamp),
+		LogMessage: &events.LogMessage{
+			Message:     []byte("some-log-message"),
+			MessageType: events.LogMessage_OUT.Enum(),
+			Timestamp:   proto.Int64(timestamp),
+		},
+	}
+	data, err := proto.Marshal(envelope)
+	Expect(err).ToNot(HaveOccurred())
+	return data
+}
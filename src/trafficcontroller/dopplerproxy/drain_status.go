@@ -0,0 +1,62 @@
+package dopplerproxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"plumbing"
+	"sort"
+	"strings"
+	"time"
+)
+
+type drainsResponse struct {
+	Drains []drainStatus `json:"drains"`
+}
+
+type drainStatus struct {
+	URL             string     `json:"url"`
+	DrainID         string     `json:"drain_id"`
+	State           string     `json:"state"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	SentMessages    uint64     `json:"sent_messages"`
+	DroppedMessages uint64     `json:"dropped_messages"`
+	Errors          uint64     `json:"errors"`
+	Reconnects      uint64     `json:"reconnects"`
+}
+
+type byDrainURL []drainStatus
+
+func (d byDrainURL) Len() int           { return len(d) }
+func (d byDrainURL) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDrainURL) Less(i, j int) bool { return d[i].URL < d[j].URL }
+
+// serveDrainStatus writes the status of an app's drains as JSON.
+func serveDrainStatus(rw http.ResponseWriter, drains []*plumbing.DrainStatus) {
+	resp := drainsResponse{Drains: []drainStatus{}}
+	for _, d := range drains {
+		status := drainStatus{
+			URL:             d.Url,
+			DrainID:         d.DrainID,
+			State:           strings.ToLower(d.State.String()),
+			LastError:       d.LastError,
+			SentMessages:    d.SentMessages,
+			DroppedMessages: d.DroppedMessages,
+			Errors:          d.Errors,
+			Reconnects:      d.Reconnects,
+		}
+		if d.LastSuccess != 0 {
+			lastSuccess := time.Unix(0, d.LastSuccess).UTC()
+			status.LastSuccess = &lastSuccess
+		}
+		resp.Drains = append(resp.Drains, status)
+	}
+	sort.Sort(byDrainURL(resp.Drains))
+
+	rw.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(rw).Encode(resp)
+	if err != nil {
+		log.Printf("http handler: failed to write drain status: %s", err)
+	}
+}
@@ -33,12 +33,20 @@ type mockGrpcConnector struct {
 	}
 	RecentLogsCalled chan bool
 	RecentLogsInput  struct {
-		Ctx   chan context.Context
-		AppID chan string
+		Ctx chan context.Context
+		Req chan *plumbing.RecentLogsRequest
 	}
 	RecentLogsOutput struct {
 		Ret0 chan [][]byte
 	}
+	DrainStatusCalled chan bool
+	DrainStatusInput  struct {
+		Ctx   chan context.Context
+		AppID chan string
+	}
+	DrainStatusOutput struct {
+		Ret0 chan []*plumbing.DrainStatus
+	}
 }
 
 func newMockGrpcConnector() *mockGrpcConnector {
@@ -54,8 +62,12 @@ func newMockGrpcConnector() *mockGrpcConnector {
 	m.ContainerMetricsOutput.Ret0 = make(chan [][]byte, 100)
 	m.RecentLogsCalled = make(chan bool, 100)
 	m.RecentLogsInput.Ctx = make(chan context.Context, 100)
-	m.RecentLogsInput.AppID = make(chan string, 100)
+	m.RecentLogsInput.Req = make(chan *plumbing.RecentLogsRequest, 100)
 	m.RecentLogsOutput.Ret0 = make(chan [][]byte, 100)
+	m.DrainStatusCalled = make(chan bool, 100)
+	m.DrainStatusInput.Ctx = make(chan context.Context, 100)
+	m.DrainStatusInput.AppID = make(chan string, 100)
+	m.DrainStatusOutput.Ret0 = make(chan []*plumbing.DrainStatus, 100)
 	return m
 }
 func (m *mockGrpcConnector) Subscribe(ctx context.Context, req *plumbing.SubscriptionRequest) (grpcconnector.Receiver, error) {
@@ -70,12 +82,18 @@ func (m *mockGrpcConnector) ContainerMetrics(ctx context.Context, appID string)
 	m.ContainerMetricsInput.AppID <- appID
 	return <-m.ContainerMetricsOutput.Ret0
 }
-func (m *mockGrpcConnector) RecentLogs(ctx context.Context, appID string) [][]byte {
+func (m *mockGrpcConnector) RecentLogs(ctx context.Context, req *plumbing.RecentLogsRequest) [][]byte {
 	m.RecentLogsCalled <- true
 	m.RecentLogsInput.Ctx <- ctx
-	m.RecentLogsInput.AppID <- appID
+	m.RecentLogsInput.Req <- req
 	return <-m.RecentLogsOutput.Ret0
 }
+func (m *mockGrpcConnector) DrainStatus(ctx context.Context, appID string) []*plumbing.DrainStatus {
+	m.DrainStatusCalled <- true
+	m.DrainStatusInput.Ctx <- ctx
+	m.DrainStatusInput.AppID <- appID
+	return <-m.DrainStatusOutput.Ret0
+}
 
 type mockReceiver struct {
 	RecvCalled chan bool
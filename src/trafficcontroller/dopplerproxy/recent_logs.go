@@ -0,0 +1,104 @@
+package dopplerproxy
+
+import (
+	"fmt"
+	"net/url"
+	"plumbing"
+	"sort"
+	"strconv"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+// recentLogsRequest builds the request sent to each Doppler from the
+// optional start_time, end_time, limit, source_type and log_type query
+// parameters. Times are in nanoseconds since the epoch.
+func recentLogsRequest(appID string, query url.Values) (*plumbing.RecentLogsRequest, error) {
+	req := &plumbing.RecentLogsRequest{
+		AppID:       appID,
+		SourceTypes: query["source_type"],
+	}
+
+	var err error
+	if req.StartTime, err = parseInt64(query, "start_time"); err != nil {
+		return nil, err
+	}
+	if req.EndTime, err = parseInt64(query, "end_time"); err != nil {
+		return nil, err
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit: %s", v)
+		}
+		req.Limit = uint32(limit)
+	}
+
+	for _, v := range query["log_type"] {
+		logType, ok := plumbing.RecentLogsRequest_LogType_value[v]
+		if !ok {
+			return nil, fmt.Errorf("invalid log_type: %s", v)
+		}
+		req.LogTypes = append(req.LogTypes, plumbing.RecentLogsRequest_LogType(logType))
+	}
+
+	return req, nil
+}
+
+func parseInt64(query url.Values, name string) (int64, error) {
+	v := query.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, v)
+	}
+	return i, nil
+}
+
+// limitRecentLogs keeps the most recent logs across all Dopplers. Each
+// Doppler applies the limit to its own logs, so the combined response can
+// hold up to limit logs per Doppler.
+func limitRecentLogs(messages [][]byte, limit uint32) [][]byte {
+	if limit == 0 || len(messages) <= int(limit) {
+		return messages
+	}
+
+	logs := make([]timestampedLog, 0, len(messages))
+	for _, message := range messages {
+		var envelope events.Envelope
+		if err := proto.Unmarshal(message, &envelope); err != nil {
+			continue
+		}
+		logs = append(logs, timestampedLog{
+			timestamp: envelope.GetLogMessage().GetTimestamp(),
+			data:      message,
+		})
+	}
+	sort.Stable(byTimestamp(logs))
+
+	if len(logs) > int(limit) {
+		logs = logs[len(logs)-int(limit):]
+	}
+
+	limited := make([][]byte, 0, len(logs))
+	for _, l := range logs {
+		limited = append(limited, l.data)
+	}
+	return limited
+}
+
+type timestampedLog struct {
+	timestamp int64
+	data      []byte
+}
+
+type byTimestamp []timestampedLog
+
+func (l byTimestamp) Len() int           { return len(l) }
+func (l byTimestamp) Less(i, j int) bool { return l[i].timestamp < l[j].timestamp }
+func (l byTimestamp) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -26,6 +26,7 @@ type DopplerPool interface {
 	Subscribe(dopplerAddr string, ctx context.Context, req *plumbing.SubscriptionRequest) (plumbing.Doppler_SubscribeClient, error)
 	ContainerMetrics(dopplerAddr string, ctx context.Context, req *plumbing.ContainerMetricsRequest) (*plumbing.ContainerMetricsResponse, error)
 	RecentLogs(dopplerAddr string, ctx context.Context, req *plumbing.RecentLogsRequest) (*plumbing.RecentLogsResponse, error)
+	DrainStatus(dopplerAddr string, ctx context.Context, req *plumbing.DrainStatusRequest) (*plumbing.DrainStatusResponse, error)
 
 	Close(dopplerAddr string)
 }
@@ -40,6 +41,12 @@ type MetaMetricBatcher interface {
 	BatchAddCounter(name string, delta uint64)
 }
 
+// LatencyRecorder records the time envelopes take to travel from Doppler to
+// a subscriber.
+type LatencyRecorder interface {
+	Record(time.Duration)
+}
+
 // Receiver yeilds messages from a pool of Dopplers.
 type Receiver interface {
 	Recv() ([]byte, error)
@@ -56,15 +63,18 @@ type GRPCConnector struct {
 	consumerStates []unsafe.Pointer
 	bufferSize     int
 	batcher        MetaMetricBatcher
+	latency        LatencyRecorder
 }
 
-// New creates a new GRPCConnector.
-func New(bufferSize int, pool DopplerPool, f Finder, batcher MetaMetricBatcher) *GRPCConnector {
+// New creates a new GRPCConnector. The latency recorder is given the time
+// since Doppler sent each envelope a subscriber receives.
+func New(bufferSize int, pool DopplerPool, f Finder, batcher MetaMetricBatcher, latency LatencyRecorder) *GRPCConnector {
 	c := &GRPCConnector{
 		bufferSize:     bufferSize,
 		pool:           pool,
 		finder:         f,
 		batcher:        batcher,
+		latency:        latency,
 		consumerStates: make([]unsafe.Pointer, maxConnections),
 	}
 	go c.readFinder()
@@ -92,16 +102,14 @@ func (c *GRPCConnector) ContainerMetrics(ctx context.Context, appID string) [][]
 	return resp
 }
 
-// RecentLogs returns the current recent logs for an app ID.
-func (c *GRPCConnector) RecentLogs(ctx context.Context, appID string) [][]byte {
+// RecentLogs returns the current recent logs that match the request from
+// every Doppler.
+func (c *GRPCConnector) RecentLogs(ctx context.Context, req *plumbing.RecentLogsRequest) [][]byte {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	var resp [][]byte
 	for _, client := range c.clients {
-		req := &plumbing.RecentLogsRequest{
-			AppID: appID,
-		}
 		nextResp, err := c.pool.RecentLogs(client.uri, ctx, req)
 		if err != nil {
 			log.Printf("error from doppler (%s) while fetching recent logs: %s", client.uri, err)
@@ -113,14 +121,74 @@ func (c *GRPCConnector) RecentLogs(ctx context.Context, appID string) [][]byte {
 	return resp
 }
 
+// DrainStatus returns the status of an app's syslog drains. Every Doppler
+// writes to each of the app's drains, so the statuses are merged by drain
+// ID: counters are summed and the drain takes the worst state and the most
+// recent success reported by any Doppler.
+func (c *GRPCConnector) DrainStatus(ctx context.Context, appID string) []*plumbing.DrainStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var drains []*plumbing.DrainStatus
+	merged := make(map[string]*plumbing.DrainStatus)
+	for _, client := range c.clients {
+		req := &plumbing.DrainStatusRequest{
+			AppID: appID,
+		}
+		nextResp, err := c.pool.DrainStatus(client.uri, ctx, req)
+		if err != nil {
+			log.Printf("error from doppler (%s) while fetching drain status: %s", client.uri, err)
+			continue
+		}
+
+		for _, drain := range nextResp.Drains {
+			existing, ok := merged[drain.DrainID]
+			if !ok {
+				merged[drain.DrainID] = drain
+				drains = append(drains, drain)
+				continue
+			}
+
+			mergeDrainStatus(existing, drain)
+		}
+	}
+	return drains
+}
+
+func mergeDrainStatus(dst, src *plumbing.DrainStatus) {
+	if drainStateRank(src.State) > drainStateRank(dst.State) {
+		dst.State = src.State
+		dst.LastError = src.LastError
+	}
+	if src.LastSuccess > dst.LastSuccess {
+		dst.LastSuccess = src.LastSuccess
+	}
+	dst.SentMessages += src.SentMessages
+	dst.DroppedMessages += src.DroppedMessages
+	dst.Errors += src.Errors
+	dst.Reconnects += src.Reconnects
+}
+
+func drainStateRank(state plumbing.DrainStatus_State) int {
+	switch state {
+	case plumbing.DrainStatus_BACKING_OFF:
+		return 2
+	case plumbing.DrainStatus_CONNECTING:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // Subscribe returns a Receiver that yields all corresponding messages from Doppler
 func (c *GRPCConnector) Subscribe(ctx context.Context, req *plumbing.SubscriptionRequest) (Receiver, error) {
 	cs := &consumerState{
-		data:     make(chan []byte, c.bufferSize),
+		data:     make(chan *plumbing.Response, c.bufferSize),
 		errs:     make(chan error, 1),
 		ctx:      ctx,
 		req:      req,
 		batcher:  c.batcher,
+		latency:  c.latency,
 		dopplers: make(map[string]bool),
 	}
 
@@ -292,7 +360,7 @@ func readStream(s plumbingReceiver, cs *consumerState, batcher MetaMetricBatcher
 
 		timer.Reset(time.Second)
 		select {
-		case cs.data <- resp.Payload:
+		case cs.data <- resp:
 			if !timer.Stop() {
 				<-timer.C
 			}
@@ -335,11 +403,12 @@ type dopplerClientInfo struct {
 type consumerState struct {
 	ctx       context.Context
 	req       *plumbing.SubscriptionRequest
-	data      chan []byte
+	data      chan *plumbing.Response
 	errs      chan error
 	missed    int
 	maxMissed int
 	batcher   MetaMetricBatcher
+	latency   LatencyRecorder
 	dead      int64
 
 	mu       sync.Mutex
@@ -350,13 +419,23 @@ func (cs *consumerState) Recv() ([]byte, error) {
 	select {
 	case err := <-cs.errs:
 		return nil, err
-	case data := <-cs.data:
-		return data, nil
+	case resp := <-cs.data:
+		cs.recordLatency(resp)
+		return resp.Payload, nil
 	case <-cs.ctx.Done():
 		return nil, cs.ctx.Err()
 	}
 }
 
+// recordLatency records the time since Doppler sent the envelope, which is
+// when the subscriber receives it.
+func (cs *consumerState) recordLatency(resp *plumbing.Response) {
+	if resp.EgressTimestamp == 0 {
+		return
+	}
+	cs.latency.Record(time.Since(time.Unix(0, resp.EgressTimestamp)))
+}
+
 func (cs *consumerState) tryAddDoppler(doppler string) bool {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
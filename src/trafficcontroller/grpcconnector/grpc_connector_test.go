@@ -31,6 +31,7 @@ var _ = Describe("GRPCConnector", func() {
 
 		mockBatcher *mockMetaMetricBatcher
 		mockChainer *mockBatchCounterChainer
+		mockLatency *mockLatencyRecorder
 	)
 
 	BeforeEach(func() {
@@ -50,6 +51,7 @@ var _ = Describe("GRPCConnector", func() {
 
 		mockBatcher = newMockMetaMetricBatcher()
 		mockChainer = newMockBatchCounterChainer()
+		mockLatency = newMockLatencyRecorder()
 
 		lisA, serverA := startGRPCServer(mockDopplerServerA, ":0")
 		lisB, serverB := startGRPCServer(mockDopplerServerB, ":0")
@@ -63,7 +65,7 @@ var _ = Describe("GRPCConnector", func() {
 			},
 		}
 
-		connector = grpcconnector.New(5, pool, mockFinder, mockBatcher)
+		connector = grpcconnector.New(5, pool, mockFinder, mockBatcher, mockLatency)
 
 		testhelpers.AlwaysReturn(mockBatcher.BatchCounterOutput, mockChainer)
 		testhelpers.AlwaysReturn(mockChainer.SetTagOutput, mockChainer)
@@ -142,6 +144,20 @@ var _ = Describe("GRPCConnector", func() {
 					Eventually(mockChainer.IncrementCalled).Should(BeCalled())
 				})
 
+				It("records the time since Doppler sent the envelope", func() {
+					senderA := captureSubscribeSender(mockDopplerServerA)
+
+					senderA.Send(&plumbing.Response{
+						Payload:         []byte("some-data-a"),
+						EgressTimestamp: time.Now().Add(-time.Second).UnixNano(),
+					})
+					Eventually(data).Should(Receive())
+
+					var elapsed time.Duration
+					Eventually(mockLatency.RecordInput.Arg0).Should(Receive(&elapsed))
+					Expect(elapsed).To(BeNumerically("~", time.Second, 500*time.Millisecond))
+				})
+
 				It("does not close the doppler connection when a client exits", func() {
 					Eventually(mockDopplerServerA.SubscribeInput.Stream).Should(Receive())
 					cancelCtx()
@@ -490,12 +506,92 @@ var _ = Describe("GRPCConnector", func() {
 
 			It("can request recent logs", func() {
 				f := func() [][]byte {
-					return connector.RecentLogs(ctx, "test-app-id")
+					return connector.RecentLogs(ctx, &plumbing.RecentLogsRequest{AppID: "test-app-id"})
+				}
+				Eventually(f).Should(ConsistOf(testRecentLogA, testRecentLogB))
+			})
+
+			It("forwards the recent logs filters to each doppler", func() {
+				req := &plumbing.RecentLogsRequest{
+					AppID:       "test-app-id",
+					StartTime:   100,
+					Limit:       10,
+					SourceTypes: []string{"RTR"},
+				}
+				f := func() [][]byte {
+					return connector.RecentLogs(ctx, req)
 				}
 				Eventually(f).Should(ConsistOf(testRecentLogA, testRecentLogB))
+
+				Expect(mockDopplerServerA.RecentLogsInput.Req).To(Receive(Equal(req)))
+				Expect(mockDopplerServerB.RecentLogsInput.Req).To(Receive(Equal(req)))
 			})
 		})
 	})
+
+	Describe("DrainStatus()", func() {
+		BeforeEach(func() {
+			event := dopplerservice.Event{
+				GRPCDopplers: createGrpcURIs(listeners),
+			}
+			mockFinder.NextOutput.Ret0 <- event
+
+			testhelpers.AlwaysReturn(mockDopplerServerA.DrainStatusOutput.Resp, &plumbing.DrainStatusResponse{
+				Drains: []*plumbing.DrainStatus{
+					{
+						DrainID:      "drain-a",
+						State:        plumbing.DrainStatus_CONNECTED,
+						LastSuccess:  200,
+						SentMessages: 10,
+					},
+					{
+						DrainID:      "drain-b",
+						State:        plumbing.DrainStatus_CONNECTED,
+						SentMessages: 1,
+					},
+				},
+			})
+			testhelpers.AlwaysReturn(mockDopplerServerA.DrainStatusOutput.Err, nil)
+			testhelpers.AlwaysReturn(mockDopplerServerB.DrainStatusOutput.Resp, &plumbing.DrainStatusResponse{
+				Drains: []*plumbing.DrainStatus{
+					{
+						DrainID:      "drain-a",
+						State:        plumbing.DrainStatus_BACKING_OFF,
+						LastError:    "connection refused",
+						LastSuccess:  100,
+						SentMessages: 5,
+						Errors:       2,
+					},
+				},
+			})
+			testhelpers.AlwaysReturn(mockDopplerServerB.DrainStatusOutput.Err, nil)
+		})
+
+		It("merges the drain status from each doppler", func() {
+			f := func() []*plumbing.DrainStatus {
+				return connector.DrainStatus(context.Background(), "test-app-id")
+			}
+			Eventually(f).Should(ConsistOf(
+				&plumbing.DrainStatus{
+					DrainID:      "drain-a",
+					State:        plumbing.DrainStatus_BACKING_OFF,
+					LastError:    "connection refused",
+					LastSuccess:  200,
+					SentMessages: 15,
+					Errors:       2,
+				},
+				&plumbing.DrainStatus{
+					DrainID:      "drain-b",
+					State:        plumbing.DrainStatus_CONNECTED,
+					SentMessages: 1,
+				},
+			))
+
+			var req *plumbing.DrainStatusRequest
+			Expect(mockDopplerServerA.DrainStatusInput.Req).To(Receive(&req))
+			Expect(req.AppID).To(Equal("test-app-id"))
+		})
+	})
 })
 
 func readFromSubscription(ctx context.Context, req *plumbing.SubscriptionRequest, connector *grpcconnector.GRPCConnector) (<-chan []byte, <-chan error, chan struct{}) {
@@ -8,6 +8,7 @@ package grpcconnector_test
 import (
 	"doppler/dopplerservice"
 	"plumbing"
+	"time"
 
 	"github.com/cloudfoundry/dropsonde/metricbatcher"
 )
@@ -145,3 +146,21 @@ func (m *mockBatchCounterChainer) Add(value uint64) {
 	m.AddCalled <- true
 	m.AddInput.Value <- value
 }
+
+type mockLatencyRecorder struct {
+	RecordCalled chan bool
+	RecordInput  struct {
+		Arg0 chan time.Duration
+	}
+}
+
+func newMockLatencyRecorder() *mockLatencyRecorder {
+	m := &mockLatencyRecorder{}
+	m.RecordCalled = make(chan bool, 100)
+	m.RecordInput.Arg0 = make(chan time.Duration, 100)
+	return m
+}
+func (m *mockLatencyRecorder) Record(arg0 time.Duration) {
+	m.RecordCalled <- true
+	m.RecordInput.Arg0 <- arg0
+}
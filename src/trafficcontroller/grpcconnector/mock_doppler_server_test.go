@@ -35,6 +35,24 @@ type mockDopplerServer struct {
 		Resp chan *plumbing.RecentLogsResponse
 		Err  chan error
 	}
+	ContainerMetricsHistoryCalled chan bool
+	ContainerMetricsHistoryInput  struct {
+		Ctx chan context.Context
+		Req chan *plumbing.ContainerMetricsHistoryRequest
+	}
+	ContainerMetricsHistoryOutput struct {
+		Resp chan *plumbing.ContainerMetricsHistoryResponse
+		Err  chan error
+	}
+	DrainStatusCalled chan bool
+	DrainStatusInput  struct {
+		Ctx chan context.Context
+		Req chan *plumbing.DrainStatusRequest
+	}
+	DrainStatusOutput struct {
+		Resp chan *plumbing.DrainStatusResponse
+		Err  chan error
+	}
 }
 
 func newMockDopplerServer() *mockDopplerServer {
@@ -53,6 +71,16 @@ func newMockDopplerServer() *mockDopplerServer {
 	m.RecentLogsInput.Req = make(chan *plumbing.RecentLogsRequest, 100)
 	m.RecentLogsOutput.Resp = make(chan *plumbing.RecentLogsResponse, 100)
 	m.RecentLogsOutput.Err = make(chan error, 100)
+	m.ContainerMetricsHistoryCalled = make(chan bool, 100)
+	m.ContainerMetricsHistoryInput.Ctx = make(chan context.Context, 100)
+	m.ContainerMetricsHistoryInput.Req = make(chan *plumbing.ContainerMetricsHistoryRequest, 100)
+	m.ContainerMetricsHistoryOutput.Resp = make(chan *plumbing.ContainerMetricsHistoryResponse, 100)
+	m.ContainerMetricsHistoryOutput.Err = make(chan error, 100)
+	m.DrainStatusCalled = make(chan bool, 100)
+	m.DrainStatusInput.Ctx = make(chan context.Context, 100)
+	m.DrainStatusInput.Req = make(chan *plumbing.DrainStatusRequest, 100)
+	m.DrainStatusOutput.Resp = make(chan *plumbing.DrainStatusResponse, 100)
+	m.DrainStatusOutput.Err = make(chan error, 100)
 	return m
 }
 func (m *mockDopplerServer) Subscribe(req *plumbing.SubscriptionRequest, stream plumbing.Doppler_SubscribeServer) (err error) {
@@ -73,6 +101,18 @@ func (m *mockDopplerServer) RecentLogs(ctx context.Context, req *plumbing.Recent
 	m.RecentLogsInput.Req <- req
 	return <-m.RecentLogsOutput.Resp, <-m.RecentLogsOutput.Err
 }
+func (m *mockDopplerServer) ContainerMetricsHistory(ctx context.Context, req *plumbing.ContainerMetricsHistoryRequest) (resp *plumbing.ContainerMetricsHistoryResponse, err error) {
+	m.ContainerMetricsHistoryCalled <- true
+	m.ContainerMetricsHistoryInput.Ctx <- ctx
+	m.ContainerMetricsHistoryInput.Req <- req
+	return <-m.ContainerMetricsHistoryOutput.Resp, <-m.ContainerMetricsHistoryOutput.Err
+}
+func (m *mockDopplerServer) DrainStatus(ctx context.Context, req *plumbing.DrainStatusRequest) (resp *plumbing.DrainStatusResponse, err error) {
+	m.DrainStatusCalled <- true
+	m.DrainStatusInput.Ctx <- ctx
+	m.DrainStatusInput.Req <- req
+	return <-m.DrainStatusOutput.Resp, <-m.DrainStatusOutput.Err
+}
 
 type mockDoppler_SubscribeServer struct {
 	SendCalled chan bool
@@ -93,6 +93,20 @@ func (p *Pool) RecentLogs(dopplerAddr string, ctx context.Context, req *plumbing
 	return client.RecentLogs(ctx, req)
 }
 
+func (p *Pool) DrainStatus(dopplerAddr string, ctx context.Context, req *plumbing.DrainStatusRequest) (*plumbing.DrainStatusResponse, error) {
+	p.mu.RLock()
+	clients := p.dopplers[dopplerAddr]
+	p.mu.RUnlock()
+
+	client := p.fetchClient(clients)
+
+	if client == nil {
+		return nil, fmt.Errorf("no connections available for drain status")
+	}
+
+	return client.DrainStatus(ctx, req)
+}
+
 func (p *Pool) Close(dopplerAddr string) {
 	p.mu.Lock()
 	clients := p.dopplers[dopplerAddr]
@@ -83,6 +83,10 @@ func main() {
 	go openFileMonitor.Start()
 	defer openFileMonitor.Stop()
 
+	websocketLatency := monitor.NewLatency("transit.dopplerToWebsocket", monitorInterval)
+	go websocketLatency.Start()
+	defer websocketLatency.Stop()
+
 	etcdAdapter := defaultStoreAdapterProvider(conf)
 	err = etcdAdapter.Connect()
 	if err != nil {
@@ -123,7 +127,7 @@ func main() {
 		panic(fmt.Errorf("Unable to create gRPC TLS config: %s", err))
 	}
 	pool := grpcconnector.NewPool(20, tlsConf)
-	grpcConnector := grpcconnector.New(1000, pool, finder, batcher)
+	grpcConnector := grpcconnector.New(1000, pool, finder, batcher, websocketLatency)
 
 	dopplerHandler := http.Handler(dopplerproxy.NewDopplerProxy(logAuthorizer, adminAuthorizer, grpcConnector, "doppler."+conf.SystemDomain, 15*time.Second))
 	if accessMiddleware != nil {
@@ -57,6 +57,31 @@ func (l *LogAllowedContext) EventAllowed(event events.Envelope_EventType) bool {
 	return event == events.Envelope_LogMessage
 }
 
+// EventTypesAllowedContext only allows envelopes of the given event types.
+type EventTypesAllowedContext struct {
+	DefaultContext
+	allowed map[events.Envelope_EventType]bool
+}
+
+func NewEventTypesAllowedContext(origin string, destination string, eventTypes ...events.Envelope_EventType) *EventTypesAllowedContext {
+	allowed := make(map[events.Envelope_EventType]bool, len(eventTypes))
+	for _, eventType := range eventTypes {
+		allowed[eventType] = true
+	}
+
+	return &EventTypesAllowedContext{
+		DefaultContext: DefaultContext{
+			destination: destination,
+			origin:      origin,
+		},
+		allowed: allowed,
+	}
+}
+
+func (e *EventTypesAllowedContext) EventAllowed(event events.Envelope_EventType) bool {
+	return e.allowed[event]
+}
+
 type SystemContext struct {
 	DefaultContext
 }
@@ -59,6 +59,33 @@ var _ = Describe("BufferContext", func() {
 		})
 	})
 
+	Context("EventTypesAllowedContext", func() {
+		var eventTypesAllowedContext *EventTypesAllowedContext
+
+		BeforeEach(func() {
+			eventTypesAllowedContext = NewEventTypesAllowedContext(
+				"origin",
+				"testIdentifier",
+				events.Envelope_ContainerMetric,
+				events.Envelope_HttpStartStop,
+			)
+		})
+
+		It("Should return a valid properties", func() {
+			Expect(eventTypesAllowedContext.Origin()).To(Equal("origin"))
+			Expect(eventTypesAllowedContext.Destination()).To(Equal("testIdentifier"))
+			for _, e := range events.Envelope_EventType_value {
+				event := events.Envelope_EventType(e)
+				allowed := eventTypesAllowedContext.EventAllowed(event)
+				if event == events.Envelope_ContainerMetric || event == events.Envelope_HttpStartStop {
+					Expect(allowed).To(BeTrue())
+				} else {
+					Expect(allowed).To(BeFalse())
+				}
+			}
+		})
+	})
+
 	Context("SystemContext", func() {
 		var systemContext *SystemContext
 
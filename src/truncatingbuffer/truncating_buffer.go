@@ -3,6 +3,7 @@ package truncatingbuffer
 import (
 	"fmt"
 	"log"
+	"marshalled"
 	"sync"
 	"time"
 
@@ -15,9 +16,9 @@ import (
 var lgrSource = proto.String("LGR")
 
 type TruncatingBuffer struct {
-	inputChannel               <-chan *events.Envelope
+	inputChannel               <-chan *marshalled.Envelope
 	context                    BufferContext
-	outputChannel              chan *events.Envelope
+	outputChannel              chan *marshalled.Envelope
 	lock                       *sync.RWMutex
 	bufferSize                 uint64
 	sentMessageCount           uint64
@@ -26,7 +27,7 @@ type TruncatingBuffer struct {
 	stopChannel                chan struct{}
 }
 
-func NewTruncatingBuffer(inputChannel <-chan *events.Envelope, bufferSize uint, context BufferContext, stopChannel chan struct{}) *TruncatingBuffer {
+func NewTruncatingBuffer(inputChannel <-chan *marshalled.Envelope, bufferSize uint, context BufferContext, stopChannel chan struct{}) *TruncatingBuffer {
 	if bufferSize < 3 {
 		panic("bufferSize must be larger than 3 for overflow")
 	}
@@ -35,7 +36,7 @@ func NewTruncatingBuffer(inputChannel <-chan *events.Envelope, bufferSize uint,
 	}
 	return &TruncatingBuffer{
 		inputChannel:               inputChannel,
-		outputChannel:              make(chan *events.Envelope, bufferSize),
+		outputChannel:              make(chan *marshalled.Envelope, bufferSize),
 		lock:                       &sync.RWMutex{},
 		bufferSize:                 uint64(bufferSize),
 		sentMessageCount:           0,
@@ -46,10 +47,18 @@ func NewTruncatingBuffer(inputChannel <-chan *events.Envelope, bufferSize uint,
 	}
 }
 
-func (r *TruncatingBuffer) GetOutputChannel() <-chan *events.Envelope {
+func (r *TruncatingBuffer) GetOutputChannel() <-chan *marshalled.Envelope {
 	return r.outputChannel
 }
 
+// GetDroppedMessageCount returns the total number of messages dropped
+// because the buffer was full.
+func (r *TruncatingBuffer) GetDroppedMessageCount() uint64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.droppedMessageCount
+}
+
 func (r *TruncatingBuffer) closeOutputChannel() {
 	close(r.outputChannel)
 }
@@ -75,7 +84,7 @@ func (r *TruncatingBuffer) Run() {
 	}
 }
 
-func (r *TruncatingBuffer) forwardMessage(msg *events.Envelope) {
+func (r *TruncatingBuffer) forwardMessage(msg *marshalled.Envelope) {
 	select {
 	case r.outputChannel <- msg:
 		r.sentMessageCount++
@@ -90,7 +99,7 @@ func (r *TruncatingBuffer) forwardMessage(msg *events.Envelope) {
 
 		r.lock.Lock()
 		r.droppedMessageCount += deltaDropped
-		appId := r.context.AppID(msg)
+		appId := r.context.AppID(msg.Envelope)
 		r.notifyMessagesDropped(r.outputChannel, deltaDropped, r.droppedMessageCount, appId)
 		totalDropped := r.droppedMessageCount
 		r.lock.Unlock()
@@ -122,7 +131,7 @@ func (r *TruncatingBuffer) dropMessages() uint64 {
 	}
 }
 
-func (r *TruncatingBuffer) notifyMessagesDropped(outputChannel chan *events.Envelope, deltaDropped, totalDropped uint64, appId string) {
+func (r *TruncatingBuffer) notifyMessagesDropped(outputChannel chan *marshalled.Envelope, deltaDropped, totalDropped uint64, appId string) {
 	metrics.BatchAddCounter("TruncatingBuffer.totalDroppedMessages", deltaDropped)
 	if r.eventAllowed(events.Envelope_LogMessage) {
 		r.emitMessage(outputChannel, generateLogMessage(deltaDropped, totalDropped, appId, r.context.Origin(), r.context.Destination()))
@@ -132,14 +141,14 @@ func (r *TruncatingBuffer) notifyMessagesDropped(outputChannel chan *events.Enve
 	}
 }
 
-func (r *TruncatingBuffer) emitMessage(outputChannel chan *events.Envelope, event events.Event) {
+func (r *TruncatingBuffer) emitMessage(outputChannel chan *marshalled.Envelope, event events.Event) {
 	env, err := emitter.Wrap(event, r.context.Origin())
 	if err != nil {
 		log.Printf("Error marshalling message: %v", err)
 		return
 	}
 
-	outputChannel <- env
+	outputChannel <- marshalled.New(env)
 	r.queuedInternalMessageCount++
 }
 
@@ -2,6 +2,7 @@ package truncatingbuffer_test
 
 import (
 	"fmt"
+	"marshalled"
 	"truncatingbuffer"
 
 	. "github.com/apoydence/eachers"
@@ -72,7 +73,7 @@ func (f *LogFilteredContext) EventAllowed(eventType events.Envelope_EventType) b
 }
 
 var _ = Describe("Truncating Buffer", func() {
-	var inMessageChan chan *events.Envelope
+	var inMessageChan chan *marshalled.Envelope
 	var stopChannel chan struct{}
 	var bufferSize uint
 	var buffer *truncatingbuffer.TruncatingBuffer
@@ -80,7 +81,7 @@ var _ = Describe("Truncating Buffer", func() {
 
 	BeforeEach(func() {
 		metrics.Initialize(nil, nil)
-		inMessageChan = make(chan *events.Envelope)
+		inMessageChan = make(chan *marshalled.Envelope)
 		stopChannel = make(chan struct{})
 		context = &FakeContext{}
 		bufferSize = 3
@@ -142,7 +143,7 @@ var _ = Describe("Truncating Buffer", func() {
 
 			sendLogMessages("message 1", inMessageChan)
 
-			var readMessage *events.Envelope
+			var readMessage *marshalled.Envelope
 			Eventually(buffer.GetOutputChannel).Should(Receive(&readMessage))
 
 			Expect(readMessage.GetLogMessage().GetMessage()).To(ContainSubstring("message 1"))
@@ -162,7 +163,7 @@ var _ = Describe("Truncating Buffer", func() {
 
 			tracksDroppedMessagesAnd := func(itMsg string, delta, total int) {
 				It(itMsg, func() {
-					var logMessageNotification *events.Envelope
+					var logMessageNotification *marshalled.Envelope
 					Eventually(buffer.GetOutputChannel).Should(Receive(&logMessageNotification))
 					Expect(logMessageNotification.GetEventType()).To(Equal(events.Envelope_LogMessage))
 					Expect(logMessageNotification.GetLogMessage().GetAppId()).To(Equal("fake-app-id"))
@@ -171,7 +172,7 @@ var _ = Describe("Truncating Buffer", func() {
 							"%d messages dropped (Total %d messages dropped) from doppler to test-sink-name.", delta, total)),
 					)
 
-					var counterEventNotification *events.Envelope
+					var counterEventNotification *marshalled.Envelope
 					Eventually(buffer.GetOutputChannel).Should(Receive(&counterEventNotification))
 					Expect(counterEventNotification.GetEventType()).To(Equal(events.Envelope_CounterEvent))
 					counterEvent := counterEventNotification.GetCounterEvent()
@@ -205,6 +206,10 @@ var _ = Describe("Truncating Buffer", func() {
 
 			Context("when the buffer fills once", func() {
 				tracksDroppedMessagesAnd("drops all the messages", 3, 3)
+
+				It("counts the dropped messages", func() {
+					Expect(buffer.GetDroppedMessageCount()).To(BeEquivalentTo(3))
+				})
 			})
 
 			Context("when the buffer fills multiple times ", func() {
@@ -327,7 +332,7 @@ var _ = Describe("Truncating Buffer", func() {
 	})
 })
 
-func sendLogMessages(message string, inMessageChan chan<- *events.Envelope) {
+func sendLogMessages(message string, inMessageChan chan<- *marshalled.Envelope) {
 	logMessage1, _ := emitter.Wrap(factories.NewLogMessage(events.LogMessage_OUT, message, "appId", "App"), "origin")
-	inMessageChan <- logMessage1
+	inMessageChan <- marshalled.New(logMessage1)
 }